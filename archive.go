@@ -0,0 +1,74 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/asbjornb/kvist/git"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// archiveItems offers to export rev of the repository at repoPath as an
+// archive, or the whole repository as a bundle. Files go next to the
+// repository unless another path is typed.
+func archiveItems(repoPath, rev, label string) []menuItem {
+	name := filepath.Base(repoPath)
+	archive := func(ext string) func(*model) tea.Cmd {
+		return func(m *model) tea.Cmd {
+			initial := filepath.Join(filepath.Dir(repoPath), name+"-"+label+ext)
+			m.openPrompt("📦 Write "+label+" to", initial, func(_ *model, path string) tea.Cmd {
+				if path = strings.TrimSpace(path); path == "" {
+					return nil
+				}
+				return archiveOperation(repoPath, rev, path)
+			})
+			return nil
+		}
+	}
+	return []menuItem{
+		{key: "z", label: "Archive " + label + " as zip", action: archive(".zip")},
+		{key: "t", label: "Archive " + label + " as tar.gz", action: archive(".tar.gz")},
+		{key: "b", label: "Bundle all branches and tags for offline transfer", action: func(m *model) tea.Cmd {
+			m.openPrompt("📦 Write bundle to", filepath.Join(filepath.Dir(repoPath), name+".bundle"), func(_ *model, path string) tea.Cmd {
+				if path = strings.TrimSpace(path); path == "" {
+					return nil
+				}
+				return bundleOperation(repoPath, path)
+			})
+			return nil
+		}},
+	}
+}
+
+// absPath resolves path, as typed in a prompt, against the repository git
+// runs in
+func absPath(repoPath, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(repoPath, path)
+}
+
+func archiveOperation(repoPath, rev, path string) tea.Cmd {
+	path = absPath(repoPath, path)
+	return requestOperation(operation{
+		desc:     "archive " + shortHash(rev) + " to " + path,
+		repoPath: repoPath,
+		refresh:  refreshStatus,
+		run:      func() error { return git.Archive(repoPath, rev, path) },
+	})
+}
+
+func bundleOperation(repoPath, path string) tea.Cmd {
+	path = absPath(repoPath, path)
+	output := make(chan string, 64)
+	return requestOperation(operation{
+		desc:     "bundle to " + path,
+		repoPath: repoPath,
+		refresh:  refreshStatus,
+		output:   output,
+		run: func() error {
+			return git.CreateBundle(repoPath, path, func(line string) { output <- line })
+		},
+	})
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/asbjornb/kvist/git"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// askpassSocketEnv names the socket kvist listens on for prompts. git and
+// ssh run kvist itself as their askpass program with it set.
+const askpassSocketEnv = "KVIST_ASKPASS_SOCKET"
+
+// askpassRequest is a prompt from git or ssh waiting for the user's answer
+type askpassRequest struct {
+	prompt string
+	reply  chan<- string // closed unanswered when the prompt is cancelled
+}
+
+// askpassMsg hands a prompt to the interface
+type askpassMsg struct {
+	askpassRequest
+}
+
+// askpassServer answers askpass prompts through the interface
+type askpassServer struct {
+	dir      string
+	listener net.Listener
+	requests chan askpassRequest
+}
+
+// startAskpass listens for prompts and returns the environment that makes
+// git and ssh send theirs to it
+func startAskpass() (*askpassServer, []string, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, nil, err
+	}
+	dir, err := os.MkdirTemp("", "kvist-askpass-")
+	if err != nil {
+		return nil, nil, err
+	}
+	socket := filepath.Join(dir, "socket")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+	s := &askpassServer{dir: dir, listener: listener, requests: make(chan askpassRequest)}
+	go s.serve()
+	env := []string{
+		askpassSocketEnv + "=" + socket,
+		"GIT_ASKPASS=" + self,
+		"SSH_ASKPASS=" + self,
+		"SSH_ASKPASS_REQUIRE=force",
+	}
+	return s, env, nil
+}
+
+func (s *askpassServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			prompt, err := bufio.NewReader(conn).ReadString('\n')
+			if err != nil {
+				return
+			}
+			// The command waiting on the answer doesn't time out meanwhile
+			git.PromptWaiting(true)
+			defer git.PromptWaiting(false)
+			reply := make(chan string, 1)
+			s.requests <- askpassRequest{prompt: strings.TrimSpace(prompt), reply: reply}
+			if answer, ok := <-reply; ok {
+				fmt.Fprintln(conn, answer)
+			}
+		}()
+	}
+}
+
+func (s *askpassServer) close() {
+	s.listener.Close()
+	os.RemoveAll(s.dir)
+}
+
+// waitForAskpass waits for the next prompt
+func waitForAskpass(requests <-chan askpassRequest) tea.Cmd {
+	return func() tea.Msg {
+		return askpassMsg{<-requests}
+	}
+}
+
+// handleAskpass queues what git or ssh prompted for and waits for the next
+// prompt. Prompts of operations running side by side are asked one at a
+// time, each once no other modal is open.
+func (m *model) handleAskpass(msg askpassMsg) tea.Cmd {
+	m.askpassPending = append(m.askpassPending, msg.askpassRequest)
+	m.showAskpass()
+	return waitForAskpass(m.askpass)
+}
+
+// showAskpass asks the user for the oldest queued prompt unless a modal is
+// open. Passwords and passphrases aren't shown as typed.
+func (m *model) showAskpass() {
+	if m.showingModal || len(m.askpassPending) == 0 {
+		return
+	}
+	req := m.askpassPending[0]
+	m.askpassPending = m.askpassPending[1:]
+	reply := req.reply
+	lower := strings.ToLower(req.prompt)
+	secret := strings.Contains(lower, "password") || strings.Contains(lower, "passphrase") || strings.Contains(lower, "token")
+	m.openPrompt("🔑 "+req.prompt, "", func(_ *model, value string) tea.Cmd {
+		reply <- value
+		close(reply)
+		return nil
+	})
+	m.prompt.secret = secret
+	m.prompt.cancel = func(*model) { close(reply) }
+}
+
+// runAskpass is kvist run as an askpass program: it sends the prompt in
+// args to the interface listening on socket and prints the answer. It exits
+// with 1 when the prompt is cancelled, which makes git and ssh give up.
+func runAskpass(socket string, args []string) int {
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "kvist: asking for credentials failed:", err)
+		return 1
+	}
+	defer conn.Close()
+	prompt := strings.ReplaceAll(strings.Join(args, " "), "\n", " ")
+	if _, err := fmt.Fprintln(conn, prompt); err != nil {
+		return 1
+	}
+	answer, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return 1
+	}
+	fmt.Print(answer)
+	return 0
+}
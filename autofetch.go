@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/asbjornb/kvist/workspace"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// autoFetchDoneMsg reports a background fetch of the workspace repositories
+type autoFetchDoneMsg struct {
+	fetched []string // paths of the repositories fetched
+	results []workspace.FetchResult
+}
+
+// scheduleAutoFetch starts the background fetch timer when it is configured
+func (m *model) scheduleAutoFetch() tea.Cmd {
+	if m.workspaceConfig == nil || m.workspaceConfig.AutoFetchMinutes <= 0 {
+		return nil
+	}
+	return m.scheduleAfter(autoFetchTimer, time.Duration(m.workspaceConfig.AutoFetchMinutes)*time.Minute)
+}
+
+// startAutoFetch fetches every known repository with an upstream in the
+// background, except archived ones and those backing off after failures
+func (m *model) startAutoFetch() tea.Cmd {
+	if m.scanner == nil {
+		return nil
+	}
+	if m.offline {
+		return m.scheduleAutoFetch()
+	}
+	var repos []workspace.RepoInfo
+	var fetched []string
+	now := time.Now()
+	for _, repo := range m.scanner.Unarchived(m.scanner.GetCachedRepos()) {
+		if repo.HasUpstream && m.fetchBackoff.Due(repo.Path, now) {
+			repos = append(repos, repo)
+			fetched = append(fetched, repo.Path)
+		}
+	}
+	scanner := m.scanner
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+		return autoFetchDoneMsg{fetched: fetched, results: scanner.FetchRepos(ctx, repos)}
+	}
+}
+
+// handleAutoFetch badges the repositories that fell behind, so they stand
+// out in the workspace list until opened, and schedules the next fetch
+func (m *model) handleAutoFetch(msg autoFetchDoneMsg) tea.Cmd {
+	failed := make(map[string]bool)
+	for _, res := range msg.results {
+		if res.Error != nil {
+			failed[res.Repo.Path] = true
+		}
+	}
+	for _, path := range msg.fetched {
+		if !failed[path] {
+			m.fetchBackoff.Succeeded(path)
+		}
+	}
+
+	var behind []string
+	interval := time.Hour
+	if m.workspaceConfig != nil && m.workspaceConfig.AutoFetchMinutes > 0 {
+		interval = time.Duration(m.workspaceConfig.AutoFetchMinutes) * time.Minute
+	}
+	for _, res := range msg.results {
+		if res.Error != nil {
+			wait := m.fetchBackoff.Failed(res.Repo.Path, interval, time.Now())
+			m.log.add(logWarn, "auto-fetch of %s failed %d time(s), next try in %s: %v",
+				res.Repo.Name, m.fetchBackoff.Failures(res.Repo.Path), wait.Round(time.Minute), res.Error)
+			continue
+		}
+		if m.fetchNotices == nil {
+			m.fetchNotices = make(map[string]int)
+		}
+		m.fetchNotices[res.Repo.Path] += res.NewBehind
+		behind = append(behind, res.Repo.Name)
+	}
+	if len(behind) > 0 {
+		m.log.add(logInfo, "auto-fetch: new commits in %s", strings.Join(behind, ", "))
+	}
+
+	if m.scanner != nil {
+		m.repos = m.scanner.GetCachedRepos()
+		m.updateFilteredRepos()
+		if err := m.scanner.SaveCache(); err != nil {
+			m.log.add(logError, "saving repository cache failed: %v", err)
+		}
+	}
+	return m.scheduleAutoFetch()
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/asbjornb/kvist/git"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxRecentBranches is how many branches the recent branches switcher lists
+const maxRecentBranches = 10
+
+// recentBranchesMsg lists the branches last checked out in a repository
+type recentBranchesMsg struct {
+	repoPath string
+	branches []string
+	err      error
+}
+
+func loadRecentBranches(repoPath string) tea.Cmd {
+	return func() tea.Msg {
+		// One more for the branch checked out now, which isn't listed
+		branches, err := git.RecentBranches(repoPath, maxRecentBranches+1)
+		return recentBranchesMsg{repoPath: repoPath, branches: branches, err: err}
+	}
+}
+
+// handleRecentBranches lists the branches checked out before the current
+// one, the last first so Enter switches back to it
+func (m *model) handleRecentBranches(msg recentBranchesMsg) {
+	if msg.err != nil {
+		m.log.add(logError, "reading the reflog failed: %v", msg.err)
+		return
+	}
+	if m.repo == nil || m.repo.Path != msg.repoPath {
+		return
+	}
+	var items []menuItem
+	for _, name := range msg.branches {
+		if name == m.repo.CurrentBranch || len(items) == maxRecentBranches {
+			continue
+		}
+		items = append(items, menuItem{key: menuKey(len(items)), label: name, action: func(m *model) tea.Cmd {
+			return checkoutBranchOperation(msg.repoPath, name)
+		}})
+	}
+	if len(items) == 0 {
+		m.log.add(logInfo, "no other branches checked out lately")
+		return
+	}
+	m.openMenu("🌿 Recent branches", items)
+}
+
+// upstreamCandidatesMsg lists the remote branches the current branch can
+// track
+type upstreamCandidatesMsg struct {
+	repoPath string
+	branch   string
+	remotes  []string
+	err      error
+}
+
+func loadUpstreamCandidates(repoPath, branch string) tea.Cmd {
+	return func() tea.Msg {
+		remotes, err := git.RemoteBranches(repoPath)
+		return upstreamCandidatesMsg{repoPath: repoPath, branch: branch, remotes: remotes, err: err}
+	}
+}
+
+// handleUpstreamCandidates lets the user pick the remote branch to track,
+// those named like the branch first
+func (m *model) handleUpstreamCandidates(msg upstreamCandidatesMsg) {
+	if msg.err != nil {
+		m.log.add(logError, "listing remote branches failed: %v", msg.err)
+		return
+	}
+	if m.repo == nil || m.repo.Path != msg.repoPath {
+		return
+	}
+	if len(msg.remotes) == 0 {
+		m.log.add(logInfo, "no remote branches; fetch or add a remote first")
+		return
+	}
+	current := ""
+	for _, branch := range m.branches {
+		if branch.Name == msg.branch {
+			current = branch.Upstream
+		}
+	}
+	remotes := slices.Clone(msg.remotes)
+	sort.SliceStable(remotes, func(i, j int) bool {
+		return m.remoteBranchName(remotes[i]) == msg.branch && m.remoteBranchName(remotes[j]) != msg.branch
+	})
+	items := make([]menuItem, 0, len(remotes))
+	for i, remote := range remotes {
+		label := remote
+		if remote == current {
+			label += " (current)"
+		}
+		items = append(items, menuItem{key: menuKey(i), label: label, action: func(m *model) tea.Cmd {
+			return setUpstreamOperation(msg.repoPath, msg.branch, remote)
+		}})
+	}
+	m.openMenu("🔗 Track with "+msg.branch, items)
+}
+
+// remoteBranchName is the name of the remote branch ref, e.g. "feature/x"
+// for "origin/feature/x", going by the longest remote name it starts with
+func (m model) remoteBranchName(ref string) string {
+	name := ref
+	for _, remote := range m.remotes {
+		if rest, ok := strings.CutPrefix(ref, remote.Name+"/"); ok && len(rest) < len(name) {
+			name = rest
+		}
+	}
+	return name
+}
+
+// setUpstreamOperation makes branch track upstream, or nothing when
+// upstream is empty
+func setUpstreamOperation(repoPath, branch, upstream string) tea.Cmd {
+	desc := "track " + upstream + " with " + branch
+	run := func() error { return git.SetUpstream(repoPath, branch, upstream) }
+	if upstream == "" {
+		desc = "stop tracking with " + branch
+		run = func() error { return git.UnsetUpstream(repoPath, branch) }
+	}
+	return requestOperation(operation{
+		desc:     desc,
+		repoPath: repoPath,
+		refresh:  refreshRepo,
+		run:      run,
+	})
+}
+
+// branchCleanupMsg lists the branches that can probably be deleted
+type branchCleanupMsg struct {
+	repoPath string
+	base     string
+	merged   []string
+	gone     []string // upstream deleted, but not merged into base
+	err      error
+}
+
+// loadBranchCleanup finds branches merged into the default branch and, when
+// prune is set, prunes origin first to find branches whose upstream is gone
+func loadBranchCleanup(repoPath string, prune bool) tea.Cmd {
+	return func() tea.Msg {
+		if prune {
+			if err := git.ExecuteGitOp(repoPath, git.OpFetchPrune); err != nil {
+				return branchCleanupMsg{repoPath: repoPath, err: fmt.Errorf("fetch --prune: %w", err)}
+			}
+		}
+		base, merged, err := git.MergedBranches(repoPath)
+		if err != nil {
+			return branchCleanupMsg{repoPath: repoPath, err: err}
+		}
+		msg := branchCleanupMsg{repoPath: repoPath, base: base, merged: merged}
+		if prune {
+			branches, err := git.GetBranches(repoPath)
+			if err != nil {
+				return branchCleanupMsg{repoPath: repoPath, err: err}
+			}
+			isMerged := make(map[string]bool, len(merged))
+			for _, name := range merged {
+				isMerged[name] = true
+			}
+			for _, branch := range branches {
+				if branch.Gone && !branch.IsCurrent && !isMerged[branch.Name] {
+					msg.gone = append(msg.gone, branch.Name)
+				}
+			}
+		}
+		return msg
+	}
+}
+
+// handleBranchCleanup lets the user pick which of the candidates to delete.
+// Merged branches start checked; gone ones may hold unmerged work and don't.
+func (m *model) handleBranchCleanup(msg branchCleanupMsg) {
+	if msg.err != nil {
+		m.log.add(logError, "branch cleanup failed: %v", msg.err)
+		return
+	}
+	if m.repo == nil || m.repo.Path != msg.repoPath {
+		return
+	}
+	if len(msg.merged)+len(msg.gone) == 0 {
+		m.log.add(logInfo, "no merged branches to clean up")
+		return
+	}
+
+	var items []checkItem
+	for _, name := range msg.merged {
+		items = append(items, checkItem{value: name, label: name + " (merged into " + msg.base + ")", checked: true})
+	}
+	for _, name := range msg.gone {
+		items = append(items, checkItem{value: name, label: name + " (upstream gone, unmerged)"})
+	}
+
+	repoPath := msg.repoPath
+	m.openChecklist("🧹 Delete branches", items, func(m *model, chosen []string) tea.Cmd {
+		if len(chosen) == 0 {
+			return nil
+		}
+		return deleteBranchesOperation(repoPath, chosen)
+	})
+}
+
+// deleteBranchesOperation deletes the chosen branches, merged or not; the
+// undo snapshot brings them all back
+func deleteBranchesOperation(repoPath string, names []string) tea.Cmd {
+	desc := "delete branch " + names[0]
+	if len(names) > 1 {
+		desc = fmt.Sprintf("delete %d branches (%s)", len(names), strings.Join(names, ", "))
+	}
+	return requestOperation(operation{
+		desc:     desc,
+		repoPath: repoPath,
+		snapshot: true,
+		refresh:  refreshRepo,
+		run:      func() error { return git.DeleteBranches(repoPath, names) },
+	})
+}
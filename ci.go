@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/asbjornb/kvist/forge"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// How long CI results are trusted before asking the host again. Finished
+// results are kept for the session.
+const (
+	ciPendingTTL = 20 * time.Second
+	ciUnknownTTL = 5 * time.Minute
+)
+
+// ciResult is a cached CI lookup for one commit
+type ciResult struct {
+	state   forge.State
+	fetched time.Time
+	loading bool
+}
+
+type ciChecksMsg struct {
+	hash  string
+	state forge.State
+	err   error
+}
+
+func fetchChecks(repoPath string, kinds map[string]string, hash string) tea.Cmd {
+	return func() tea.Msg {
+		provider, err := forge.ForRepo(repoPath, kinds)
+		if err != nil {
+			// No hosted remote, nothing to ask
+			return ciChecksMsg{hash: hash, err: forge.ErrUnsupported}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		state, err := provider.GetChecks(ctx, hash)
+		return ciChecksMsg{hash: hash, state: state, err: err}
+	}
+}
+
+// requestChecks fetches the CI state of a commit unless a fresh enough
+// result is cached or a lookup is already running
+func (m *model) requestChecks(hash string) tea.Cmd {
+	if m.repo == nil || hash == "" || m.safeMode || m.offline {
+		return nil
+	}
+	if m.ciChecks == nil {
+		m.ciChecks = make(map[string]ciResult)
+	}
+	if cached, ok := m.ciChecks[hash]; ok {
+		ttl := ciUnknownTTL
+		if cached.state == forge.StatePending {
+			ttl = ciPendingTTL
+		}
+		if cached.loading || cached.state.Final() || time.Since(cached.fetched) < ttl {
+			return nil
+		}
+	}
+	m.ciChecks[hash] = ciResult{state: m.ciChecks[hash].state, loading: true}
+	return fetchChecks(m.repo.Path, m.forgeKinds(), hash)
+}
+
+// requestVisibleChecks fetches CI state for HEAD and the selected commit
+func (m *model) requestVisibleChecks() tea.Cmd {
+	var cmds []tea.Cmd
+	if len(m.commits) > 0 {
+		cmds = append(cmds, m.requestChecks(m.commits[0].Hash))
+	}
+	if m.currentMode == historyMode && m.selectedCommit < len(m.commits) {
+		cmds = append(cmds, m.requestChecks(m.commits[m.selectedCommit].Hash))
+	}
+	return tea.Batch(cmds...)
+}
+
+func (m *model) handleChecks(msg ciChecksMsg) tea.Cmd {
+	m.ciChecks[msg.hash] = ciResult{state: msg.state, fetched: time.Now()}
+	if msg.err != nil && !errors.Is(msg.err, forge.ErrUnsupported) {
+		m.log.add(logWarn, "CI status for %s: %v", shortHash(msg.hash), msg.err)
+	}
+	if msg.state == forge.StatePending {
+		// Poll until the checks finish
+		return m.schedule(ciTimer)
+	}
+	return nil
+}
+
+// ciBadge renders the cached CI state of a commit, or "" if there is none
+func (m model) ciBadge(hash string) string {
+	switch m.ciChecks[hash].state {
+	case forge.StateSuccess:
+		return lipgloss.NewStyle().Foreground(lipgloss.Color("42")).Render("✓")
+	case forge.StateFailure:
+		return lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Render("✗")
+	case forge.StatePending:
+		return lipgloss.NewStyle().Foreground(lipgloss.Color("214")).Render("●")
+	}
+	return ""
+}
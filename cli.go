@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/asbjornb/kvist/git"
+	"github.com/asbjornb/kvist/workspace"
+)
+
+const usage = `usage: kvist                            start the interface
+       kvist <dir>                      open the repository containing dir, e.g. kvist .
+       kvist --print-path [dir]         as above; Q quits and prints the selected repo's path
+       kvist list [--json]              list the known repositories
+       kvist scan                       rescan all workspaces and update the cache
+       kvist status-all [--json]        show changes, ahead and behind counts per repository
+       kvist manifest export [file]     write workspaces and repo remotes as YAML
+       kvist manifest import <file>     add missing workspaces and clone missing repos
+       kvist --version                  print the version
+
+--debug (or KVIST_DEBUG=1) logs git commands and interface events to debug.log
+in kvist's cache directory.`
+
+// repoArgument returns the directory to open when the only argument names
+// one rather than a command
+func repoArgument(args []string) (string, bool) {
+	if len(args) != 1 || strings.HasPrefix(args[0], "-") {
+		return "", false
+	}
+	switch args[0] {
+	case "list", "scan", "status-all", "manifest", "help", "version":
+		return "", false
+	}
+	dir, err := filepath.Abs(workspace.ExpandPath(args[0]))
+	if err != nil {
+		return "", false
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return "", false
+	}
+	return dir, true
+}
+
+// runCommand runs a command given on the command line instead of the interface
+func runCommand(args []string) error {
+	switch args[0] {
+	case "list":
+		if rest, asJSON := jsonFlag(args[1:]); len(rest) == 0 {
+			return listRepos(asJSON)
+		}
+	case "scan":
+		if len(args) == 1 {
+			return scanAll()
+		}
+	case "status-all":
+		if rest, asJSON := jsonFlag(args[1:]); len(rest) == 0 {
+			return statusAll(asJSON)
+		}
+	case "manifest":
+		if len(args) >= 2 && args[1] == "export" && len(args) <= 3 {
+			return exportManifest(args[2:])
+		}
+		if len(args) == 3 && args[1] == "import" {
+			return importManifest(args[2])
+		}
+	case "help", "-h", "--help":
+		fmt.Println(usage)
+		return nil
+	case "version", "--version":
+		fmt.Println(versionString())
+		return nil
+	}
+	return fmt.Errorf("unknown command %q\n%s", strings.Join(args, " "), usage)
+}
+
+// jsonFlag takes --json out of args
+func jsonFlag(args []string) (rest []string, asJSON bool) {
+	for _, arg := range args {
+		if arg == "--json" {
+			asJSON = true
+		} else {
+			rest = append(rest, arg)
+		}
+	}
+	return rest, asJSON
+}
+
+// writeJSON prints value as indented JSON on stdout
+func writeJSON(value any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// cachedRepos returns the repositories in the cache, sorted by path
+func cachedRepos(scanner *workspace.Scanner) []workspace.RepoInfo {
+	repos := scanner.GetCachedRepos()
+	sort.Slice(repos, func(i, j int) bool { return repos[i].Path < repos[j].Path })
+	return repos
+}
+
+// listRepos prints the repositories kvist knows about
+func listRepos(asJSON bool) error {
+	scanner, _, err := loadScanner()
+	if err != nil {
+		return err
+	}
+	repos := cachedRepos(scanner)
+	if asJSON {
+		return writeJSON(repos)
+	}
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "WORKSPACE\tNAME\tPATH")
+	for _, repo := range repos {
+		fmt.Fprintf(table, "%s\t%s\t%s\n", repo.WorkspaceName, repo.Name, repo.Path)
+	}
+	return table.Flush()
+}
+
+// scanAll rescans every workspace and saves the results to the cache
+func scanAll() error {
+	scanner, _, err := loadScanner()
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	result, ok := <-scanner.ScanWorkspaces(ctx)
+	if !ok {
+		return ctx.Err()
+	}
+	fmt.Printf("scanned %d repositories\n", len(result.Repos))
+	return result.Error
+}
+
+// repoStatus is a repository's line in status-all
+type repoStatus struct {
+	Workspace string `json:"workspace"`
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	Branch    string `json:"branch"`
+	Changed   int    `json:"changed"`
+	Untracked int    `json:"untracked"`
+	Ahead     int    `json:"ahead"`
+	Behind    int    `json:"behind"`
+	Unpushed  int    `json:"unpushed"`
+	Stashes   int    `json:"stashes"`
+}
+
+// statusAll prints the cached working state of every repository; kvist
+// scan brings it up to date first
+func statusAll(asJSON bool) error {
+	scanner, _, err := loadScanner()
+	if err != nil {
+		return err
+	}
+	var statuses []repoStatus
+	for _, repo := range cachedRepos(scanner) {
+		if repo.Kind == workspace.ProjectDir {
+			continue
+		}
+		statuses = append(statuses, repoStatus{
+			Workspace: repo.WorkspaceName, Name: repo.Name, Path: repo.Path, Branch: repo.Branch,
+			Changed: repo.Changed, Untracked: repo.Untracked, Ahead: repo.Ahead, Behind: repo.Behind,
+			Unpushed: repo.Unpushed, Stashes: repo.Stashes,
+		})
+	}
+	if asJSON {
+		return writeJSON(statuses)
+	}
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "WORKSPACE\tREPO\tBRANCH\tCHANGED\tUNTRACKED\tAHEAD\tBEHIND\tUNPUSHED\tSTASHES")
+	for _, s := range statuses {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\t%d\n",
+			s.Workspace, s.Name, s.Branch, s.Changed, s.Untracked, s.Ahead, s.Behind, s.Unpushed, s.Stashes)
+	}
+	return table.Flush()
+}
+
+// exportManifest writes the manifest to the file in args, or to stdout
+func exportManifest(args []string) error {
+	scanner, config, err := loadScanner()
+	if err != nil {
+		return err
+	}
+
+	manifest, skipped := workspace.ExportManifest(config, scanner.GetCachedRepos())
+	for _, path := range skipped {
+		fmt.Fprintf(os.Stderr, "skipped %s: no remote to clone from\n", path)
+	}
+	data, err := manifest.Marshal()
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 || args[0] == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(args[0], data, 0644)
+}
+
+// importManifest recreates the setup described by the manifest in file,
+// or on stdin for "-"
+func importManifest(file string) error {
+	var data []byte
+	var err error
+	if file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return err
+	}
+	manifest, err := workspace.ParseManifest(data)
+	if err != nil {
+		return err
+	}
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	cloned, err := workspace.ImportManifest(config, manifest, func(line string) { fmt.Println(line) })
+	fmt.Printf("cloned %d repositories\n", cloned)
+	return err
+}
+
+// loadConfig loads the configuration, printing problems that don't stop
+// it from being used
+func loadConfig() (*workspace.Config, error) {
+	config, err := workspace.LoadConfig()
+	var configErr *workspace.ConfigError
+	if errors.As(err, &configErr) && !configErr.Fatal() {
+		for _, problem := range configErr.Problems {
+			fmt.Fprintf(os.Stderr, "warning: %s: %s\n", configErr.Path, problem)
+		}
+		err = nil
+	}
+	if config != nil {
+		git.SetRenames(config.Renames.GitRenames())
+		git.SetTextConv(config.Diff.TextConv)
+		git.SetEnvironment(config.CommandEnv)
+	}
+	return config, err
+}
+
+// loadScanner loads the configuration and the cache
+func loadScanner() (*workspace.Scanner, *workspace.Config, error) {
+	config, err := loadConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	cache, err := workspace.LoadRepoCache()
+	if errors.Is(err, workspace.ErrCacheCorrupt) {
+		fmt.Fprintln(os.Stderr, "warning:", err)
+	} else if err != nil {
+		return nil, nil, err
+	}
+	return workspace.NewScanner(config, cache), config, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asbjornb/kvist/git"
+	"github.com/asbjornb/kvist/workspace"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// startClone asks for a URL, then where to put the clone
+func (m *model) startClone() {
+	if !m.networkAllowed("clone") {
+		return
+	}
+	if m.workspaceConfig == nil || len(m.workspaceConfig.Workspaces) == 0 {
+		m.log.add(logWarn, "add a workspace with w before cloning into it")
+		return
+	}
+	m.openPrompt("📥 Clone repository: URL", "", func(m *model, value string) tea.Cmd {
+		url := strings.TrimSpace(value)
+		if url == "" {
+			return nil
+		}
+		m.pickCloneWorkspace(url)
+		return nil
+	})
+}
+
+// pickCloneWorkspace offers the workspaces to clone into, and skips the
+// question when there is only one
+func (m *model) pickCloneWorkspace(url string) {
+	workspaces := m.workspaceConfig.Workspaces
+	if len(workspaces) == 1 {
+		m.promptCloneName(url, workspaces[0])
+		return
+	}
+	m.pickWorkspace("📥 Clone into workspace", "", func(m *model, ws workspace.Workspace) tea.Cmd {
+		m.promptCloneName(url, ws)
+		return nil
+	})
+}
+
+// pickWorkspace offers the configured workspaces other than skip in a
+// numbered menu, the current one first
+func (m *model) pickWorkspace(title, skip string, pick func(m *model, ws workspace.Workspace) tea.Cmd) {
+	if m.workspaceConfig == nil {
+		return
+	}
+	var ordered []workspace.Workspace
+	if m.currentWorkspace != nil && m.currentWorkspace.Name != skip {
+		ordered = append(ordered, *m.currentWorkspace)
+	}
+	for _, ws := range m.workspaceConfig.Workspaces {
+		if ws.Name != skip && (m.currentWorkspace == nil || ws.Name != m.currentWorkspace.Name) {
+			ordered = append(ordered, ws)
+		}
+	}
+	if len(ordered) == 0 {
+		m.log.add(logWarn, "there is no other workspace; add one with w")
+		return
+	}
+	items := make([]menuItem, 0, len(ordered))
+	for i, ws := range ordered {
+		key := ""
+		if i < 9 {
+			key = strconv.Itoa(i + 1)
+		}
+		items = append(items, menuItem{key: key, label: ws.Name + "  " + ws.Path, action: func(m *model) tea.Cmd {
+			return pick(m, ws)
+		}})
+	}
+	m.openMenu(title, items)
+}
+
+// promptCloneName asks for the directory to clone into, defaulting to the
+// name git would pick
+func (m *model) promptCloneName(url string, ws workspace.Workspace) {
+	m.openPrompt("📥 Clone into "+ws.Name+": directory", git.RepoNameFromURL(url), func(m *model, value string) tea.Cmd {
+		name := strings.TrimSpace(value)
+		if name == "" {
+			return nil
+		}
+		return cloneOperation(m.scanner, url, workspace.ExpandPath(ws.Path), name)
+	})
+}
+
+// handleCloned adds a fresh clone to the repo list and opens it
+func (m *model) handleCloned(repoPath string) tea.Cmd {
+	if m.scanner == nil {
+		return nil
+	}
+	m.repos = m.scanner.GetCachedRepos()
+	m.updateFilteredRepos()
+	repo, ok := m.scanner.GetRepo(repoPath)
+	if !ok {
+		return nil
+	}
+	return m.openRepo(repo)
+}
+
+// addClonedRepo records a fresh clone in the repository cache
+func addClonedRepo(scanner *workspace.Scanner, repoPath string) error {
+	if scanner == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return scanner.UpdateRepo(ctx, repoPath)
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/asbjornb/kvist/git"
+	"github.com/asbjornb/kvist/workspace"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type repoLoadedMsg struct {
+	repo     *git.Repository
+	commits  []git.Commit
+	branches []git.Branch
+	status   *git.Status
+	remotes  []git.Remote
+	stashes  []git.Stash
+	refs     map[string][]string
+	err      error
+}
+
+// Incremental loading messages
+type repoBasicsLoadedMsg struct {
+	path       string // the path the repository was loaded from
+	repo       *git.Repository
+	status     *git.Status
+	statusTime time.Duration // how long git status took
+	err        error
+}
+
+type repoMetadataLoadedMsg struct {
+	path     string
+	commits  []git.Commit
+	branches []git.Branch
+	remotes  []git.Remote
+	stashes  []git.Stash
+	refs     map[string][]string
+	err      error
+}
+
+type incrementalScanInitMsg struct {
+	channel <-chan workspace.RepoInfo
+	cancel  context.CancelFunc
+}
+
+// Fast loading: repository basics and status for immediate file view
+func loadRepositoryBasics(path string, filter fileFilter) tea.Cmd {
+	return func() tea.Msg {
+		repo, err := git.OpenRepository(path)
+		if err != nil {
+			return repoBasicsLoadedMsg{path: path, err: err}
+		}
+
+		started := time.Now()
+		status, err := readStatus(repo.Path, filter)
+		if err != nil {
+			return repoBasicsLoadedMsg{path: path, err: err}
+		}
+
+		return repoBasicsLoadedMsg{
+			path:       path,
+			repo:       repo,
+			status:     status,
+			statusTime: time.Since(started),
+		}
+	}
+}
+
+// Status only, for auto-refresh of an already open repository
+func loadStatus(repo *git.Repository, filter fileFilter) tea.Cmd {
+	return func() tea.Msg {
+		started := time.Now()
+		status, err := readStatus(repo.Path, filter)
+		if err != nil {
+			return repoBasicsLoadedMsg{path: repo.Path, err: err}
+		}
+		return repoBasicsLoadedMsg{path: repo.Path, repo: repo, status: status, statusTime: time.Since(started)}
+	}
+}
+
+// Slow loading: commits, branches, remotes, stashes for history view
+func loadRepositoryMetadata(path string, commitLimit int) tea.Cmd {
+	return func() tea.Msg {
+		if commitLimit <= 0 {
+			commitLimit = defaultCommitLimit
+		}
+		commits, _ := git.GetCommits(path, commitLimit)
+		branches, _ := git.GetBranches(path)
+		remotes, _ := git.GetRemotes(path)
+		stashes, _ := git.GetStashes(path)
+		refs, _ := git.GetRefs(path)
+
+		return repoMetadataLoadedMsg{
+			path:     path,
+			commits:  commits,
+			branches: branches,
+			remotes:  remotes,
+			stashes:  stashes,
+			refs:     refs,
+		}
+	}
+}
+
+type diffLoadedMsg struct {
+	gen  int // the diff request this answers
+	diff string
+	full []string // git arguments for the whole diff when diff was cut short
+	err  error
+}
+
+type workspaceConfigMsg struct {
+	config   *workspace.Config
+	cache    *workspace.RepoCache
+	warnings []string // problems that didn't stop loading
+	err      error
+}
+
+type workspaceScanMsg struct {
+	repos []workspace.RepoInfo
+	err   error
+}
+
+type repoDiscoveredMsg struct {
+	repo workspace.RepoInfo
+	err  error
+}
+
+type repoCacheUpdatedMsg struct {
+	repo workspace.RepoInfo
+	err  error
+}
+
+// newDiffRequest stops the diff still loading, if any, and returns the
+// context and generation for the next one
+func (m *model) newDiffRequest() (context.Context, int) {
+	if m.diffCancel != nil {
+		m.diffCancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.diffCancel = cancel
+	m.diffGen++
+	return ctx, m.diffGen
+}
+
+func loadDiff(ctx context.Context, gen int, repoPath string, file git.FileStatus, staged bool) tea.Cmd {
+	filePath, isUntracked := file.Path, file.IsUntracked()
+	// Staged content is named by its blobs; the working tree can change any time
+	var key string
+	if staged {
+		key = stagedDiffKey(repoPath, filePath, file.HeadBlob, file.IndexBlob)
+	}
+	return func() tea.Msg {
+		if diff, ok := diffs.get(key); ok {
+			return diffLoadedMsg{gen: gen, diff: diff}
+		}
+		if isUntracked && strings.HasSuffix(filePath, "/") {
+			return diffLoadedMsg{gen: gen, diff: fmt.Sprintf("Directory %s (contents not shown)", filePath)}
+		}
+		if isUntracked {
+			// Check if the file is binary using Git
+			isBinary, err := git.UntrackedIsBinary(repoPath, filePath)
+			if err != nil {
+				return diffLoadedMsg{gen: gen, diff: "", err: err}
+			}
+			if isBinary {
+				diff := fmt.Sprintf("Binary file %s (not shown)", filePath)
+				return diffLoadedMsg{gen: gen, diff: diff, err: nil}
+			}
+
+			// For untracked text files, use Git to generate the patch
+			diff, err := git.UntrackedPatch(repoPath, filePath)
+			if err != nil {
+				return diffLoadedMsg{gen: gen, diff: "", err: err}
+			}
+
+			return diffLoadedMsg{gen: gen, diff: diff, err: nil}
+		}
+
+		// For tracked files, first check if it's a binary change using numstat
+		isBinary, err := git.IsBinaryChange(repoPath, staged, filePath)
+		if err != nil {
+			return diffLoadedMsg{gen: gen, diff: "", err: err}
+		}
+
+		if isBinary {
+			diff := fmt.Sprintf("Binary file %s (not shown)", filePath)
+			diffs.put(key, diff)
+			return diffLoadedMsg{gen: gen, diff: diff, err: nil}
+		}
+
+		// Get the actual diff for text files
+		diff, err := git.GetDiffContext(ctx, repoPath, filePath, staged)
+		if errors.Is(err, git.ErrTruncated) {
+			full := []string{"diff", "--no-ext-diff"}
+			if staged {
+				full = append(full, "--cached")
+			}
+			return diffLoadedMsg{gen: gen, diff: diff, full: append(full, "--", filePath)}
+		}
+		if err != nil {
+			return diffLoadedMsg{gen: gen, diff: "", err: err}
+		}
+
+		diffs.put(key, diff)
+		return diffLoadedMsg{gen: gen, diff: diff, err: nil}
+	}
+}
+
+func loadCommitDiff(ctx context.Context, gen int, repoPath string, commitHash string) tea.Cmd {
+	key := commitDiffKey(repoPath, commitHash)
+	return func() tea.Msg {
+		if diff, ok := diffs.get(key); ok {
+			return diffLoadedMsg{gen: gen, diff: diff}
+		}
+		diff, err := git.GetCommitDiffContext(ctx, repoPath, commitHash)
+		if errors.Is(err, git.ErrTruncated) {
+			return diffLoadedMsg{gen: gen, diff: diff, full: []string{"show", "--no-ext-diff", "--first-parent", commitHash}}
+		}
+		if err != nil {
+			// Include git's output in the error message for debugging
+			errMsg := fmt.Sprintf("Commit: %s\nRepo: %s\nError: %v\nGit output: %s",
+				commitHash, repoPath, err, diff)
+			return diffLoadedMsg{gen: gen, diff: "", err: fmt.Errorf("%s", errMsg)}
+		}
+		diffs.put(key, diff)
+		return diffLoadedMsg{gen: gen, diff: diff, err: nil}
+	}
+}
+
+func loadWorkspaceConfig() tea.Msg {
+	var warnings []string
+	config, err := workspace.LoadConfig()
+	var configErr *workspace.ConfigError
+	if errors.As(err, &configErr) && !configErr.Fatal() {
+		for _, problem := range configErr.Problems {
+			warnings = append(warnings, "config "+problem.String())
+		}
+	} else if err != nil {
+		return workspaceConfigMsg{err: err}
+	}
+
+	cache, err := workspace.LoadRepoCache()
+	if errors.Is(err, workspace.ErrCacheCorrupt) {
+		warnings = append(warnings, err.Error())
+	} else if err != nil {
+		return workspaceConfigMsg{err: err}
+	}
+
+	return workspaceConfigMsg{config: config, cache: cache, warnings: warnings}
+}
+
+func scanWorkspaces(scanner *workspace.Scanner) tea.Cmd {
+	return func() tea.Msg {
+		if scanner == nil {
+			return workspaceScanMsg{err: fmt.Errorf("workspace scanner not available")}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		results := scanner.ScanWorkspaces(ctx)
+		result := <-results
+
+		return workspaceScanMsg{repos: result.Repos, err: result.Error}
+	}
+}
+
+func scanSingleWorkspaceIncremental(scanner *workspace.Scanner, ws *workspace.Workspace) tea.Cmd {
+	if scanner == nil || ws == nil {
+		return func() tea.Msg {
+			return workspaceScanMsg{err: fmt.Errorf("workspace scanner not available")}
+		}
+	}
+
+	workspaceCopy := *ws
+
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		repoChannel := scanner.DiscoverReposIncremental(ctx, workspaceCopy)
+		return incrementalScanInitMsg{channel: repoChannel, cancel: cancel}
+	}
+}
+
+func incrementalScanNextCmd(scanner *workspace.Scanner, ch <-chan workspace.RepoInfo, cancel context.CancelFunc) tea.Cmd {
+	return func() tea.Msg {
+		if ch == nil {
+			if cancel != nil {
+				cancel()
+			}
+			return workspaceScanMsg{err: fmt.Errorf("no incremental scan channel")}
+		}
+
+		repo, ok := <-ch
+		if !ok {
+			if cancel != nil {
+				cancel()
+			}
+			var (
+				repos   []workspace.RepoInfo
+				saveErr error
+			)
+			if scanner != nil {
+				saveErr = scanner.SaveCache()
+				repos = scanner.GetCachedRepos()
+			}
+			return workspaceScanMsg{repos: repos, err: saveErr}
+		}
+
+		if scanner != nil {
+			scanner.UpdateCacheRepo(repo)
+		}
+
+		return repoDiscoveredMsg{repo: repo}
+	}
+}
+
+func refreshRepoMetadata(scanner *workspace.Scanner, repoPath string) tea.Cmd {
+	return func() tea.Msg {
+		if scanner == nil || repoPath == "" {
+			return repoCacheUpdatedMsg{err: fmt.Errorf("workspace scanner not available")}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := scanner.UpdateRepo(ctx, repoPath); err != nil {
+			return repoCacheUpdatedMsg{err: err}
+		}
+
+		repo, exists := scanner.GetRepo(repoPath)
+		if !exists {
+			return repoCacheUpdatedMsg{err: fmt.Errorf("repository not found in cache")}
+		}
+
+		return repoCacheUpdatedMsg{repo: repo}
+	}
+}
+
+// Load repository incrementally: fast basics first, then metadata
+// commitLimit is the number of history entries to load; 0 means the default
+func loadRepositoryIncremental(path string, commitLimit int, filter fileFilter) tea.Cmd {
+	return tea.Batch(
+		loadRepositoryBasics(path, filter),
+		loadRepositoryMetadata(path, commitLimit),
+	)
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/asbjornb/kvist/conventional"
+	"github.com/asbjornb/kvist/git"
+	"github.com/asbjornb/kvist/workspace"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// commitSettingsMsg carries the commit settings for a repository, ready to
+// start writing a message
+type commitSettingsMsg struct {
+	repoPath string
+	settings workspace.CommitSettings
+	noVerify bool          // skip the commit hooks
+	snapshot *git.Snapshot // pre-commit state, taken when git will ask for the message
+}
+
+// editorCommitFinishedMsg reports the end of a git commit run in the terminal
+type editorCommitFinishedMsg struct {
+	repoPath string
+	snapshot *git.Snapshot
+	err      error
+}
+
+// loadCommitSettings applies the repository's own git config on top of the
+// kvist settings. The keys are kvist.conventionalCommits (true/false),
+// kvist.commitTypes (comma separated), kvist.maxHeaderLength and
+// kvist.commitEditor (true/false).
+func loadCommitSettings(repoPath string, settings workspace.CommitSettings, noVerify bool) tea.Cmd {
+	return func() tea.Msg {
+		if value, _ := git.GetConfig(repoPath, "kvist.conventionalCommits"); value != "" {
+			if on, err := strconv.ParseBool(value); err == nil {
+				settings.Conventional = on
+			}
+		}
+		if value, _ := git.GetConfig(repoPath, "kvist.commitTypes"); value != "" {
+			settings.Types = nil
+			for _, kind := range strings.Split(value, ",") {
+				if kind = strings.TrimSpace(kind); kind != "" {
+					settings.Types = append(settings.Types, kind)
+				}
+			}
+		}
+		if value, _ := git.GetConfig(repoPath, "kvist.maxHeaderLength"); value != "" {
+			if n, err := strconv.Atoi(value); err == nil {
+				settings.MaxHeader = n
+			}
+		}
+		if value, _ := git.GetConfig(repoPath, "kvist.commitEditor"); value != "" {
+			if on, err := strconv.ParseBool(value); err == nil {
+				settings.Editor = on
+			}
+		}
+		msg := commitSettingsMsg{repoPath: repoPath, settings: settings, noVerify: noVerify}
+		if settings.Editor {
+			// The commit bypasses the operation bus, so record undo here
+			msg.snapshot, _ = git.TakeSnapshot(repoPath, "commit")
+		}
+		return msg
+	}
+}
+
+// startCommit begins writing a commit message for the staged changes.
+// noVerify skips the commit hooks, and asks for confirmation first.
+func (m *model) startCommit(noVerify bool) tea.Cmd {
+	if m.repo == nil || m.status == nil {
+		return nil
+	}
+	staged := 0
+	for _, file := range m.status.Files {
+		if file.Staged != "" {
+			staged++
+		}
+	}
+	if staged == 0 {
+		m.log.add(logWarn, "nothing staged to commit; stage files with space first")
+		return nil
+	}
+
+	var settings workspace.CommitSettings
+	if m.workspaceConfig != nil {
+		settings = m.workspaceConfig.CommitSettingsFor(m.repo.Path)
+	}
+	if !noVerify {
+		return loadCommitSettings(m.repo.Path, settings, false)
+	}
+	repoPath := m.repo.Path
+	m.openConfirmation("⚠ Commit without hooks?",
+		"The pre-commit and commit-msg hooks will not run for this commit (git commit --no-verify).",
+		func(*model) tea.Cmd { return loadCommitSettings(repoPath, settings, true) })
+	return nil
+}
+
+// handleCommitSettings opens the message editor: git commit in the user's
+// editor, a single prompt, or the Conventional Commits steps
+func (m *model) handleCommitSettings(msg commitSettingsMsg) tea.Cmd {
+	if m.repo == nil || m.repo.Path != msg.repoPath {
+		return nil
+	}
+	repoPath := msg.repoPath
+	if msg.settings.Editor {
+		args := []string{"commit"}
+		if msg.noVerify {
+			args = append(args, "--no-verify")
+		}
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repoPath
+		cmd.Env = m.commandEnv(repoPath)
+		return tea.ExecProcess(cmd, func(err error) tea.Msg {
+			return editorCommitFinishedMsg{repoPath: repoPath, snapshot: msg.snapshot, err: err}
+		})
+	}
+	if !msg.settings.Conventional {
+		m.openPrompt("📝 Commit message", "", func(m *model, value string) tea.Cmd {
+			if strings.TrimSpace(value) == "" {
+				m.log.add(logWarn, "commit cancelled: empty message")
+				return nil
+			}
+			return commitOperation(repoPath, value, msg.noVerify)
+		})
+		return nil
+	}
+
+	rules := conventional.Rules{Types: msg.settings.Types, MaxHeader: msg.settings.MaxHeader}
+	m.openMenu("📝 Commit type", commitTypeItems(rules.AllowedTypes(), func(m *model, kind string) tea.Cmd {
+		m.composeCommit(repoPath, msg.noVerify, rules, conventional.Message{Type: kind})
+		return nil
+	}))
+	return nil
+}
+
+// handleEditorCommitFinished logs the outcome of a commit written in the
+// user's editor and runs the commit hooks like any other commit; git
+// reports an aborted message as an error
+func (m *model) handleEditorCommitFinished(msg editorCommitFinishedMsg) tea.Cmd {
+	if msg.err != nil {
+		m.log.add(logError, "commit failed: %v", msg.err)
+		return nil
+	}
+	m.log.add(logInfo, "commit completed")
+	if msg.snapshot != nil {
+		m.undo = msg.snapshot
+	}
+	hooks := m.runHooks("commit", msg.repoPath)
+	if m.repo == nil || m.repo.Path != msg.repoPath {
+		return hooks
+	}
+	return tea.Batch(m.reload(refreshRepo), hooks)
+}
+
+// commitTypeItems offers each type under the first of its letters not
+// already taken by an earlier one, e.g. f for feat and i for fix
+func commitTypeItems(types []string, pick func(m *model, kind string) tea.Cmd) []menuItem {
+	used := make(map[rune]bool)
+	items := make([]menuItem, 0, len(types))
+	for _, kind := range types {
+		key := ""
+		for _, r := range kind {
+			if !used[r] {
+				used[r] = true
+				key = string(r)
+				break
+			}
+		}
+		items = append(items, menuItem{key: key, label: kind, action: func(m *model) tea.Cmd {
+			return pick(m, kind)
+		}})
+	}
+	return items
+}
+
+// composeCommit asks for the remaining parts of msg one prompt at a time,
+// then shows the finished message with any rule warnings before committing
+func (m *model) composeCommit(repoPath string, noVerify bool, rules conventional.Rules, msg conventional.Message) {
+	title := func(step string) string {
+		return fmt.Sprintf("📝 %s: %s", msg.Type, step)
+	}
+	m.openPrompt(title("scope (optional)"), "", func(m *model, value string) tea.Cmd {
+		msg.Scope = strings.TrimSpace(value)
+		m.openPrompt(title("subject"), "", func(m *model, value string) tea.Cmd {
+			msg.Subject = strings.TrimSpace(value)
+			m.openPrompt(title("body (optional)"), "", func(m *model, value string) tea.Cmd {
+				msg.Body = strings.TrimSpace(value)
+				m.openPrompt(title("footer (optional, e.g. Refs: #12 or BREAKING CHANGE: ...)"), "", func(m *model, value string) tea.Cmd {
+					msg.Footer = strings.TrimSpace(value)
+					m.confirmCommit(repoPath, noVerify, rules, msg)
+					return nil
+				})
+				return nil
+			})
+			return nil
+		})
+		return nil
+	})
+}
+
+// confirmCommit previews the message; warnings don't block the commit
+func (m *model) confirmCommit(repoPath string, noVerify bool, rules conventional.Rules, msg conventional.Message) {
+	message := msg.String()
+	preview := message
+	title := "📝 Commit?"
+	if warnings := rules.Check(msg); len(warnings) > 0 {
+		title = fmt.Sprintf("⚠ Commit with %d warning(s)?", len(warnings))
+		preview += "\n"
+		for _, warning := range warnings {
+			preview += "\n⚠ " + warning
+		}
+	}
+	m.openConfirmation(title, preview, func(m *model) tea.Cmd {
+		return commitOperation(repoPath, message, noVerify)
+	})
+}
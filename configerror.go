@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/asbjornb/kvist/workspace"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// configError returns the config problems that stopped kvist from starting
+func (m model) configError() *workspace.ConfigError {
+	var configErr *workspace.ConfigError
+	if errors.As(m.err, &configErr) {
+		return configErr
+	}
+	return nil
+}
+
+// handleConfigErrorKey lets r reload the config from the config error
+// screen once the file has been fixed
+func (m *model) handleConfigErrorKey(key string) (tea.Cmd, bool) {
+	if m.configError() == nil || m.showingModal || key != "r" {
+		return nil, false
+	}
+	m.err = nil
+	m.log.add(logInfo, "reloading the configuration")
+	return loadWorkspaceConfig, true
+}
+
+// renderConfigError lists what is wrong with the config file, each problem
+// with its line and how to fix it
+func (m model) renderConfigError(configErr *workspace.ConfigError) string {
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("196"))
+	lineStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("242"))
+	fieldStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("39"))
+	hintStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+
+	var b strings.Builder
+	b.WriteString("\n  " + titleStyle.Render("⚠ kvist can't use its configuration") + "\n\n")
+	b.WriteString("  " + configErr.Path + "\n\n")
+	for _, problem := range configErr.Problems {
+		where := "        "
+		if problem.Line > 0 {
+			where = fmt.Sprintf("line %-3d", problem.Line)
+		}
+		b.WriteString("  " + lineStyle.Render(where) + " ")
+		if problem.Field != "" {
+			b.WriteString(fieldStyle.Render(problem.Field) + ": ")
+		}
+		b.WriteString(problem.Message + "\n")
+		if problem.Suggestion != "" {
+			b.WriteString("           " + hintStyle.Render("→ "+problem.Suggestion) + "\n")
+		}
+	}
+	b.WriteString("\n  Fix the file and press r to reload, L for the message log, or q to quit.\n")
+	return b.String()
+}
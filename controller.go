@@ -0,0 +1,98 @@
+package main
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// modeController owns the keys, messages and selection of one view mode.
+// update offers input to the active mode's controller before the global key
+// bindings, so a new mode is a new controller rather than more cases in a
+// shared switch.
+type modeController interface {
+	// handleKey handles a key in this mode; unhandled keys fall through to
+	// the global bindings
+	handleKey(m *model, key string) (tea.Cmd, bool)
+	// handleMsg handles results of commands this mode started. Every
+	// controller sees every message, whichever mode is active.
+	handleMsg(m *model, msg tea.Msg) (tea.Cmd, bool)
+	// selection returns the selected index of the top panel list and its length
+	selection(m *model) (*int, int)
+	// selectionChanged loads whatever the current selection shows
+	selectionChanged(m *model) tea.Cmd
+	// panels is how many panels tab cycles through
+	panels() int
+}
+
+// baseController provides the defaults for modes that don't need a hook
+type baseController struct{}
+
+func (baseController) handleKey(*model, string) (tea.Cmd, bool)  { return nil, false }
+func (baseController) handleMsg(*model, tea.Msg) (tea.Cmd, bool) { return nil, false }
+func (baseController) selection(*model) (*int, int)              { return nil, 0 }
+func (baseController) selectionChanged(*model) tea.Cmd           { return nil }
+func (baseController) panels() int                               { return 2 }
+
+var controllers = [...]modeController{
+	workspaceMode:       workspaceController{},
+	workspaceManageMode: workspaceManageController{},
+	historyMode:         historyController{},
+	filesMode:           filesController{},
+	treeMode:            treeController{},
+	todoMode:            todoController{},
+	incomingMode:        incomingController{},
+	statsMode:           statsController{},
+	sizeMode:            sizeController{},
+	taskMode:            taskController{},
+	pluginMode:          pluginController{},
+	compareMode:         compareController{},
+	changelogMode:       changelogController{},
+}
+
+func (m model) controller() modeController {
+	return controllers[m.currentMode]
+}
+
+// selectionChangedMsg is emitted when the top panel selection moves. It is
+// dropped if the selection has moved again by the time it is handled, so
+// scrolling quickly through a list only loads what it stops on.
+type selectionChangedMsg struct {
+	mode  viewMode
+	index int
+}
+
+// selectionChanged emits a selectionChangedMsg for the current selection
+func (m *model) selectionChanged() tea.Cmd {
+	sel, _ := m.controller().selection(m)
+	if sel == nil {
+		return nil
+	}
+	msg := selectionChangedMsg{mode: m.currentMode, index: *sel}
+	return func() tea.Msg {
+		return msg
+	}
+}
+
+// handleSelectionChanged passes a current selectionChangedMsg to its controller
+func (m *model) handleSelectionChanged(msg selectionChangedMsg) tea.Cmd {
+	if msg.mode != m.currentMode {
+		return nil
+	}
+	if sel, _ := m.controller().selection(m); sel == nil || *sel != msg.index {
+		return nil
+	}
+	return m.controller().selectionChanged(m)
+}
+
+// moveSelection moves the selection in the top panel list of the current mode
+func (m *model) moveSelection(delta int) tea.Cmd {
+	sel, n := m.controller().selection(m)
+	if sel == nil {
+		return nil
+	}
+	prev := *sel
+	*sel = moveIndex(*sel, delta, n)
+	if *sel == prev {
+		return nil
+	}
+	return m.selectionChanged()
+}
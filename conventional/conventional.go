@@ -0,0 +1,133 @@
+package conventional
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// DefaultTypes are the commit types allowed when none are configured
+var DefaultTypes = []string{"feat", "fix", "docs", "style", "refactor", "perf", "test", "build", "ci", "chore", "revert"}
+
+// DefaultMaxHeader is the longest header line accepted without a warning
+// when no limit is configured
+const DefaultMaxHeader = 72
+
+// Message is a commit message in Conventional Commits form:
+//
+//	type(scope)!: subject
+//
+//	body
+//
+//	footer
+type Message struct {
+	Type    string
+	Scope   string // optional
+	Subject string
+	Body    string // optional
+	Footer  string // optional, e.g. "Refs: #12" or "BREAKING CHANGE: ..."
+}
+
+// headerPattern matches a header line: type, optional scope, optional ! and
+// the subject
+var headerPattern = regexp.MustCompile(`^(\w[\w-]*)(?:\(([^()]*)\))?(!)?: (.+)$`)
+
+// footerPattern matches the first line of a footer paragraph, a token
+// followed by ": " or " #"
+var footerPattern = regexp.MustCompile(`^(BREAKING CHANGE|[\w-]+)(: | #)`)
+
+// Parse reads a commit's subject line and body as a Conventional Commits
+// message, reporting false when the subject isn't in that form. The last
+// paragraph of the body is the footer when it starts like one. A ! in the
+// header without a BREAKING CHANGE footer gets one describing the change by
+// the subject, so Breaking reports it.
+func Parse(subject, body string) (Message, bool) {
+	match := headerPattern.FindStringSubmatch(strings.TrimSpace(subject))
+	if match == nil {
+		return Message{}, false
+	}
+	m := Message{Type: match[1], Scope: match[2], Subject: match[4]}
+	body = strings.TrimSpace(body)
+	m.Body = body
+	if i := strings.LastIndex(body, "\n\n"); i < 0 && footerPattern.MatchString(body) {
+		m.Body, m.Footer = "", body
+	} else if i >= 0 && footerPattern.MatchString(body[i+2:]) {
+		m.Body, m.Footer = strings.TrimSpace(body[:i]), body[i+2:]
+	}
+	if match[3] != "" && !m.Breaking() {
+		breaking := "BREAKING CHANGE: " + m.Subject
+		if m.Footer != "" {
+			breaking += "\n" + m.Footer
+		}
+		m.Footer = breaking
+	}
+	return m, true
+}
+
+// Breaking reports whether the footer announces a breaking change
+func (m Message) Breaking() bool {
+	return strings.HasPrefix(m.Footer, "BREAKING CHANGE:") || strings.HasPrefix(m.Footer, "BREAKING-CHANGE:")
+}
+
+// Header returns the first line of the message
+func (m Message) Header() string {
+	header := m.Type
+	if m.Scope != "" {
+		header += "(" + m.Scope + ")"
+	}
+	if m.Breaking() {
+		header += "!"
+	}
+	return header + ": " + m.Subject
+}
+
+// String returns the full commit message
+func (m Message) String() string {
+	parts := []string{m.Header()}
+	for _, part := range []string{m.Body, m.Footer} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// Rules are the checks a message is validated against
+type Rules struct {
+	Types     []string // allowed types; empty means DefaultTypes
+	MaxHeader int      // longest header line; 0 means DefaultMaxHeader
+}
+
+// AllowedTypes returns the configured types, or DefaultTypes
+func (r Rules) AllowedTypes() []string {
+	if len(r.Types) > 0 {
+		return r.Types
+	}
+	return DefaultTypes
+}
+
+// Check returns warnings for the ways m breaks the rules; none means it's fine
+func (r Rules) Check(m Message) []string {
+	var warnings []string
+	if !slices.Contains(r.AllowedTypes(), m.Type) {
+		warnings = append(warnings, fmt.Sprintf("type %q is not one of %s", m.Type, strings.Join(r.AllowedTypes(), ", ")))
+	}
+	if strings.ContainsAny(m.Scope, "() ") {
+		warnings = append(warnings, fmt.Sprintf("scope %q should be a single word without parentheses", m.Scope))
+	}
+	switch subject := strings.TrimSpace(m.Subject); {
+	case subject == "":
+		warnings = append(warnings, "subject is empty")
+	case strings.HasSuffix(subject, "."):
+		warnings = append(warnings, "subject should not end with a period")
+	}
+	maxHeader := r.MaxHeader
+	if maxHeader <= 0 {
+		maxHeader = DefaultMaxHeader
+	}
+	if n := len([]rune(m.Header())); n > maxHeader {
+		warnings = append(warnings, fmt.Sprintf("header is %d characters, limit is %d", n, maxHeader))
+	}
+	return warnings
+}
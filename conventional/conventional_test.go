@@ -0,0 +1,93 @@
+package conventional
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMessageString(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  Message
+		want string
+	}{
+		{"minimal", Message{Type: "fix", Subject: "handle empty repos"}, "fix: handle empty repos"},
+		{"scoped", Message{Type: "feat", Scope: "tree", Subject: "add tree view"}, "feat(tree): add tree view"},
+		{
+			"full",
+			Message{Type: "feat", Scope: "api", Subject: "drop v1", Body: "v1 has been deprecated for a year.", Footer: "BREAKING CHANGE: v1 endpoints are gone"},
+			"feat(api)!: drop v1\n\nv1 has been deprecated for a year.\n\nBREAKING CHANGE: v1 endpoints are gone",
+		},
+		{"footer only", Message{Type: "chore", Subject: "bump deps", Footer: "Refs: #12"}, "chore: bump deps\n\nRefs: #12"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.msg.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules Rules
+		msg   Message
+		want  []string // substrings of the expected warnings, in order
+	}{
+		{"valid", Rules{}, Message{Type: "fix", Subject: "handle empty repos"}, nil},
+		{"unknown type", Rules{}, Message{Type: "feature", Subject: "x"}, []string{`type "feature"`}},
+		{"custom types", Rules{Types: []string{"feature"}}, Message{Type: "feature", Subject: "x"}, nil},
+		{"empty subject", Rules{}, Message{Type: "fix", Subject: " "}, []string{"subject is empty"}},
+		{"period", Rules{}, Message{Type: "fix", Subject: "done."}, []string{"period"}},
+		{"bad scope", Rules{}, Message{Type: "fix", Scope: "a b", Subject: "x"}, []string{"scope"}},
+		{"too long", Rules{MaxHeader: 10}, Message{Type: "fix", Subject: "much too long"}, []string{"18 characters, limit is 10"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.rules.Check(tt.msg)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Check() = %q, want %d warnings", got, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(got[i], want) {
+					t.Errorf("warning %d = %q, want it to mention %q", i, got[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name          string
+		subject, body string
+		want          Message
+		ok            bool
+	}{
+		{"minimal", "fix: handle empty repos", "", Message{Type: "fix", Subject: "handle empty repos"}, true},
+		{"scoped", "feat(tree): add tree view", "Lists files.", Message{Type: "feat", Scope: "tree", Subject: "add tree view", Body: "Lists files."}, true},
+		{
+			"footer",
+			"feat(api): drop v1", "v1 has been deprecated for a year.\n\nBREAKING CHANGE: v1 endpoints are gone",
+			Message{Type: "feat", Scope: "api", Subject: "drop v1", Body: "v1 has been deprecated for a year.", Footer: "BREAKING CHANGE: v1 endpoints are gone"},
+			true,
+		},
+		{"footer only", "chore: bump deps", "Refs: #12", Message{Type: "chore", Subject: "bump deps", Footer: "Refs: #12"}, true},
+		{"bang", "refactor!: rename config", "", Message{Type: "refactor", Subject: "rename config", Footer: "BREAKING CHANGE: rename config"}, true},
+		{"not conventional", "Fix the build", "", Message{}, false},
+		{"no space", "fix:the build", "", Message{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Parse(tt.subject, tt.body)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("Parse() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+	if m, _ := Parse("feat(api)!: drop v1", ""); m.Header() != "feat(api)!: drop v1" {
+		t.Errorf("Expected a parsed breaking header to come out the same, got %q", m.Header())
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/asbjornb/kvist/git"
+	"github.com/asbjornb/kvist/workspace"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// customCommandValues are what the placeholders of custom commands stand
+// for in the current view
+func (m model) customCommandValues() map[string]string {
+	values := map[string]string{"repoPath": m.selectedRepoPath()}
+	switch m.currentMode {
+	case historyMode:
+		if m.selectedCommit < len(m.commits) {
+			values["commitHash"] = m.commits[m.selectedCommit].Hash
+		}
+	case filesMode:
+		if m.status != nil && m.selectedFile < len(m.status.Files) {
+			values["selectedFile"] = m.status.Files[m.selectedFile].Path
+		}
+	case treeMode:
+		values["commitHash"] = m.treeRev
+		values["selectedFile"] = m.treeFilePath
+	case sizeMode:
+		if m.sizes != nil && m.selectedBlob < len(m.sizes.Largest) {
+			values["selectedFile"] = m.sizes.Largest[m.selectedBlob].Path
+		}
+	case workspaceMode:
+		if m.selectedRepo < len(m.filteredRepos) {
+			values["branch"] = m.filteredRepos[m.selectedRepo].Branch
+		}
+	}
+	if m.repo != nil && m.currentMode != workspaceMode && m.currentMode != workspaceManageMode {
+		values["branch"] = m.repo.CurrentBranch
+	}
+	return values
+}
+
+// customCommands returns the user's custom commands
+func (m model) customCommands() []workspace.CustomCommand {
+	if m.workspaceConfig == nil {
+		return nil
+	}
+	return m.workspaceConfig.CustomCommands
+}
+
+// openCustomCommands lists the custom commands to run in the selected
+// repository
+func (m *model) openCustomCommands() {
+	commands := m.customCommands()
+	if len(commands) == 0 {
+		m.log.add(logInfo, "no custom commands; add them under customCommands in the config")
+		return
+	}
+	items := make([]menuItem, 0, len(commands))
+	for i, command := range commands {
+		key := command.Key
+		if key == "" {
+			key = menuKey(i)
+		}
+		items = append(items, menuItem{key: key, label: command.Name, action: func(m *model) tea.Cmd {
+			return m.runCustomCommand(command)
+		}})
+	}
+	m.openMenu("⚡ Custom commands", items)
+}
+
+// customCommandForKey returns the custom command bound to key
+func (m model) customCommandForKey(key string) (workspace.CustomCommand, bool) {
+	for _, command := range m.customCommands() {
+		if command.Key == key {
+			return command, true
+		}
+	}
+	return workspace.CustomCommand{}, false
+}
+
+// runCustomCommand fills in the placeholders of command from the current
+// view and runs it in the selected repository, asking first if it says so
+func (m *model) runCustomCommand(command workspace.CustomCommand) tea.Cmd {
+	repoPath := m.selectedRepoPath()
+	if repoPath == "" {
+		m.log.add(logInfo, "select a repository to run %s in", command.Name)
+		return nil
+	}
+	line, err := command.Expand(m.customCommandValues(), workspace.ShellQuote)
+	if err != nil {
+		m.log.add(logError, "%v", err)
+		return nil
+	}
+	run := func(m *model) tea.Cmd {
+		return customCommandOperation(command.Name, repoPath, line, m.commandEnv(repoPath))
+	}
+	if command.Confirm {
+		m.openConfirmation("⚡ "+command.Name, fmt.Sprintf("Run %s in %s?", line, filepath.Base(repoPath)), run)
+		return nil
+	}
+	return run(m)
+}
+
+// customCommandOperation runs line through the shell in repoPath, logging
+// what it prints
+func customCommandOperation(name, repoPath, line string, env []string) tea.Cmd {
+	output := make(chan string, 64)
+	return requestOperation(operation{
+		desc:     name,
+		repoPath: repoPath,
+		refresh:  refreshRepo,
+		output:   output,
+		run: func() error {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+			defer cancel()
+			return runShellStreaming(ctx, repoPath, line, env, func(text string) { output <- text })
+		},
+	})
+}
+
+// runShellStreaming runs line through the shell in dir until it exits or
+// ctx is done, passing each line it prints to output
+func runShellStreaming(ctx context.Context, dir, line string, env []string, output func(text string)) error {
+	cmd := shellCommand(line)
+	cmd.Dir = dir
+	cmd.Env = env
+	return git.RunStreaming(ctx, cmd, output)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/asbjornb/kvist/workspace"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// repoState is a condition the workspace repo list can be narrowed to from
+// the overview dashboard
+type repoState int
+
+const (
+	anyRepoState repoState = iota
+	dirtyRepos
+	aheadRepos
+	behindRepos
+	offDefaultRepos
+	stashedRepos
+	staleBranchRepos
+)
+
+// dashboardStates are the states the dashboard counts, in display order
+var dashboardStates = []struct {
+	state repoState
+	key   string
+	label string
+}{
+	{dirtyRepos, "d", "with uncommitted changes"},
+	{aheadRepos, "a", "ahead of upstream"},
+	{behindRepos, "b", "behind upstream"},
+	{offDefaultRepos, "n", "on a non-default branch"},
+	{stashedRepos, "s", "with stashes"},
+	{staleBranchRepos, "x", "with stale merged branches"},
+}
+
+// matches reports whether repo is in state s
+func (s repoState) matches(repo workspace.RepoInfo) bool {
+	switch s {
+	case dirtyRepos:
+		return repo.Dirty()
+	case aheadRepos:
+		return repo.Ahead > 0
+	case behindRepos:
+		return repo.Behind > 0
+	case offDefaultRepos:
+		return repo.Branch != "" && repo.DefaultBranch != "" && repo.Branch != repo.DefaultBranch
+	case stashedRepos:
+		return repo.Stashes > 0
+	case staleBranchRepos:
+		return repo.StaleBranches > 0
+	}
+	return true
+}
+
+func (s repoState) String() string {
+	for _, entry := range dashboardStates {
+		if entry.state == s {
+			return entry.label
+		}
+	}
+	return "all"
+}
+
+// reposInView returns the repositories of the current workspace, or all of
+// them when no workspace is selected. Archived ones are left out unless
+// they are being shown.
+func (m model) reposInView() []workspace.RepoInfo {
+	repos := m.repos
+	if m.currentWorkspace != nil {
+		repos = nil
+		for _, repo := range m.repos {
+			if repo.WorkspaceName == m.currentWorkspace.Name {
+				repos = append(repos, repo)
+			}
+		}
+	}
+	if m.showArchived || m.scanner == nil {
+		return repos
+	}
+	return m.scanner.Unarchived(repos)
+}
+
+// openDashboard summarizes the repositories in view by state; picking a
+// state narrows the repo list to the repositories in it
+func (m *model) openDashboard() {
+	repos := m.reposInView()
+	scope := "All repositories"
+	if m.currentWorkspace != nil {
+		scope = m.currentWorkspace.Name
+	}
+
+	show := func(state repoState) func(m *model) tea.Cmd {
+		return func(m *model) tea.Cmd {
+			m.repoState = state
+			m.selectedRepo = 0
+			m.updateFilteredRepos()
+			return nil
+		}
+	}
+	items := make([]menuItem, 0, len(dashboardStates)+1)
+	for _, entry := range dashboardStates {
+		count := 0
+		for _, repo := range repos {
+			if entry.state.matches(repo) {
+				count++
+			}
+		}
+		items = append(items, menuItem{
+			key:    entry.key,
+			label:  fmt.Sprintf("%3d  %s", count, entry.label),
+			action: show(entry.state),
+		})
+	}
+	items = append(items, menuItem{key: "*", label: fmt.Sprintf("%3d  all repositories", len(repos)), action: show(anyRepoState)})
+	m.openMenu(fmt.Sprintf("📊 %s: %d repos", scope, len(repos)), items)
+}
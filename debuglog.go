@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/asbjornb/kvist/git"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxDebugLogSize is how large the debug log grows before it is rotated;
+// the previous log is kept with a .1 suffix
+const maxDebugLogSize = 10 << 20
+
+// debugLog receives git commands and Bubble Tea messages as JSON lines; it
+// is nil unless kvist runs with --debug or KVIST_DEBUG set
+var debugLog *slog.Logger
+
+// startDebugLog opens the debug log at path and starts tracing git
+// commands into it. The returned function closes it.
+func startDebugLog(path string) (stop func(), err error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := openRotatingFile(path, maxDebugLogSize)
+	if err != nil {
+		return nil, err
+	}
+	logger := slog.New(slog.NewJSONHandler(file, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	debugLog = logger
+	// The logger itself is traced to, as commands may finish after stop
+	git.SetTracer(func(dir string, args []string, elapsed time.Duration, exitCode int) {
+		logger.Debug("git", "dir", dir, "args", args, "ms", elapsed.Milliseconds(), "exit", exitCode)
+	})
+	logger.Info("started", "version", versionString(), "pid", os.Getpid())
+	return func() {
+		git.SetTracer(nil)
+		debugLog = nil
+		file.Close()
+	}, nil
+}
+
+// traceMsg logs the type of a message reaching Update, and the key of key
+// presses
+func traceMsg(msg tea.Msg) {
+	if debugLog == nil {
+		return
+	}
+	if key, ok := msg.(tea.KeyMsg); ok {
+		debugLog.Debug("msg", "type", fmt.Sprintf("%T", msg), "key", key.String())
+		return
+	}
+	debugLog.Debug("msg", "type", fmt.Sprintf("%T", msg))
+}
+
+// rotatingFile appends to a file, moving it aside to path.1 once it would
+// grow past max bytes
+type rotatingFile struct {
+	mu   sync.Mutex
+	path string
+	max  int64
+	file *os.File
+	size int64
+}
+
+func openRotatingFile(path string, max int64) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, max: max}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file, r.size = file, info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if r.size > 0 && r.size+int64(len(p)) > r.max {
+		r.file.Close()
+		os.Rename(r.path, r.path+".1")
+		if err := r.open(); err != nil {
+			r.file = nil
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+)
+
+// diffCacheBytes bounds how much diff text is kept
+const diffCacheBytes = 16 << 20
+
+// diffs keeps diffs that can't change: those of commits, and staged diffs
+// of files whose HEAD and index blobs are known. Refreshing clears it.
+var diffs = newDiffCache(diffCacheBytes)
+
+// diffCache is a least recently used cache of diff text, bounded by size
+type diffCache struct {
+	mu      sync.Mutex
+	limit   int
+	size    int
+	order   *list.List // of *diffEntry, most recently used first
+	entries map[string]*list.Element
+}
+
+type diffEntry struct {
+	key  string
+	diff string
+}
+
+func newDiffCache(limit int) *diffCache {
+	return &diffCache{limit: limit, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// commitDiffKey identifies the diff of a commit
+func commitDiffKey(repoPath, hash string) string {
+	return "commit\x00" + repoPath + "\x00" + hash
+}
+
+// stagedDiffKey identifies the staged diff of a file by the blobs on both
+// sides, or returns "" when they aren't known
+func stagedDiffKey(repoPath, path, headBlob, indexBlob string) string {
+	if indexBlob == "" {
+		return ""
+	}
+	return "staged\x00" + repoPath + "\x00" + path + "\x00" + headBlob + "\x00" + indexBlob
+}
+
+func (c *diffCache) get(key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*diffEntry).diff, true
+}
+
+// put stores diff under key; diffs larger than the whole cache aren't kept
+func (c *diffCache) put(key, diff string) {
+	if key == "" || len(diff) > c.limit {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.size -= len(elem.Value.(*diffEntry).diff)
+		c.order.Remove(elem)
+	}
+	c.entries[key] = c.order.PushFront(&diffEntry{key: key, diff: diff})
+	c.size += len(diff)
+	for c.size > c.limit {
+		oldest := c.order.Back()
+		entry := oldest.Value.(*diffEntry)
+		c.order.Remove(oldest)
+		delete(c.entries, entry.key)
+		c.size -= len(entry.diff)
+	}
+}
+
+// clear forgets every diff
+func (c *diffCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	c.entries = make(map[string]*list.Element)
+	c.size = 0
+}
@@ -0,0 +1,537 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/asbjornb/kvist/git"
+	"github.com/asbjornb/kvist/workspace"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// refreshScope says how much of the open repository to reload after an operation
+type refreshScope int
+
+const (
+	refreshNone   refreshScope = iota
+	refreshStatus              // work tree status only
+	refreshRepo                // status, history and the workspace cache entry
+)
+
+// operation is a mutating git command. Controllers describe what to run and
+// what it affects; the bus runs it and applies the result in one place, so
+// logging, undo and refreshing work the same for every operation.
+type operation struct {
+	desc     string // shown in the log, e.g. "checkout branch main"
+	repoPath string
+	snapshot bool // record an undo snapshot before running
+	refresh  refreshScope
+	run      func() error
+	finish   func(m *model) tea.Cmd            // applied to the model after a successful run
+	failed   func(m *model, err error) tea.Cmd // applied after a failed run, before the refresh
+	// output, if set, receives lines run prints while it works, such as
+	// hook output; the bus logs them as they arrive and closes it after run
+	output chan string
+}
+
+// operationRequestedMsg asks the bus to run an operation
+type operationRequestedMsg struct {
+	op operation
+}
+
+// operationFinishedMsg reports the outcome of a requested operation
+type operationFinishedMsg struct {
+	op       operation
+	snapshot *git.Snapshot
+	err      error
+}
+
+// requestOperation emits an operationRequestedMsg for op
+func requestOperation(op operation) tea.Cmd {
+	return func() tea.Msg {
+		return operationRequestedMsg{op: op}
+	}
+}
+
+// operationOutputMsg is a line of output from a running operation
+type operationOutputMsg struct {
+	op   operation
+	line string
+	done <-chan operationFinishedMsg
+}
+
+// runOperation runs op in the background and reports an operationFinishedMsg.
+// Operations with output report each line first, so the log keeps their order.
+func runOperation(op operation) tea.Cmd {
+	finished := func() operationFinishedMsg {
+		var snapshot *git.Snapshot
+		if op.snapshot {
+			snapshot, _ = git.TakeSnapshot(op.repoPath, op.desc)
+		}
+		return operationFinishedMsg{op: op, snapshot: snapshot, err: op.run()}
+	}
+	if op.output == nil {
+		return func() tea.Msg { return finished() }
+	}
+
+	done := make(chan operationFinishedMsg, 1)
+	go func() {
+		msg := finished()
+		close(op.output)
+		done <- msg
+	}()
+	return nextOperationOutput(op, done)
+}
+
+// nextOperationOutput waits for the next line of op's output, or for op to
+// finish once the output is closed
+func nextOperationOutput(op operation, done <-chan operationFinishedMsg) tea.Cmd {
+	return func() tea.Msg {
+		if line, ok := <-op.output; ok {
+			return operationOutputMsg{op: op, line: line, done: done}
+		}
+		return <-done
+	}
+}
+
+func gitOperation(repoPath string, gitOp git.GitOp) tea.Cmd {
+	return requestOperation(operation{
+		desc:     gitOp.String(),
+		repoPath: repoPath,
+		snapshot: gitOp == git.OpPull, // pull may move the branch
+		refresh:  refreshRepo,
+		run:      func() error { return git.ExecuteGitOp(repoPath, gitOp) },
+		finish: func(m *model) tea.Cmd {
+			if gitOp != git.OpPull {
+				return nil
+			}
+			return m.runHooks("pull", repoPath)
+		},
+		failed: offerRetry(gitOp.String(), func(m *model) tea.Cmd {
+			if !m.networkAllowed(gitOp.String()) {
+				return nil
+			}
+			return gitOperation(repoPath, gitOp)
+		}),
+	})
+}
+
+// offerRetry is a failed hook for network operations that asks whether to
+// try again when the remote looked unreachable
+func offerRetry(desc string, retry func(m *model) tea.Cmd) func(m *model, err error) tea.Cmd {
+	return func(m *model, err error) tea.Cmd {
+		if git.IsTransient(err) {
+			m.openConfirmation("⚠️  "+desc+" failed",
+				fmt.Sprintf("%v\n\nThe remote couldn't be reached. Try again?", err), retry)
+		}
+		return nil
+	}
+}
+
+// moveRepoOperation moves a repository directory, following it with the
+// open repository when it is the one moved
+func moveRepoOperation(scanner *workspace.Scanner, oldPath, newPath, workspaceName string) tea.Cmd {
+	return requestOperation(operation{
+		desc:     "move " + filepath.Base(oldPath) + " to " + newPath,
+		repoPath: oldPath,
+		run: func() error {
+			if scanner == nil {
+				return fmt.Errorf("workspace scanner not available")
+			}
+			return scanner.MoveRepo(oldPath, newPath, workspaceName)
+		},
+		finish: func(m *model) tea.Cmd {
+			m.repos = scanner.GetCachedRepos()
+			m.updateFilteredRepos()
+			m.selectRepo(newPath)
+			if m.repo == nil || m.repo.Path != oldPath {
+				return nil
+			}
+			repo, ok := scanner.GetRepo(newPath)
+			if !ok {
+				return nil
+			}
+			mode := m.currentMode
+			m.closeTab(m.tabIndex(oldPath))
+			cmd := m.openRepo(repo)
+			m.currentMode = mode
+			return cmd
+		},
+	})
+}
+
+// deleteRepoOperation removes a repository from disk; if it is the open
+// repository, kvist goes back to the workspace list
+func deleteRepoOperation(scanner *workspace.Scanner, repoPath string, force bool) tea.Cmd {
+	return requestOperation(operation{
+		desc:     "delete " + repoPath,
+		repoPath: repoPath,
+		run: func() error {
+			if scanner == nil {
+				return fmt.Errorf("workspace scanner not available")
+			}
+			return scanner.DeleteRepo(repoPath, force)
+		},
+		finish: func(m *model) tea.Cmd {
+			if i := m.tabIndex(repoPath); i >= 0 && i != m.activeTab {
+				m.closeTab(i)
+			}
+			if m.repo != nil && m.repo.Path == repoPath {
+				m.closeTab(m.activeTab)
+				m.repo = nil
+				m.status = nil
+				m.currentDiff = ""
+				m.currentMode = workspaceMode
+			}
+			m.repos = scanner.GetCachedRepos()
+			m.updateFilteredRepos()
+			return nil
+		},
+	})
+}
+
+// adoptOperation puts a project directory under git with an initial commit
+func adoptOperation(scanner *workspace.Scanner, dir string) tea.Cmd {
+	return requestOperation(operation{
+		desc:     "git init " + filepath.Base(dir),
+		repoPath: dir,
+		run: func() error {
+			if err := git.InitRepo(dir, "Initial commit"); err != nil {
+				return err
+			}
+			return addClonedRepo(scanner, dir)
+		},
+		finish: func(m *model) tea.Cmd {
+			if scanner == nil {
+				return nil
+			}
+			m.repos = scanner.GetCachedRepos()
+			m.updateFilteredRepos()
+			m.selectRepo(dir)
+			return nil
+		},
+	})
+}
+
+// stageOperation unstages a file listed as staged and stages it from any
+// other section, which marks a conflict resolved
+func stageOperation(repoPath string, file git.FileStatus, side fileSide) tea.Cmd {
+	op := operation{repoPath: repoPath, refresh: refreshRepo}
+	switch side {
+	case stagedSide:
+		op.desc = "unstage " + file.Path
+		op.run = func() error { return git.UnstageFile(repoPath, file.Path) }
+	case conflictSide:
+		op.desc = "mark " + file.Path + " resolved"
+		op.run = func() error { return git.StageFile(repoPath, file.Path) }
+	default:
+		op.desc = "stage " + file.Path
+		op.run = func() error { return git.StageFile(repoPath, file.Path) }
+	}
+	return requestOperation(op)
+}
+
+func commitOperation(repoPath, message string, noVerify bool) tea.Cmd {
+	subject, _, _ := strings.Cut(message, "\n")
+	output := make(chan string, 64)
+	desc := "commit " + subject
+	if noVerify {
+		desc += " (hooks skipped)"
+	}
+	return requestOperation(operation{
+		desc:     desc,
+		repoPath: repoPath,
+		snapshot: true,
+		refresh:  refreshRepo,
+		output:   output,
+		run: func() error {
+			hooks := git.CommitHooks(repoPath)
+			if noVerify || len(hooks) == 0 {
+				return git.CommitStaged(repoPath, message, noVerify, func(line string) { output <- line })
+			}
+			output <- "running " + strings.Join(hooks, ", ") + " hooks"
+			err := git.CommitStaged(repoPath, message, false, func(line string) { output <- line })
+			if err != nil {
+				return fmt.Errorf("%w (rejected by a hook? C commits without hooks)", err)
+			}
+			output <- "hooks passed"
+			return nil
+		},
+		finish: func(m *model) tea.Cmd {
+			return m.runHooks("commit", repoPath)
+		},
+	})
+}
+
+func checkoutBranchOperation(repoPath, branch string) tea.Cmd {
+	return requestOperation(operation{
+		desc:     "checkout branch " + branch,
+		repoPath: repoPath,
+		snapshot: true,
+		refresh:  refreshRepo,
+		run:      func() error { return git.CheckoutBranch(repoPath, branch) },
+	})
+}
+
+func stashOperation(repoPath string, opts git.StashOptions) tea.Cmd {
+	desc := "stash changes"
+	if len(opts.Paths) > 0 {
+		desc = fmt.Sprintf("stash %d path(s)", len(opts.Paths))
+	}
+	if opts.Message != "" {
+		desc += ": " + opts.Message
+	}
+	return requestOperation(operation{
+		desc:     desc,
+		repoPath: repoPath,
+		refresh:  refreshRepo,
+		run:      func() error { return git.StashPush(repoPath, opts) },
+	})
+}
+
+func applyStashOperation(repoPath string, stash git.Stash) tea.Cmd {
+	return requestOperation(operation{
+		desc:     "apply " + stash.Index,
+		repoPath: repoPath,
+		refresh:  refreshStatus,
+		run:      func() error { return git.ApplyStash(repoPath, stash.Hash) },
+	})
+}
+
+func stashFileOperation(repoPath string, stash git.Stash, path string) tea.Cmd {
+	return requestOperation(operation{
+		desc:     "check out " + path + " from " + stash.Index,
+		repoPath: repoPath,
+		refresh:  refreshStatus,
+		run:      func() error { return git.CheckoutStashFile(repoPath, stash.Hash, path) },
+	})
+}
+
+func applyPatchOperation(repoPath, desc, patch string) tea.Cmd {
+	return requestOperation(operation{
+		desc:     desc,
+		repoPath: repoPath,
+		refresh:  refreshStatus,
+		run:      func() error { return git.ApplyPatch(repoPath, patch) },
+	})
+}
+
+func formatPatchOperation(repoPath, oldest, newest, dir string) tea.Cmd {
+	var paths []string
+	return requestOperation(operation{
+		desc:     "format-patch into " + dir,
+		repoPath: repoPath,
+		refresh:  refreshStatus,
+		run: func() (err error) {
+			paths, err = git.FormatPatches(repoPath, oldest, newest, dir)
+			return err
+		},
+		finish: func(m *model) tea.Cmd {
+			m.log.add(logInfo, "wrote %d patch file(s) to %s", len(paths), dir)
+			return nil
+		},
+	})
+}
+
+func cleanOperation(repoPath string, mode git.CleanMode, paths []string) tea.Cmd {
+	return requestOperation(operation{
+		desc:     fmt.Sprintf("clean %d path(s)", len(paths)),
+		repoPath: repoPath,
+		refresh:  refreshStatus,
+		run:      func() error { return git.Clean(repoPath, mode, paths) },
+	})
+}
+
+func gitignoreOperation(repoPath, pattern string) tea.Cmd {
+	return requestOperation(operation{
+		desc:     "ignore " + pattern,
+		repoPath: repoPath,
+		refresh:  refreshStatus,
+		run:      func() error { return git.AddToGitignore(repoPath, pattern) },
+	})
+}
+
+func restoreFileOperation(repoPath, commit, from, path string) tea.Cmd {
+	return requestOperation(operation{
+		desc:     fmt.Sprintf("restore %s from %s", path, shortHash(commit)),
+		repoPath: repoPath,
+		refresh:  refreshStatus,
+		run:      func() error { return git.RestoreFileFrom(repoPath, commit, from, path) },
+	})
+}
+
+func checkoutCommitOperation(repoPath, commit string) tea.Cmd {
+	return requestOperation(operation{
+		desc:     "checkout commit " + shortHash(commit),
+		repoPath: repoPath,
+		snapshot: true,
+		refresh:  refreshRepo,
+		run:      func() error { return git.CheckoutCommit(repoPath, commit) },
+	})
+}
+
+func createBranchOperation(repoPath, branch, startPoint string, checkout bool) tea.Cmd {
+	desc := "create branch " + branch
+	if startPoint != "" {
+		desc += " at " + shortHash(startPoint)
+	}
+	return requestOperation(operation{
+		desc:     desc,
+		repoPath: repoPath,
+		snapshot: true,
+		refresh:  refreshRepo,
+		run:      func() error { return git.CreateBranch(repoPath, branch, startPoint, checkout) },
+	})
+}
+
+func cloneOperation(scanner *workspace.Scanner, url, parentDir, name string) tea.Cmd {
+	repoPath := filepath.Join(parentDir, name)
+	output := make(chan string, 64)
+	return requestOperation(operation{
+		desc:     "clone " + name,
+		repoPath: repoPath,
+		output:   output,
+		run: func() error {
+			if err := git.Clone(parentDir, url, name, func(line string) { output <- line }); err != nil {
+				return err
+			}
+			return addClonedRepo(scanner, repoPath)
+		},
+		finish: func(m *model) tea.Cmd { return m.handleCloned(repoPath) },
+	})
+}
+
+func undoOperation(snapshot *git.Snapshot) tea.Cmd {
+	return requestOperation(operation{
+		desc:     "undo " + snapshot.Operation,
+		repoPath: snapshot.RepoPath,
+		refresh:  refreshRepo,
+		run:      func() error { return git.RestoreSnapshot(snapshot) },
+		finish: func(m *model) tea.Cmd {
+			m.undo = nil
+			return nil
+		},
+	})
+}
+
+// reload reloads the open repository. All repository loads go through here
+// (or openRepo), and the auto-refresh timer is rescheduled by the load result.
+func (m *model) reload(scope refreshScope) tea.Cmd {
+	if m.repo == nil {
+		return nil
+	}
+	switch scope {
+	case refreshStatus:
+		m.loadingRepo = true
+		return loadStatus(m.repo, m.filesFilter)
+	case refreshRepo:
+		m.loadingRepo = true
+		m.loadingMetadata = true
+		cmds := []tea.Cmd{loadRepositoryIncremental(m.repo.Path, m.commitLimit, m.filesFilter)}
+		if m.scanner != nil {
+			cmds = append(cmds, refreshRepoMetadata(m.scanner, m.repo.Path))
+		}
+		if m.currentMode == incomingMode {
+			// A fetch or pull changes what is incoming
+			cmds = append(cmds, m.startIncoming())
+		}
+		return tea.Batch(cmds...)
+	}
+	return nil
+}
+
+// timerKind identifies one of the model's recurring timers
+type timerKind int
+
+const (
+	cursorTimer      timerKind = iota // redraws the blinking text cursor
+	autoRefreshTimer                  // reloads the work tree status
+	autoScanTimer                     // rescans workspaces for new repositories
+	ciTimer                           // polls CI checks that are still running
+	autoFetchTimer                    // fetches workspace repos; the interval is configured
+	hudTimer                          // redraws the performance HUD
+	timerKinds
+)
+
+var timerIntervals = [timerKinds]time.Duration{
+	cursorTimer:      100 * time.Millisecond,
+	autoRefreshTimer: 5 * time.Second,
+	autoScanTimer:    5 * time.Minute,
+	ciTimer:          30 * time.Second,
+	hudTimer:         time.Second,
+}
+
+// timerMsg is a tick of a timer started by schedule
+type timerMsg struct {
+	kind timerKind
+	gen  int
+}
+
+// schedule (re)starts a timer. A tick still pending for the same kind is
+// dropped when it arrives, so each timer has at most one live chain no
+// matter how many code paths ask for it. Background timers don't run in
+// safe mode.
+func (m *model) schedule(kind timerKind) tea.Cmd {
+	return m.scheduleAfter(kind, timerIntervals[kind])
+}
+
+// scheduleAfter is schedule with an interval other than the kind's default
+func (m *model) scheduleAfter(kind timerKind, interval time.Duration) tea.Cmd {
+	if m.safeMode && kind != cursorTimer && kind != hudTimer {
+		return nil
+	}
+	m.timerGens[kind]++
+	gen := m.timerGens[kind]
+	return tea.Tick(interval, func(time.Time) tea.Msg {
+		return timerMsg{kind: kind, gen: gen}
+	})
+}
+
+// scheduleAutoScan starts the workspace rescan timer when there is anything to scan
+func (m *model) scheduleAutoScan() tea.Cmd {
+	if m.workspaceConfig == nil || len(m.workspaceConfig.Workspaces) == 0 {
+		return nil
+	}
+	return m.schedule(autoScanTimer)
+}
+
+// handleTimer runs the work for a current timer tick
+func (m *model) handleTimer(msg timerMsg) tea.Cmd {
+	if msg.gen != m.timerGens[msg.kind] {
+		return nil // superseded by a later schedule
+	}
+	m.timerFired[msg.kind] = time.Now()
+	switch msg.kind {
+	case cursorTimer:
+		// Keep ticking while something shows a cursor or spinner
+		if m.scanning || m.editingWorkspace || m.searchMode || m.showingModal {
+			return m.schedule(cursorTimer)
+		}
+	case autoRefreshTimer:
+		// Status only; the load result schedules the next tick
+		if !m.loadingRepo {
+			return m.reload(refreshStatus)
+		}
+	case autoScanTimer:
+		if m.scanning {
+			// The running scan reschedules when it finishes
+			return nil
+		}
+		if cmd := m.startWorkspaceScan(); cmd != nil {
+			return cmd
+		}
+		return m.scheduleAutoScan()
+	case ciTimer:
+		return m.requestVisibleChecks()
+	case autoFetchTimer:
+		// The fetch result schedules the next tick
+		return m.startAutoFetch()
+	case hudTimer:
+		if m.showHUD {
+			return m.schedule(hudTimer)
+		}
+	}
+	return nil
+}
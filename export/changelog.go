@@ -0,0 +1,157 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/asbjornb/kvist/conventional"
+)
+
+// ChangelogEntry is a commit going into a changelog
+type ChangelogEntry struct {
+	ShortHash string
+	Author    string
+	Subject   string
+	Body      string
+}
+
+// ChangelogSection is a heading of a changelog and the changes under it
+type ChangelogSection struct {
+	Title   string
+	Changes []string
+}
+
+// Changelog is the notes of one release, e.g. what changed between two tags
+type Changelog struct {
+	Title    string // e.g. "v1.2.0 (2026-10-15)" or "Unreleased"
+	Sections []ChangelogSection
+}
+
+// typeTitles names the sections of the commit types a changelog lists, in
+// the order they are listed; other types go under "Other changes"
+var typeTitles = []struct{ kind, title string }{
+	{"feat", "Features"},
+	{"fix", "Bug Fixes"},
+	{"perf", "Performance"},
+	{"revert", "Reverts"},
+	{"docs", "Documentation"},
+	{"refactor", "Refactoring"},
+}
+
+const (
+	breakingTitle = "⚠ BREAKING CHANGES"
+	otherTitle    = "Other changes"
+)
+
+// ChangelogByType groups entries by their Conventional Commits type, with
+// breaking changes listed first again on their own. Entries whose subject
+// isn't a conventional header go under "Other changes" as written.
+func ChangelogByType(title string, entries []ChangelogEntry) Changelog {
+	changes := make(map[string][]string)
+	var breaking []string
+	for _, entry := range entries {
+		msg, ok := conventional.Parse(entry.Subject, entry.Body)
+		if !ok {
+			changes[otherTitle] = append(changes[otherTitle], fmt.Sprintf("%s (%s)", entry.Subject, entry.ShortHash))
+			continue
+		}
+		change := func(text string) string {
+			if msg.Scope != "" {
+				text = "**" + msg.Scope + ":** " + text
+			}
+			return fmt.Sprintf("%s (%s)", text, entry.ShortHash)
+		}
+		if msg.Breaking() {
+			note, _, _ := strings.Cut(msg.Footer[len("BREAKING CHANGE:"):], "\n")
+			if note = strings.TrimSpace(note); note == "" {
+				note = msg.Subject
+			}
+			breaking = append(breaking, change(note))
+		}
+		sectionTitle := otherTitle
+		for _, t := range typeTitles {
+			if t.kind == msg.Type {
+				sectionTitle = t.title
+			}
+		}
+		changes[sectionTitle] = append(changes[sectionTitle], change(msg.Subject))
+	}
+
+	log := Changelog{Title: title}
+	if len(breaking) > 0 {
+		log.Sections = append(log.Sections, ChangelogSection{Title: breakingTitle, Changes: breaking})
+	}
+	for _, t := range typeTitles {
+		if len(changes[t.title]) > 0 {
+			log.Sections = append(log.Sections, ChangelogSection{Title: t.title, Changes: changes[t.title]})
+		}
+	}
+	if len(changes[otherTitle]) > 0 {
+		log.Sections = append(log.Sections, ChangelogSection{Title: otherTitle, Changes: changes[otherTitle]})
+	}
+	return log
+}
+
+// ChangelogByAuthor groups entries by who made them, authors in the order
+// of their newest change
+func ChangelogByAuthor(title string, entries []ChangelogEntry) Changelog {
+	log := Changelog{Title: title}
+	index := make(map[string]int)
+	for _, entry := range entries {
+		i, ok := index[entry.Author]
+		if !ok {
+			i = len(log.Sections)
+			index[entry.Author] = i
+			log.Sections = append(log.Sections, ChangelogSection{Title: entry.Author})
+		}
+		log.Sections[i].Changes = append(log.Sections[i].Changes, fmt.Sprintf("%s (%s)", entry.Subject, entry.ShortHash))
+	}
+	return log
+}
+
+// Markdown renders the changelog as a CHANGELOG.md entry: the title as a
+// second level heading and a list of changes under each section
+func (c Changelog) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s\n", c.Title)
+	if len(c.Sections) == 0 {
+		b.WriteString("\nNo changes.\n")
+	}
+	for _, section := range c.Sections {
+		fmt.Fprintf(&b, "\n### %s\n\n", section.Title)
+		for _, change := range section.Changes {
+			fmt.Fprintf(&b, "- %s\n", change)
+		}
+	}
+	return b.String()
+}
+
+// PrependChangelog adds the changelog at the top of the CHANGELOG.md at
+// path, below its top level heading, creating the file when there is none
+func PrependChangelog(c Changelog, path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		data, err = []byte("# Changelog\n"), nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read changelog: %w", err)
+	}
+
+	existing := string(data)
+	var heading string
+	if strings.HasPrefix(existing, "# ") {
+		heading, existing, _ = strings.Cut(existing, "\n")
+		heading += "\n\n"
+	}
+	content := heading + c.Markdown()
+	if existing = strings.TrimLeft(existing, "\n"); existing != "" {
+		content += "\n" + existing
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write changelog: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var testEntries = []ChangelogEntry{
+	{ShortHash: "a1", Author: "Ann", Subject: "feat(tree): add tree view"},
+	{ShortHash: "b2", Author: "Bo", Subject: "fix: handle empty repos"},
+	{ShortHash: "c3", Author: "Ann", Subject: "feat!: drop the old config", Body: "BREAKING CHANGE: config.toml is no longer read"},
+	{ShortHash: "d4", Author: "Bo", Subject: "Update README"},
+	{ShortHash: "e5", Author: "Ann", Subject: "chore: bump deps"},
+}
+
+func TestChangelogByType(t *testing.T) {
+	got := ChangelogByType("v1.2.0 (2026-10-15)", testEntries).Markdown()
+	want := "## v1.2.0 (2026-10-15)\n" +
+		"\n### ⚠ BREAKING CHANGES\n\n" +
+		"- config.toml is no longer read (c3)\n" +
+		"\n### Features\n\n" +
+		"- **tree:** add tree view (a1)\n" +
+		"- drop the old config (c3)\n" +
+		"\n### Bug Fixes\n\n" +
+		"- handle empty repos (b2)\n" +
+		"\n### Other changes\n\n" +
+		"- Update README (d4)\n" +
+		"- bump deps (e5)\n"
+	if got != want {
+		t.Errorf("Markdown() =\n%s\nwant\n%s", got, want)
+	}
+
+	if got := ChangelogByType("Unreleased", nil).Markdown(); got != "## Unreleased\n\nNo changes.\n" {
+		t.Errorf("Expected an empty changelog to say so, got %q", got)
+	}
+}
+
+func TestChangelogByAuthor(t *testing.T) {
+	log := ChangelogByAuthor("Unreleased", testEntries)
+	if len(log.Sections) != 2 || log.Sections[0].Title != "Ann" || log.Sections[1].Title != "Bo" {
+		t.Fatalf("Expected sections for Ann then Bo, got %+v", log.Sections)
+	}
+	if len(log.Sections[0].Changes) != 3 || log.Sections[0].Changes[1] != "feat!: drop the old config (c3)" {
+		t.Errorf("Expected Ann's changes as written, got %q", log.Sections[0].Changes)
+	}
+}
+
+func TestPrependChangelog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "CHANGELOG.md")
+	first := Changelog{Title: "v1.0.0", Sections: []ChangelogSection{{Title: "Features", Changes: []string{"one (a1)"}}}}
+	second := Changelog{Title: "v1.1.0", Sections: []ChangelogSection{{Title: "Bug Fixes", Changes: []string{"two (b2)"}}}}
+	for _, log := range []Changelog{first, second} {
+		if err := PrependChangelog(log, path); err != nil {
+			t.Fatalf("PrependChangelog failed: %v", err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read changelog: %v", err)
+	}
+	want := "# Changelog\n\n" + second.Markdown() + "\n" + first.Markdown()
+	if string(data) != want {
+		t.Errorf("CHANGELOG.md =\n%s\nwant\n%s", data, want)
+	}
+}
@@ -0,0 +1,126 @@
+package export
+
+import (
+	"path"
+	"strings"
+	"unicode"
+)
+
+// token is a piece of a line and the CSS class it is shown with, "" for
+// plain text
+type token struct {
+	Class string
+	Text  string
+}
+
+// language is what highlighting needs to know about a programming language
+type language struct {
+	keywords    map[string]bool
+	lineComment string // starts a comment running to the end of the line
+}
+
+func newLanguage(lineComment, keywords string) *language {
+	l := &language{keywords: make(map[string]bool), lineComment: lineComment}
+	for _, word := range strings.Fields(keywords) {
+		l.keywords[word] = true
+	}
+	return l
+}
+
+var (
+	goLanguage = newLanguage("//", `break case chan const continue default defer else fallthrough
+		for func go goto if import interface map package range return select struct switch type var
+		nil true false iota`)
+	cLikeLanguage = newLanguage("//", `abstract async await break case catch class const continue
+		default delete do else enum export extends false final finally for fun function if implements
+		import in instanceof interface let namespace new null override package private protected public
+		return static struct super switch this throw throws true try typedef typeof undefined union using
+		val var virtual void volatile when while yield int char long short float double bool boolean
+		string unsigned signed auto sizeof`)
+	rustLanguage = newLanguage("//", `as async await break const continue crate else enum extern false
+		fn for if impl in let loop match mod move mut pub ref return self Self static struct super trait
+		true type unsafe use where while`)
+	pythonLanguage = newLanguage("#", `and as assert async await break class continue def del elif else
+		except False finally for from global if import in is lambda None nonlocal not or pass raise
+		return True try while with yield self`)
+	rubyLanguage = newLanguage("#", `alias and begin break case class def defined do else elsif end
+		ensure false for if in module next nil not or redo rescue retry return self super then true
+		undef unless until when while yield require`)
+	shellLanguage = newLanguage("#", `case do done elif else esac export fi for function if in local
+		read return select then until while echo exit set unset`)
+)
+
+// languages are the languages highlighted, by file extension
+var languages = map[string]*language{
+	".go": goLanguage,
+	".c":  cLikeLanguage, ".h": cLikeLanguage, ".cc": cLikeLanguage, ".cpp": cLikeLanguage,
+	".hpp": cLikeLanguage, ".cs": cLikeLanguage, ".java": cLikeLanguage, ".kt": cLikeLanguage,
+	".swift": cLikeLanguage, ".js": cLikeLanguage, ".jsx": cLikeLanguage, ".mjs": cLikeLanguage,
+	".ts": cLikeLanguage, ".tsx": cLikeLanguage,
+	".rs": rustLanguage,
+	".py": pythonLanguage,
+	".rb": rubyLanguage,
+	".sh": shellLanguage, ".bash": shellLanguage, ".zsh": shellLanguage,
+}
+
+// languageOf returns the language of the file at name, nil when it isn't
+// one that is highlighted
+func languageOf(name string) *language {
+	return languages[strings.ToLower(path.Ext(name))]
+}
+
+// highlight splits a line of code into keywords, strings, numbers and
+// comments. Lines are highlighted on their own, so comments and strings
+// spanning lines are only recognised on the line they start on.
+func (l *language) highlight(line string) []token {
+	var tokens []token
+	add := func(class, text string) {
+		if n := len(tokens); n > 0 && class == "" && tokens[n-1].Class == "" {
+			tokens[n-1].Text += text
+			return
+		}
+		tokens = append(tokens, token{Class: class, Text: text})
+	}
+	isWord := func(r byte) bool {
+		return r == '_' || r < 0x80 && (unicode.IsLetter(rune(r)) || unicode.IsDigit(rune(r)))
+	}
+
+	for i := 0; i < len(line); {
+		c := line[i]
+		switch {
+		case strings.HasPrefix(line[i:], l.lineComment):
+			add("com", line[i:])
+			return tokens
+		case c == '"' || c == '\'' || c == '`':
+			end := i + 1
+			for end < len(line) && line[end] != c {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end = min(end+1, len(line))
+			add("str", line[i:end])
+			i = end
+		case isWord(c):
+			end := i
+			for end < len(line) && isWord(line[end]) {
+				end++
+			}
+			word := line[i:end]
+			switch {
+			case l.keywords[word]:
+				add("kw", word)
+			case c >= '0' && c <= '9':
+				add("num", word)
+			default:
+				add("", word)
+			}
+			i = end
+		default:
+			add("", line[i:i+1])
+			i++
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,122 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+	"strings"
+)
+
+// DiffDocument describes a diff to be rendered as a standalone HTML page
+type DiffDocument struct {
+	Title     string   // page heading, e.g. the commit subject
+	Details   []string // extra header lines such as author and date
+	Diff      string   // unified diff text
+	Truncated bool     // Diff is only the start of a diff too large to show whole
+}
+
+// diffLine is a single rendered line of a diff, its code highlighted
+type diffLine struct {
+	Class  string
+	Tokens []token
+}
+
+var pageTemplate = template.Must(template.New("diff").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #1f2328; }
+h1 { font-size: 1.3em; margin-bottom: 0.3em; }
+.details { color: #59636e; margin: 0 0 1.5em 0; padding: 0; list-style: none; }
+pre { font-family: ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; font-size: 13px; border: 1px solid #d1d9e0; border-radius: 6px; overflow-x: auto; margin: 0; }
+pre > span { display: block; padding: 0 1em; white-space: pre; }
+.file { background: #f6f8fa; font-weight: bold; border-top: 1px solid #d1d9e0; }
+.meta { color: #59636e; background: #f6f8fa; }
+.hunk { color: #0550ae; background: #ddf4ff; }
+.add { background: #dafbe1; }
+.del { background: #ffebe9; }
+.kw { color: #cf222e; }
+.str { color: #0a3069; }
+.num { color: #0550ae; }
+.com { color: #59636e; font-style: italic; }
+.truncated { color: #9a6700; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{if .Details}}<ul class="details">{{range .Details}}<li>{{.}}</li>{{end}}</ul>
+{{end}}<pre>{{range .Lines}}<span class="{{.Class}}">{{range .Tokens}}{{if .Class}}<span class="{{.Class}}">{{.Text}}</span>{{else}}{{.Text}}{{end}}{{end}}</span>{{end}}</pre>
+{{if .Truncated}}<p class="truncated">The diff was too large to export whole; only its start is shown.</p>
+{{end}}</body>
+</html>
+`))
+
+// RenderDiffHTML renders the document as a self-contained HTML page with
+// diff lines highlighted by kind, and the code in them by the language of
+// their file
+func RenderDiffHTML(doc DiffDocument) (string, error) {
+	var lines []diffLine
+	var lang *language
+	for _, line := range strings.Split(strings.TrimSuffix(doc.Diff, "\n"), "\n") {
+		class := classifyLine(line)
+		if class == "file" {
+			// diff --git a/<old> b/<new>
+			lang = nil
+			if i := strings.LastIndex(line, " b/"); i >= 0 {
+				lang = languageOf(line[i+3:])
+			}
+		}
+		tokens := []token{{Text: line}}
+		if lang != nil && line != "" && (class == "add" || class == "del" || class == "ctx") {
+			tokens = append([]token{{Text: line[:1]}}, lang.highlight(line[1:])...)
+		}
+		lines = append(lines, diffLine{Class: class, Tokens: tokens})
+	}
+
+	var buf bytes.Buffer
+	err := pageTemplate.Execute(&buf, struct {
+		Title     string
+		Details   []string
+		Lines     []diffLine
+		Truncated bool
+	}{doc.Title, doc.Details, lines, doc.Truncated})
+	if err != nil {
+		return "", fmt.Errorf("failed to render diff: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// WriteDiffHTML renders the document and writes it to path
+func WriteDiffHTML(doc DiffDocument, path string) error {
+	page, err := RenderDiffHTML(doc)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(page), 0644); err != nil {
+		return fmt.Errorf("failed to write export file: %w", err)
+	}
+	return nil
+}
+
+// classifyLine returns the CSS class for a unified diff line
+func classifyLine(line string) string {
+	switch {
+	case strings.HasPrefix(line, "diff --git"):
+		return "file"
+	case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"),
+		strings.HasPrefix(line, "index "), strings.HasPrefix(line, "new file"),
+		strings.HasPrefix(line, "deleted file"), strings.HasPrefix(line, "similarity"),
+		strings.HasPrefix(line, "rename "), strings.HasPrefix(line, "Binary files"):
+		return "meta"
+	case strings.HasPrefix(line, "@@"):
+		return "hunk"
+	case strings.HasPrefix(line, "+"):
+		return "add"
+	case strings.HasPrefix(line, "-"):
+		return "del"
+	}
+	return "ctx"
+}
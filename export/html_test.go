@@ -0,0 +1,75 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderDiffHTML(t *testing.T) {
+	doc := DiffDocument{
+		Title:   "Fix <script> handling",
+		Details: []string{"Author: Test <test@example.com>"},
+		Diff: "diff --git a/x.go b/x.go\n" +
+			"--- a/x.go\n" +
+			"+++ b/x.go\n" +
+			"@@ -1,2 +1,2 @@\n" +
+			" package x\n" +
+			"-var a = \"<b>\"\n" +
+			"+var a = \"&\"\n",
+	}
+
+	page, err := RenderDiffHTML(doc)
+	if err != nil {
+		t.Fatalf("RenderDiffHTML failed: %v", err)
+	}
+
+	for _, want := range []string{
+		"<title>Fix &lt;script&gt; handling</title>",
+		"Author: Test &lt;test@example.com&gt;",
+		`<span class="file">diff --git a/x.go b/x.go</span>`,
+		`<span class="meta">&#43;&#43;&#43; b/x.go</span>`,
+		`<span class="hunk">@@ -1,2 &#43;1,2 @@</span>`,
+		`<span class="ctx"> <span class="kw">package</span> x</span>`,
+		`<span class="del">-<span class="kw">var</span> a = <span class="str">&#34;&lt;b&gt;&#34;</span></span>`,
+		`<span class="add">&#43;<span class="kw">var</span> a = <span class="str">&#34;&amp;&#34;</span></span>`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("expected page to contain %q", want)
+		}
+	}
+	if strings.Contains(page, "<script>") || strings.Contains(page, "<b>") {
+		t.Error("expected diff content to be escaped")
+	}
+	if strings.Contains(page, `class="truncated"`) {
+		t.Error("expected no truncation note for a whole diff")
+	}
+
+	doc.Diff = "diff --git a/notes.txt b/notes.txt\n+var x = 1 // not code\n"
+	doc.Truncated = true
+	page, err = RenderDiffHTML(doc)
+	if err != nil {
+		t.Fatalf("RenderDiffHTML failed: %v", err)
+	}
+	if !strings.Contains(page, `<span class="add">&#43;var x = 1 // not code</span>`) {
+		t.Error("expected files of unknown languages left plain")
+	}
+	if !strings.Contains(page, `class="truncated"`) {
+		t.Error("expected a note that the diff was cut short")
+	}
+}
+
+func TestWriteDiffHTML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "diff.html")
+	if err := WriteDiffHTML(DiffDocument{Title: "t", Diff: "+x\n"}, path); err != nil {
+		t.Fatalf("WriteDiffHTML failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read export: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "<!DOCTYPE html>") {
+		t.Errorf("unexpected export content: %.40q", data)
+	}
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/asbjornb/kvist/export"
+	"github.com/asbjornb/kvist/forge"
+	"github.com/asbjornb/kvist/git"
+	"github.com/aymanbagabas/go-osc52/v2"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type editorFinishedMsg struct {
+	path string
+	err  error
+}
+
+// editorCommand builds the command that opens path in the user's editor,
+// positioned at line when the editor has a known way to take one
+func editorCommand(path string, line int) *exec.Cmd {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	fields := strings.Fields(editor)
+	args := fields[1:]
+
+	switch {
+	case line <= 0:
+		args = append(args, path)
+	case slices.Contains([]string{"code", "code-insiders", "codium", "cursor"}, filepath.Base(fields[0])):
+		args = append(args, "--goto", fmt.Sprintf("%s:%d", path, line))
+	case slices.Contains([]string{"hx", "helix", "subl", "zed"}, filepath.Base(fields[0])):
+		args = append(args, fmt.Sprintf("%s:%d", path, line))
+	default:
+		// vi, vim, nvim, nano, emacs, micro, kak and most others take +N
+		args = append(args, fmt.Sprintf("+%d", line), path)
+	}
+	return exec.Command(fields[0], args...)
+}
+
+// shellCommand runs a user-configured command line through the platform shell
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
+
+type repoOpenedMsg struct {
+	path string
+	err  error
+}
+
+// openRepoExternally runs the configured open command in the repository, or
+// hands the directory to the system file manager when none is configured
+func (m model) openRepoExternally(repoPath string) tea.Cmd {
+	command := ""
+	if m.workspaceConfig != nil {
+		command = m.workspaceConfig.OpenCommandFor(repoPath)
+	}
+	if command == "" {
+		return func() tea.Msg {
+			return repoOpenedMsg{path: repoPath, err: openExternal(repoPath)}
+		}
+	}
+
+	// Run in the foreground so terminal editors work; GUI launchers return right away
+	cmd := shellCommand(command)
+	cmd.Dir = repoPath
+	cmd.Env = m.commandEnv(repoPath)
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return repoOpenedMsg{path: repoPath, err: err}
+	})
+}
+
+// commandEnv returns the environment for external commands run in repoPath,
+// including variables from the repo's workspace
+func (m model) commandEnv(repoPath string) []string {
+	if m.workspaceConfig == nil {
+		return os.Environ()
+	}
+	return m.workspaceConfig.CommandEnv(repoPath)
+}
+
+type pagerFinishedMsg struct {
+	err error
+}
+
+// pageDiff shows the diff git prints for args in git's pager, which is
+// $GIT_PAGER, core.pager or $PAGER
+func pageDiff(repoPath string, args []string) tea.Cmd {
+	cmd := exec.Command("git", append([]string{"--paginate"}, args...)...)
+	cmd.Dir = repoPath
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return pagerFinishedMsg{err: err}
+	})
+}
+
+type clipboardMsg struct {
+	what string
+	err  error
+}
+
+// terminalOutput is the file the interface is drawn to. Escape sequences
+// kvist sends the terminal itself are written through it too, so they land
+// between frames instead of in the middle of one.
+type terminalOutput struct {
+	*os.File
+	mu sync.Mutex
+}
+
+func (t *terminalOutput) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.File.Write(p)
+}
+
+// terminal is where the interface is drawn: stdout, or stderr when stdout
+// is for the path printed on exit
+var terminal = &terminalOutput{File: os.Stdout}
+
+// copyToClipboard puts text on the system clipboard via an OSC52 escape
+// sequence, which the terminal handles even over SSH or inside tmux
+func copyToClipboard(what, text string) tea.Cmd {
+	return func() tea.Msg {
+		seq := osc52.New(text)
+		if os.Getenv("TMUX") != "" {
+			seq = seq.Tmux()
+		} else if strings.HasPrefix(os.Getenv("TERM"), "screen") {
+			seq = seq.Screen()
+		}
+		_, err := seq.WriteTo(terminal)
+		return clipboardMsg{what: what, err: err}
+	}
+}
+
+type diffExportedMsg struct {
+	path string
+	err  error
+}
+
+// exportDiff writes the diff as an HTML page to a new file in the temp
+// directory and opens it
+func exportDiff(doc export.DiffDocument, name string) tea.Cmd {
+	return func() tea.Msg {
+		file, err := os.CreateTemp("", "kvist-"+name+"-*.html")
+		if err != nil {
+			return diffExportedMsg{err: err}
+		}
+		path := file.Name()
+		file.Close()
+		if err := export.WriteDiffHTML(doc, path); err != nil {
+			return diffExportedMsg{path: path, err: err}
+		}
+		if err := openExternal(path); err != nil {
+			return diffExportedMsg{path: path, err: fmt.Errorf("exported but could not open browser: %w", err)}
+		}
+		return diffExportedMsg{path: path}
+	}
+}
+
+// openExternal opens a file or URL with the platform's default handler
+func openExternal(target string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", target)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", target)
+	default:
+		cmd = exec.Command("xdg-open", target)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
+
+// yankItems lists what can be copied from the current view
+func (m model) yankItems() []menuItem {
+	var items []menuItem
+	yank := func(key, label, what, text string) {
+		if text == "" {
+			return
+		}
+		items = append(items, menuItem{key: key, label: label, action: func(*model) tea.Cmd {
+			return copyToClipboard(what, text)
+		}})
+	}
+
+	switch m.currentMode {
+	case historyMode:
+		if m.selectedCommit < len(m.commits) {
+			c := m.commits[m.selectedCommit]
+			yank("h", "Commit hash", "commit hash", c.Hash)
+			yank("s", "Commit subject", "commit subject", c.Subject)
+		}
+		yank("d", "Diff", "diff", m.currentDiff)
+	case filesMode:
+		if m.status != nil && m.selectedFile < len(m.status.Files) {
+			yank("p", "File path", "file path", m.status.Files[m.selectedFile].Path)
+		}
+		yank("d", "Diff", "diff", m.currentDiff)
+	case treeMode:
+		yank("h", "Commit hash", "commit hash", m.treeRev)
+		yank("p", "File path", "file path", m.treeFilePath)
+		yank("f", "File contents", "file contents", m.treeFileContent)
+	case sizeMode:
+		if m.sizes != nil && m.selectedBlob < len(m.sizes.Largest) {
+			blob := m.sizes.Largest[m.selectedBlob]
+			yank("p", "File path", "file path", blob.Path)
+			yank("h", "Blob hash", "blob hash", blob.Hash)
+		}
+	case workspaceMode:
+		if m.selectedRepo < len(m.filteredRepos) {
+			repo := m.filteredRepos[m.selectedRepo]
+			yank("p", "Repository path", "repository path", repo.Path)
+			yank("b", "Branch name", "branch name", repo.Branch)
+		}
+	}
+	if m.repo != nil && m.currentMode != workspaceMode && m.currentMode != workspaceManageMode {
+		yank("b", "Branch name", "branch name", m.repo.CurrentBranch)
+	}
+	return items
+}
+
+// truncatedDiffHint is appended to a diff shown cut short
+func truncatedDiffHint() string {
+	return fmt.Sprintf("\n… diff truncated at %d MB (press V to view the full diff in the pager)\n", git.MaxDiffBytes>>20)
+}
+
+// currentDiffDocument describes the diff currently on screen for export
+func (m model) currentDiffDocument() (export.DiffDocument, string, bool) {
+	if m.repo == nil || m.currentDiff == "" {
+		return export.DiffDocument{}, "", false
+	}
+	repoName := filepath.Base(m.repo.Path)
+	diff, truncated := m.currentDiff, m.fullDiff != nil
+	if truncated {
+		diff = strings.TrimSuffix(diff, truncatedDiffHint())
+	}
+
+	switch m.currentMode {
+	case historyMode:
+		if m.selectedCommit >= len(m.commits) {
+			return export.DiffDocument{}, "", false
+		}
+		c := m.commits[m.selectedCommit]
+		doc := export.DiffDocument{
+			Title: c.Subject,
+			Details: []string{
+				fmt.Sprintf("%s @ %s", repoName, c.Hash),
+				fmt.Sprintf("%s <%s>, %s", c.Author, c.Email, c.Date),
+			},
+			Diff:      diff,
+			Truncated: truncated,
+		}
+		return doc, repoName + "-" + shortHash(c.Hash), true
+	case filesMode:
+		if m.status == nil || m.selectedFile >= len(m.status.Files) {
+			return export.DiffDocument{}, "", false
+		}
+		file := m.status.Files[m.selectedFile]
+		doc := export.DiffDocument{
+			Title:     file.Path,
+			Details:   []string{fmt.Sprintf("%s, working tree changes on %s", repoName, m.repo.CurrentBranch)},
+			Diff:      diff,
+			Truncated: truncated,
+		}
+		return doc, repoName + "-" + strings.ReplaceAll(file.Path, "/", "_"), true
+	}
+	return export.DiffDocument{}, "", false
+}
+
+type browserOpenedMsg struct {
+	url string
+	err error
+}
+
+// openInBrowser opens a page of the repository on its forge; page builds
+// the URL once the forge is known
+func openInBrowser(repoPath string, kinds map[string]string, page func(p forge.Provider) (string, error)) tea.Cmd {
+	return func() tea.Msg {
+		provider, err := forge.ForRepo(repoPath, kinds)
+		if err != nil {
+			return browserOpenedMsg{err: err}
+		}
+		url, err := page(provider)
+		if err != nil {
+			return browserOpenedMsg{err: err}
+		}
+		return browserOpenedMsg{url: url, err: openExternal(url)}
+	}
+}
+
+// browserItems lists the pages of the current view that can be opened in a browser
+func (m model) browserItems() []menuItem {
+	var repoPath string
+	if m.currentMode == workspaceMode {
+		if m.selectedRepo < len(m.filteredRepos) {
+			repoPath = m.filteredRepos[m.selectedRepo].Path
+		}
+	} else if m.repo != nil && m.currentMode != workspaceManageMode {
+		repoPath = m.repo.Path
+	}
+	if repoPath == "" {
+		return nil
+	}
+
+	var items []menuItem
+	kinds := m.forgeKinds()
+	open := func(key, label string, page func(p forge.Provider) (string, error)) {
+		items = append(items, menuItem{key: key, label: label, action: func(*model) tea.Cmd {
+			return openInBrowser(repoPath, kinds, page)
+		}})
+	}
+	browse := func(page forge.Page) func(forge.Provider) (string, error) {
+		return func(p forge.Provider) (string, error) {
+			return p.BrowseURL(page), nil
+		}
+	}
+	// Work tree files are shown on the current branch, or at HEAD when detached
+	workTreeFile := func(path string, line int) func(forge.Provider) (string, error) {
+		branch := m.repo.CurrentBranch
+		return func(p forge.Provider) (string, error) {
+			rev := branch
+			if rev == "" {
+				hash, err := git.ResolveCommit(repoPath, "HEAD")
+				if err != nil {
+					return "", err
+				}
+				rev = hash
+			}
+			return p.BrowseURL(forge.Page{Commit: rev, File: path, Line: line}), nil
+		}
+	}
+
+	open("r", "Repository", browse(forge.Page{}))
+	switch m.currentMode {
+	case historyMode:
+		if m.selectedCommit < len(m.commits) {
+			open("c", "Selected commit", browse(forge.Page{Commit: m.commits[m.selectedCommit].Hash}))
+		}
+	case incomingMode:
+		if m.selectedIncoming < len(m.incomingCommits) {
+			open("c", "Selected commit", browse(forge.Page{Commit: m.incomingCommits[m.selectedIncoming].Hash}))
+		}
+	case treeMode:
+		open("c", "Commit", browse(forge.Page{Commit: m.treeRev}))
+		if m.treeFilePath != "" {
+			open("f", "File at commit", browse(forge.Page{Commit: m.treeRev, File: m.treeFilePath}))
+		}
+	case filesMode:
+		if m.status != nil && m.selectedFile < len(m.status.Files) {
+			path := m.status.Files[m.selectedFile].Path
+			open("f", "Selected file", workTreeFile(path, git.FirstChangedLine(m.currentDiff)))
+		}
+	case todoMode:
+		if m.selectedTodo < len(m.todoMarkers) {
+			marker := m.todoMarkers[m.selectedTodo]
+			open("f", "File at marker", workTreeFile(marker.Path, marker.Line))
+		}
+	}
+	return items
+}
+
+// forgeKinds returns the configured forge kinds of self-hosted hosts
+func (m model) forgeKinds() map[string]string {
+	if m.workspaceConfig == nil {
+		return nil
+	}
+	return m.workspaceConfig.Forges
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/asbjornb/kvist/git"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// fileSide is the section of the files panel a change is listed in. A file
+// with both staged and unstaged changes is listed in both sections.
+type fileSide int
+
+const (
+	conflictSide fileSide = iota
+	stagedSide
+	unstagedSide
+	untrackedSide
+	ignoredSide
+	numSides
+)
+
+func (s fileSide) String() string {
+	return [...]string{"Conflicts", "Staged", "Unstaged", "Untracked", "Ignored"}[s]
+}
+
+// fileSides returns the sections file is listed in
+func fileSides(file git.FileStatus) []fileSide {
+	switch {
+	case file.Conflict != "":
+		return []fileSide{conflictSide}
+	case file.Unstaged == "untracked":
+		return []fileSide{untrackedSide}
+	case file.Unstaged == "ignored":
+		return []fileSide{ignoredSide}
+	}
+	var sides []fileSide
+	if file.Staged != "" {
+		sides = append(sides, stagedSide)
+	}
+	if file.Unstaged != "" {
+		sides = append(sides, unstagedSide)
+	}
+	return sides
+}
+
+// fileGroup is a section of the files panel, or a directory within one
+type fileGroup struct {
+	side fileSide
+	dir  string // ending in /; empty for the whole section
+}
+
+// fileRow is a line of the files panel: a section header, a directory in
+// tree view, or a file
+type fileRow struct {
+	side  fileSide
+	dir   string // directory path ending in /, empty for other rows
+	file  int    // index in the status files, -1 for headers and directories
+	depth int
+	count int // changed files in a section or under a directory
+}
+
+// group returns the section or directory of a header or directory row
+func (r fileRow) group() fileGroup {
+	return fileGroup{side: r.side, dir: r.dir}
+}
+
+// fileRows lays out files in sections, flat or as directory trees, leaving
+// out what is inside collapsed sections and directories
+func fileRows(files []git.FileStatus, tree bool, collapsed map[fileGroup]bool) []fileRow {
+	var sections [numSides][]int
+	for i, file := range files {
+		for _, side := range fileSides(file) {
+			sections[side] = append(sections[side], i)
+		}
+	}
+
+	var rows []fileRow
+	for side, members := range sections {
+		if len(members) == 0 {
+			continue
+		}
+		side := fileSide(side)
+		rows = append(rows, fileRow{side: side, file: -1, count: len(members)})
+		switch {
+		case collapsed[fileGroup{side: side}]:
+		case tree:
+			rows = append(rows, fileTree(files, members, side, collapsed)...)
+		default:
+			for _, i := range members {
+				rows = append(rows, fileRow{side: side, file: i, depth: 1})
+			}
+		}
+	}
+	return rows
+}
+
+// fileTree lays out the members of a section as a directory tree, leaving
+// out what is inside collapsed directories
+func fileTree(files []git.FileStatus, members []int, side fileSide, collapsed map[fileGroup]bool) []fileRow {
+	order := append([]int(nil), members...)
+	sort.SliceStable(order, func(a, b int) bool { return files[order[a]].Path < files[order[b]].Path })
+
+	counts := make(map[string]int)
+	for _, i := range members {
+		for _, dir := range parentDirs(files[i].Path) {
+			counts[dir]++
+		}
+	}
+
+	var rows []fileRow
+	var open []string // directories of the previous file
+	for _, i := range order {
+		dirs := parentDirs(files[i].Path)
+		shared := 0
+		for shared < len(open) && shared < len(dirs) && open[shared] == dirs[shared] {
+			shared++
+		}
+		hidden := false
+		for depth, dir := range dirs {
+			if depth >= shared && !hidden {
+				rows = append(rows, fileRow{side: side, dir: dir, file: -1, depth: depth + 1, count: counts[dir]})
+			}
+			if collapsed[fileGroup{side: side, dir: dir}] {
+				hidden = true
+			}
+		}
+		if !hidden {
+			rows = append(rows, fileRow{side: side, file: i, depth: len(dirs) + 1})
+		}
+		open = dirs
+	}
+	return rows
+}
+
+// parentDirs returns the directories path is in, outermost first, each
+// ending in /
+func parentDirs(path string) []string {
+	parts := strings.Split(strings.TrimSuffix(path, "/"), "/")
+	dirs := make([]string, 0, len(parts)-1)
+	for i := 1; i < len(parts); i++ {
+		dirs = append(dirs, strings.Join(parts[:i], "/")+"/")
+	}
+	return dirs
+}
+
+// fileRows returns the rows of the files panel
+func (m model) fileRows() []fileRow {
+	if m.status == nil {
+		return nil
+	}
+	return fileRows(m.status.Files, m.fileTreeView, m.collapsedGroups)
+}
+
+// fileSelection is what is selected in the files panel, by name rather than
+// by position, so it can be found again after the status changes
+type fileSelection struct {
+	path  string
+	side  fileSide
+	group *fileGroup
+}
+
+func (m model) fileSelection() fileSelection {
+	sel := fileSelection{side: m.selectedSide, group: m.selectedGroup}
+	if m.status != nil && m.selectedFile < len(m.status.Files) {
+		sel.path = m.status.Files[m.selectedFile].Path
+	}
+	return sel
+}
+
+// isRow reports whether row shows what sel names
+func (m model) isRow(row fileRow, sel fileSelection) bool {
+	if sel.group != nil {
+		return row.file < 0 && row.group() == *sel.group
+	}
+	return row.file >= 0 && row.side == sel.side && m.status.Files[row.file].Path == sel.path
+}
+
+// listedIn reports whether the file at path is listed in the section side
+func (m model) listedIn(path string, side fileSide) bool {
+	for _, file := range m.status.Files {
+		if file.Path == path && slices.Contains(fileSides(file), side) {
+			return true
+		}
+	}
+	return false
+}
+
+// syncFileRow points the selection at the row of sel. A file hidden in a
+// collapsed section or directory selects that instead, and when sel is gone,
+// what is in its place is selected.
+func (m *model) syncFileRow(sel fileSelection) {
+	rows := m.fileRows()
+	for i, row := range rows {
+		if m.isRow(row, sel) {
+			m.selectedRow = i
+			m.selectFileRow()
+			return
+		}
+	}
+	if sel.group == nil && sel.path != "" && m.listedIn(sel.path, sel.side) {
+		for i, row := range rows {
+			if row.side == sel.side && row.file < 0 && m.collapsedGroups[row.group()] && strings.HasPrefix(sel.path, row.dir) {
+				m.selectedRow = i
+				m.selectFileRow()
+				return
+			}
+		}
+	}
+	if sel.path == "" && sel.group == nil {
+		m.resetFileSelection()
+		return
+	}
+	m.selectFileRow()
+}
+
+// selectFileRow makes the row at m.selectedRow the selection. A header or
+// directory leaves no file selected, so file actions don't apply to it.
+func (m *model) selectFileRow() {
+	rows := m.fileRows()
+	if len(rows) == 0 {
+		m.selectedRow, m.selectedFile, m.selectedGroup = 0, 0, nil
+		return
+	}
+	m.selectedRow = min(m.selectedRow, len(rows)-1)
+	row := rows[m.selectedRow]
+	m.selectedSide = row.side
+	if row.file >= 0 {
+		m.selectedFile, m.selectedGroup = row.file, nil
+	} else {
+		group := row.group()
+		m.selectedFile, m.selectedGroup = len(m.status.Files), &group
+	}
+}
+
+// resetFileSelection selects the first file of the files panel
+func (m *model) resetFileSelection() {
+	m.selectedFile, m.selectedRow, m.selectedGroup = 0, 0, nil
+	for i, row := range m.fileRows() {
+		if row.file >= 0 {
+			m.selectedRow = i
+			break
+		}
+	}
+	m.selectFileRow()
+}
+
+// loadSelectedDiff loads the diff of the selected file, staged or not
+// depending on its section
+func (m *model) loadSelectedDiff() tea.Cmd {
+	if m.repo == nil || m.status == nil || m.selectedFile >= len(m.status.Files) {
+		m.currentDiff = ""
+		return nil
+	}
+	ctx, gen := m.newDiffRequest()
+	return loadDiff(ctx, gen, m.repo.Path, m.status.Files[m.selectedFile], m.selectedSide == stagedSide)
+}
+
+// toggleFileTree switches the files panel between flat lists and trees
+func (m *model) toggleFileTree() tea.Cmd {
+	sel := m.fileSelection()
+	m.fileTreeView = !m.fileTreeView
+	if sel.group == nil || sel.group.dir == "" {
+		m.syncFileRow(sel)
+		return nil
+	}
+	// Back to the first file of the directory that was selected
+	files := m.groupFiles(*sel.group)
+	if len(files) > 0 {
+		sel = fileSelection{path: files[0].Path, side: sel.side}
+	}
+	m.syncFileRow(sel)
+	m.diffScrollOffset = 0
+	return m.loadSelectedDiff()
+}
+
+// setGroupCollapsed collapses or expands the selected section or directory.
+// Collapsing with a file selected collapses the directory it is in, or its
+// section in the flat view.
+func (m *model) setGroupCollapsed(collapse bool) tea.Cmd {
+	var group fileGroup
+	switch {
+	case m.selectedGroup != nil:
+		group = *m.selectedGroup
+	case collapse && m.status != nil && m.selectedFile < len(m.status.Files):
+		group = fileGroup{side: m.selectedSide}
+		if dirs := parentDirs(m.status.Files[m.selectedFile].Path); m.fileTreeView && len(dirs) > 0 {
+			group.dir = dirs[len(dirs)-1]
+		}
+	default:
+		return nil
+	}
+	if m.collapsedGroups == nil {
+		m.collapsedGroups = make(map[fileGroup]bool)
+	}
+	if collapse {
+		m.collapsedGroups[group] = true
+	} else {
+		delete(m.collapsedGroups, group)
+	}
+	m.syncFileRow(fileSelection{side: group.side, group: &group})
+	return nil
+}
+
+// renderFileRows renders the rows of the files panel that fit in height,
+// keeping the selection in sight
+func (m model) renderFileRows(width, height int, mark func(git.FileStatus, fileSide) string, itemStyle, selectedStyle, headerStyle, dirStyle lipgloss.Style) []string {
+	rows := m.fileRows()
+	start := 0
+	if m.selectedRow >= height {
+		start = m.selectedRow - height + 1
+	}
+	var lines []string
+	for i := start; i < min(start+height, len(rows)); i++ {
+		row := rows[i]
+		indent := strings.Repeat("  ", row.depth)
+		arrow := "▾"
+		if m.collapsedGroups[row.group()] {
+			arrow = "▸"
+		}
+		var line string
+		switch {
+		case row.file < 0 && row.dir == "":
+			line = fmt.Sprintf(" %s %s %s", arrow, headerStyle.Render(row.side.String()), dirStyle.Render(fmt.Sprintf("(%d)", row.count)))
+		case row.file < 0:
+			line = fmt.Sprintf(" %s%s %s %s", indent, arrow, path.Base(row.dir)+"/", dirStyle.Render(fmt.Sprintf("(%d)", row.count)))
+		case m.fileTreeView:
+			file := m.status.Files[row.file]
+			name := path.Base(file.Path)
+			if strings.HasSuffix(file.Path, "/") {
+				name += "/"
+			}
+			if file.OldPath != "" {
+				name += fmt.Sprintf(" (from %s, %d%%)", file.OldPath, file.Similarity)
+			}
+			line = fmt.Sprintf(" %s%s %s", indent, mark(file, row.side), name)
+		default:
+			file := m.status.Files[row.file]
+			name := file.Path
+			// Handle renames - show "old -> new" and how alike they are
+			if file.OldPath != "" {
+				name = fmt.Sprintf("%s -> %s (%d%%)", file.OldPath, file.Path, file.Similarity)
+			}
+			if len(name) > width-11 {
+				name = "..." + name[len(name)-(width-14):]
+			}
+			line = fmt.Sprintf(" %s%s %s", indent, mark(file, row.side), name)
+		}
+		style := itemStyle
+		if m.activePanel == topPanel && m.currentMode == filesMode && i == m.selectedRow {
+			style = selectedStyle
+		}
+		lines = append(lines, style.Width(width-2).MaxHeight(1).Render(line))
+	}
+	return lines
+}
+
+// groupFiles returns the changed files in a section or under a directory of it
+func (m model) groupFiles(group fileGroup) []git.FileStatus {
+	var files []git.FileStatus
+	if m.status != nil {
+		for _, file := range m.status.Files {
+			if strings.HasPrefix(file.Path, group.dir) && slices.Contains(fileSides(file), group.side) {
+				files = append(files, file)
+			}
+		}
+	}
+	return files
+}
+
+// stageGroupOperation unstages the files of a group in the staged section
+// and stages them anywhere else, which marks conflicts resolved
+func stageGroupOperation(repoPath string, group fileGroup, files []git.FileStatus) tea.Cmd {
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, file.Path)
+		if group.side == stagedSide && file.OldPath != "" {
+			// The rename's deletion is staged too
+			paths = append(paths, file.OldPath)
+		}
+	}
+	name := group.dir
+	if name == "" {
+		name = strings.ToLower(group.side.String()) + " files"
+	}
+	op := operation{repoPath: repoPath, refresh: refreshRepo}
+	switch group.side {
+	case stagedSide:
+		op.desc = "unstage " + name
+		op.run = func() error { return git.UnstagePaths(repoPath, paths) }
+	case conflictSide:
+		op.desc = "mark " + name + " resolved"
+		op.run = func() error { return git.StagePaths(repoPath, paths) }
+	default:
+		op.desc = "stage " + name
+		op.run = func() error { return git.StagePaths(repoPath, paths) }
+	}
+	return requestOperation(op)
+}
+
+// groupSummary describes the selected section or directory in place of a diff
+func (m model) groupSummary() []string {
+	group := *m.selectedGroup
+	files := m.groupFiles(group)
+	action := "stage"
+	switch group.side {
+	case stagedSide:
+		action = "unstage"
+	case conflictSide:
+		action = "mark resolved"
+	}
+	summary := fmt.Sprintf("  %d %s files", len(files), strings.ToLower(group.side.String()))
+	if group.dir != "" {
+		summary = fmt.Sprintf("  📁 %s: %d %s files", group.dir, len(files), strings.ToLower(group.side.String()))
+	}
+	lines := []string{summary, ""}
+	if group.side == conflictSide {
+		for _, file := range files {
+			lines = append(lines, fmt.Sprintf("    %s (%s)", file.Path, file.Conflict))
+		}
+		lines = append(lines, "")
+	}
+	return append(lines, fmt.Sprintf("  space/enter: %s all • ←/→: collapse/expand", action))
+}
@@ -0,0 +1,38 @@
+package forge
+
+import (
+	"context"
+	"strconv"
+)
+
+// bitbucketProvider only knows Bitbucket's web pages; its API needs app
+// passwords and isn't wired up
+type bitbucketProvider struct {
+	home string // web page of the repository
+}
+
+func (p *bitbucketProvider) ListPRs(context.Context) ([]PullRequest, error) {
+	return nil, ErrUnsupported
+}
+
+func (p *bitbucketProvider) CreatePR(context.Context, NewPullRequest) (*PullRequest, error) {
+	return nil, ErrUnsupported
+}
+
+func (p *bitbucketProvider) GetChecks(context.Context, string) (State, error) {
+	return StateNone, ErrUnsupported
+}
+
+func (p *bitbucketProvider) BrowseURL(page Page) string {
+	switch {
+	case page.File != "":
+		u := p.home + "/src/" + filePath(page.Commit, page.File)
+		if page.Line > 0 {
+			u += "#lines-" + strconv.Itoa(page.Line)
+		}
+		return u
+	case page.Commit != "":
+		return p.home + "/commits/" + page.Commit
+	}
+	return p.home
+}
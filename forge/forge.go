@@ -0,0 +1,198 @@
+package forge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/asbjornb/kvist/git"
+)
+
+// ErrUnsupported is returned for hosts without a known API, and by
+// providers for features their host doesn't offer
+var ErrUnsupported = errors.New("host not supported")
+
+// State is the combined CI result of a commit
+type State string
+
+const (
+	StateNone    State = ""        // nothing reported for the commit
+	StatePending State = "pending" // queued or running
+	StateSuccess State = "success"
+	StateFailure State = "failure"
+)
+
+// Final reports whether the state can no longer change without a re-run
+func (s State) Final() bool {
+	return s == StateSuccess || s == StateFailure
+}
+
+// combine merges the states of several checks: any failure fails the
+// commit, otherwise anything unfinished keeps it pending
+func combine(states ...State) State {
+	result := StateNone
+	for _, s := range states {
+		switch {
+		case s == StateFailure:
+			return StateFailure
+		case s == StatePending:
+			result = StatePending
+		case s == StateSuccess && result == StateNone:
+			result = StateSuccess
+		}
+	}
+	return result
+}
+
+// PullRequest is an open pull request (a merge request on GitLab)
+type PullRequest struct {
+	Number int
+	Title  string
+	Author string
+	Branch string // source branch
+	Base   string // target branch
+	URL    string
+	Draft  bool
+}
+
+// NewPullRequest describes a pull request to open
+type NewPullRequest struct {
+	Title  string
+	Body   string
+	Branch string
+	Base   string
+}
+
+// Page is a page of a repository's web interface. The zero Page is the
+// repository's home page.
+type Page struct {
+	Commit string // commit to show, or the revision of File
+	File   string
+	Line   int // anchor within File; 0 for none
+}
+
+// Provider is the web API of the forge hosting a repository
+type Provider interface {
+	ListPRs(ctx context.Context) ([]PullRequest, error)
+	CreatePR(ctx context.Context, pr NewPullRequest) (*PullRequest, error)
+	GetChecks(ctx context.Context, sha string) (State, error)
+	BrowseURL(page Page) string
+}
+
+// For returns the provider for a remote. kinds maps host names to forge
+// kinds ("github", "gitlab", "gitea" or "bitbucket") for self-hosted
+// instances whose name doesn't give them away.
+func For(web *git.RemoteWeb, kinds map[string]string) (Provider, error) {
+	kind := web.Kind
+	if k, ok := kinds[web.Host]; ok {
+		kind = k
+	}
+	home := "https://" + web.Host + "/" + web.Path
+	switch kind {
+	case "github":
+		api := "https://" + web.Host + "/api/v3" // GitHub Enterprise
+		if web.Host == "github.com" {
+			api = "https://api.github.com"
+		}
+		return &githubProvider{home: home, api: api, repo: web.Path}, nil
+	case "gitlab":
+		return &gitlabProvider{home: home, api: "https://" + web.Host + "/api/v4", repo: web.Path}, nil
+	case "gitea":
+		// Forgejo keeps Gitea's API
+		return &giteaProvider{home: home, api: "https://" + web.Host + "/api/v1", repo: web.Path}, nil
+	case "bitbucket":
+		return &bitbucketProvider{home: home}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupported, web.Host)
+}
+
+// ForRepo returns the provider for a repository's origin remote
+func ForRepo(repoPath string, kinds map[string]string) (Provider, error) {
+	web, err := git.GetRemoteWeb(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	return For(web, kinds)
+}
+
+// filePath joins a revision and a file path for use in a URL. Branch names
+// such as feature/x keep their slashes, as the forges expect.
+func filePath(rev, file string) string {
+	return escapePath(rev) + "/" + escapePath(file)
+}
+
+// escapePath escapes each slash-separated segment of p for use in a URL
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// doJSON sends a request with an optional JSON body and decodes the JSON
+// response into v
+func doJSON(ctx context.Context, method, endpoint string, header http.Header, body any, v any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("%s %s: %s", method, strings.SplitN(endpoint, "?", 2)[0], resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// pullJSON is a pull request as returned by the GitHub and Gitea APIs
+type pullJSON struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	URL    string `json:"html_url"`
+	Draft  bool   `json:"draft"`
+	User   struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	Head struct {
+		Ref string `json:"ref"`
+	} `json:"head"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+}
+
+func (p pullJSON) pullRequest() PullRequest {
+	return PullRequest{
+		Number: p.Number,
+		Title:  p.Title,
+		Author: p.User.Login,
+		Branch: p.Head.Ref,
+		Base:   p.Base.Ref,
+		URL:    p.URL,
+		Draft:  p.Draft,
+	}
+}
@@ -0,0 +1,270 @@
+package forge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/asbjornb/kvist/git"
+)
+
+func TestCombine(t *testing.T) {
+	tests := []struct {
+		name   string
+		states []State
+		want   State
+	}{
+		{"nothing", nil, StateNone},
+		{"all green", []State{StateSuccess, StateSuccess}, StateSuccess},
+		{"running", []State{StateSuccess, StatePending}, StatePending},
+		{"failure wins", []State{StatePending, StateFailure, StateSuccess}, StateFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := combine(tt.states...); got != tt.want {
+				t.Errorf("combine() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReleaseNewerThan(t *testing.T) {
+	tests := []struct {
+		latest, current string
+		want            bool
+	}{
+		{"v1.2.0", "v1.1.9", true},
+		{"v1.2.0", "v1.2.0", false},
+		{"v1.1.0", "v1.2.0", false},
+		{"v1.10.0", "v1.9.0", true},
+		{"1.2.0", "v1.2.0", false},
+		{"v1.2.1", "1.2.0", true},
+		{"v1.2", "v1.2.0", false},
+		{"v1.2.0.1", "v1.2", true},
+		{"v2", "v1.9.9", true},
+		{"v1.2.0", "v1.2.0-rc1", true},
+		{"v1.2.0-rc2", "v1.2.0", false},
+		{"v1.2.0-rc2", "v1.2.0-rc1", false},
+		{"v1.3.0-rc1", "v1.2.0", true},
+		{"v1.2.0+build5", "v1.2.0", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.latest+" vs "+tt.current, func(t *testing.T) {
+			if got := (Release{Tag: tt.latest}).NewerThan(tt.current); got != tt.want {
+				t.Errorf("NewerThan() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFor(t *testing.T) {
+	web := &git.RemoteWeb{Host: "git.example.com", Path: "o/r"}
+	if _, err := For(web, nil); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("For() unknown host error = %v, want ErrUnsupported", err)
+	}
+	p, err := For(web, map[string]string{"git.example.com": "gitea"})
+	if err != nil {
+		t.Fatalf("For() error: %v", err)
+	}
+	if gitea, ok := p.(*giteaProvider); !ok || gitea.api != "https://git.example.com/api/v1" {
+		t.Errorf("For() = %#v, want the Gitea API of git.example.com", p)
+	}
+}
+
+func TestBrowseURL(t *testing.T) {
+	const hash = "0123456789abcdef0123456789abcdef01234567"
+	tests := []struct {
+		provider Provider
+		home     string
+		commit   string
+		file     string
+	}{
+		{
+			&githubProvider{home: "https://github.com/o/r"},
+			"https://github.com/o/r",
+			"https://github.com/o/r/commit/" + hash,
+			"https://github.com/o/r/blob/main/dir/my%20file.go#L7",
+		},
+		{
+			&gitlabProvider{home: "https://gitlab.com/g/o/r"},
+			"https://gitlab.com/g/o/r",
+			"https://gitlab.com/g/o/r/-/commit/" + hash,
+			"https://gitlab.com/g/o/r/-/blob/main/dir/my%20file.go#L7",
+		},
+		{
+			&giteaProvider{home: "https://codeberg.org/o/r"},
+			"https://codeberg.org/o/r",
+			"https://codeberg.org/o/r/commit/" + hash,
+			"https://codeberg.org/o/r/src/branch/main/dir/my%20file.go#L7",
+		},
+		{
+			&bitbucketProvider{home: "https://bitbucket.org/o/r"},
+			"https://bitbucket.org/o/r",
+			"https://bitbucket.org/o/r/commits/" + hash,
+			"https://bitbucket.org/o/r/src/main/dir/my%20file.go#lines-7",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.home, func(t *testing.T) {
+			if got := tt.provider.BrowseURL(Page{}); got != tt.home {
+				t.Errorf("BrowseURL(home) = %s, want %s", got, tt.home)
+			}
+			if got := tt.provider.BrowseURL(Page{Commit: hash}); got != tt.commit {
+				t.Errorf("BrowseURL(commit) = %s, want %s", got, tt.commit)
+			}
+			if got := tt.provider.BrowseURL(Page{Commit: "main", File: "dir/my file.go", Line: 7}); got != tt.file {
+				t.Errorf("BrowseURL(file) = %s, want %s", got, tt.file)
+			}
+		})
+	}
+
+	gitea := &giteaProvider{home: "https://codeberg.org/o/r"}
+	if got, want := gitea.BrowseURL(Page{Commit: hash, File: "f"}), "https://codeberg.org/o/r/src/commit/"+hash+"/f"; got != want {
+		t.Errorf("BrowseURL(file at commit) = %s, want %s", got, want)
+	}
+	github := &githubProvider{home: "https://github.com/o/r"}
+	if got, want := github.BrowseURL(Page{Commit: "feature/a b", File: "f"}), "https://github.com/o/r/blob/feature/a%20b/f"; got != want {
+		t.Errorf("BrowseURL(file on a branch with a slash) = %s, want %s", got, want)
+	}
+}
+
+func TestGitHubProvider(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "secret")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("missing token on %s", r.URL.Path)
+		}
+		switch r.Method + " " + r.URL.Path {
+		case "GET /repos/o/r/commits/abc/status":
+			w.Write([]byte(`{"state": "pending", "total_count": 0}`))
+		case "GET /repos/o/r/commits/abc/check-runs":
+			w.Write([]byte(`{"check_runs": [
+				{"status": "completed", "conclusion": "success"},
+				{"status": "completed", "conclusion": "skipped"}
+			]}`))
+		case "GET /repos/o/r/pulls":
+			w.Write([]byte(`[{"number": 7, "title": "Add tree view", "user": {"login": "ann"},
+				"head": {"ref": "tree"}, "base": {"ref": "main"}, "html_url": "https://github.com/o/r/pull/7"}]`))
+		case "GET /repos/o/r/releases/latest":
+			w.Write([]byte(`{"tag_name": "v1.2.0", "html_url": "https://github.com/o/r/releases/tag/v1.2.0"}`))
+		case "POST /repos/o/r/pulls":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["head"] != "feature" || body["base"] != "main" {
+				t.Errorf("CreatePR body = %v", body)
+			}
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"number": 8, "title": "Feature", "html_url": "https://github.com/o/r/pull/8"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	p := &githubProvider{api: server.URL, repo: "o/r"}
+	ctx := context.Background()
+
+	state, err := p.GetChecks(ctx, "abc")
+	if err != nil {
+		t.Fatalf("GetChecks() error: %v", err)
+	}
+	// An empty combined status reports "pending" and must not count
+	if state != StateSuccess {
+		t.Errorf("GetChecks() = %q, want %q", state, StateSuccess)
+	}
+
+	prs, err := p.ListPRs(ctx)
+	if err != nil {
+		t.Fatalf("ListPRs() error: %v", err)
+	}
+	want := PullRequest{Number: 7, Title: "Add tree view", Author: "ann", Branch: "tree", Base: "main", URL: "https://github.com/o/r/pull/7"}
+	if len(prs) != 1 || prs[0] != want {
+		t.Errorf("ListPRs() = %+v, want [%+v]", prs, want)
+	}
+
+	pr, err := p.CreatePR(ctx, NewPullRequest{Title: "Feature", Branch: "feature", Base: "main"})
+	if err != nil {
+		t.Fatalf("CreatePR() error: %v", err)
+	}
+	if pr.Number != 8 {
+		t.Errorf("CreatePR() number = %d, want 8", pr.Number)
+	}
+
+	release, err := p.latestRelease(ctx)
+	if err != nil {
+		t.Fatalf("latestRelease() error: %v", err)
+	}
+	if release.Tag != "v1.2.0" || release.URL != "https://github.com/o/r/releases/tag/v1.2.0" {
+		t.Errorf("latestRelease() = %+v", release)
+	}
+
+	missing := &githubProvider{api: server.URL, repo: "o/missing"}
+	if _, err := missing.GetChecks(ctx, "abc"); err == nil {
+		t.Error("GetChecks() should fail for an unknown repository")
+	}
+}
+
+func TestGitLabProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.EscapedPath() {
+		case "GET /projects/g%2Fr/repository/commits/abc":
+			w.Write([]byte(`{"last_pipeline": {"status": "running"}}`))
+		case "GET /projects/g%2Fr/repository/commits/def":
+			w.Write([]byte(`{"last_pipeline": null}`))
+		case "GET /projects/g%2Fr/merge_requests":
+			w.Write([]byte(`[{"iid": 3, "title": "Fix", "author": {"username": "bo"},
+				"source_branch": "fix", "target_branch": "main", "web_url": "u", "draft": true}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	p := &gitlabProvider{api: server.URL, repo: "g/r"}
+	ctx := context.Background()
+
+	for sha, want := range map[string]State{"abc": StatePending, "def": StateNone} {
+		state, err := p.GetChecks(ctx, sha)
+		if err != nil {
+			t.Fatalf("GetChecks(%s) error: %v", sha, err)
+		}
+		if state != want {
+			t.Errorf("GetChecks(%s) = %q, want %q", sha, state, want)
+		}
+	}
+
+	prs, err := p.ListPRs(ctx)
+	if err != nil {
+		t.Fatalf("ListPRs() error: %v", err)
+	}
+	want := PullRequest{Number: 3, Title: "Fix", Author: "bo", Branch: "fix", Base: "main", URL: "u", Draft: true}
+	if len(prs) != 1 || prs[0] != want {
+		t.Errorf("ListPRs() = %+v, want [%+v]", prs, want)
+	}
+}
+
+func TestGiteaProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/o/r/commits/abc/status":
+			w.Write([]byte(`{"state": "failure", "total_count": 2}`))
+		case "/repos/o/r/commits/def/status":
+			w.Write([]byte(`{"state": "", "total_count": 0}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	p := &giteaProvider{api: server.URL, repo: "o/r"}
+
+	for sha, want := range map[string]State{"abc": StateFailure, "def": StateNone} {
+		state, err := p.GetChecks(context.Background(), sha)
+		if err != nil {
+			t.Fatalf("GetChecks(%s) error: %v", sha, err)
+		}
+		if state != want {
+			t.Errorf("GetChecks(%s) = %q, want %q", sha, state, want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package forge
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+)
+
+type giteaProvider struct {
+	home string // web page of the repository
+	api  string // API base URL
+	repo string // owner/repo
+}
+
+// header authenticates with GITEA_TOKEN (or FORGEJO_TOKEN) when it is set
+func (p *giteaProvider) header() http.Header {
+	header := http.Header{"Accept": {"application/json"}}
+	token := os.Getenv("GITEA_TOKEN")
+	if token == "" {
+		token = os.Getenv("FORGEJO_TOKEN")
+	}
+	if token != "" {
+		header.Set("Authorization", "token "+token)
+	}
+	return header
+}
+
+func (p *giteaProvider) ListPRs(ctx context.Context) ([]PullRequest, error) {
+	var pulls []pullJSON
+	endpoint := p.api + "/repos/" + p.repo + "/pulls?state=open&limit=50"
+	if err := doJSON(ctx, http.MethodGet, endpoint, p.header(), nil, &pulls); err != nil {
+		return nil, err
+	}
+	prs := make([]PullRequest, len(pulls))
+	for i, pull := range pulls {
+		prs[i] = pull.pullRequest()
+	}
+	return prs, nil
+}
+
+func (p *giteaProvider) CreatePR(ctx context.Context, pr NewPullRequest) (*PullRequest, error) {
+	body := map[string]any{"title": pr.Title, "body": pr.Body, "head": pr.Branch, "base": pr.Base}
+	var pull pullJSON
+	if err := doJSON(ctx, http.MethodPost, p.api+"/repos/"+p.repo+"/pulls", p.header(), body, &pull); err != nil {
+		return nil, err
+	}
+	created := pull.pullRequest()
+	return &created, nil
+}
+
+// GetChecks reads the combined commit status; Gitea Actions report through it too
+func (p *giteaProvider) GetChecks(ctx context.Context, sha string) (State, error) {
+	var status struct {
+		State      string `json:"state"`
+		TotalCount int    `json:"total_count"`
+	}
+	endpoint := p.api + "/repos/" + p.repo + "/commits/" + url.PathEscape(sha) + "/status"
+	if err := doJSON(ctx, http.MethodGet, endpoint, p.header(), nil, &status); err != nil {
+		return StateNone, err
+	}
+	if status.TotalCount == 0 {
+		return StateNone, nil
+	}
+	switch status.State {
+	case "success", "warning":
+		return StateSuccess, nil
+	case "pending":
+		return StatePending, nil
+	}
+	return StateFailure, nil
+}
+
+func (p *giteaProvider) BrowseURL(page Page) string {
+	switch {
+	case page.File != "":
+		// Gitea needs to know whether the revision is a commit or a branch
+		kind := "/src/branch/"
+		if isCommitHash(page.Commit) {
+			kind = "/src/commit/"
+		}
+		u := p.home + kind + filePath(page.Commit, page.File)
+		if page.Line > 0 {
+			u += "#L" + strconv.Itoa(page.Line)
+		}
+		return u
+	case page.Commit != "":
+		return p.home + "/commit/" + page.Commit
+	}
+	return p.home
+}
+
+func isCommitHash(rev string) bool {
+	if len(rev) != 40 {
+		return false
+	}
+	for _, c := range rev {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
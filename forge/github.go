@@ -0,0 +1,171 @@
+package forge
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type githubProvider struct {
+	home string // web page of the repository
+	api  string // API base URL
+	repo string // owner/repo
+}
+
+// header authenticates with GITHUB_TOKEN or GH_TOKEN; public repositories
+// work without them, within the anonymous rate limit
+func (p *githubProvider) header() http.Header {
+	header := http.Header{"Accept": {"application/vnd.github+json"}}
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" {
+		token = os.Getenv("GH_TOKEN")
+	}
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+	return header
+}
+
+func (p *githubProvider) ListPRs(ctx context.Context) ([]PullRequest, error) {
+	var pulls []pullJSON
+	endpoint := p.api + "/repos/" + p.repo + "/pulls?state=open&per_page=100"
+	if err := doJSON(ctx, http.MethodGet, endpoint, p.header(), nil, &pulls); err != nil {
+		return nil, err
+	}
+	prs := make([]PullRequest, len(pulls))
+	for i, pull := range pulls {
+		prs[i] = pull.pullRequest()
+	}
+	return prs, nil
+}
+
+func (p *githubProvider) CreatePR(ctx context.Context, pr NewPullRequest) (*PullRequest, error) {
+	body := map[string]any{"title": pr.Title, "body": pr.Body, "head": pr.Branch, "base": pr.Base}
+	var pull pullJSON
+	if err := doJSON(ctx, http.MethodPost, p.api+"/repos/"+p.repo+"/pulls", p.header(), body, &pull); err != nil {
+		return nil, err
+	}
+	created := pull.pullRequest()
+	return &created, nil
+}
+
+// GetChecks combines the legacy commit statuses with check runs, since
+// projects use either or both
+func (p *githubProvider) GetChecks(ctx context.Context, sha string) (State, error) {
+	base := p.api + "/repos/" + p.repo + "/commits/" + url.PathEscape(sha)
+
+	var status struct {
+		State      string `json:"state"`
+		TotalCount int    `json:"total_count"`
+	}
+	if err := doJSON(ctx, http.MethodGet, base+"/status", p.header(), nil, &status); err != nil {
+		return StateNone, err
+	}
+	var states []State
+	if status.TotalCount > 0 {
+		// "error" is a failure to run the check, which fails it just the same
+		switch status.State {
+		case "success":
+			states = append(states, StateSuccess)
+		case "pending":
+			states = append(states, StatePending)
+		default:
+			states = append(states, StateFailure)
+		}
+	}
+
+	var runs struct {
+		CheckRuns []struct {
+			Status     string `json:"status"`
+			Conclusion string `json:"conclusion"`
+		} `json:"check_runs"`
+	}
+	if err := doJSON(ctx, http.MethodGet, base+"/check-runs?per_page=100", p.header(), nil, &runs); err != nil {
+		return StateNone, err
+	}
+	for _, run := range runs.CheckRuns {
+		switch {
+		case run.Status != "completed":
+			states = append(states, StatePending)
+		case run.Conclusion == "success":
+			states = append(states, StateSuccess)
+		case run.Conclusion == "failure", run.Conclusion == "timed_out", run.Conclusion == "cancelled",
+			run.Conclusion == "action_required", run.Conclusion == "startup_failure":
+			states = append(states, StateFailure)
+		}
+		// neutral and skipped don't count either way
+	}
+	return combine(states...), nil
+}
+
+func (p *githubProvider) BrowseURL(page Page) string {
+	switch {
+	case page.File != "":
+		u := p.home + "/blob/" + filePath(page.Commit, page.File)
+		if page.Line > 0 {
+			u += "#L" + strconv.Itoa(page.Line)
+		}
+		return u
+	case page.Commit != "":
+		return p.home + "/commit/" + page.Commit
+	}
+	return p.home
+}
+
+// Release is a published release of a repository
+type Release struct {
+	Tag string // e.g. v1.2.0
+	URL string // release page
+}
+
+// LatestRelease returns the newest published release of a github.com
+// repository given as owner/repo
+func LatestRelease(ctx context.Context, repo string) (Release, error) {
+	p := &githubProvider{api: "https://api.github.com", repo: repo}
+	return p.latestRelease(ctx)
+}
+
+func (p *githubProvider) latestRelease(ctx context.Context) (Release, error) {
+	var release struct {
+		Tag string `json:"tag_name"`
+		URL string `json:"html_url"`
+	}
+	if err := doJSON(ctx, http.MethodGet, p.api+"/repos/"+p.repo+"/releases/latest", p.header(), nil, &release); err != nil {
+		return Release{}, err
+	}
+	return Release{Tag: release.Tag, URL: release.URL}, nil
+}
+
+// NewerThan reports whether the release comes after version current. The
+// dotted numbers are compared, missing ones counting as 0, and on a tie a
+// release beats a pre-release such as v1.2.0-rc1; build metadata after "+"
+// is ignored.
+func (r Release) NewerThan(current string) bool {
+	parse := func(v string) (parts []int, pre bool) {
+		v, _, _ = strings.Cut(strings.TrimPrefix(v, "v"), "+")
+		v, _, pre = strings.Cut(v, "-")
+		for _, part := range strings.Split(v, ".") {
+			n, _ := strconv.Atoi(part)
+			parts = append(parts, n)
+		}
+		return parts, pre
+	}
+	a, aPre := parse(r.Tag)
+	b, bPre := parse(current)
+	for i := 0; i < max(len(a), len(b)); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			return x > y
+		}
+	}
+	return bPre && !aPre
+}
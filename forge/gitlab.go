@@ -0,0 +1,120 @@
+package forge
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+)
+
+type gitlabProvider struct {
+	home string // web page of the project
+	api  string // API base URL
+	repo string // group/project, possibly with subgroups
+}
+
+// header authenticates with GITLAB_TOKEN when it is set
+func (p *gitlabProvider) header() http.Header {
+	header := http.Header{}
+	if token := os.Getenv("GITLAB_TOKEN"); token != "" {
+		header.Set("PRIVATE-TOKEN", token)
+	}
+	return header
+}
+
+func (p *gitlabProvider) project() string {
+	return p.api + "/projects/" + url.PathEscape(p.repo)
+}
+
+// mergeJSON is a merge request as returned by the GitLab API
+type mergeJSON struct {
+	IID          int    `json:"iid"`
+	Title        string `json:"title"`
+	URL          string `json:"web_url"`
+	Draft        bool   `json:"draft"`
+	SourceBranch string `json:"source_branch"`
+	TargetBranch string `json:"target_branch"`
+	Author       struct {
+		Username string `json:"username"`
+	} `json:"author"`
+}
+
+func (mr mergeJSON) pullRequest() PullRequest {
+	return PullRequest{
+		Number: mr.IID,
+		Title:  mr.Title,
+		Author: mr.Author.Username,
+		Branch: mr.SourceBranch,
+		Base:   mr.TargetBranch,
+		URL:    mr.URL,
+		Draft:  mr.Draft,
+	}
+}
+
+func (p *gitlabProvider) ListPRs(ctx context.Context) ([]PullRequest, error) {
+	var merges []mergeJSON
+	endpoint := p.project() + "/merge_requests?state=opened&per_page=100"
+	if err := doJSON(ctx, http.MethodGet, endpoint, p.header(), nil, &merges); err != nil {
+		return nil, err
+	}
+	prs := make([]PullRequest, len(merges))
+	for i, mr := range merges {
+		prs[i] = mr.pullRequest()
+	}
+	return prs, nil
+}
+
+func (p *gitlabProvider) CreatePR(ctx context.Context, pr NewPullRequest) (*PullRequest, error) {
+	body := map[string]any{
+		"title":         pr.Title,
+		"description":   pr.Body,
+		"source_branch": pr.Branch,
+		"target_branch": pr.Base,
+	}
+	var mr mergeJSON
+	if err := doJSON(ctx, http.MethodPost, p.project()+"/merge_requests", p.header(), body, &mr); err != nil {
+		return nil, err
+	}
+	created := mr.pullRequest()
+	return &created, nil
+}
+
+// GetChecks reads the state of the last pipeline for the commit
+func (p *gitlabProvider) GetChecks(ctx context.Context, sha string) (State, error) {
+	var commit struct {
+		LastPipeline *struct {
+			Status string `json:"status"`
+		} `json:"last_pipeline"`
+	}
+	endpoint := p.project() + "/repository/commits/" + url.PathEscape(sha)
+	if err := doJSON(ctx, http.MethodGet, endpoint, p.header(), nil, &commit); err != nil {
+		return StateNone, err
+	}
+	if commit.LastPipeline == nil {
+		return StateNone, nil
+	}
+	switch commit.LastPipeline.Status {
+	case "success":
+		return StateSuccess, nil
+	case "failed", "canceled":
+		return StateFailure, nil
+	case "skipped", "manual":
+		return StateNone, nil
+	}
+	return StatePending, nil
+}
+
+func (p *gitlabProvider) BrowseURL(page Page) string {
+	switch {
+	case page.File != "":
+		u := p.home + "/-/blob/" + filePath(page.Commit, page.File)
+		if page.Line > 0 {
+			u += "#L" + strconv.Itoa(page.Line)
+		}
+		return u
+	case page.Commit != "":
+		return p.home + "/-/commit/" + page.Commit
+	}
+	return p.home
+}
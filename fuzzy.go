@@ -0,0 +1,103 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// fuzzyMatch reports whether the letters of pattern appear in text in order,
+// ignoring case, how well they do, and at which rune positions. Letters at
+// the start of a word and runs of adjacent letters score higher, and the
+// best scoring placement wins. An empty pattern matches anything.
+func fuzzyMatch(pattern, text string) (score int, positions []int, ok bool) {
+	if pattern == "" {
+		return 0, nil, true
+	}
+	want := []rune(strings.ToLower(pattern))
+	runes := []rune(strings.ToLower(text))
+	original := []rune(text)
+
+	// Greedy from each place the first letter occurs, keeping the best
+	for start, r := range runes {
+		if r != want[0] {
+			continue
+		}
+		s, p, matched := fuzzyFrom(want, runes, original, start)
+		if matched && (!ok || s > score) {
+			score, positions, ok = s, p, true
+		}
+	}
+	return score, positions, ok
+}
+
+// fuzzyFrom matches want against runes greedily from start
+func fuzzyFrom(want, runes, original []rune, start int) (int, []int, bool) {
+	score, next, last := 0, 0, -2
+	var positions []int
+	for i := start; i < len(runes) && next < len(want); i++ {
+		if runes[i] != want[next] {
+			continue
+		}
+		score++
+		if i == last+1 {
+			score += 2 // continues a run
+		}
+		if startsWord(original, i) {
+			score += 3
+		}
+		positions = append(positions, i)
+		last = i
+		next++
+	}
+	return score, positions, next == len(want)
+}
+
+// startsWord reports whether runes[i] begins a word: it follows a separator,
+// or is an upper case letter after a lower case one
+func startsWord(runes []rune, i int) bool {
+	if i == 0 {
+		return true
+	}
+	prev := runes[i-1]
+	if !unicode.IsLetter(prev) && !unicode.IsDigit(prev) {
+		return true
+	}
+	return unicode.IsUpper(runes[i]) && unicode.IsLower(prev)
+}
+
+// highlightMatches renders text in style with the runes at positions in
+// highlight
+func highlightMatches(text string, positions []int, style, highlight lipgloss.Style) string {
+	if len(positions) == 0 {
+		return style.Render(text)
+	}
+	matched := make(map[int]bool, len(positions))
+	for _, pos := range positions {
+		matched[pos] = true
+	}
+	var b strings.Builder
+	var run []rune
+	inMatch := false
+	flush := func() {
+		if len(run) == 0 {
+			return
+		}
+		if inMatch {
+			b.WriteString(highlight.Render(string(run)))
+		} else {
+			b.WriteString(style.Render(string(run)))
+		}
+		run = run[:0]
+	}
+	for i, r := range []rune(text) {
+		if matched[i] != inMatch {
+			flush()
+			inMatch = matched[i]
+		}
+		run = append(run, r)
+	}
+	flush()
+	return b.String()
+}
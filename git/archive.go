@@ -0,0 +1,15 @@
+package git
+
+// Archive writes the files of rev to path, as a zip, tar or tar.gz archive
+// by path's extension
+func Archive(repoPath, rev, path string) error {
+	_, err := runGit(repoPath, "archive", "--output", path, rev)
+	return err
+}
+
+// CreateBundle writes all branches and tags with their history to a single
+// file at path, which can be cloned or fetched from offline. Each finished
+// phase of git's progress is passed to output if given.
+func CreateBundle(repoPath, path string, output func(line string)) error {
+	return runStreaming(repoPath, output, "bundle", "create", "--progress", path, "--all")
+}
@@ -0,0 +1,162 @@
+package git
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxBatches is how many repositories keep a cat-file process; the least
+// recently used one is stopped to make room
+const maxBatches = 4
+
+// catFile is a long-lived "git cat-file --batch" that answers object reads
+// for one repository without starting a process per read. Only reads of
+// objects, such as the content of a file at a revision, can go through it:
+// git has no long-lived mode for status or diffs, so those still start a
+// process each.
+type catFile struct {
+	mu       sync.Mutex // one read at a time, and no close during one
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	stdout   *bufio.Reader
+	lastUsed time.Time // guarded by batches
+}
+
+var batches = struct {
+	sync.Mutex
+	procs map[string]*catFile
+}{procs: make(map[string]*catFile)}
+
+// errObjectMissing means the batch process found no object for the name
+var errObjectMissing = errors.New("object not found")
+
+// batchFor returns the cat-file process for repoPath, starting it if needed
+func batchFor(repoPath string) (*catFile, error) {
+	batches.Lock()
+	defer batches.Unlock()
+	if proc, ok := batches.procs[repoPath]; ok {
+		proc.lastUsed = time.Now()
+		return proc, nil
+	}
+	if len(batches.procs) >= maxBatches {
+		oldest := ""
+		for path, proc := range batches.procs {
+			if oldest == "" || proc.lastUsed.Before(batches.procs[oldest].lastUsed) {
+				oldest = path
+			}
+		}
+		// Closed once a read still going through it is done
+		go batches.procs[oldest].close()
+		delete(batches.procs, oldest)
+	}
+
+	// Not started with startCmd: the process lives as long as the repo is in
+	// use, and each read through it is recorded on its own instead
+	cmd := exec.Command("git", "cat-file", "--batch")
+	cmd.Dir = repoPath
+	cmd.Env = envFor(repoPath)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, explain(err)
+	}
+	proc := &catFile{cmd: cmd, stdin: stdin, stdout: bufio.NewReader(stdout), lastUsed: time.Now()}
+	batches.procs[repoPath] = proc
+	return proc, nil
+}
+
+// read asks for the object named by name, e.g. "HEAD:README.md", and
+// returns its contents and type
+func (c *catFile) read(name string) ([]byte, string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := io.WriteString(c.stdin, name+"\n"); err != nil {
+		return nil, "", err
+	}
+	// <oid> SP <type> SP <size> LF <contents> LF, or <name> SP missing LF
+	header, err := c.stdout.ReadString('\n')
+	if err != nil {
+		return nil, "", err
+	}
+	if strings.HasSuffix(header, " missing\n") || strings.HasSuffix(header, " ambiguous\n") {
+		return nil, "", errObjectMissing
+	}
+	fields := strings.Fields(header)
+	if len(fields) != 3 {
+		return nil, "", fmt.Errorf("unexpected cat-file header %q", header)
+	}
+	size, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return nil, "", fmt.Errorf("unexpected cat-file header %q", header)
+	}
+	data := make([]byte, size+1)
+	if _, err := io.ReadFull(c.stdout, data); err != nil {
+		return nil, "", err
+	}
+	return data[:size], fields[1], nil
+}
+
+// close stops the process, waiting for a read going through it to finish
+func (c *catFile) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stdin.Close()
+	go c.cmd.Wait()
+}
+
+// readObject reads an object and its type through the repository's
+// cat-file process.
+// A process that fails is stopped, and the next read starts a new one.
+func readObject(repoPath string, name string) ([]byte, string, error) {
+	if strings.Contains(name, "\n") {
+		return nil, "", fmt.Errorf("can't batch read %q", name)
+	}
+	proc, err := batchFor(repoPath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	started := time.Now()
+	data, kind, err := proc.read(name)
+	code := 0
+	switch {
+	case errors.Is(err, errObjectMissing):
+		code = 128
+	case err != nil:
+		code = -1
+		batches.Lock()
+		if batches.procs[repoPath] == proc {
+			delete(batches.procs, repoPath)
+		}
+		batches.Unlock()
+		proc.close()
+	}
+	record(repoPath, []string{"cat-file", "--batch", name}, started, code)
+	return data, kind, err
+}
+
+// CloseBatches stops the cat-file processes kept for reading objects,
+// waiting for reads going through them to finish
+func CloseBatches() {
+	batches.Lock()
+	procs := batches.procs
+	batches.procs = make(map[string]*catFile)
+	batches.Unlock()
+	for _, proc := range procs {
+		proc.close()
+	}
+}
@@ -0,0 +1,84 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"sync/atomic"
+)
+
+// environment is the function SetEnvironment set, swapped whole so commands
+// running while it changes use either the old or the new one
+var environment atomic.Pointer[func(repoPath string) []string]
+
+// SetEnvironment sets the environment git runs with in a repository, so its
+// hooks see the same variables as editors and shells started there. With
+// nil, git gets kvist's own environment.
+func SetEnvironment(env func(repoPath string) []string) {
+	if env == nil {
+		environment.Store(nil)
+		return
+	}
+	environment.Store(&env)
+}
+
+// envFor is the environment of git run in dir
+func envFor(dir string) []string {
+	env := environment.Load()
+	if env == nil {
+		return os.Environ()
+	}
+	return (*env)(dir)
+}
+
+// withEnv gives cmd the environment of its directory unless it has one
+func withEnv(cmd *exec.Cmd) {
+	if env := environment.Load(); cmd.Env == nil && env != nil {
+		cmd.Env = (*env)(cmd.Dir)
+	}
+}
+
+// textConv are the patterns SetTextConv set, swapped whole like environment
+var textConv atomic.Pointer[[]string]
+
+// SetTextConv sets the patterns, e.g. "*.ipynb", of files whose diffs are
+// always put through the textconv filters the repository's .gitattributes
+// and git config set up for them. Other files are diffed the way git does
+// by default. Patterns without a slash match the file name in any directory.
+func SetTextConv(patterns []string) {
+	textConv.Store(&patterns)
+}
+
+// textConvPatterns returns the patterns SetTextConv set
+func textConvPatterns() []string {
+	if p := textConv.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
+
+// UsesTextConv reports whether the diff of path is shown converted
+func UsesTextConv(file string) bool {
+	for _, pattern := range textConvPatterns() {
+		name := file
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(file)
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// textconvFlags are the git diff flags for a diff of file, or of a whole
+// commit when file is "". A whole commit can only be converted all or
+// nothing, so it is converted once any pattern is set. Without a match
+// there are none, leaving git's default.
+func textconvFlags(file string) []string {
+	if (file == "" && len(textConvPatterns()) > 0) || (file != "" && UsesTextConv(file)) {
+		return []string{"--textconv"}
+	}
+	return nil
+}
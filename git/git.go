@@ -4,10 +4,14 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -20,6 +24,7 @@ const (
 	OpFetch GitOp = iota
 	OpPull
 	OpPush
+	OpFetchPrune // fetch and delete remote-tracking branches gone from the remote
 )
 
 // String returns the string representation of the GitOp
@@ -31,6 +36,8 @@ func (op GitOp) String() string {
 		return "pull"
 	case OpPush:
 		return "push"
+	case OpFetchPrune:
+		return "fetch --prune"
 	default:
 		return "unknown"
 	}
@@ -40,6 +47,7 @@ type Repository struct {
 	Path          string
 	Name          string
 	CurrentBranch string
+	DetachedHead  string // the commit HEAD is at when no branch is checked out
 }
 
 func OpenRepository(path string) (*Repository, error) {
@@ -53,7 +61,7 @@ func OpenRepository(path string) (*Repository, error) {
 
 	cmd := exec.CommandContext(ctx, "git", "rev-parse", "--show-toplevel")
 	cmd.Dir = absPath
-	output, err := cmd.Output()
+	output, err := cmdOutput(cmd)
 	if err != nil {
 		return nil, fmt.Errorf("not a git repository: %w", err)
 	}
@@ -61,11 +69,16 @@ func OpenRepository(path string) (*Repository, error) {
 	repoPath := strings.TrimSpace(string(output))
 
 	branch, _ := getCurrentBranch(repoPath)
+	var detached string
+	if branch == "" {
+		detached, _ = runGit(repoPath, "rev-parse", "--verify", "--quiet", "HEAD")
+	}
 
 	return &Repository{
 		Path:          repoPath,
 		Name:          filepath.Base(repoPath),
 		CurrentBranch: branch,
+		DetachedHead:  detached,
 	}, nil
 }
 
@@ -73,13 +86,28 @@ func getCurrentBranch(repoPath string) (string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "git", "branch", "--show-current")
+	// Rather than git branch --show-current, which needs git 2.22
+	cmd := exec.CommandContext(ctx, "git", "symbolic-ref", "-q", "HEAD")
 	cmd.Dir = repoPath
-	output, err := cmd.Output()
+	output, err := cmdOutput(cmd)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return "", nil // detached
+	}
 	if err != nil {
 		return "", err
 	}
-	return strings.TrimSpace(string(output)), nil
+	return strings.TrimPrefix(strings.TrimSpace(string(output)), "refs/heads/"), nil
+}
+
+// absoluteGitDir returns the absolute path of the git directory of the
+// repository at repoPath; rev-parse --absolute-git-dir needs git 2.13
+func absoluteGitDir(repoPath string) (string, error) {
+	dir, err := runGit(repoPath, "rev-parse", "--git-dir")
+	if err != nil || filepath.IsAbs(dir) {
+		return dir, err
+	}
+	return filepath.Join(repoPath, dir), nil
 }
 
 // GetCurrentBranch returns the current branch name for a repository
@@ -88,15 +116,84 @@ func GetCurrentBranch(repoPath string) (string, error) {
 }
 
 func GetCommits(repoPath string, limit int) ([]Commit, error) {
+	return getCommits(repoPath, limit)
+}
+
+// GetIncomingCommits returns the commits on origin's default branch that
+// HEAD doesn't have, newest first, along with the upstream ref compared against
+func GetIncomingCommits(repoPath string, limit int) (string, []Commit, error) {
+	base, err := DefaultBranch(repoPath)
+	if err != nil {
+		return "", nil, err
+	}
+	upstream := "origin/" + base
+	if _, err := runGit(repoPath, "rev-parse", "--verify", "--quiet", "refs/remotes/"+upstream); err != nil {
+		return "", nil, fmt.Errorf("%s not found, fetch first", upstream)
+	}
+	commits, err := getCommits(repoPath, limit, "HEAD.."+upstream, "--")
+	return upstream, commits, err
+}
+
+// FileCommit is a commit in the history of a file
+type FileCommit struct {
+	Commit
+	// Path is the file's path at the commit, an older one before a rename
+	Path string
+	// RenamedFrom is the path the commit renamed the file from, if it did
+	RenamedFrom string
+}
+
+// GetFileCommits returns the commits that changed path, newest first,
+// following the file back across renames unless rename detection is off
+func GetFileCommits(repoPath string, path string, limit int) ([]FileCommit, error) {
+	var follow []string
+	if renames := Renames(); !renames.Off {
+		follow = append([]string{"--follow"}, renames.diffFlags()...)
+	}
+	commits, err := getCommits(repoPath, limit, append(follow, "HEAD", "--", path)...)
+	if err != nil {
+		return nil, err
+	}
+	renames := make(map[string][2]string)
+	if len(follow) > 0 {
+		args := append([]string{"log", fmt.Sprintf("--max-count=%d", limit), "--name-status", "-z", "--format=%x1e%H"}, follow...)
+		out, err := runGit(repoPath, append(args, "HEAD", "--", path)...)
+		if err != nil {
+			return nil, err
+		}
+		// Each record is the hash then status, path(s) for the file, all
+		// separated by NULs; renames and copies list the old path first
+		for _, rec := range strings.Split(out, "\x1e") {
+			fields := strings.Split(rec, "\x00")
+			if len(fields) >= 4 && strings.ContainsAny(strings.TrimSpace(fields[1]), "RC") {
+				renames[fields[0]] = [2]string{fields[2], fields[3]}
+			}
+		}
+	}
+
+	files := make([]FileCommit, len(commits))
+	for i, commit := range commits {
+		files[i] = FileCommit{Commit: commit, Path: path}
+		if rename, ok := renames[commit.Hash]; ok {
+			files[i].Path, files[i].RenamedFrom = rename[1], rename[0]
+			path = rename[0]
+		}
+	}
+	return files, nil
+}
+
+// getCommits runs git log over revs (HEAD when none are given)
+func getCommits(repoPath string, limit int, revs ...string) ([]Commit, error) {
 	// %x1e = RS between commits, %x00 between fields
 	const logFmt = "%H%x00%h%x00%an%x00%ae%x00%at%x00%s%x00%b%x00%x1e"
 
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "git", "log", fmt.Sprintf("--max-count=%d", limit), "--format="+logFmt)
+	args := append([]string{"log", fmt.Sprintf("--max-count=%d", limit), "--format=" + logFmt}, revs...)
+	cmd := exec.CommandContext(ctx, "git", logArgs(repoPath, args...)...)
 	cmd.Dir = repoPath
-	output, err := cmd.Output()
+	output, err := cmdOutput(cmd)
 	if err != nil {
 		return nil, err
 	}
@@ -186,70 +283,59 @@ type Commit struct {
 	Body      string
 }
 
+// GetBranches lists local branches with their upstream and ahead/behind
+// counts, followed by origin's branches that have no local counterpart
 func GetBranches(repoPath string) ([]Branch, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	// %00 between fields; track is e.g. "ahead 2, behind 1" or "gone"
+	const refFmt = "%(HEAD)%00%(refname)%00%(symref)%00%(upstream:short)%00%(upstream:track,nobracket)"
 
-	cmd := exec.CommandContext(ctx, "git", "branch", "-a")
-	cmd.Dir = repoPath
-	output, err := cmd.Output()
+	out, err := runGit(repoPath, "for-each-ref", "--format="+refFmt, "refs/heads", "refs/remotes/origin")
 	if err != nil {
 		return nil, err
 	}
 
 	var branches []Branch
-
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
+	local := make(map[string]bool)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, "\x00")
+		if len(fields) != 5 || fields[2] != "" {
+			// Skip symbolic refs like origin/HEAD
 			continue
 		}
+		ref := fields[1]
 
-		var isCurrent bool
-		var name string
-
-		if strings.HasPrefix(line, "* ") {
-			isCurrent = true
-			name = strings.TrimSpace(line[2:])
-		} else {
-			isCurrent = false
-			name = strings.TrimSpace(line)
-		}
-
-		// Skip remote tracking branches that are duplicates of local branches
-		if strings.HasPrefix(name, "remotes/origin/") {
-			remoteName := strings.TrimPrefix(name, "remotes/origin/")
-			// Check if we already have this as a local branch
-			hasLocal := false
-			for _, existing := range branches {
-				if existing.Name == remoteName {
-					hasLocal = true
-					break
-				}
-			}
-			if hasLocal {
-				continue
-			}
-			name = remoteName + " (remote)"
+		if name, ok := strings.CutPrefix(ref, "refs/heads/"); ok {
+			branch := Branch{Name: name, IsCurrent: fields[0] == "*", Upstream: fields[3]}
+			branch.Ahead, branch.Behind, branch.Gone = parseTrack(fields[4])
+			branches = append(branches, branch)
+			local[name] = true
+			continue
 		}
 
-		var ahead, behind int
-		if isCurrent && !strings.Contains(name, "(remote)") {
-			// Only get ahead/behind for the current branch
-			ahead, behind, _ = getAheadBehind(repoPath)
+		// refs/heads sort before refs/remotes, so every local branch is known
+		name := strings.TrimPrefix(ref, "refs/remotes/origin/")
+		if !local[name] {
+			branches = append(branches, Branch{Name: name + " (remote)"})
 		}
-
-		branches = append(branches, Branch{
-			Name:      name,
-			IsCurrent: isCurrent,
-			Ahead:     ahead,
-			Behind:    behind,
-		})
 	}
 	return branches, nil
 }
 
+// parseTrack parses %(upstream:track,nobracket)
+func parseTrack(track string) (ahead, behind int, gone bool) {
+	if track == "gone" {
+		return 0, 0, true
+	}
+	for _, part := range strings.Split(track, ", ") {
+		if n, ok := strings.CutPrefix(part, "ahead "); ok {
+			ahead, _ = strconv.Atoi(n)
+		} else if n, ok := strings.CutPrefix(part, "behind "); ok {
+			behind, _ = strconv.Atoi(n)
+		}
+	}
+	return ahead, behind, false
+}
+
 func getAheadBehind(repoPath string) (ahead, behind int, ok bool) {
 	// Get the upstream branch reference
 	up, err := runGitAllowExit1(repoPath, "rev-parse", "--abbrev-ref", "@{u}")
@@ -277,6 +363,76 @@ func GetAheadBehind(repoPath string) (ahead, behind int, ok bool) {
 	return getAheadBehind(repoPath)
 }
 
+// Unpushed counts the commits on HEAD that are on no remote branch, which
+// also covers branches that were never pushed, and returns the commit time
+// of the oldest of them. A repository without remotes has nowhere to push
+// to, so nothing in it counts as unpushed.
+func Unpushed(repoPath string) (count int, oldest time.Time, err error) {
+	remotes, err := runGit(repoPath, "remote")
+	if err != nil || remotes == "" {
+		return 0, time.Time{}, err
+	}
+	out, err := runGit(repoPath, "rev-list", "--count", "HEAD", "--not", "--remotes")
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	if count, err = strconv.Atoi(out); err != nil || count == 0 {
+		return 0, time.Time{}, err
+	}
+	// Only the oldest is printed; the history may be long
+	out, err = runGit(repoPath, "log", "-1", "--skip="+strconv.Itoa(count-1), "--format=%ct", "HEAD", "--not", "--remotes")
+	if err != nil {
+		return count, time.Time{}, err
+	}
+	secs, err := strconv.ParseInt(out, 10, 64)
+	if err != nil {
+		return count, time.Time{}, err
+	}
+	return count, time.Unix(secs, 0), nil
+}
+
+// CountUnpushedBranches returns the number of commits on any local branch
+// that are on no remote branch
+func CountUnpushedBranches(repoPath string) (int, error) {
+	out, err := runGit(repoPath, "rev-list", "--count", "--branches", "--not", "--remotes")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(out)
+}
+
+// InProgressOperation names the operation the repository is stopped in the
+// middle of: "merge", "rebase", "cherry-pick", "revert" or "bisect", or ""
+func InProgressOperation(repoPath string) (string, error) {
+	gitDir, err := absoluteGitDir(repoPath)
+	if err != nil {
+		return "", err
+	}
+	markers := []struct{ file, op string }{
+		{"rebase-merge", "rebase"},
+		{"rebase-apply", "rebase"},
+		{"MERGE_HEAD", "merge"},
+		{"CHERRY_PICK_HEAD", "cherry-pick"},
+		{"REVERT_HEAD", "revert"},
+		{"BISECT_LOG", "bisect"},
+	}
+	for _, marker := range markers {
+		if _, err := os.Stat(filepath.Join(gitDir, marker.file)); err == nil {
+			return marker.op, nil
+		}
+	}
+	return "", nil
+}
+
+// CountConflicts returns the number of files with unresolved merge conflicts
+func CountConflicts(repoPath string) (int, error) {
+	out, err := runGit(repoPath, "diff", "--name-only", "--diff-filter=U")
+	if err != nil || out == "" {
+		return 0, err
+	}
+	return len(strings.Split(out, "\n")), nil
+}
+
 // GetAheadBehindBranch returns ahead/behind counts for the current branch vs a specific branch
 func GetAheadBehindBranch(repoPath string, targetBranch string) (ahead, behind int, ok bool) {
 	// Get ahead/behind counts
@@ -297,6 +453,8 @@ func GetAheadBehindBranch(repoPath string, targetBranch string) (ahead, behind i
 type Branch struct {
 	Name      string
 	IsCurrent bool
+	Upstream  string // e.g. "origin/main"; empty when not tracking
+	Gone      bool   // upstream was deleted
 	Ahead     int
 	Behind    int
 }
@@ -306,9 +464,9 @@ func GetStatus(repoPath string) (*Status, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "git", "status", "--porcelain=v2", "-z")
+	cmd := exec.CommandContext(ctx, "git", statusArgs(repoPath)...)
 	cmd.Dir = repoPath
-	output, err := cmd.Output()
+	output, err := cmdOutput(cmd)
 	if err != nil {
 		return nil, err
 	}
@@ -339,15 +497,21 @@ func GetStatus(repoPath string) (*Status, error) {
 			// Skip directories - git status may show untracked directories (like empty git repos)
 			// but we can't meaningfully diff them
 			fullPath := filepath.Join(repoPath, string(path))
-			if info, err := os.Stat(fullPath); err == nil && info.IsDir() {
+			info, err := os.Stat(fullPath)
+			if err == nil && info.IsDir() {
 				b = rest
 				continue
 			}
 
-			status.Files = append(status.Files, FileStatus{
+			fileStatus := FileStatus{
 				Path:     string(path),
 				Unstaged: "untracked",
-			})
+				XY:       "??",
+			}
+			if err == nil {
+				fileStatus.statWorktree(info)
+			}
+			status.Files = append(status.Files, fileStatus)
 			b = rest
 
 		case '!':
@@ -365,9 +529,10 @@ func GetStatus(repoPath string) (*Status, error) {
 			}
 
 			xy := fields[1]
-			path := fields[8]
+			// The path ends the record and may contain spaces
+			path := strings.SplitN(string(line), " ", 9)[8]
 
-			fileStatus := FileStatus{Path: path}
+			fileStatus := FileStatus{Path: path, XY: shortXY(xy), HeadBlob: fields[6], IndexBlob: fields[7]}
 
 			// Parse staged status (X)
 			switch xy[0] {
@@ -381,6 +546,8 @@ func GetStatus(repoPath string) (*Status, error) {
 				fileStatus.Staged = "renamed"
 			case 'C':
 				fileStatus.Staged = "copied"
+			case 'T':
+				fileStatus.Staged = "typechange"
 			}
 
 			// Parse unstaged status (Y)
@@ -389,6 +556,13 @@ func GetStatus(repoPath string) (*Status, error) {
 				fileStatus.Unstaged = "modified"
 			case 'D':
 				fileStatus.Unstaged = "deleted"
+			case 'T':
+				fileStatus.Unstaged = "typechange"
+			}
+			if fileStatus.Unstaged == "modified" {
+				if info, err := os.Stat(filepath.Join(repoPath, fileStatus.Path)); err == nil {
+					fileStatus.statWorktree(info)
+				}
 			}
 
 			status.Files = append(status.Files, fileStatus)
@@ -404,13 +578,19 @@ func GetStatus(repoPath string) (*Status, error) {
 			}
 
 			xy := fields[1]
-			// After the first NUL, read the two paths
-			origPath, rest2 := readToNul(rest)
-			newPath, rest3 := readToNul(rest2)
+			// The new path ends the record (it may contain spaces); the
+			// original path follows after the NUL
+			newPath := strings.SplitN(string(line), " ", 10)[9]
+			origPath, rest3 := readToNul(rest)
+			similarity, _ := strconv.Atoi(fields[8][1:])
 
 			fileStatus := FileStatus{
-				Path:    string(newPath),
-				OldPath: string(origPath),
+				Path:       newPath,
+				OldPath:    string(origPath),
+				XY:         shortXY(xy),
+				HeadBlob:   fields[6],
+				IndexBlob:  fields[7],
+				Similarity: similarity,
 			}
 
 			// Parse staged status (X)
@@ -427,11 +607,27 @@ func GetStatus(repoPath string) (*Status, error) {
 				fileStatus.Unstaged = "modified"
 			case 'D':
 				fileStatus.Unstaged = "deleted"
+			case 'T':
+				fileStatus.Unstaged = "typechange"
+			}
+			if fileStatus.Unstaged == "modified" {
+				if info, err := os.Stat(filepath.Join(repoPath, fileStatus.Path)); err == nil {
+					fileStatus.statWorktree(info)
+				}
 			}
 
 			status.Files = append(status.Files, fileStatus)
 			b = rest3
 
+		case 'u':
+			// Unmerged: u <xy> <sub> <m1> <m2> <m3> <mW> <h1> <h2> <h3> <path>
+			line, rest := readToNul(b)
+			parts := strings.SplitN(string(line), " ", 11)
+			if len(parts) == 11 {
+				status.Files = append(status.Files, FileStatus{Path: parts[10], XY: parts[1], Conflict: conflictKinds[parts[1]]})
+			}
+			b = rest
+
 		default:
 			// Unknown format, skip to next NUL
 			_, rest := readToNul(b)
@@ -442,6 +638,113 @@ func GetStatus(repoPath string) (*Status, error) {
 	return status, nil
 }
 
+// shortXY turns porcelain v2 status letters into those of the short format,
+// where an unchanged side is a space
+func shortXY(xy string) string {
+	return strings.ReplaceAll(xy, ".", " ")
+}
+
+// conflictKinds describes the unmerged states git status reports
+var conflictKinds = map[string]string{
+	"DD": "both deleted",
+	"AU": "added by us",
+	"UD": "deleted by them",
+	"UA": "added by them",
+	"DU": "deleted by us",
+	"AA": "both added",
+	"UU": "both modified",
+}
+
+// GetIgnored lists the ignored files in the work tree. Wholly ignored
+// directories are listed once, with a trailing slash.
+func GetIgnored(repoPath string) ([]FileStatus, error) {
+	out, err := runGit(repoPath, "ls-files", "-z", "--others", "--ignored", "--exclude-standard", "--directory")
+	if err != nil {
+		return nil, err
+	}
+	files := []FileStatus{}
+	for _, path := range strings.Split(out, "\x00") {
+		if path != "" {
+			files = append(files, FileStatus{Path: path, Unstaged: "ignored", XY: "!!"})
+		}
+	}
+	return files, nil
+}
+
+// AddToGitignore appends pattern to the .gitignore at the repository root,
+// creating it if needed. Patterns already listed aren't added again.
+func AddToGitignore(repoPath string, pattern string) error {
+	path := filepath.Join(repoPath, ".gitignore")
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, line := range strings.Split(string(existing), "\n") {
+		if strings.TrimSpace(line) == pattern {
+			return nil
+		}
+	}
+
+	entry := pattern + "\n"
+	if len(existing) > 0 && !strings.HasSuffix(string(existing), "\n") {
+		entry = "\n" + entry
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(entry); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// CleanMode selects which files git clean removes
+type CleanMode int
+
+const (
+	CleanUntracked CleanMode = iota // untracked files, keeping ignored ones
+	CleanIgnored                    // only ignored files, such as build output
+	CleanAll                        // untracked and ignored files
+)
+
+func (c CleanMode) args() []string {
+	switch c {
+	case CleanIgnored:
+		return []string{"-d", "-X"}
+	case CleanAll:
+		return []string{"-d", "-x"}
+	}
+	return []string{"-d"}
+}
+
+// CleanPreview returns the paths git clean would remove, without removing anything
+func CleanPreview(repoPath string, mode CleanMode) ([]string, error) {
+	out, err := runGit(repoPath, append([]string{"-c", "core.quotepath=false", "clean", "-n"}, mode.args()...)...)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, line := range strings.Split(out, "\n") {
+		if path, ok := strings.CutPrefix(line, "Would remove "); ok {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// Clean deletes paths, as CleanPreview listed them for the same mode.
+// Files that appeared since the preview are left alone.
+func Clean(repoPath string, mode CleanMode, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	args := append([]string{"--literal-pathspecs", "clean", "-f"}, mode.args()...)
+	_, err := runGit(repoPath, append(append(args, "--"), paths...)...)
+	return err
+}
+
 func readToNul(b []byte) (field []byte, rest []byte) {
 	i := bytes.IndexByte(b, 0)
 	if i < 0 {
@@ -459,10 +762,81 @@ type FileStatus struct {
 	OldPath  string // For renames
 	Staged   string
 	Unstaged string
+	// XY is the status in git's short format: a letter for the index and
+	// one for the work tree, e.g. "MM", "A ", " D", "??" or "UU"
+	XY        string
+	HeadBlob  string // object id in HEAD, empty for untracked files
+	IndexBlob string // object id in the index, empty for untracked files
+	// Similarity is how alike a renamed or copied file is to its original,
+	// in percent
+	Similarity int
+	// Conflict describes an unresolved merge conflict, e.g. "both modified"
+	Conflict string
+	// Size and modification time (unix nanoseconds) of the working tree
+	// file when it differs from the index, to tell later edits apart
+	WorktreeSize  int64
+	WorktreeMtime int64
+}
+
+// statWorktree records the working tree file's size and modification time
+func (f *FileStatus) statWorktree(info os.FileInfo) {
+	f.WorktreeSize = info.Size()
+	f.WorktreeMtime = info.ModTime().UnixNano()
+}
+
+// StatusDelta is how a status differs from the one before it, by path
+type StatusDelta struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+// Empty reports whether nothing changed
+func (d StatusDelta) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffStatus compares status with the previous one; prev may be nil
+func DiffStatus(prev, status *Status) StatusDelta {
+	before := make(map[string]FileStatus)
+	if prev != nil {
+		for _, file := range prev.Files {
+			before[file.Path] = file
+		}
+	}
+	var delta StatusDelta
+	if status != nil {
+		for _, file := range status.Files {
+			old, ok := before[file.Path]
+			switch {
+			case !ok:
+				delta.Added = append(delta.Added, file.Path)
+			case old != file:
+				delta.Changed = append(delta.Changed, file.Path)
+			}
+			delete(before, file.Path)
+		}
+	}
+	for path := range before {
+		delta.Removed = append(delta.Removed, path)
+	}
+	sort.Strings(delta.Removed)
+	return delta
+}
+
+// IsUntracked reports whether the file is unknown to git, ignored or not
+func (f FileStatus) IsUntracked() bool {
+	return f.Unstaged == "untracked" || f.Unstaged == "ignored"
 }
 
 func GetDiff(repoPath string, path string, staged bool) (string, error) {
-	args := []string{"diff", "--no-ext-diff", "-U3"}
+	return GetDiffContext(context.Background(), repoPath, path, staged)
+}
+
+// GetDiffContext is GetDiff, stopping git when ctx is cancelled. Diffs
+// larger than MaxDiffBytes are cut short and returned with ErrTruncated.
+func GetDiffContext(ctx context.Context, repoPath string, path string, staged bool) (string, error) {
+	args := append(append([]string{"diff", "--no-ext-diff", "-U3"}, textconvFlags(path)...), Renames().diffFlags()...)
 	if staged {
 		args = append(args, "--cached")
 	}
@@ -470,16 +844,71 @@ func GetDiff(repoPath string, path string, staged bool) (string, error) {
 		args = append(args, "--", path)
 	}
 
-	return runGitAllowExit1(repoPath, args...)
+	return runGitAllowExit1Context(ctx, repoPath, MaxDiffBytes, args...)
 }
 
 // GetCommitDiff returns the diff for a specific commit
 func GetCommitDiff(repoPath string, commitHash string) (string, error) {
+	return GetCommitDiffContext(context.Background(), repoPath, commitHash)
+}
+
+// GetCommitDiffContext is GetCommitDiff, stopping git when ctx is
+// cancelled. Diffs larger than MaxDiffBytes are cut short and returned with
+// ErrTruncated.
+func GetCommitDiffContext(ctx context.Context, repoPath string, commitHash string) (string, error) {
 	// git show --no-ext-diff -U3 --format= --first-parent <hash>
 	// --format= suppresses commit message (already shown in UI)
 	// --first-parent shows diff against first parent for merge commits
-	args := []string{"show", "--no-ext-diff", "-U3", "--format=", "--first-parent", commitHash}
-	return runGitAllowExit1(repoPath, args...)
+	args := append(append([]string{"show", "--no-ext-diff", "-U3", "--format=", "--first-parent"}, textconvFlags("")...), Renames().diffFlags()...)
+	args = append(args, commitHash)
+	return runGitAllowExit1Context(ctx, repoPath, MaxDiffBytes, args...)
+}
+
+// GetRangeDiffContext returns the combined changes of the commits from
+// oldest to newest, cut short like GetCommitDiffContext
+func GetRangeDiffContext(ctx context.Context, repoPath, oldest, newest string) (string, error) {
+	base, err := RangeBase(repoPath, oldest)
+	if err != nil {
+		return "", err
+	}
+	args := append(append([]string{"diff", "--no-ext-diff", "-U3"}, textconvFlags("")...), Renames().diffFlags()...)
+	args = append(args, base, newest, "--")
+	return runGitAllowExit1Context(ctx, repoPath, MaxDiffBytes, args...)
+}
+
+// RangeBase returns what the commits from oldest on are diffed against: the
+// parent of oldest, or the empty tree when oldest is a root commit
+func RangeBase(repoPath, oldest string) (string, error) {
+	parent, err := parentOf(repoPath, oldest)
+	if err != nil || parent != "" {
+		return parent, err
+	}
+	// Hashed rather than hardcoded so SHA-256 repositories work too
+	return runGit(repoPath, "hash-object", "-t", "tree", "--stdin")
+}
+
+// parentOf returns the hash of the first parent of rev, "" for a root commit
+func parentOf(repoPath, rev string) (string, error) {
+	out, err := runGitAllowExit1(repoPath, "rev-parse", "--verify", "--quiet", rev+"^")
+	return strings.TrimSpace(out), err
+}
+
+// FormatPatches writes the commits from oldest to newest as numbered patch
+// files into dir and returns their paths
+func FormatPatches(repoPath, oldest, newest, dir string) ([]string, error) {
+	parent, err := parentOf(repoPath, oldest)
+	if err != nil {
+		return nil, err
+	}
+	revs := []string{parent + ".." + newest}
+	if parent == "" {
+		revs = []string{"--root", newest}
+	}
+	out, err := runGit(repoPath, append([]string{"format-patch", "--output-directory", dir}, revs...)...)
+	if err != nil || out == "" {
+		return nil, err
+	}
+	return strings.Split(out, "\n"), nil
 }
 
 type Numstat struct {
@@ -490,7 +919,7 @@ type Numstat struct {
 }
 
 func DiffNumstat(repoPath string, staged bool, paths ...string) ([]Numstat, error) {
-	args := []string{"diff", "--numstat", "--no-textconv"}
+	args := append([]string{"diff", "--numstat", "--no-textconv"}, Renames().diffFlags()...)
 	if staged {
 		args = append(args, "--cached")
 	}
@@ -528,6 +957,10 @@ func DiffNumstat(repoPath string, staged bool, paths ...string) ([]Numstat, erro
 }
 
 func IsBinaryChange(repoPath string, staged bool, path string) (bool, error) {
+	if UsesTextConv(path) {
+		// Shown as text by its textconv filter
+		return false, nil
+	}
 	stats, err := DiffNumstat(repoPath, staged, path)
 	if err != nil {
 		return false, err
@@ -586,7 +1019,8 @@ func IsBinaryFile(repoPath string, path string) bool {
 
 // ExecuteGitOp performs a git operation with proper timeout handling
 func ExecuteGitOp(repoPath string, op GitOp) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
+	timeout := 8 * time.Second
+	ctx, cancel := withPromptTimeout(timeout)
 	defer cancel()
 
 	var cmd *exec.Cmd
@@ -597,19 +1031,167 @@ func ExecuteGitOp(repoPath string, op GitOp) error {
 		cmd = exec.CommandContext(ctx, "git", "pull")
 	case OpPush:
 		cmd = exec.CommandContext(ctx, "git", "push")
+	case OpFetchPrune:
+		cmd = exec.CommandContext(ctx, "git", "fetch", "--prune")
 	default:
 		return fmt.Errorf("unknown git operation: %v", op)
 	}
 
 	cmd.Dir = repoPath
-	return cmd.Run()
+	cmd.Env = promptEnv(repoPath)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := runCmd(cmd); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("%w: timed out after %s", err, timeout)
+		}
+		if msg := failureLine(stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// failureLine picks the line of git's output that says why it failed: the
+// first fatal or error line, or else the last line
+func failureLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	for _, line := range lines {
+		if strings.HasPrefix(line, "fatal:") || strings.HasPrefix(line, "error:") {
+			return line
+		}
+	}
+	return lines[len(lines)-1]
+}
+
+// Clone clones url into the directory name under parentDir, or git's choice
+// of name when it is empty. Each finished phase of git's progress, e.g.
+// "Receiving objects: 100% (20/20), done.", is passed to output if given;
+// the in-place updates in between are dropped.
+func Clone(parentDir, url, name string, output func(line string)) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	args := []string{"clone", "--progress", "--", url}
+	if name != "" {
+		args = append(args, name)
+	}
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = parentDir
+	cmd.Env = promptEnv(parentDir)
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	started, err := startCmd(cmd)
+	if err != nil {
+		return err
+	}
+
+	last := streamProgress(stderr, output)
+	if err := waitCmd(cmd, started); err != nil {
+		if last != "" {
+			return fmt.Errorf("%w: %s", err, last)
+		}
+		return err
+	}
+	return nil
+}
+
+// streamProgress passes each line printed to r to output, if given,
+// dropping the in-place progress updates between them, and returns the last
+func streamProgress(r io.Reader, output func(line string)) string {
+	var last string
+	var line []byte
+	reader := bufio.NewReader(r)
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return last
+		}
+		switch b {
+		case '\r':
+			if next, _ := reader.Peek(1); len(next) == 1 && next[0] == '\n' {
+				continue // a Windows line end
+			}
+			line = line[:0] // a progress update; the next one replaces it
+		case '\n':
+			// Indentation is kept, e.g. for the output of tests
+			if text := strings.TrimRight(string(line), " \t"); strings.TrimSpace(text) != "" {
+				last = strings.TrimSpace(text)
+				if output != nil {
+					output(text)
+				}
+			}
+			line = line[:0]
+		default:
+			line = append(line, b)
+		}
+	}
+}
+
+// RepoNameFromURL returns the directory name git clone picks for url,
+// e.g. "kvist" for "git@github.com:asbjornb/kvist.git"
+func RepoNameFromURL(url string) string {
+	name := strings.TrimRight(url, "/")
+	name = strings.TrimSuffix(name, "/.git")
+	if i := strings.LastIndexAny(name, "/:"); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.TrimSuffix(name, ".git")
+}
+
+// BackgroundFetch fetches the repository's remotes without user interaction:
+// neither git nor ssh prompt for credentials or passphrases, so a remote
+// that needs them just fails
+func BackgroundFetch(repoPath string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", "fetch", "--quiet")
+	cmd.Dir = repoPath
+	cmd.Env = append(envFor(repoPath), "GIT_TERMINAL_PROMPT=0")
+	if !sshCommandSet(repoPath, cmd.Env) {
+		cmd.Env = append(cmd.Env, "GIT_SSH_COMMAND=ssh -o BatchMode=yes")
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := runCmd(cmd); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// sshCommandSet reports whether env or the repository's config choose the
+// ssh command git runs, which BackgroundFetch then leaves alone
+func sshCommandSet(repoPath string, env []string) bool {
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "GIT_SSH_COMMAND=") || strings.HasPrefix(kv, "GIT_SSH=") {
+			return true
+		}
+	}
+	out, err := runGitAllowExit1(repoPath, "config", "--get", "core.sshCommand")
+	return err == nil && strings.TrimSpace(out) != ""
 }
 
 // runGitAllowExit1 executes git commands that may exit with code 1 (like diff)
 func runGitAllowExit1(dir string, args ...string) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
+	return runGitAllowExit1Context(context.Background(), dir, 0, args...)
+}
+
+// runGitAllowExit1Context is runGitAllowExit1, stopping git when ctx is
+// cancelled. With a limit above 0 at most limit bytes of output are read;
+// larger output is cut at the last full line and returned with ErrTruncated.
+func runGitAllowExit1Context(parent context.Context, dir string, limit int, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(parent, 8*time.Second)
 	defer cancel()
 
+	// The only settings overridden; the user's and repository's config,
+	// e.g. diff drivers and hunk headers, apply otherwise
 	base := []string{
 		"-c", "color.ui=false",
 		"-c", "core.pager=cat",
@@ -619,11 +1201,14 @@ func runGitAllowExit1(dir string, args ...string) (string, error) {
 	cmd := exec.CommandContext(ctx, "git", append(base, args...)...)
 	cmd.Dir = dir
 	// ensure no pager even if user config overrides
-	cmd.Env = append(os.Environ(), "GIT_PAGER=cat")
+	cmd.Env = append(envFor(dir), "GIT_PAGER=cat")
 
-	var out bytes.Buffer
-	cmd.Stdout, cmd.Stderr = &out, &out
-	err := cmd.Run()
+	out := &cappedBuffer{limit: limit}
+	cmd.Stdout, cmd.Stderr = out, out
+	err := runCmd(cmd)
+	if out.full {
+		return out.String(), ErrTruncated
+	}
 	if err != nil {
 		if ee, ok := err.(*exec.ExitError); ok && ee.ExitCode() == 1 {
 			return out.String(), nil // differences found → OK
@@ -633,6 +1218,32 @@ func runGitAllowExit1(dir string, args ...string) (string, error) {
 	return out.String(), nil
 }
 
+// runGit executes a git command and returns its trimmed stdout, folding stderr into the error
+func runGit(dir string, args ...string) (string, error) {
+	out, err := runGitUntrimmed(dir, args...)
+	return strings.TrimSpace(out), err
+}
+
+// runGitUntrimmed is runGit returning stdout as git wrote it, for output
+// such as patches where whitespace matters
+func runGitUntrimmed(dir string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := runCmd(cmd); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
 // UntrackedIsBinary detects if an untracked file is binary using git diff --numstat
 func UntrackedIsBinary(repoPath, rel string) (bool, error) {
 	abs := filepath.Join(repoPath, rel)
@@ -664,7 +1275,19 @@ func StageFile(repoPath string, path string) error {
 
 	cmd := exec.CommandContext(ctx, "git", "add", path)
 	cmd.Dir = repoPath
-	return cmd.Run()
+	return runCmd(cmd)
+}
+
+// StagePaths stages the changes to paths, deletions included
+func StagePaths(repoPath string, paths []string) error {
+	_, err := runGit(repoPath, append([]string{"add", "-A", "--"}, paths...)...)
+	return err
+}
+
+// UnstagePaths takes paths out of the index, back to their state in HEAD
+func UnstagePaths(repoPath string, paths []string) error {
+	_, err := runGit(repoPath, append([]string{"reset", "-q", "HEAD", "--"}, paths...)...)
+	return err
 }
 
 func UnstageFile(repoPath string, path string) error {
@@ -673,7 +1296,7 @@ func UnstageFile(repoPath string, path string) error {
 
 	cmd := exec.CommandContext(ctx, "git", "reset", "HEAD", path)
 	cmd.Dir = repoPath
-	return cmd.Run()
+	return runCmd(cmd)
 }
 
 func CheckoutBranch(repoPath string, branch string) error {
@@ -682,33 +1305,300 @@ func CheckoutBranch(repoPath string, branch string) error {
 
 	cmd := exec.CommandContext(ctx, "git", "checkout", branch)
 	cmd.Dir = repoPath
-	return cmd.Run()
+	return runCmd(cmd)
 }
 
-func CreateBranch(repoPath string, branch string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// RemoteBranches returns the branches of all remotes, e.g. "origin/main",
+// leaving out the remotes' HEAD
+func RemoteBranches(repoPath string) ([]string, error) {
+	out, err := runGit(repoPath, "for-each-ref", "--format=%(refname)", "refs/remotes")
+	if err != nil {
+		return nil, err
+	}
+	var branches []string
+	for _, ref := range strings.Split(out, "\n") {
+		name, ok := strings.CutPrefix(ref, "refs/remotes/")
+		if !ok || strings.HasSuffix(name, "/HEAD") {
+			continue
+		}
+		branches = append(branches, name)
+	}
+	return branches, nil
+}
 
-	cmd := exec.CommandContext(ctx, "git", "checkout", "-b", branch)
-	cmd.Dir = repoPath
-	return cmd.Run()
+// SetUpstream makes branch track upstream, a remote branch such as
+// "origin/main"
+func SetUpstream(repoPath, branch, upstream string) error {
+	_, err := runGit(repoPath, "branch", "--set-upstream-to="+upstream, "--", branch)
+	return err
 }
 
-func GetRemotes(repoPath string) ([]Remote, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// UnsetUpstream stops branch from tracking a remote branch
+func UnsetUpstream(repoPath, branch string) error {
+	_, err := runGit(repoPath, "branch", "--unset-upstream", "--", branch)
+	return err
+}
 
-	cmd := exec.CommandContext(ctx, "git", "remote", "-v")
-	cmd.Dir = repoPath
-	output, err := cmd.Output()
+// RecentBranches returns up to n local branches in the order they were last
+// checked out, newest first, as the reflog of HEAD records them. The branch
+// checked out now is first unless HEAD is detached. Branches deleted since
+// and commits checked out directly are left out.
+func RecentBranches(repoPath string, n int) ([]string, error) {
+	refs, err := runGit(repoPath, "for-each-ref", "--format=%(refname:short)", "refs/heads")
 	if err != nil {
 		return nil, err
 	}
+	exists := make(map[string]bool)
+	for _, name := range strings.Split(refs, "\n") {
+		exists[name] = true
+	}
+	log, err := runGit(repoPath, "reflog", "show", "--format=%gs", "-n", "1000", "HEAD", "--")
+	if err != nil {
+		// A repository without commits has no reflog yet; git words that
+		// differently across versions, so ask whether HEAD resolves
+		if _, verifyErr := runGit(repoPath, "rev-parse", "--verify", "--quiet", "HEAD"); verifyErr != nil {
+			return nil, nil
+		}
+		return nil, err
+	}
 
-	var remotes []Remote
-	remotesMap := make(map[string]*Remote)
-
-	lines := strings.Split(string(output), "\n")
+	var branches []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if exists[name] && !seen[name] && len(branches) < n {
+			seen[name] = true
+			branches = append(branches, name)
+		}
+	}
+	if current, err := GetCurrentBranch(repoPath); err == nil {
+		add(current)
+	}
+	for _, line := range strings.Split(log, "\n") {
+		move, ok := strings.CutPrefix(line, "checkout: moving from ")
+		if !ok {
+			continue
+		}
+		// Branch names can't contain spaces; "to" separates the two
+		from, to, ok := strings.Cut(move, " to ")
+		if !ok {
+			continue
+		}
+		add(to)
+		add(from)
+	}
+	return branches, nil
+}
+
+// RestoreFile replaces the work tree copy of path with its content at source
+func RestoreFile(repoPath string, source string, path string) error {
+	if err := Supports(FeatureRestore); err != nil {
+		return err
+	}
+	_, err := runGit(repoPath, "restore", "--source="+source, "--worktree", "--", path)
+	return err
+}
+
+// RestoreFileFrom replaces the work tree copy of path with the content
+// from had at source, for a file renamed since. The content is checked out
+// the way git would check out path: through its filters and line endings,
+// as a symlink when it was one, and executable when it was. Gits without
+// git restore get the content written the same way.
+func RestoreFileFrom(repoPath, source, from, path string) error {
+	if from == path && Supports(FeatureRestore) == nil {
+		return RestoreFile(repoPath, source, path)
+	}
+	entry, err := runGit(repoPath, "ls-tree", "--full-tree", source, "--", from)
+	if err != nil {
+		return err
+	}
+	mode, _, _ := strings.Cut(entry, " ")
+	if mode == "" {
+		return fmt.Errorf("%s is not in %s", from, source)
+	}
+
+	full := filepath.Join(repoPath, path)
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		return err
+	}
+	// Written anew rather than through whatever is there, e.g. a symlink
+	if err := os.Remove(full); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if mode == "120000" {
+		target, err := GetFileAtRevision(repoPath, source, from)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, full)
+	}
+
+	cmd := exec.Command("git", "cat-file", "--filters", "--path="+path, source+":"+from)
+	cmd.Dir = repoPath
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := runCmd(cmd); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	perm := os.FileMode(0644)
+	if mode == "100755" {
+		perm = 0755
+	}
+	return os.WriteFile(full, stdout.Bytes(), perm)
+}
+
+// CheckoutCommit checks out a commit with a detached HEAD
+func CheckoutCommit(repoPath string, commit string) error {
+	_, err := runGit(repoPath, "checkout", "--detach", commit)
+	return err
+}
+
+// CreateBranch creates a branch at startPoint, or at HEAD when startPoint
+// is empty, and checks it out if checkout is set
+func CreateBranch(repoPath string, branch string, startPoint string, checkout bool) error {
+	args := []string{"branch", branch}
+	if checkout {
+		args = []string{"checkout", "-b", branch}
+	}
+	if startPoint != "" {
+		args = append(args, startPoint)
+	}
+	_, err := runGit(repoPath, args...)
+	return err
+}
+
+// CommitStaged records the staged changes with message. Each line git and
+// the commit hooks print is passed to output, if given, as it appears.
+// noVerify skips the pre-commit and commit-msg hooks.
+func CommitStaged(repoPath string, message string, noVerify bool, output func(line string)) error {
+	// Hooks such as linters and test runners can take a while
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	// Read the message from stdin so it needs no quoting and keeps its newlines
+	args := []string{"commit", "--file=-"}
+	if noVerify {
+		args = append(args, "--no-verify")
+	}
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = repoPath
+	cmd.Stdin = strings.NewReader(message)
+
+	pr, pw := io.Pipe()
+	cmd.Stdout, cmd.Stderr = pw, pw
+	var last string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(pr)
+		for scanner.Scan() {
+			line := strings.TrimRight(scanner.Text(), " \t\r")
+			if line == "" {
+				continue
+			}
+			last = line
+			if output != nil {
+				output(line)
+			}
+		}
+		// Keep draining so git never blocks on a full pipe
+		_, _ = io.Copy(io.Discard, pr)
+	}()
+
+	err := runCmd(cmd)
+	pw.Close()
+	<-done
+	if err != nil && last != "" {
+		return fmt.Errorf("%w: %s", err, last)
+	}
+	return err
+}
+
+// InitRepo turns dir into a repository and commits everything in it that
+// isn't ignored as the first commit
+func InitRepo(dir, message string) error {
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-A"},
+		{"commit", "-q", "--allow-empty", "-m", message},
+	} {
+		if _, err := runGit(dir, args...); err != nil {
+			return fmt.Errorf("git %s: %w", args[0], err)
+		}
+	}
+	return nil
+}
+
+// RepairWorktrees fixes the links between a repository and its linked
+// worktrees after either was moved
+func RepairWorktrees(repoPath string) error {
+	if err := Supports(FeatureWorktreeRepair); err != nil {
+		return err
+	}
+	_, err := runGit(repoPath, "worktree", "repair")
+	return err
+}
+
+// Worktrees returns the paths of the repository's work trees, the main one
+// first and then the linked worktrees
+func Worktrees(repoPath string) ([]string, error) {
+	out, err := runGit(repoPath, "worktree", "list", "--porcelain")
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, line := range strings.Split(out, "\n") {
+		if path, ok := strings.CutPrefix(line, "worktree "); ok {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// CommitHooks returns the names of the installed hooks that can reject a
+// commit, honouring core.hooksPath
+func CommitHooks(repoPath string) []string {
+	dir, err := runGit(repoPath, "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return nil
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(repoPath, dir)
+	}
+	var hooks []string
+	for _, name := range []string{"pre-commit", "prepare-commit-msg", "commit-msg"} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err == nil && !info.IsDir() && info.Mode()&0o111 != 0 {
+			hooks = append(hooks, name)
+		}
+	}
+	return hooks
+}
+
+// GetConfig returns the value of a git config key as seen from repoPath,
+// or "" when it isn't set
+func GetConfig(repoPath string, key string) (string, error) {
+	out, err := runGitAllowExit1(repoPath, "config", "--get", key)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+func GetRemotes(repoPath string) ([]Remote, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", "remote", "-v")
+	cmd.Dir = repoPath
+	output, err := cmdOutput(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	var remotes []Remote
+	remotesMap := make(map[string]*Remote)
+
+	lines := strings.Split(string(output), "\n")
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
 		if line == "" {
@@ -756,9 +1646,9 @@ func GetStashes(repoPath string) ([]Stash, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "git", "stash", "list", "--format=%gd%x00%gs%x00%gD")
+	cmd := exec.CommandContext(ctx, "git", "stash", "list", "--format=%gd%x00%gs%x00%gD%x00%H")
 	cmd.Dir = repoPath
-	output, err := cmd.Output()
+	output, err := cmdOutput(cmd)
 	if err != nil {
 		return nil, err
 	}
@@ -772,9 +1662,10 @@ func GetStashes(repoPath string) ([]Stash, error) {
 		}
 
 		parts := strings.Split(line, "\x00")
-		if len(parts) >= 3 {
+		if len(parts) >= 4 {
 			stashes = append(stashes, Stash{
 				Index:   parts[0],
+				Hash:    parts[3],
 				Message: parts[1],
 				Date:    parts[2],
 			})
@@ -786,18 +1677,74 @@ func GetStashes(repoPath string) ([]Stash, error) {
 
 type Stash struct {
 	Index   string
+	Hash    string // the stash commit, which stays the same as stashes come and go
 	Message string
 	Date    string
 }
 
-// GetRefs returns a map of commit SHA -> list of ref names (branches, remotes, HEAD)
+// StashOptions choose what git stash push saves
+type StashOptions struct {
+	Message          string
+	IncludeUntracked bool     // stash untracked files too
+	KeepIndex        bool     // leave staged changes in the index as well
+	Paths            []string // stash only these paths; empty for everything
+}
+
+// StashPush saves local changes to a new stash and reverts them. Gits
+// without git stash push can still stash everything.
+func StashPush(repoPath string, opts StashOptions) error {
+	if err := Supports(FeatureStashPush); err != nil {
+		if len(opts.Paths) > 0 {
+			return err
+		}
+		return stashSave(repoPath, opts)
+	}
+	args := []string{"stash", "push"}
+	if opts.Message != "" {
+		args = append(args, "--message", opts.Message)
+	}
+	if opts.IncludeUntracked {
+		args = append(args, "--include-untracked")
+	}
+	if opts.KeepIndex {
+		args = append(args, "--keep-index")
+	}
+	if len(opts.Paths) > 0 {
+		args = append(append(args, "--"), opts.Paths...)
+	}
+	_, err := runGit(repoPath, args...)
+	return err
+}
+
+// stashSave is StashPush for gits older than git stash push
+func stashSave(repoPath string, opts StashOptions) error {
+	args := []string{"stash", "save"}
+	if opts.IncludeUntracked {
+		args = append(args, "--include-untracked")
+	}
+	if opts.KeepIndex {
+		args = append(args, "--keep-index")
+	}
+	if opts.Message != "" {
+		args = append(args, opts.Message)
+	}
+	_, err := runGit(repoPath, args...)
+	return err
+}
+
+// TagRefPrefix starts the names of tags among the refs GetRefs returns
+const TagRefPrefix = "tag: "
+
+// GetRefs returns a map of commit SHA -> list of ref names (branches, remotes, HEAD).
+// Tags are named as git log's %d names them, "tag: v1.2.3", and annotated
+// tags are listed at the commit they point at.
 func GetRefs(repoPath string) (map[string][]string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	cmd := exec.CommandContext(ctx, "git", "show-ref", "--heads", "--tags", "-d")
 	cmd.Dir = repoPath
-	output, err := cmd.Output()
+	output, err := cmdOutput(cmd)
 	if err != nil {
 		// show-ref fails if there are no refs, which is OK
 		return make(map[string][]string), nil
@@ -822,7 +1769,8 @@ func GetRefs(repoPath string) (map[string][]string, error) {
 			if strings.HasPrefix(refName, "refs/heads/") {
 				friendlyName = strings.TrimPrefix(refName, "refs/heads/")
 			} else if strings.HasPrefix(refName, "refs/tags/") {
-				friendlyName = strings.TrimPrefix(refName, "refs/tags/")
+				// -d lists an annotated tag again as tag^{} at its commit
+				friendlyName = TagRefPrefix + strings.TrimSuffix(strings.TrimPrefix(refName, "refs/tags/"), "^{}")
 			} else {
 				friendlyName = refName
 			}
@@ -834,7 +1782,7 @@ func GetRefs(repoPath string) (map[string][]string, error) {
 	// Also get remote refs
 	cmd = exec.CommandContext(ctx, "git", "show-ref")
 	cmd.Dir = repoPath
-	output, err = cmd.Output()
+	output, err = cmdOutput(cmd)
 	if err != nil {
 		return refs, nil // Return what we have so far
 	}
@@ -864,3 +1812,518 @@ func GetRefs(repoPath string) (map[string][]string, error) {
 
 	return refs, nil
 }
+
+// GrepMatch is a single line matched by git grep
+type GrepMatch struct {
+	Path string
+	Line int
+	Text string
+}
+
+// Grep searches the tracked files in the work tree for pattern and returns at most limit matches
+func Grep(repoPath string, pattern string, limit int) ([]GrepMatch, error) {
+	return grep(repoPath, limit, "-e", pattern)
+}
+
+// GrepMarkers searches tracked and untracked (but not ignored) files for lines
+// containing any of the given words, e.g. TODO or FIXME, and returns at most limit matches
+func GrepMarkers(repoPath string, words []string, limit int) ([]GrepMatch, error) {
+	args := []string{"--untracked", "-w", "-E"}
+	for _, word := range words {
+		args = append(args, "-e", word)
+	}
+	return grep(repoPath, limit, args...)
+}
+
+// grep runs git grep with args, reading its matches as they come and
+// stopping git once it has found limit of them
+func grep(repoPath string, limit int, args ...string) ([]GrepMatch, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
+	defer cancel()
+
+	// -z separates path, line number and text with NULs so colons in paths are safe
+	args = append([]string{"-c", "color.grep=false", "grep", "-n", "-I", "-z", "--full-name"}, args...)
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = repoPath
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	started, err := startCmd(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []GrepMatch
+	reader := bufio.NewReader(stdout)
+	full := false
+	for !full {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			break
+		}
+		parts := strings.SplitN(strings.TrimSuffix(line, "\n"), "\x00", 3)
+		if len(parts) < 3 {
+			continue
+		}
+		lineNum, _ := strconv.Atoi(parts[1])
+		matches = append(matches, GrepMatch{
+			Path: parts[0],
+			Line: lineNum,
+			Text: parts[2],
+		})
+		full = limit > 0 && len(matches) >= limit
+	}
+	if full {
+		cancel() // the rest isn't needed
+	}
+
+	err = waitCmd(cmd, started)
+	var exitErr *exec.ExitError
+	switch {
+	case full:
+		return matches, nil
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 1:
+		return nil, nil // no matches
+	case err != nil:
+		return nil, fmt.Errorf("git grep failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return matches, nil
+}
+
+// BlameTimes returns the author time of the given lines of path in the work
+// tree, keyed by line number. Uncommitted lines get the current time.
+func BlameTimes(repoPath string, path string, lines []int) (map[int]time.Time, error) {
+	// -M also finds lines moved within the file; blame follows renames
+	args := []string{"blame", "--line-porcelain", "-M"}
+	for _, line := range lines {
+		args = append(args, "-L", fmt.Sprintf("%d,%d", line, line))
+	}
+	args = append(args, "--", path)
+
+	output, err := runGit(repoPath, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	times := make(map[int]time.Time, len(lines))
+	current := 0
+	for _, line := range strings.Split(output, "\n") {
+		// Each entry starts with "<sha> <orig-line> <final-line> [<count>]"
+		if fields := strings.Fields(line); len(fields) >= 3 && len(fields[0]) >= 40 && !strings.Contains(fields[0], "-") {
+			if n, err := strconv.Atoi(fields[2]); err == nil {
+				current = n
+				continue
+			}
+		}
+		if ts, ok := strings.CutPrefix(line, "author-time "); ok {
+			if secs, err := strconv.ParseInt(ts, 10, 64); err == nil {
+				times[current] = time.Unix(secs, 0)
+			}
+		}
+	}
+	return times, nil
+}
+
+// Snapshot records HEAD and the local branch tips before a mutating operation
+// so it can be undone. The recorded commits stay reachable through the reflog,
+// so restoring only has to move refs back.
+type Snapshot struct {
+	RepoPath  string
+	Operation string
+	Branch    string            // checked out branch, empty when HEAD was detached
+	Head      string            // commit HEAD pointed at
+	Tips      map[string]string // local branch name -> commit
+}
+
+// TakeSnapshot captures the current HEAD and branch tips of a repository
+func TakeSnapshot(repoPath string, operation string) (*Snapshot, error) {
+	head, err := runGit(repoPath, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve HEAD: %w", err)
+	}
+
+	branch, _ := getCurrentBranch(repoPath)
+
+	tips, err := getBranchTips(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Snapshot{
+		RepoPath:  repoPath,
+		Operation: operation,
+		Branch:    branch,
+		Head:      head,
+		Tips:      tips,
+	}, nil
+}
+
+// getBranchTips returns local branch name -> commit hash
+func getBranchTips(repoPath string) (map[string]string, error) {
+	out, err := runGit(repoPath, "for-each-ref", "--format=%(refname:short)%00%(objectname)", "refs/heads")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branches: %w", err)
+	}
+
+	tips := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		parts := strings.SplitN(line, "\x00", 2)
+		if len(parts) == 2 {
+			tips[parts[0]] = parts[1]
+		}
+	}
+	return tips, nil
+}
+
+// RestoreSnapshot moves HEAD and the local branches back to where they were
+// when the snapshot was taken. Work tree changes are kept (reset --keep), and
+// branches created since the snapshot are only removed if fully merged.
+func RestoreSnapshot(snap *Snapshot) error {
+	repoPath := snap.RepoPath
+
+	current, _ := getCurrentBranch(repoPath)
+	tips, err := getBranchTips(repoPath)
+	if err != nil {
+		return err
+	}
+
+	// Get back onto the original branch (or detached commit)
+	if snap.Branch == "" {
+		if _, err := runGit(repoPath, "checkout", "--detach", snap.Head); err != nil {
+			return fmt.Errorf("failed to check out %s: %w", snap.Head, err)
+		}
+	} else if current != snap.Branch {
+		if _, exists := tips[snap.Branch]; !exists {
+			if _, err := runGit(repoPath, "branch", snap.Branch, snap.Tips[snap.Branch]); err != nil {
+				return fmt.Errorf("failed to recreate branch %s: %w", snap.Branch, err)
+			}
+		}
+		if _, err := runGit(repoPath, "checkout", snap.Branch); err != nil {
+			return fmt.Errorf("failed to check out %s: %w", snap.Branch, err)
+		}
+	}
+
+	// Move the branch itself back if the operation advanced or rewrote it
+	if head, err := runGit(repoPath, "rev-parse", "HEAD"); err != nil || head != snap.Head {
+		if _, err := runGit(repoPath, "reset", "--keep", snap.Head); err != nil {
+			return fmt.Errorf("failed to reset to %s: %w", snap.Head, err)
+		}
+	}
+
+	// Restore other branches that were moved or deleted
+	var failed []string
+	for name, tip := range snap.Tips {
+		if name == snap.Branch || tips[name] == tip {
+			continue
+		}
+		if _, err := runGit(repoPath, "branch", "-f", name, tip); err != nil {
+			failed = append(failed, name)
+		}
+	}
+
+	// Remove branches the operation created, but never lose unmerged work
+	for name := range tips {
+		if _, existed := snap.Tips[name]; !existed && name != snap.Branch {
+			_, _ = runGit(repoPath, "branch", "-d", name)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to restore branches: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// TreeEntry is a file or directory in the tree of a commit
+type TreeEntry struct {
+	Name string
+	Path string // path relative to the repository root
+	Type string // "blob", "tree" or "commit" (submodule)
+	Size int64  // blob size in bytes, -1 for trees and submodules
+}
+
+// IsDir reports whether the entry is a directory
+func (e TreeEntry) IsDir() bool {
+	return e.Type == "tree"
+}
+
+// ListTree lists the entries of dir (relative to the repository root, "" for the root)
+// as of the given revision, directories first
+func ListTree(repoPath string, rev string, dir string) ([]TreeEntry, error) {
+	args := []string{"ls-tree", "-z", "-l", rev}
+	if dir != "" {
+		// A trailing slash lists the directory's contents rather than the directory itself
+		args = append(args, "--", strings.TrimSuffix(dir, "/")+"/")
+	}
+
+	output, err := runGit(repoPath, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []TreeEntry
+	for _, record := range strings.Split(output, "\x00") {
+		// <mode> SP <type> SP <object> SP <size> TAB <path>
+		meta, path, ok := strings.Cut(record, "\t")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(meta)
+		if len(fields) < 4 {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[3], 10, 64)
+		if err != nil {
+			size = -1
+		}
+		entries = append(entries, TreeEntry{
+			Name: filepath.Base(path),
+			Path: path,
+			Type: fields[1],
+			Size: size,
+		})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].IsDir() != entries[j].IsDir() {
+			return entries[i].IsDir()
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	return entries, nil
+}
+
+// GetFileAtRevision returns the contents of path as of the given revision
+func GetFileAtRevision(repoPath string, rev string, path string) (string, error) {
+	if data, kind, err := readObject(repoPath, rev+":"+path); err == nil && kind == "blob" {
+		return string(data), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", "show", "--no-textconv", rev+":"+path)
+	cmd.Dir = repoPath
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := runCmd(cmd); err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// GetCommitFiles returns the files a commit added or modified, compared to
+// its first parent
+func GetCommitFiles(repoPath string, hash string) ([]string, error) {
+	out, err := runGit(repoPath, "diff-tree", "-r", "-z", "--root", "--no-commit-id", "--name-only", "--diff-filter=d", "-m", "--first-parent", hash)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, file := range strings.Split(out, "\x00") {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// IsBinaryContent reports whether content looks binary (contains a NUL byte near the start)
+func IsBinaryContent(content string) bool {
+	if len(content) > 8000 {
+		content = content[:8000]
+	}
+	return strings.IndexByte(content, 0) >= 0
+}
+
+// ResolveCommit resolves a hash, abbreviated hash or ref name to a full commit hash
+func ResolveCommit(repoPath string, ref string) (string, error) {
+	if strings.HasPrefix(ref, "-") {
+		return "", fmt.Errorf("invalid revision: %s", ref)
+	}
+	hash, err := runGit(repoPath, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil || hash == "" {
+		return "", fmt.Errorf("no commit matches %q", ref)
+	}
+	return hash, nil
+}
+
+// IsAncestor reports whether commit is reachable from rev
+func IsAncestor(repoPath string, commit string, rev string) (bool, error) {
+	_, err := runGit(repoPath, "merge-base", "--is-ancestor", commit, rev)
+	if err == nil {
+		return true, nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return false, nil
+	}
+	return false, err
+}
+
+// FirstChangedLine returns the line number in the new version of the file of
+// the first added or removed line in a unified diff, or 0 if there is none
+func FirstChangedLine(diff string) int {
+	line := 0
+	inHunk := false
+	for _, text := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(text, "@@"):
+			// @@ -a,b +c,d @@
+			fields := strings.Fields(text)
+			if len(fields) < 3 || !strings.HasPrefix(fields[2], "+") {
+				inHunk = false
+				continue
+			}
+			start, _, _ := strings.Cut(fields[2][1:], ",")
+			n, err := strconv.Atoi(start)
+			if err != nil {
+				inHunk = false
+				continue
+			}
+			line, inHunk = n, true
+		case !inHunk:
+			continue
+		case strings.HasPrefix(text, "+"), strings.HasPrefix(text, "-"):
+			return max(line, 1)
+		case strings.HasPrefix(text, " "):
+			line++
+		}
+	}
+	return 0
+}
+
+// RemoteWeb identifies a repository on a hosting service
+type RemoteWeb struct {
+	Kind string // forge kind guessed from the host name, "" if unknown
+	Host string // e.g. github.com
+	Path string // owner/repo; GitLab subgroups add more segments
+}
+
+// ParseRemoteURL parses the SSH (scp-like or ssh://) or HTTPS form of a remote URL
+func ParseRemoteURL(remote string) (*RemoteWeb, error) {
+	var host, path string
+	if strings.Contains(remote, "://") {
+		u, err := url.Parse(remote)
+		if err != nil {
+			return nil, err
+		}
+		host, path = u.Hostname(), u.Path
+	} else {
+		// user@host:owner/repo.git
+		hostPart, pathPart, ok := strings.Cut(remote, ":")
+		if !ok {
+			return nil, fmt.Errorf("not a remote URL: %s", remote)
+		}
+		if _, h, found := strings.Cut(hostPart, "@"); found {
+			hostPart = h
+		}
+		host, path = hostPart, pathPart
+	}
+
+	// SSH over port 443 uses separate host names
+	host = strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(host), "ssh."), "altssh.")
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if host == "" || strings.Count(path, "/") < 1 {
+		return nil, fmt.Errorf("no host and owner/repository in remote URL: %s", remote)
+	}
+
+	web := &RemoteWeb{Host: host, Path: path}
+	switch {
+	case strings.Contains(host, "github"):
+		web.Kind = "github"
+	case strings.Contains(host, "gitlab"):
+		web.Kind = "gitlab"
+	case strings.Contains(host, "gitea"), strings.Contains(host, "forgejo"), host == "codeberg.org":
+		web.Kind = "gitea"
+	case strings.Contains(host, "bitbucket"):
+		web.Kind = "bitbucket"
+	}
+	return web, nil
+}
+
+// GetRemoteWeb returns the web interface of origin, or of the first remote
+// by name if there is no origin
+func GetRemoteWeb(repoPath string) (*RemoteWeb, error) {
+	remotes, err := GetRemotes(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(remotes) == 0 {
+		return nil, fmt.Errorf("repository has no remotes")
+	}
+	sort.Slice(remotes, func(i, j int) bool {
+		if (remotes[i].Name == "origin") != (remotes[j].Name == "origin") {
+			return remotes[i].Name == "origin"
+		}
+		return remotes[i].Name < remotes[j].Name
+	})
+	fetchURL := remotes[0].FetchURL
+	if fetchURL == "" {
+		fetchURL = remotes[0].PushURL
+	}
+	return ParseRemoteURL(fetchURL)
+}
+
+// DefaultBranch returns the branch origin's HEAD points at, falling back to
+// a local main or master
+func DefaultBranch(repoPath string) (string, error) {
+	if ref, err := runGit(repoPath, "symbolic-ref", "--quiet", "--short", "refs/remotes/origin/HEAD"); err == nil && ref != "" {
+		return strings.TrimPrefix(ref, "origin/"), nil
+	}
+	for _, name := range []string{"main", "master"} {
+		if _, err := runGit(repoPath, "rev-parse", "--verify", "--quiet", "refs/heads/"+name); err == nil {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("no default branch found")
+}
+
+// MergedBranches returns the local branches fully merged into the default
+// branch, other than the default branch itself and the current branch
+func MergedBranches(repoPath string) (base string, merged []string, err error) {
+	base, err = DefaultBranch(repoPath)
+	if err != nil {
+		return "", nil, err
+	}
+	target := "refs/heads/" + base
+	if _, err := runGit(repoPath, "rev-parse", "--verify", "--quiet", target); err != nil {
+		// Only the remote has it
+		target = "refs/remotes/origin/" + base
+	}
+
+	out, err := runGit(repoPath, "for-each-ref", "--merged="+target, "--format=%(HEAD)%00%(refname:short)", "refs/heads")
+	if err != nil {
+		return "", nil, err
+	}
+	for _, line := range strings.Split(out, "\n") {
+		current, name, ok := strings.Cut(line, "\x00")
+		if !ok || current == "*" || name == base {
+			continue
+		}
+		merged = append(merged, name)
+	}
+	return base, merged, nil
+}
+
+// DeleteBranches deletes local branches whether or not git thinks they are
+// merged: git branch -d compares with the upstream or HEAD, not the base
+// MergedBranches checked them against. Each is deleted on its own so one
+// failing doesn't keep the rest; the error names those that failed.
+func DeleteBranches(repoPath string, names []string) error {
+	var failed []string
+	var errs []error
+	for _, name := range names {
+		if _, err := runGit(repoPath, "branch", "-D", "--", name); err != nil {
+			failed = append(failed, name)
+			errs = append(errs, err)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to delete %s: %w", strings.Join(failed, ", "), errors.Join(errs...))
+	}
+	return nil
+}
@@ -1,11 +1,15 @@
 package git
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestIsBinaryFile(t *testing.T) {
@@ -422,6 +426,7 @@ func TestGitOpType(t *testing.T) {
 		{OpFetch, "fetch"},
 		{OpPull, "pull"},
 		{OpPush, "push"},
+		{OpFetchPrune, "fetch --prune"},
 	}
 
 	for _, test := range ops {
@@ -435,4 +440,1775 @@ func TestGitOpType(t *testing.T) {
 	if got := unknown.String(); got != "unknown" {
 		t.Errorf("Unknown GitOp.String() = %q, want %q", got, "unknown")
 	}
-}
\ No newline at end of file
+}
+func TestGrep(t *testing.T) {
+	repo := initTestRepo(t)
+	var many strings.Builder
+	for i := range 1000 {
+		fmt.Fprintf(&many, "needle %d\n", i)
+	}
+	for name, content := range map[string]string{
+		"src/a:b.go": "package a\nfunc GetCommits() {}\n",
+		"many.txt":   many.String(),
+	} {
+		path := filepath.Join(repo, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := runGit(repo, "add", "."); err != nil {
+		t.Fatal(err)
+	}
+
+	matches, err := Grep(repo, "func GetCommits(", 10)
+	if err != nil {
+		t.Fatalf("Grep failed: %v", err)
+	}
+	if len(matches) != 1 || matches[0].Path != "src/a:b.go" || matches[0].Line != 2 || matches[0].Text != "func GetCommits() {}" {
+		t.Errorf("Expected the match in src/a:b.go, got %+v", matches)
+	}
+
+	// Reading stops at the limit
+	matches, err = Grep(repo, "needle", 10)
+	if err != nil || len(matches) != 10 {
+		t.Errorf("Expected 10 matches, got %d, %v", len(matches), err)
+	}
+
+	// No matches is not an error
+	matches, err = Grep(repo, "kvist-pattern-that-does-not-exist-"+"anywhere", 10)
+	if err != nil {
+		t.Fatalf("Grep with no matches failed: %v", err)
+	}
+	if len(matches) != 0 {
+		t.Errorf("Expected no matches, got %d", len(matches))
+	}
+}
+
+// initTestRepo creates a throwaway repository with a single commit on main
+func initTestRepo(t *testing.T) string {
+	t.Helper()
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"commit", "-q", "--allow-empty", "-m", "initial"},
+	} {
+		if _, err := runGit(dir, args...); err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+	}
+	return dir
+}
+
+// writeFile writes content to name in repo
+func writeFile(t *testing.T, repo, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(repo, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// commitFile writes content to name in repo and commits it, with name as
+// the subject
+func commitFile(t *testing.T, repo, name, content string) {
+	t.Helper()
+	writeFile(t, repo, name, content)
+	mustGit(t, repo, "add", name)
+	mustGit(t, repo, "commit", "-q", "-m", name)
+}
+
+// mustGit runs a git command that sets up a test, failing the test if it fails
+func mustGit(t *testing.T, repo string, args ...string) string {
+	t.Helper()
+	out, err := runGit(repo, args...)
+	if err != nil {
+		t.Fatalf("git %v failed: %v", args, err)
+	}
+	return out
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	repo := initTestRepo(t)
+
+	snap, err := TakeSnapshot(repo, "checkout")
+	if err != nil {
+		t.Fatalf("TakeSnapshot failed: %v", err)
+	}
+	if snap.Branch != "main" {
+		t.Errorf("Expected snapshot branch main, got %q", snap.Branch)
+	}
+
+	// Move to a new branch and advance main behind its back
+	if err := CreateBranch(repo, "feature", "", true); err != nil {
+		t.Fatalf("CreateBranch failed: %v", err)
+	}
+	if _, err := runGit(repo, "commit", "-q", "--allow-empty", "-m", "feature work"); err != nil {
+		t.Fatalf("git commit failed: %v", err)
+	}
+	if _, err := runGit(repo, "branch", "-f", "main", "HEAD"); err != nil {
+		t.Fatalf("git branch -f failed: %v", err)
+	}
+
+	if err := RestoreSnapshot(snap); err != nil {
+		t.Fatalf("RestoreSnapshot failed: %v", err)
+	}
+
+	if branch, _ := GetCurrentBranch(repo); branch != "main" {
+		t.Errorf("Expected to be back on main, got %q", branch)
+	}
+	if head, _ := runGit(repo, "rev-parse", "HEAD"); head != snap.Head {
+		t.Errorf("Expected HEAD %s, got %s", snap.Head, head)
+	}
+	// feature has a commit that is no longer merged anywhere, so it must survive
+	if tips, _ := getBranchTips(repo); tips["feature"] == "" {
+		t.Errorf("Expected unmerged branch feature to be kept, got %v", tips)
+	}
+}
+
+func TestListTreeAndFileAtRevision(t *testing.T) {
+	repo := initTestRepo(t)
+
+	if err := os.MkdirAll(filepath.Join(repo, "docs"), 0755); err != nil {
+		t.Fatalf("Failed to create docs dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "docs", "guide.md"), []byte("# Guide\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "README.md"), []byte("hello\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	for _, args := range [][]string{{"add", "."}, {"commit", "-q", "-m", "add files"}} {
+		if _, err := runGit(repo, args...); err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+	}
+
+	entries, err := ListTree(repo, "HEAD", "")
+	if err != nil {
+		t.Fatalf("ListTree failed: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Name != "docs" || !entries[0].IsDir() || entries[1].Name != "README.md" {
+		t.Fatalf("Expected [docs README.md] with docs first, got %+v", entries)
+	}
+
+	entries, err = ListTree(repo, "HEAD", "docs")
+	if err != nil {
+		t.Fatalf("ListTree(docs) failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Path != "docs/guide.md" || entries[0].Size != 8 {
+		t.Fatalf("Expected docs/guide.md (8 bytes), got %+v", entries)
+	}
+
+	content, err := GetFileAtRevision(repo, "HEAD", "docs/guide.md")
+	if err != nil {
+		t.Fatalf("GetFileAtRevision failed: %v", err)
+	}
+	if content != "# Guide\n" {
+		t.Errorf("Unexpected content %q", content)
+	}
+
+	files, err := GetCommitFiles(repo, "HEAD")
+	if err != nil {
+		t.Fatalf("GetCommitFiles failed: %v", err)
+	}
+	if len(files) != 2 || files[0] != "README.md" || files[1] != "docs/guide.md" {
+		t.Errorf("Expected [README.md docs/guide.md], got %v", files)
+	}
+}
+
+func TestResolveCommitAndIsAncestor(t *testing.T) {
+	repo := initTestRepo(t)
+	first, err := runGit(repo, "rev-parse", "HEAD")
+	if err != nil {
+		t.Fatalf("rev-parse failed: %v", err)
+	}
+	if _, err := runGit(repo, "commit", "-q", "--allow-empty", "-m", "second"); err != nil {
+		t.Fatalf("commit failed: %v", err)
+	}
+
+	hash, err := ResolveCommit(repo, first[:7])
+	if err != nil {
+		t.Fatalf("ResolveCommit failed: %v", err)
+	}
+	if hash != first {
+		t.Errorf("Expected %s, got %s", first, hash)
+	}
+	if _, err := ResolveCommit(repo, "does-not-exist"); err == nil {
+		t.Error("Expected error for unknown ref")
+	}
+
+	if ok, err := IsAncestor(repo, first, "HEAD"); err != nil || !ok {
+		t.Errorf("Expected first commit to be an ancestor of HEAD, got %v, %v", ok, err)
+	}
+	head, _ := ResolveCommit(repo, "HEAD")
+	if ok, err := IsAncestor(repo, head, first); err != nil || ok {
+		t.Errorf("Expected HEAD not to be an ancestor of first commit, got %v, %v", ok, err)
+	}
+}
+
+func TestFirstChangedLine(t *testing.T) {
+	tests := []struct {
+		name string
+		diff string
+		want int
+	}{
+		{"no diff", "", 0},
+		{"addition after context", "--- a/f\n+++ b/f\n@@ -10,4 +10,5 @@ func x() {\n a\n b\n+c\n d\n", 12},
+		{"removal", "--- a/f\n+++ b/f\n@@ -3,3 +3,2 @@\n a\n-b\n c\n", 4},
+		{"new file", "--- /dev/null\n+++ b/f\n@@ -0,0 +1,2 @@\n+a\n+b\n", 1},
+		{"deleted file", "--- a/f\n+++ /dev/null\n@@ -1,2 +0,0 @@\n-a\n-b\n", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FirstChangedLine(tt.diff); got != tt.want {
+				t.Errorf("FirstChangedLine() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGrepMarkersAndBlameTimes(t *testing.T) {
+	repo := initTestRepo(t)
+	content := "package x\n// TODO: tidy up\nvar todos = 1\n// FIXME broken\n"
+	if err := os.WriteFile(filepath.Join(repo, "x.go"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"add", "x.go"},
+		{"commit", "-q", "-m", "add x", "--date", "2020-01-02T03:04:05Z"},
+	} {
+		if _, err := runGit(repo, args...); err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+	}
+	// Untracked files are searched too
+	if err := os.WriteFile(filepath.Join(repo, "new.txt"), []byte("HACK here\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	matches, err := GrepMarkers(repo, []string{"TODO", "FIXME", "HACK"}, 0)
+	if err != nil {
+		t.Fatalf("GrepMarkers failed: %v", err)
+	}
+	var got []string
+	for _, m := range matches {
+		got = append(got, fmt.Sprintf("%s:%d", m.Path, m.Line))
+	}
+	want := []string{"new.txt:1", "x.go:2", "x.go:4"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("Expected matches %v, got %v", want, got)
+	}
+
+	times, err := BlameTimes(repo, "x.go", []int{2, 4})
+	if err != nil {
+		t.Fatalf("BlameTimes failed: %v", err)
+	}
+	for _, line := range []int{2, 4} {
+		if got := times[line].UTC().Year(); got != 2020 {
+			t.Errorf("Expected line %d authored in 2020, got %v", line, times[line])
+		}
+	}
+}
+
+func TestParseRemoteURL(t *testing.T) {
+	tests := []struct {
+		remote string
+		want   RemoteWeb
+	}{
+		{"git@github.com:asbjornb/kvist.git", RemoteWeb{"github", "github.com", "asbjornb/kvist"}},
+		{"https://github.com/asbjornb/kvist", RemoteWeb{"github", "github.com", "asbjornb/kvist"}},
+		{"ssh://git@ssh.github.com:443/asbjornb/kvist.git", RemoteWeb{"github", "github.com", "asbjornb/kvist"}},
+		{"https://gitlab.com/group/sub/project.git", RemoteWeb{"gitlab", "gitlab.com", "group/sub/project"}},
+		{"https://user@bitbucket.org/team/repo.git", RemoteWeb{"bitbucket", "bitbucket.org", "team/repo"}},
+		{"git@codeberg.org:owner/repo.git", RemoteWeb{"gitea", "codeberg.org", "owner/repo"}},
+		{"https://git.example.com/owner/repo.git", RemoteWeb{"", "git.example.com", "owner/repo"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.remote, func(t *testing.T) {
+			web, err := ParseRemoteURL(tt.remote)
+			if err != nil {
+				t.Fatalf("ParseRemoteURL() error: %v", err)
+			}
+			if *web != tt.want {
+				t.Errorf("ParseRemoteURL() = %+v, want %+v", *web, tt.want)
+			}
+		})
+	}
+
+	for _, remote := range []string{"/srv/git/repo.git", "file:///srv/git/repo.git", "git@github.com:kvist"} {
+		if _, err := ParseRemoteURL(remote); err == nil {
+			t.Errorf("ParseRemoteURL(%q) should fail", remote)
+		}
+	}
+}
+
+func TestDefaultBranch(t *testing.T) {
+	repo := initTestRepo(t)
+	if got, err := DefaultBranch(repo); err != nil || got != "main" {
+		t.Errorf("DefaultBranch() = %q, %v, want main", got, err)
+	}
+
+	head, _ := ResolveCommit(repo, "HEAD")
+	for _, args := range [][]string{
+		{"update-ref", "refs/remotes/origin/trunk", head},
+		{"symbolic-ref", "refs/remotes/origin/HEAD", "refs/remotes/origin/trunk"},
+	} {
+		if _, err := runGit(repo, args...); err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+	}
+	if got, err := DefaultBranch(repo); err != nil || got != "trunk" {
+		t.Errorf("DefaultBranch() = %q, %v, want trunk", got, err)
+	}
+}
+
+func TestGetBranchesTracking(t *testing.T) {
+	repo := initTestRepo(t)
+	head, _ := ResolveCommit(repo, "HEAD")
+	for _, args := range [][]string{
+		{"branch", "feature"},
+		{"branch", "--set-upstream-to=main", "feature"},
+		{"checkout", "-q", "feature"},
+		{"commit", "-q", "--allow-empty", "-m", "one"},
+		{"commit", "-q", "--allow-empty", "-m", "two"},
+		{"update-ref", "refs/remotes/origin/main", head},
+		{"update-ref", "refs/remotes/origin/review", head},
+	} {
+		if _, err := runGit(repo, args...); err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+	}
+
+	branches, err := GetBranches(repo)
+	if err != nil {
+		t.Fatalf("GetBranches failed: %v", err)
+	}
+	want := []Branch{
+		{Name: "feature", IsCurrent: true, Upstream: "main", Ahead: 2},
+		{Name: "main"},
+		{Name: "review (remote)"},
+	}
+	if len(branches) != len(want) {
+		t.Fatalf("Expected %+v, got %+v", want, branches)
+	}
+	for i := range want {
+		if branches[i] != want[i] {
+			t.Errorf("Branch %d: expected %+v, got %+v", i, want[i], branches[i])
+		}
+	}
+}
+
+func TestParseTrack(t *testing.T) {
+	tests := []struct {
+		track         string
+		ahead, behind int
+		gone          bool
+	}{
+		{"", 0, 0, false},
+		{"ahead 3", 3, 0, false},
+		{"behind 2", 0, 2, false},
+		{"ahead 1, behind 4", 1, 4, false},
+		{"gone", 0, 0, true},
+	}
+	for _, tt := range tests {
+		ahead, behind, gone := parseTrack(tt.track)
+		if ahead != tt.ahead || behind != tt.behind || gone != tt.gone {
+			t.Errorf("parseTrack(%q) = %d, %d, %v", tt.track, ahead, behind, gone)
+		}
+	}
+}
+
+func TestMergedAndDeleteBranches(t *testing.T) {
+	repo := initTestRepo(t)
+	for _, args := range [][]string{
+		{"branch", "done"},
+		{"checkout", "-q", "-b", "wip"},
+		{"commit", "-q", "--allow-empty", "-m", "unmerged"},
+		{"checkout", "-q", "main"},
+		{"checkout", "-q", "-b", "current"},
+	} {
+		if _, err := runGit(repo, args...); err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+	}
+
+	base, merged, err := MergedBranches(repo)
+	if err != nil {
+		t.Fatalf("MergedBranches failed: %v", err)
+	}
+	if base != "main" || len(merged) != 1 || merged[0] != "done" {
+		t.Errorf("Expected [done] merged into main, got %v into %s", merged, base)
+	}
+
+	// One failing doesn't keep the others, merged or not, from going
+	err = DeleteBranches(repo, []string{"done", "missing", "wip"})
+	if err == nil || !strings.Contains(err.Error(), "missing") || strings.Contains(err.Error(), "wip,") {
+		t.Errorf("Expected only missing to fail, got %v", err)
+	}
+	if out, _ := runGit(repo, "branch", "--format=%(refname:short)"); out != "current\nmain" {
+		t.Errorf("Expected only current and main left, got %q", out)
+	}
+}
+
+func TestCreateBranchAtStartPoint(t *testing.T) {
+	repo := initTestRepo(t)
+	first, _ := ResolveCommit(repo, "HEAD")
+	if _, err := runGit(repo, "commit", "-q", "--allow-empty", "-m", "second"); err != nil {
+		t.Fatalf("commit failed: %v", err)
+	}
+
+	if err := CreateBranch(repo, "old", first, false); err != nil {
+		t.Fatalf("CreateBranch failed: %v", err)
+	}
+	if tip, _ := ResolveCommit(repo, "old"); tip != first {
+		t.Errorf("Expected old at %s, got %s", first, tip)
+	}
+	if branch, _ := GetCurrentBranch(repo); branch != "main" {
+		t.Errorf("Expected to stay on main, got %q", branch)
+	}
+
+	if err := CreateBranch(repo, "older", first[:7], true); err != nil {
+		t.Fatalf("CreateBranch failed: %v", err)
+	}
+	if branch, _ := GetCurrentBranch(repo); branch != "older" {
+		t.Errorf("Expected to be on older, got %q", branch)
+	}
+}
+
+func TestCheckoutCommit(t *testing.T) {
+	repo := initTestRepo(t)
+	first, _ := ResolveCommit(repo, "HEAD")
+	if _, err := runGit(repo, "commit", "-q", "--allow-empty", "-m", "second"); err != nil {
+		t.Fatalf("commit failed: %v", err)
+	}
+
+	if err := CheckoutCommit(repo, first); err != nil {
+		t.Fatalf("CheckoutCommit failed: %v", err)
+	}
+	if branch, err := GetCurrentBranch(repo); err != nil || branch != "" {
+		t.Errorf("Expected no current branch when detached, got %q, %v", branch, err)
+	}
+	if head, _ := ResolveCommit(repo, "HEAD"); head != first {
+		t.Errorf("Expected HEAD at %s, got %s", first, head)
+	}
+}
+
+func TestRecentBranches(t *testing.T) {
+	repo := initTestRepo(t)
+	for _, branch := range []string{"feature", "fix", "old"} {
+		if err := CreateBranch(repo, branch, "", false); err != nil {
+			t.Fatalf("CreateBranch failed: %v", err)
+		}
+	}
+	for _, branch := range []string{"old", "feature", "main", "fix", "feature"} {
+		if err := CheckoutBranch(repo, branch); err != nil {
+			t.Fatalf("CheckoutBranch failed: %v", err)
+		}
+	}
+	if _, err := runGit(repo, "branch", "-D", "old"); err != nil {
+		t.Fatalf("deleting old failed: %v", err)
+	}
+
+	branches, err := RecentBranches(repo, 10)
+	if err != nil {
+		t.Fatalf("RecentBranches failed: %v", err)
+	}
+	if got := strings.Join(branches, ","); got != "feature,fix,main" {
+		t.Errorf("Expected feature,fix,main, got %s", got)
+	}
+	if branches, _ := RecentBranches(repo, 2); len(branches) != 2 {
+		t.Errorf("Expected the list cut at 2, got %v", branches)
+	}
+
+	// No commits yet means no reflog, which isn't an error
+	empty := t.TempDir()
+	if _, err := runGit(empty, "init", "-q"); err != nil {
+		t.Fatalf("git init failed: %v", err)
+	}
+	if branches, err := RecentBranches(empty, 10); err != nil || len(branches) != 0 {
+		t.Errorf("Expected no branches and no error without commits, got %v, %v", branches, err)
+	}
+}
+
+func TestSetUpstream(t *testing.T) {
+	repo := initTestRepo(t)
+	if _, err := runGit(repo, "remote", "add", "origin", repo); err != nil {
+		t.Fatalf("adding origin failed: %v", err)
+	}
+	for _, ref := range []string{"refs/remotes/origin/main", "refs/remotes/origin/dev"} {
+		if _, err := runGit(repo, "update-ref", ref, "HEAD"); err != nil {
+			t.Fatalf("update-ref failed: %v", err)
+		}
+	}
+	if _, err := runGit(repo, "symbolic-ref", "refs/remotes/origin/HEAD", "refs/remotes/origin/main"); err != nil {
+		t.Fatalf("symbolic-ref failed: %v", err)
+	}
+
+	remotes, err := RemoteBranches(repo)
+	if err != nil || strings.Join(remotes, ",") != "origin/dev,origin/main" {
+		t.Errorf("Expected origin/dev,origin/main without HEAD, got %v, %v", remotes, err)
+	}
+
+	if err := SetUpstream(repo, "main", "origin/dev"); err != nil {
+		t.Fatalf("SetUpstream failed: %v", err)
+	}
+	if upstream, _ := runGit(repo, "rev-parse", "--abbrev-ref", "main@{upstream}"); upstream != "origin/dev" {
+		t.Errorf("Expected main to track origin/dev, got %q", upstream)
+	}
+	if err := UnsetUpstream(repo, "main"); err != nil {
+		t.Fatalf("UnsetUpstream failed: %v", err)
+	}
+	if _, err := runGit(repo, "rev-parse", "--abbrev-ref", "main@{upstream}"); err == nil {
+		t.Error("Expected main to track nothing")
+	}
+}
+
+func TestGetRefs(t *testing.T) {
+	repo := initTestRepo(t)
+	for _, args := range [][]string{
+		{"branch", "dev"},
+		{"tag", "v1.0.0"},
+		{"tag", "-a", "-m", "release", "v1.1.0"},
+	} {
+		if _, err := runGit(repo, args...); err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+	}
+	head, _ := runGit(repo, "rev-parse", "HEAD")
+
+	refs, err := GetRefs(repo)
+	if err != nil {
+		t.Fatalf("GetRefs failed: %v", err)
+	}
+	got := strings.Join(refs[head], ",")
+	if got != "dev,main,tag: v1.0.0,tag: v1.1.0" {
+		t.Errorf("Expected the branches and both tags at HEAD, got %q", got)
+	}
+}
+
+func TestTagsAndReleaseCommits(t *testing.T) {
+	// Tags sort by creation date, so each step happens a day later
+	t.Setenv("GIT_COMMITTER_DATE", "2026-01-01T12:00:00")
+	repo := initTestRepo(t)
+	for i, args := range [][]string{
+		{"tag", "v1.0.0"},
+		{"commit", "-q", "--allow-empty", "-m", "feat: one"},
+		{"commit", "-q", "--allow-empty", "-m", "fix: two"},
+		{"tag", "-a", "-m", "release", "v1.1.0"},
+		{"commit", "-q", "--allow-empty", "-m", "docs: three"},
+	} {
+		t.Setenv("GIT_COMMITTER_DATE", fmt.Sprintf("2026-01-%02dT12:00:00", i+2))
+		if _, err := runGit(repo, args...); err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+	}
+
+	tags, err := Tags(repo)
+	if err != nil || strings.Join(tags, ",") != "v1.1.0,v1.0.0" {
+		t.Errorf("Expected v1.1.0,v1.0.0, got %v, %v", tags, err)
+	}
+
+	subjects := func(from, to string) string {
+		commits, err := ReleaseCommits(repo, from, to, 100)
+		if err != nil {
+			t.Fatalf("ReleaseCommits(%q, %q) failed: %v", from, to, err)
+		}
+		var s []string
+		for _, c := range commits {
+			s = append(s, c.Subject)
+		}
+		return strings.Join(s, ",")
+	}
+	if got := subjects("v1.0.0", "v1.1.0"); got != "fix: two,feat: one" {
+		t.Errorf("Expected the commits between the tags, got %q", got)
+	}
+	if got := subjects("v1.1.0", "HEAD"); got != "docs: three" {
+		t.Errorf("Expected the unreleased commit, got %q", got)
+	}
+	if got := subjects("", "v1.0.0"); got != "initial" {
+		t.Errorf("Expected the first release's history, got %q", got)
+	}
+
+	for rev, want := range map[string]string{"v1.1.0": "v1.0.0", "v1.0.0": ""} {
+		if prev, err := PreviousTag(repo, rev); err != nil || prev != want {
+			t.Errorf("Expected %q before %s, got %q, %v", want, rev, prev, err)
+		}
+	}
+	if latest, err := LatestTag(repo, "HEAD"); err != nil || latest != "v1.1.0" {
+		t.Errorf("Expected v1.1.0 as the latest release, got %q, %v", latest, err)
+	}
+	if latest, err := LatestTag(initTestRepo(t), "HEAD"); err != nil || latest != "" {
+		t.Errorf("Expected no release without tags, got %q, %v", latest, err)
+	}
+}
+
+func TestSigningConfig(t *testing.T) {
+	repo := initTestRepo(t)
+	// Signing turned on globally shows, but isn't the repository's own
+	global := filepath.Join(t.TempDir(), "gitconfig")
+	if err := os.WriteFile(global, []byte("[commit]\n\tgpgsign = true\n"), 0644); err != nil {
+		t.Fatalf("Failed to write global config: %v", err)
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", global)
+
+	signing, err := GetSigning(repo)
+	if err != nil {
+		t.Fatalf("GetSigning failed: %v", err)
+	}
+	if !signing.Commits || signing.Tags || signing.Push != "" || signing.IsLocal(CommitSigningKey) {
+		t.Errorf("Expected only global commit signing, got %+v", signing)
+	}
+
+	for key, value := range map[string]string{CommitSigningKey: "false", TagSigningKey: "yes", PushSigningKey: "if-asked"} {
+		if err := SetLocalConfig(repo, key, value); err != nil {
+			t.Fatalf("SetLocalConfig(%s) failed: %v", key, err)
+		}
+	}
+	signing, _ = GetSigning(repo)
+	if signing.Commits || !signing.Tags || signing.Push != "if-asked" || !signing.IsLocal(CommitSigningKey) {
+		t.Errorf("Expected the repository's config to win, got %+v", signing)
+	}
+
+	if err := SetLocalConfig(repo, CommitSigningKey, ""); err != nil {
+		t.Fatalf("unsetting failed: %v", err)
+	}
+	signing, _ = GetSigning(repo)
+	if !signing.Commits || signing.IsLocal(CommitSigningKey) {
+		t.Errorf("Expected the global setting back, got %+v", signing)
+	}
+
+	// Without a key to sign with only the annotated tag can be made
+	if err := SetLocalConfig(repo, TagSigningKey, ""); err != nil {
+		t.Fatalf("unsetting failed: %v", err)
+	}
+	if err := CreateTag(repo, "v1.0.0", "HEAD", "", false); err != nil {
+		t.Fatalf("CreateTag failed: %v", err)
+	}
+	if kind, _ := runGit(repo, "cat-file", "-t", "v1.0.0"); kind != "tag" {
+		t.Errorf("Expected an annotated tag, got %q", kind)
+	}
+}
+
+func TestCommitStaged(t *testing.T) {
+	repo := initTestRepo(t)
+	if err := os.WriteFile(filepath.Join(repo, "a.txt"), []byte("a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := StageFile(repo, "a.txt"); err != nil {
+		t.Fatalf("StageFile failed: %v", err)
+	}
+
+	message := "feat(cli): add a\n\nWith a body.\n\nRefs: #1"
+	if err := CommitStaged(repo, message, false, nil); err != nil {
+		t.Fatalf("CommitStaged failed: %v", err)
+	}
+	if got, _ := runGit(repo, "log", "-1", "--format=%B"); got != message {
+		t.Errorf("Expected message %q, got %q", message, got)
+	}
+
+	// Nothing left to commit
+	if err := CommitStaged(repo, "chore: empty", false, nil); err == nil {
+		t.Error("Expected an error with nothing staged")
+	}
+}
+
+func TestCommitStagedHooks(t *testing.T) {
+	repo := initTestRepo(t)
+	if hooks := CommitHooks(repo); len(hooks) != 0 {
+		t.Errorf("Expected no hooks, got %v", hooks)
+	}
+
+	hook := filepath.Join(repo, ".git", "hooks", "pre-commit")
+	if err := os.MkdirAll(filepath.Dir(hook), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(hook, []byte("#!/bin/sh\necho lint: 2 problems\nexit 1\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if hooks := CommitHooks(repo); len(hooks) != 1 || hooks[0] != "pre-commit" {
+		t.Errorf("Expected [pre-commit], got %v", hooks)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "a.txt"), []byte("a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := StageFile(repo, "a.txt"); err != nil {
+		t.Fatalf("StageFile failed: %v", err)
+	}
+
+	var lines []string
+	output := func(line string) { lines = append(lines, line) }
+	if err := CommitStaged(repo, "chore: rejected", false, output); err == nil || !strings.Contains(err.Error(), "2 problems") {
+		t.Errorf("Expected the hook to reject the commit, got %v", err)
+	}
+	if len(lines) != 1 || lines[0] != "lint: 2 problems" {
+		t.Errorf("Expected the hook output, got %q", lines)
+	}
+
+	if err := CommitStaged(repo, "chore: bypassed", true, nil); err != nil {
+		t.Errorf("Expected --no-verify to skip the hook, got %v", err)
+	}
+}
+
+func TestGetConfig(t *testing.T) {
+	repo := initTestRepo(t)
+	if value, err := GetConfig(repo, "kvist.conventionalCommits"); err != nil || value != "" {
+		t.Errorf("Expected unset key to be empty, got %q, %v", value, err)
+	}
+	if _, err := runGit(repo, "config", "kvist.conventionalCommits", "true"); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := GetConfig(repo, "kvist.conventionalCommits"); err != nil || value != "true" {
+		t.Errorf("Expected true, got %q, %v", value, err)
+	}
+}
+
+func TestUnpushed(t *testing.T) {
+	repo := initTestRepo(t)
+	if n, oldest, err := Unpushed(repo); err != nil || n != 0 || !oldest.IsZero() {
+		t.Errorf("Expected nothing unpushed without remotes, got %d, %v, %v", n, oldest, err)
+	}
+
+	if _, err := runGit(repo, "remote", "add", "origin", "https://example.com/repo.git"); err != nil {
+		t.Fatal(err)
+	}
+	if n, oldest, err := Unpushed(repo); err != nil || n != 1 || oldest.IsZero() {
+		t.Errorf("Expected the initial commit unpushed before any push, got %d, %v, %v", n, oldest, err)
+	}
+
+	for _, args := range [][]string{
+		{"update-ref", "refs/remotes/origin/main", "HEAD"},
+		{"commit", "-q", "--allow-empty", "-m", "local one"},
+		{"commit", "-q", "--allow-empty", "-m", "local two"},
+	} {
+		if _, err := runGit(repo, args...); err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+	}
+	if n, oldest, err := Unpushed(repo); err != nil || n != 2 || oldest.IsZero() {
+		t.Errorf("Expected 2 unpushed commits, got %d, %v, %v", n, oldest, err)
+	}
+}
+
+func TestRiskyStates(t *testing.T) {
+	repo := initTestRepo(t)
+	if op, err := InProgressOperation(repo); op != "" || err != nil {
+		t.Errorf("Expected no operation in progress, got %q, %v", op, err)
+	}
+
+	// Conflicting edits to one file on two branches
+	writeFile(t, repo, "f.txt", "base\n")
+	for _, args := range [][]string{
+		{"add", "f.txt"},
+		{"commit", "-q", "-m", "base"},
+		{"update-ref", "refs/remotes/origin/main", "HEAD"},
+		{"checkout", "-q", "-b", "other"},
+	} {
+		if _, err := runGit(repo, args...); err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+	}
+	writeFile(t, repo, "f.txt", "other\n")
+	mustGit(t, repo, "commit", "-q", "-am", "other")
+	mustGit(t, repo, "checkout", "-q", "main")
+	writeFile(t, repo, "f.txt", "main\n")
+	mustGit(t, repo, "commit", "-q", "-am", "main")
+	if _, err := runGit(repo, "merge", "other"); err == nil {
+		t.Fatal("Expected the merge to conflict")
+	}
+
+	if op, err := InProgressOperation(repo); op != "merge" || err != nil {
+		t.Errorf("Expected a merge in progress, got %q, %v", op, err)
+	}
+	if n, err := CountConflicts(repo); n != 1 || err != nil {
+		t.Errorf("Expected 1 conflicted file, got %d, %v", n, err)
+	}
+}
+
+func TestInitRepo(t *testing.T) {
+	initTestRepo(t) // for the identity
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := InitRepo(dir, "Initial commit"); err != nil {
+		t.Fatalf("InitRepo failed: %v", err)
+	}
+	commits, err := GetCommits(dir, 5)
+	if err != nil || len(commits) != 1 || commits[0].Subject != "Initial commit" {
+		t.Fatalf("Expected one initial commit, got %v, %v", commits, err)
+	}
+	if files, _ := runGit(dir, "ls-files"); files != "go.mod" {
+		t.Errorf("Expected go.mod to be committed, got %q", files)
+	}
+}
+
+func TestClone(t *testing.T) {
+	source := initTestRepo(t)
+	parent := t.TempDir()
+
+	var lines []string
+	if err := Clone(parent, source, "copy", func(line string) { lines = append(lines, line) }); err != nil {
+		t.Fatalf("Clone failed: %v", err)
+	}
+	if len(lines) == 0 || !strings.HasPrefix(lines[0], "Cloning into") {
+		t.Errorf("Expected clone progress, got %q", lines)
+	}
+	if _, err := os.Stat(filepath.Join(parent, "copy", ".git")); err != nil {
+		t.Errorf("Expected a repository in copy: %v", err)
+	}
+
+	if err := Clone(parent, source, "copy", nil); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Expected cloning over an existing directory to fail, got %v", err)
+	}
+}
+
+func TestRepoNameFromURL(t *testing.T) {
+	tests := map[string]string{
+		"https://github.com/asbjornb/kvist.git": "kvist",
+		"https://github.com/asbjornb/kvist":     "kvist",
+		"git@github.com:asbjornb/kvist.git":     "kvist",
+		"git@host:kvist.git":                    "kvist",
+		"ssh://git@host:22/group/sub/repo/":     "repo",
+		"/srv/git/project/.git":                 "project",
+	}
+	for url, want := range tests {
+		if got := RepoNameFromURL(url); got != want {
+			t.Errorf("RepoNameFromURL(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
+
+func TestGetIncomingCommits(t *testing.T) {
+	repo := initTestRepo(t)
+	if _, _, err := GetIncomingCommits(repo, 10); err == nil {
+		t.Error("Expected an error without origin/main")
+	}
+
+	// Pretend origin has moved on by two commits
+	for _, args := range [][]string{
+		{"commit", "-q", "--allow-empty", "-m", "upstream one"},
+		{"commit", "-q", "--allow-empty", "-m", "upstream two"},
+		{"update-ref", "refs/remotes/origin/main", "HEAD"},
+		{"reset", "-q", "--hard", "HEAD~2"},
+		{"commit", "-q", "--allow-empty", "-m", "local"},
+	} {
+		if _, err := runGit(repo, args...); err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+	}
+
+	upstream, commits, err := GetIncomingCommits(repo, 10)
+	if err != nil {
+		t.Fatalf("GetIncomingCommits failed: %v", err)
+	}
+	if upstream != "origin/main" {
+		t.Errorf("Expected origin/main, got %s", upstream)
+	}
+	if len(commits) != 2 || commits[0].Subject != "upstream two" || commits[1].Subject != "upstream one" {
+		t.Errorf("Expected the two upstream commits, got %+v", commits)
+	}
+}
+
+func TestFileCommitsAndRestoreFile(t *testing.T) {
+	repo := initTestRepo(t)
+	path := filepath.Join(repo, "notes.txt")
+	for _, version := range []string{"one\n", "two\n"} {
+		if err := os.WriteFile(path, []byte(version), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		for _, args := range [][]string{{"add", "notes.txt"}, {"commit", "-q", "-m", "notes " + version}} {
+			if _, err := runGit(repo, args...); err != nil {
+				t.Fatalf("git %v failed: %v", args, err)
+			}
+		}
+	}
+
+	commits, err := GetFileCommits(repo, "notes.txt", 10)
+	if err != nil {
+		t.Fatalf("GetFileCommits failed: %v", err)
+	}
+	if len(commits) != 2 {
+		t.Fatalf("Expected 2 commits touching notes.txt, got %d", len(commits))
+	}
+
+	if err := RestoreFile(repo, commits[1].Hash, "notes.txt"); err != nil {
+		t.Fatalf("RestoreFile failed: %v", err)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "one\n" {
+		t.Errorf("Expected the first version back, got %q", content)
+	}
+}
+
+func TestFileCommitsFollowRenames(t *testing.T) {
+	repo := initTestRepo(t)
+	content := "one\ntwo\nthree\nfour\nfive\n"
+	if err := os.WriteFile(filepath.Join(repo, "old.txt"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	for _, args := range [][]string{
+		{"add", "old.txt"}, {"commit", "-q", "-m", "add old"},
+		{"mv", "old.txt", "new name.txt"}, {"commit", "-q", "-m", "rename"},
+	} {
+		if _, err := runGit(repo, args...); err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+	}
+
+	commits, err := GetFileCommits(repo, "new name.txt", 10)
+	if err != nil {
+		t.Fatalf("GetFileCommits failed: %v", err)
+	}
+	if len(commits) != 2 {
+		t.Fatalf("Expected the history to go past the rename, got %+v", commits)
+	}
+	if commits[0].Path != "new name.txt" || commits[0].RenamedFrom != "old.txt" {
+		t.Errorf("Expected the rename boundary at the newest commit, got %+v", commits[0])
+	}
+	if commits[1].Path != "old.txt" || commits[1].RenamedFrom != "" {
+		t.Errorf("Expected the old path before the rename, got %+v", commits[1])
+	}
+
+	if err := os.WriteFile(filepath.Join(repo, "new name.txt"), []byte("changed\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := RestoreFileFrom(repo, commits[1].Hash, commits[1].Path, "new name.txt"); err != nil {
+		t.Fatalf("RestoreFileFrom failed: %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(repo, "new name.txt")); string(got) != content {
+		t.Errorf("Expected the content from before the rename, got %q", got)
+	}
+
+	// Into a directory that is gone, and a symlink as a symlink
+	if err := os.Symlink("new name.txt", filepath.Join(repo, "link")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	if _, err := runGit(repo, "add", "link"); err != nil {
+		t.Fatalf("git add failed: %v", err)
+	}
+	if _, err := runGit(repo, "commit", "-q", "-m", "link"); err != nil {
+		t.Fatalf("git commit failed: %v", err)
+	}
+	if err := RestoreFileFrom(repo, "HEAD", "old name.txt", "gone/old.txt"); err == nil {
+		t.Error("Expected restoring a path the commit doesn't have to fail")
+	}
+	if err := RestoreFileFrom(repo, commits[1].Hash, commits[1].Path, "gone/old.txt"); err != nil {
+		t.Fatalf("RestoreFileFrom into a new directory failed: %v", err)
+	}
+	if err := RestoreFileFrom(repo, "HEAD", "link", "gone/link"); err != nil {
+		t.Fatalf("RestoreFileFrom of a symlink failed: %v", err)
+	}
+	if target, err := os.Readlink(filepath.Join(repo, "gone", "link")); err != nil || target != "new name.txt" {
+		t.Errorf("Expected the symlink restored as one, got %q, %v", target, err)
+	}
+}
+
+func TestIgnoredAndClean(t *testing.T) {
+	repo := initTestRepo(t)
+	files := map[string]string{
+		".gitignore":     "build/\n*.log\n",
+		"build/out.bin":  "x",
+		"debug.log":      "x",
+		"scratch.txt":    "x",
+		"tmp/scratch.go": "x",
+	}
+	for name, content := range files {
+		path := filepath.Join(repo, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	for _, args := range [][]string{{"add", ".gitignore"}, {"commit", "-q", "-m", "ignore"}} {
+		if _, err := runGit(repo, args...); err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+	}
+
+	ignored, err := GetIgnored(repo)
+	if err != nil {
+		t.Fatalf("GetIgnored failed: %v", err)
+	}
+	if len(ignored) != 2 || ignored[0].Path != "build/" || ignored[1].Path != "debug.log" || !ignored[0].IsUntracked() {
+		t.Errorf("Expected [build/ debug.log], got %+v", ignored)
+	}
+
+	tests := []struct {
+		mode CleanMode
+		want []string
+	}{
+		{CleanUntracked, []string{"scratch.txt", "tmp/"}},
+		{CleanIgnored, []string{"build/", "debug.log"}},
+		{CleanAll, []string{"build/", "debug.log", "scratch.txt", "tmp/"}},
+	}
+	for _, tt := range tests {
+		paths, err := CleanPreview(repo, tt.mode)
+		if err != nil {
+			t.Fatalf("CleanPreview(%d) failed: %v", tt.mode, err)
+		}
+		if strings.Join(paths, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("CleanPreview(%d) = %v, want %v", tt.mode, paths, tt.want)
+		}
+	}
+
+	// Only what the preview listed goes, not what appeared since
+	preview, _ := CleanPreview(repo, CleanIgnored)
+	if err := os.WriteFile(filepath.Join(repo, "late.log"), []byte("new\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := Clean(repo, CleanIgnored, preview); err != nil {
+		t.Fatalf("Clean failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "late.log")); err != nil {
+		t.Error("Expected late.log, created after the preview, to be kept")
+	}
+	if _, err := os.Stat(filepath.Join(repo, "build")); !os.IsNotExist(err) {
+		t.Error("Expected build/ to be removed")
+	}
+	if _, err := os.Stat(filepath.Join(repo, "scratch.txt")); err != nil {
+		t.Error("Expected untracked scratch.txt to be kept")
+	}
+}
+
+func TestAddToGitignore(t *testing.T) {
+	repo := initTestRepo(t)
+	path := filepath.Join(repo, ".gitignore")
+	if err := os.WriteFile(path, []byte("*.log"), 0644); err != nil {
+		t.Fatalf("Failed to write .gitignore: %v", err)
+	}
+
+	for _, pattern := range []string{"/build/", "*.log", "/build/"} {
+		if err := AddToGitignore(repo, pattern); err != nil {
+			t.Fatalf("AddToGitignore(%q) failed: %v", pattern, err)
+		}
+	}
+	content, _ := os.ReadFile(path)
+	if string(content) != "*.log\n/build/\n" {
+		t.Errorf("Unexpected .gitignore %q", content)
+	}
+}
+
+func TestStreamProgress(t *testing.T) {
+	var lines []string
+	last := streamProgress(strings.NewReader("one\r\n  two  \n\nfetch 10%\rfetch 100%\n"), func(line string) {
+		lines = append(lines, line)
+	})
+	if want := []string{"one", "  two", "fetch 100%"}; !reflect.DeepEqual(lines, want) || last != "fetch 100%" {
+		t.Errorf("Streamed %q, last %q, want %q", lines, last, want)
+	}
+}
+
+func TestPromptTimeout(t *testing.T) {
+	PromptWaiting(true)
+	ctx, cancel := withPromptTimeout(200 * time.Millisecond)
+	defer cancel()
+	time.Sleep(400 * time.Millisecond)
+	if ctx.Err() != nil {
+		t.Fatal("Expected the timeout to wait while a prompt does")
+	}
+	PromptWaiting(false)
+	select {
+	case <-ctx.Done():
+	case <-time.After(2 * time.Second):
+		t.Error("Expected the timeout to run out once the prompt was answered")
+	}
+}
+
+func TestTracer(t *testing.T) {
+	dir := t.TempDir()
+	type call struct {
+		args     string
+		exitCode int
+	}
+	var calls []call
+	SetTracer(func(_ string, args []string, _ time.Duration, exitCode int) {
+		calls = append(calls, call{strings.Join(args, " "), exitCode})
+	})
+	defer SetTracer(nil)
+
+	runGit(dir, "init", "-q")
+	runGit(dir, "rev-parse", "--verify", "HEAD")
+	want := []call{{"init -q", 0}, {"rev-parse --verify HEAD", 128}}
+	if len(calls) != len(want) || calls[0] != want[0] || calls[1] != want[1] {
+		t.Errorf("Traced %+v, want %+v", calls, want)
+	}
+}
+
+func TestOpenRepositoryDetached(t *testing.T) {
+	repo := initTestRepo(t)
+	r, err := OpenRepository(repo)
+	if err != nil || r.CurrentBranch != "main" || r.DetachedHead != "" {
+		t.Fatalf("Expected main checked out, got %+v, %v", r, err)
+	}
+
+	head, _ := runGit(repo, "rev-parse", "HEAD")
+	runGit(repo, "checkout", "-q", "--detach")
+	r, err = OpenRepository(repo)
+	if err != nil || r.CurrentBranch != "" || r.DetachedHead != head {
+		t.Errorf("Expected HEAD detached at %s, got %+v, %v", head, r, err)
+	}
+}
+
+func TestReadObjectBatch(t *testing.T) {
+	repo := initTestRepo(t)
+	defer CloseBatches()
+
+	commitFile(t, repo, "a file.txt", "one\n")
+	if content, err := GetFileAtRevision(repo, "HEAD", "a file.txt"); err != nil || content != "one\n" {
+		t.Errorf("Expected one, got %q, %v", content, err)
+	}
+	// The same process has to see commits made after it started
+	commitFile(t, repo, "a file.txt", "two\n")
+	if content, err := GetFileAtRevision(repo, "HEAD", "a file.txt"); err != nil || content != "two\n" {
+		t.Errorf("Expected two, got %q, %v", content, err)
+	}
+
+	if _, _, err := readObject(repo, "HEAD:nothing"); !errors.Is(err, errObjectMissing) {
+		t.Errorf("Expected a missing object, got %v", err)
+	}
+	if _, err := GetFileAtRevision(repo, "HEAD", "nothing"); err == nil {
+		t.Error("Expected an error for a file that doesn't exist")
+	}
+	if content, err := GetFileAtRevision(repo, "HEAD~1", "a file.txt"); err != nil || content != "one\n" {
+		t.Errorf("Expected one after a missing object, got %q, %v", content, err)
+	}
+}
+
+func TestGetStatusBlobs(t *testing.T) {
+	repo := initTestRepo(t)
+	if err := os.WriteFile(filepath.Join(repo, "file.txt"), []byte("one\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(repo, "add", "file.txt")
+	runGit(repo, "commit", "-q", "-m", "add file")
+	if err := os.WriteFile(filepath.Join(repo, "file.txt"), []byte("two\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(repo, "add", "file.txt")
+
+	status, err := GetStatus(repo)
+	if err != nil || len(status.Files) != 1 {
+		t.Fatalf("Expected one changed file, got %+v, %v", status, err)
+	}
+	head, _ := runGit(repo, "rev-parse", "HEAD:file.txt")
+	index, _ := runGit(repo, "rev-parse", ":file.txt")
+	if file := status.Files[0]; file.HeadBlob != head || file.IndexBlob != index {
+		t.Errorf("Expected blobs %s and %s, got %s and %s", head, index, file.HeadBlob, file.IndexBlob)
+	}
+}
+
+func TestDiffStatus(t *testing.T) {
+	prev := &Status{Files: []FileStatus{
+		{Path: "kept.go", Unstaged: "modified", WorktreeMtime: 1},
+		{Path: "edited.go", Unstaged: "modified", WorktreeMtime: 1},
+		{Path: "gone.go", Unstaged: "untracked"},
+	}}
+	status := &Status{Files: []FileStatus{
+		{Path: "kept.go", Unstaged: "modified", WorktreeMtime: 1},
+		{Path: "edited.go", Unstaged: "modified", WorktreeMtime: 2},
+		{Path: "new.go", Unstaged: "untracked"},
+	}}
+
+	delta := DiffStatus(prev, status)
+	if !reflect.DeepEqual(delta, StatusDelta{Added: []string{"new.go"}, Removed: []string{"gone.go"}, Changed: []string{"edited.go"}}) {
+		t.Errorf("Unexpected delta %+v", delta)
+	}
+	if !DiffStatus(status, status).Empty() {
+		t.Error("Expected no changes against the same status")
+	}
+	if delta := DiffStatus(nil, status); len(delta.Added) != 3 {
+		t.Errorf("Expected every file added to a nil status, got %+v", delta)
+	}
+}
+
+func TestLargeRepoStatus(t *testing.T) {
+	repo := initTestRepo(t)
+	if err := os.WriteFile(filepath.Join(repo, "untracked.txt"), []byte("new\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	SetLargeRepo(repo, true)
+	defer SetLargeRepo(repo, false)
+	status, err := GetStatus(repo)
+	if err != nil || len(status.Files) != 0 {
+		t.Errorf("Expected untracked files hidden in large repo mode, got %+v, %v", status, err)
+	}
+	if commits, err := GetCommits(repo, 1); err != nil || len(commits) != 1 {
+		t.Errorf("Expected the log to work in large repo mode, got %v, %v", commits, err)
+	}
+
+	SetLargeRepo(repo, false)
+	if status, err := GetStatus(repo); err != nil || len(status.Files) != 1 {
+		t.Errorf("Expected the untracked file back, got %+v, %v", status, err)
+	}
+}
+
+func TestDiffOutputLimit(t *testing.T) {
+	repo := initTestRepo(t)
+	var content strings.Builder
+	for i := 0; i < 10000; i++ {
+		fmt.Fprintf(&content, "line %d\n", i)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "big.txt"), []byte(content.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(repo, "add", "big.txt")
+
+	diff, err := runGitAllowExit1Context(context.Background(), repo, 4096, "diff", "--cached")
+	if !errors.Is(err, ErrTruncated) {
+		t.Fatalf("Expected ErrTruncated, got %v", err)
+	}
+	if len(diff) > 4096 || !strings.HasSuffix(diff, "\n") || !strings.HasPrefix(diff, "diff --git") {
+		t.Errorf("Expected at most 4096 bytes cut at a line end, got %d bytes ending %q", len(diff), diff[max(len(diff)-10, 0):])
+	}
+
+	if diff, err := GetDiff(repo, "big.txt", true); err != nil || !strings.Contains(diff, "+line 9999") {
+		t.Errorf("Expected the whole diff under MaxDiffBytes, got %d bytes, %v", len(diff), err)
+	}
+}
+
+func TestStatusRenames(t *testing.T) {
+	repo := initTestRepo(t)
+	defer SetRenames(RenameOptions{})
+	if err := os.WriteFile(filepath.Join(repo, "old name.txt"), []byte("a\nb\nc\nd\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "z.txt"), []byte("z\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(repo, "add", ".")
+	runGit(repo, "commit", "-q", "-m", "files")
+	runGit(repo, "mv", "old name.txt", "new name.txt")
+	if err := os.WriteFile(filepath.Join(repo, "new name.txt"), []byte("a\nb\nc\nx\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(repo, "add", ".")
+	if err := os.WriteFile(filepath.Join(repo, "z.txt"), []byte("changed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := GetStatus(repo)
+	if err != nil || len(status.Files) != 2 {
+		t.Fatalf("Expected a rename and a modification, got %+v, %v", status, err)
+	}
+	if renamed := status.Files[0]; renamed.Path != "new name.txt" || renamed.OldPath != "old name.txt" || renamed.Similarity != 75 {
+		t.Errorf("Unexpected rename %+v", renamed)
+	}
+	if status.Files[1].Path != "z.txt" {
+		t.Errorf("Expected z.txt after the rename, got %+v", status.Files[1])
+	}
+
+	SetRenames(RenameOptions{Similarity: 90})
+	if status, err := GetStatus(repo); err != nil || len(status.Files) != 3 {
+		t.Errorf("Expected no rename above 90%% similarity, got %+v, %v", status, err)
+	}
+	SetRenames(RenameOptions{Off: true})
+	if diff, err := GetDiff(repo, "", true); err != nil || strings.Contains(diff, "rename from") {
+		t.Errorf("Expected no renames in the diff, got %q, %v", diff, err)
+	}
+}
+
+func TestStatusConflicts(t *testing.T) {
+	repo := initTestRepo(t)
+	writeFile(t, repo, "both changed.txt", "base\n")
+	mustGit(t, repo, "add", ".")
+	mustGit(t, repo, "commit", "-q", "-m", "base")
+	mustGit(t, repo, "checkout", "-q", "-b", "other")
+	writeFile(t, repo, "both changed.txt", "theirs\n")
+	mustGit(t, repo, "commit", "-q", "-am", "theirs")
+	mustGit(t, repo, "checkout", "-q", "-")
+	writeFile(t, repo, "both changed.txt", "ours\n")
+	mustGit(t, repo, "commit", "-q", "-am", "ours")
+	if _, err := runGit(repo, "merge", "other"); err == nil {
+		t.Fatal("Expected the merge to conflict")
+	}
+
+	status, err := GetStatus(repo)
+	if err != nil || len(status.Files) != 1 {
+		t.Fatalf("Expected one conflicted file, got %+v, %v", status, err)
+	}
+	if file := status.Files[0]; file.Path != "both changed.txt" || file.Conflict != "both modified" {
+		t.Errorf("Unexpected conflict %+v", file)
+	}
+
+	if err := StagePaths(repo, []string{"both changed.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	if status, err := GetStatus(repo); err != nil || len(status.Files) != 1 || status.Files[0].Conflict != "" || status.Files[0].Staged != "modified" {
+		t.Errorf("Expected the conflict resolved and staged, got %+v, %v", status, err)
+	}
+	if err := UnstagePaths(repo, []string{"both changed.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	if status, err := GetStatus(repo); err != nil || len(status.Files) != 1 || status.Files[0].Staged != "" {
+		t.Errorf("Expected the file unstaged, got %+v, %v", status, err)
+	}
+}
+
+func TestStatusShortFormat(t *testing.T) {
+	repo := initTestRepo(t)
+	writeFile(t, repo, "both sides.txt", "one\n")
+	writeFile(t, repo, "gone.txt", "gone\n")
+	mustGit(t, repo, "add", ".")
+	mustGit(t, repo, "commit", "-q", "-m", "files")
+	writeFile(t, repo, "both sides.txt", "two\n")
+	mustGit(t, repo, "add", "both sides.txt")
+	writeFile(t, repo, "both sides.txt", "three\n")
+	writeFile(t, repo, "new.txt", "new\n")
+	mustGit(t, repo, "add", "new.txt")
+	if err := os.Remove(filepath.Join(repo, "gone.txt")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, repo, "stray.txt", "stray\n")
+
+	status, err := GetStatus(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, file := range status.Files {
+		got[file.Path] = file.XY
+	}
+	want := map[string]string{"both sides.txt": "MM", "gone.txt": " D", "new.txt": "A ", "stray.txt": "??"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestStashPush(t *testing.T) {
+	repo := initTestRepo(t)
+	writeFile(t, repo, "a.txt", "a\n")
+	writeFile(t, repo, "b.txt", "b\n")
+	mustGit(t, repo, "add", ".")
+	mustGit(t, repo, "commit", "-q", "-m", "files")
+	writeFile(t, repo, "a.txt", "changed a\n")
+	writeFile(t, repo, "b.txt", "changed b\n")
+	writeFile(t, repo, "new.txt", "new\n")
+
+	if err := StashPush(repo, StashOptions{Message: "just a", Paths: []string{"a.txt"}}); err != nil {
+		t.Fatal(err)
+	}
+	stashes, err := GetStashes(repo)
+	if err != nil || len(stashes) != 1 || !strings.HasSuffix(stashes[0].Message, "just a") {
+		t.Fatalf("Expected the stash with its message, got %+v, %v", stashes, err)
+	}
+	status, _ := GetStatus(repo)
+	if len(status.Files) != 2 || status.Files[0].Path != "b.txt" || status.Files[1].Path != "new.txt" {
+		t.Errorf("Expected only a.txt stashed, got %+v", status.Files)
+	}
+
+	if err := StashPush(repo, StashOptions{IncludeUntracked: true}); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := GetStatus(repo); len(status.Files) != 0 {
+		t.Errorf("Expected untracked files stashed too, got %+v", status.Files)
+	}
+}
+
+func TestApplyFromStash(t *testing.T) {
+	repo := initTestRepo(t)
+	writeFile(t, repo, "a.txt", "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n")
+	writeFile(t, repo, "b.txt", "b\n")
+	mustGit(t, repo, "add", ".")
+	mustGit(t, repo, "commit", "-q", "-m", "files")
+	writeFile(t, repo, "a.txt", "one\n2\n3\n4\n5\n6\n7\n8\n9\nten\n")
+	writeFile(t, repo, "b.txt", "stashed b\n")
+	if err := StashPush(repo, StashOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := StashFiles(repo, "stash@{0}")
+	if err != nil || !reflect.DeepEqual(files, []string{"a.txt", "b.txt"}) {
+		t.Fatalf("Expected both files in the stash, got %v, %v", files, err)
+	}
+	stashes, err := GetStashes(repo)
+	if err != nil || len(stashes) != 1 || stashes[0].Hash == "" {
+		t.Fatalf("Expected the stash with its commit, got %+v, %v", stashes, err)
+	}
+	// The patch applies whatever prefixes the config asks for
+	mustGit(t, repo, "config", "diff.noprefix", "true")
+	diff, err := StashFileDiff(repo, stashes[0].Hash, "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, hunks := SplitHunks(diff)
+	if len(hunks) != 2 || !strings.HasPrefix(header, "diff --git") {
+		t.Fatalf("Expected a header and two hunks, got %q, %q", header, hunks)
+	}
+	if err := ApplyPatch(repo, header+hunks[1]); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(repo, "a.txt")); string(got) != "1\n2\n3\n4\n5\n6\n7\n8\n9\nten\n" {
+		t.Errorf("Expected only the second hunk applied, got %q", got)
+	}
+
+	if err := CheckoutStashFile(repo, "stash@{0}", "b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(repo, "b.txt")); string(got) != "stashed b\n" {
+		t.Errorf("Expected the stashed b.txt, got %q", got)
+	}
+}
+
+func TestRebaseOnto(t *testing.T) {
+	repo := initTestRepo(t)
+	commitFile(t, repo, "base.txt", "base\n")
+	main := mustGit(t, repo, "rev-parse", "--abbrev-ref", "HEAD")
+	mustGit(t, repo, "checkout", "-q", "-b", "topic")
+	commitFile(t, repo, "topic.txt", "topic\n")
+	oldBase := mustGit(t, repo, "rev-parse", "HEAD")
+	mustGit(t, repo, "checkout", "-q", "-b", "feature")
+	commitFile(t, repo, "feature.txt", "feature\n")
+	mustGit(t, repo, "checkout", "-q", main)
+	commitFile(t, repo, "main.txt", "main\n")
+	mustGit(t, repo, "checkout", "-q", "feature")
+
+	commits, err := TransplantCommits(repo, oldBase, 10)
+	if err != nil || len(commits) != 1 || commits[0].Subject != "feature.txt" {
+		t.Fatalf("Expected the feature commit to move, got %+v, %v", commits, err)
+	}
+	if err := RebaseOnto(repo, main, oldBase); err != nil {
+		t.Fatal(err)
+	}
+	if out, _ := runGit(repo, "log", "--format=%s"); out != "feature.txt\nmain.txt\nbase.txt\ninitial" {
+		t.Errorf("Expected feature on top of main without topic, got %q", out)
+	}
+
+	// A conflict stops the rebase until it is aborted
+	commitFile(t, repo, "main.txt", "feature's main\n")
+	mustGit(t, repo, "checkout", "-q", main)
+	commitFile(t, repo, "main.txt", "main again\n")
+	mustGit(t, repo, "checkout", "-q", "feature")
+	err = RebaseOnto(repo, main, main+"~1")
+	if !errors.Is(err, ErrStopped) {
+		t.Fatalf("Expected the rebase to stop on the conflict, got %v", err)
+	}
+	if err := AbortSequence(repo, "rebase"); err != nil {
+		t.Fatal(err)
+	}
+	if op, _ := InProgressOperation(repo); op != "" {
+		t.Errorf("Expected no rebase in progress after aborting, got %q", op)
+	}
+}
+
+func TestCommitRanges(t *testing.T) {
+	repo := initTestRepo(t)
+	main, _ := runGit(repo, "rev-parse", "--abbrev-ref", "HEAD")
+	runGit(repo, "checkout", "-q", "-b", "other")
+	runGit(repo, "checkout", "-q", main)
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		if err := os.WriteFile(filepath.Join(repo, name), []byte(name+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		runGit(repo, "add", name)
+		runGit(repo, "commit", "-q", "-m", name)
+	}
+	oldest, _ := runGit(repo, "rev-parse", "HEAD~1")
+	newest, _ := runGit(repo, "rev-parse", "HEAD")
+
+	diff, err := GetRangeDiffContext(context.Background(), repo, oldest, newest)
+	if err != nil || !strings.Contains(diff, "+b.txt") || !strings.Contains(diff, "+c.txt") || strings.Contains(diff, "+a.txt") {
+		t.Errorf("Expected the changes of b and c, got %q, %v", diff, err)
+	}
+
+	patches, err := FormatPatches(repo, oldest, newest, filepath.Join(t.TempDir(), "patches"))
+	if err != nil || len(patches) != 2 {
+		t.Errorf("Expected two patch files, got %v, %v", patches, err)
+	}
+
+	// From the root commit, which has no parent to start after
+	root, _ := runGit(repo, "rev-list", "--max-parents=0", "HEAD")
+	diff, err = GetRangeDiffContext(context.Background(), repo, root, newest)
+	if err != nil || !strings.Contains(diff, "+a.txt") || !strings.Contains(diff, "+c.txt") {
+		t.Errorf("Expected the changes of a to c from the root, got %q, %v", diff, err)
+	}
+	patches, err = FormatPatches(repo, root, newest, filepath.Join(t.TempDir(), "patches"))
+	if err != nil || len(patches) != 4 {
+		t.Errorf("Expected four patch files from the root, got %v, %v", patches, err)
+	}
+
+	if err := RevertRange(repo, oldest, newest); err != nil {
+		t.Fatal(err)
+	}
+	if out, _ := runGit(repo, "log", "--format=%s", "-2"); out != `Revert "b.txt"`+"\n"+`Revert "c.txt"` {
+		t.Errorf("Expected c then b reverted, got %q", out)
+	}
+
+	runGit(repo, "checkout", "-q", "other")
+	if err := CherryPickRange(repo, oldest, newest); err != nil {
+		t.Fatal(err)
+	}
+	if out, _ := runGit(repo, "log", "--format=%s", "-3"); out != "c.txt\nb.txt\ninitial" {
+		t.Errorf("Expected b and c picked onto other, got %q", out)
+	}
+}
+
+func TestRepoStats(t *testing.T) {
+	repo := initTestRepo(t)
+	for i, author := range []string{"Ann", "Bob", "Ann"} {
+		if err := os.WriteFile(filepath.Join(repo, "a.txt"), []byte(fmt.Sprint(i)), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		for _, args := range [][]string{
+			{"add", "a.txt"},
+			{"-c", "user.name=" + author, "commit", "-q", "--author", author + " <" + author + "@example.com>", "-m", "change"},
+		} {
+			if _, err := runGit(repo, args...); err != nil {
+				t.Fatalf("git %v failed: %v", args, err)
+			}
+		}
+	}
+
+	stats, err := GetRepoStats(repo, 4, 10, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("GetRepoStats failed: %v", err)
+	}
+	want := []AuthorCount{{"Ann", 2}, {"Bob", 1}, {"Test", 1}}
+	if !reflect.DeepEqual(stats.Authors, want) {
+		t.Errorf("Expected authors %v, got %v", want, stats.Authors)
+	}
+	if len(stats.Weeks) != 4 || stats.Weeks[3] != 4 {
+		t.Errorf("Expected all 4 commits in the last week, got %v", stats.Weeks)
+	}
+	if len(stats.Files) != 1 || stats.Files[0] != (FileChurn{"a.txt", 3}) {
+		t.Errorf("Expected a.txt changed 3 times, got %v", stats.Files)
+	}
+}
+
+func TestMaintenance(t *testing.T) {
+	repo := initTestRepo(t)
+	if err := os.WriteFile(filepath.Join(repo, "a.txt"), []byte("content\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	for _, args := range [][]string{{"add", "a.txt"}, {"commit", "-q", "-m", "add a"}} {
+		if _, err := runGit(repo, args...); err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+	}
+	if size, err := RepoSize(repo); err != nil || size == 0 {
+		t.Fatalf("Expected loose objects to take space, got %d, %v", size, err)
+	}
+
+	for _, task := range []MaintenanceTask{TaskGC, TaskPrune, TaskRepack, TaskFsck} {
+		if err := RunMaintenance(repo, task, nil); err != nil {
+			t.Errorf("%s failed: %v", task, err)
+		}
+	}
+	if out, _ := runGit(repo, "count-objects", "-v"); !strings.Contains(out, "count: 0") {
+		t.Errorf("Expected gc to pack the loose objects, got %q", out)
+	}
+}
+
+func TestRepoSizes(t *testing.T) {
+	repo := initTestRepo(t)
+	big := strings.Repeat("x", 10000)
+	for _, args := range [][]string{{"big.bin", big}, {"small.txt", "small\n"}} {
+		if err := os.WriteFile(filepath.Join(repo, args[0]), []byte(args[1]), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	for _, args := range [][]string{{"add", "."}, {"commit", "-q", "-m", "add files"}, {"rm", "-q", "big.bin"}, {"commit", "-q", "-m", "drop big"}} {
+		if _, err := runGit(repo, args...); err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+	}
+
+	sizes, err := GetRepoSizes(repo, 1)
+	if err != nil {
+		t.Fatalf("GetRepoSizes failed: %v", err)
+	}
+	if len(sizes.Largest) != 1 || sizes.Largest[0].Path != "big.bin" || sizes.Largest[0].Size != 10000 {
+		t.Errorf("Expected the deleted big.bin as the largest blob, got %+v", sizes.Largest)
+	}
+	if sizes.WorkTree != 6 {
+		t.Errorf("Expected only small.txt in the work tree, got %d bytes", sizes.WorkTree)
+	}
+	if sizes.GitDir == 0 {
+		t.Errorf("Expected .git to take space")
+	}
+}
+
+func TestArchiveAndBundle(t *testing.T) {
+	repo := initTestRepo(t)
+	if err := os.WriteFile(filepath.Join(repo, "a.txt"), []byte("a\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	for _, args := range [][]string{{"add", "a.txt"}, {"commit", "-q", "-m", "add a"}} {
+		if _, err := runGit(repo, args...); err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+	}
+	out := t.TempDir()
+
+	zip := filepath.Join(out, "repo.zip")
+	if err := Archive(repo, "HEAD", zip); err != nil {
+		t.Fatalf("Archive failed: %v", err)
+	}
+	if data, _ := os.ReadFile(zip); !strings.HasPrefix(string(data), "PK") {
+		t.Errorf("Expected a zip archive")
+	}
+
+	bundle := filepath.Join(out, "repo.bundle")
+	if err := CreateBundle(repo, bundle, nil); err != nil {
+		t.Fatalf("CreateBundle failed: %v", err)
+	}
+	clone := filepath.Join(out, "clone")
+	if _, err := runGit(out, "clone", "-q", bundle, clone); err != nil {
+		t.Fatalf("Cloning the bundle failed: %v", err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(clone, "a.txt")); string(content) != "a\n" {
+		t.Errorf("Expected a.txt in the clone of the bundle, got %q", content)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	cases := map[string]bool{
+		"fatal: unable to access 'https://example.com/r.git/': Could not resolve host: example.com": true,
+		"ssh: connect to host example.com port 22: Connection refused":                              true,
+		"timed out after 30s":                            true,
+		"error: failed to push some refs":                false,
+		"fatal: Authentication failed for 'https://x/'": false,
+	}
+	for msg, want := range cases {
+		if got := IsTransient(errors.New(msg)); got != want {
+			t.Errorf("IsTransient(%q) = %v, want %v", msg, got, want)
+		}
+	}
+	if IsTransient(nil) {
+		t.Error("IsTransient(nil) should be false")
+	}
+}
+
+// TestMinimumVersion pins MinimumVersion against the flags this package
+// passes git that came in after git 2.0. Those only used behind a Supports
+// or version check are gated; the rest must work on MinimumVersion.
+func TestMinimumVersion(t *testing.T) {
+	flags := []struct {
+		arg   string
+		since Version
+		gated bool
+	}{
+		{`"--porcelain=v2"`, Version{2, 11, 0}, false},
+		{`"--filters"`, Version{2, 11, 0}, false},
+		{`"--git-path"`, Version{2, 5, 0}, false},
+		{`"worktree"`, Version{2, 5, 0}, false},
+		{`"--absolute-git-dir"`, Version{2, 13, 0}, false},
+		{`"--show-current"`, Version{2, 22, 0}, false},
+		{`"stash", "push"`, FeatureStashPush.Since, true},
+		{`"restore"`, FeatureRestore.Since, true},
+		{`"maintenance"`, FeatureMaintenance.Since, true},
+		{`"--show-scope"`, configScopeVersion, true},
+	}
+	sources, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var code strings.Builder
+	for _, source := range sources {
+		if strings.HasSuffix(source, "_test.go") {
+			continue
+		}
+		data, err := os.ReadFile(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		code.Write(data)
+	}
+	for _, flag := range flags {
+		if strings.Contains(code.String(), flag.arg) && !flag.gated && !MinimumVersion.AtLeast(flag.since) {
+			t.Errorf("%s needs git %s, but MinimumVersion is %s", flag.arg, flag.since, MinimumVersion)
+		}
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	cases := map[string]Version{
+		"git version 2.39.2\n":               {2, 39, 2},
+		"git version 2.45.1.windows.1":       {2, 45, 1},
+		"git version 2.39.3 (Apple Git-146)": {2, 39, 3},
+		"git version 2.50.0-rc1":             {2, 50, 0},
+		"git version 1.8":                    {1, 8, 0},
+	}
+	for out, want := range cases {
+		got, err := ParseVersion(out)
+		if err != nil || got != want {
+			t.Errorf("ParseVersion(%q) = %v, %v; want %v", out, got, err, want)
+		}
+	}
+	if _, err := ParseVersion("hub version 2.14.2"); err == nil {
+		t.Error("expected an error for output that isn't git's")
+	}
+
+	if !(Version{2, 30, 0}).AtLeast(FeatureMaintenance.Since) || (Version{2, 29, 9}).AtLeast(FeatureMaintenance.Since) {
+		t.Error("AtLeast compares minor versions wrong")
+	}
+	if (Version{1, 99, 0}).AtLeast(MinimumVersion) || !(Version{3, 0, 0}).AtLeast(MinimumVersion) {
+		t.Error("AtLeast compares major versions wrong")
+	}
+
+	if err := CheckInstalled(); err != nil {
+		t.Errorf("the git running these tests should do: %v", err)
+	}
+}
+
+func TestTextConvAndEnvironment(t *testing.T) {
+	repo := initTestRepo(t)
+	t.Cleanup(func() { SetTextConv(nil); SetEnvironment(nil) })
+	for name, content := range map[string]string{
+		".gitattributes": "*.nb diff=marked\n*.txt diff=marked\n",
+		"a.nb":           "one\n",
+		"a.txt":          "one\n",
+	} {
+		if err := os.WriteFile(filepath.Join(repo, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	for _, args := range [][]string{
+		{"config", "diff.marked.textconv", "sed s/^/converted:/"},
+		{"add", "."}, {"commit", "-q", "-m", "add"},
+	} {
+		if _, err := runGit(repo, args...); err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+	}
+	for _, name := range []string{"a.nb", "a.txt"} {
+		if err := os.WriteFile(filepath.Join(repo, name), []byte("two\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	SetTextConv([]string{"*.nb"})
+	diff, err := GetDiff(repo, "a.nb", false)
+	if err != nil || !strings.Contains(diff, "+converted:two") {
+		t.Errorf("Expected a.nb diffed through its textconv filter, got %q, %v", diff, err)
+	}
+	// Files no pattern matches are left to git, which converts them too
+	diff, err = GetDiff(repo, "a.txt", false)
+	if err != nil || !strings.Contains(diff, "+converted:two") {
+		t.Errorf("Expected a.txt diffed as git does by default, got %q, %v", diff, err)
+	}
+	if !UsesTextConv("notebooks/deep/b.nb") || UsesTextConv("a.nbx") {
+		t.Error("Expected patterns without a slash to match file names anywhere")
+	}
+
+	// Hooks see the repository's environment
+	hook := filepath.Join(repo, ".git", "hooks", "pre-commit")
+	if err := os.WriteFile(hook, []byte("#!/bin/sh\necho \"$KVIST_TEST_VAR\" > hook-ran\n"), 0755); err != nil {
+		t.Fatalf("Failed to write hook: %v", err)
+	}
+	SetEnvironment(func(string) []string { return append(os.Environ(), "KVIST_TEST_VAR=from-workspace") })
+	if _, err := runGit(repo, "commit", "-q", "-am", "change"); err != nil {
+		t.Fatalf("commit failed: %v", err)
+	}
+	if out, _ := os.ReadFile(filepath.Join(repo, "hook-ran")); strings.TrimSpace(string(out)) != "from-workspace" {
+		t.Errorf("Expected the hook to see the workspace variable, got %q", out)
+	}
+}
@@ -0,0 +1,55 @@
+package git
+
+import (
+	"context"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// largeRepos are the repositories in large repo mode
+var largeRepos sync.Map // path -> struct{}
+
+// SetLargeRepo turns large repo mode on or off for the repository at
+// repoPath. In large repo mode status leaves out untracked files and uses
+// the untracked cache, and log reads the commit-graph file.
+func SetLargeRepo(repoPath string, large bool) {
+	if large {
+		largeRepos.Store(repoPath, struct{}{})
+	} else {
+		largeRepos.Delete(repoPath)
+	}
+}
+
+// IsLargeRepo reports whether the repository at repoPath is in large repo mode
+func IsLargeRepo(repoPath string) bool {
+	_, ok := largeRepos.Load(repoPath)
+	return ok
+}
+
+// statusArgs are the arguments for git status in repoPath
+func statusArgs(repoPath string) []string {
+	args := []string{"status", "--porcelain=v2", "-z"}
+	if IsLargeRepo(repoPath) {
+		args = append([]string{"-c", "core.untrackedCache=true"}, append(args, "--untracked-files=no")...)
+	}
+	return append(args, Renames().statusFlags()...)
+}
+
+// logArgs prefixes the arguments of a git log in repoPath
+func logArgs(repoPath string, args ...string) []string {
+	if IsLargeRepo(repoPath) {
+		return append([]string{"-c", "core.commitGraph=true"}, args...)
+	}
+	return args
+}
+
+// WriteCommitGraph writes the commit-graph file that speeds up history walks
+func WriteCommitGraph(repoPath string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", "commit-graph", "write", "--reachable")
+	cmd.Dir = repoPath
+	return runCmd(cmd)
+}
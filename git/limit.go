@@ -0,0 +1,45 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+)
+
+// MaxDiffBytes caps how much of a diff is read into memory
+const MaxDiffBytes = 4 << 20
+
+// ErrTruncated comes with output that was cut short at its size limit; the
+// output returned with it is still usable
+var ErrTruncated = errors.New("output truncated")
+
+// errBufferFull stops the command writing into a full cappedBuffer
+var errBufferFull = errors.New("output limit reached")
+
+// cappedBuffer keeps the first limit bytes written to it, or everything
+// when limit is 0. Writes past the limit fail, which makes git stop.
+type cappedBuffer struct {
+	buf   bytes.Buffer
+	limit int
+	full  bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.limit > 0 && b.buf.Len()+len(p) > b.limit {
+		n, _ := b.buf.Write(p[:b.limit-b.buf.Len()])
+		b.full = true
+		return n, errBufferFull
+	}
+	return b.buf.Write(p)
+}
+
+// String returns what was kept, cut at the last full line when the limit
+// was reached
+func (b *cappedBuffer) String() string {
+	data := b.buf.Bytes()
+	if b.full {
+		if i := bytes.LastIndexByte(data, '\n'); i >= 0 {
+			data = data[:i+1]
+		}
+	}
+	return string(data)
+}
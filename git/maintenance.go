@@ -0,0 +1,149 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaintenanceTask is a housekeeping command that keeps a repository small
+// and checks it for damage
+type MaintenanceTask string
+
+const (
+	// TaskGC packs loose objects and drops old unreachable ones
+	TaskGC MaintenanceTask = "gc"
+	// TaskPrune deletes all unreachable loose objects
+	TaskPrune MaintenanceTask = "prune"
+	// TaskRepack rewrites all objects into a single pack
+	TaskRepack MaintenanceTask = "repack"
+	// TaskFsck checks the objects for corruption and dangling ones
+	TaskFsck MaintenanceTask = "fsck"
+)
+
+// args are the git arguments that run t
+func (t MaintenanceTask) args() []string {
+	switch t {
+	case TaskPrune:
+		return []string{"prune", "--progress"}
+	case TaskRepack:
+		return []string{"repack", "-a", "-d"}
+	case TaskFsck:
+		return []string{"fsck", "--progress"}
+	}
+	return []string{"gc"}
+}
+
+// RunMaintenance runs task in repoPath, passing each line git prints to
+// output if given
+func RunMaintenance(repoPath string, task MaintenanceTask, output func(line string)) error {
+	return runStreaming(repoPath, output, task.args()...)
+}
+
+// runStreaming runs git with args for up to half an hour, passing each line
+// it prints to output if given
+func runStreaming(repoPath string, output func(line string), args ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = repoPath
+	withEnv(cmd)
+	var last string
+	started := begin(cmd)
+	err := RunStreaming(ctx, cmd, func(line string) {
+		last = strings.TrimSpace(line)
+		if output != nil {
+			output(line)
+		}
+	})
+	finish(cmd, started, err)
+	if err != nil && last != "" {
+		return fmt.Errorf("%w: %s", explain(err), last)
+	}
+	return explain(err)
+}
+
+// RunStreaming runs cmd until it exits or ctx is done, passing each line it
+// prints to output. Problems may go to stdout and progress to stderr, so
+// both are read in the order they were printed; a line redrawn with \r is
+// passed once it is finished.
+func RunStreaming(ctx context.Context, cmd *exec.Cmd, output func(line string)) error {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	cmd.Stdout, cmd.Stderr = w, w
+	err = cmd.Start()
+	w.Close()
+	if err != nil {
+		return err
+	}
+	// Closing r stops the reading even when children of cmd hold the pipe
+	// open
+	stop := context.AfterFunc(ctx, func() {
+		cmd.Process.Kill()
+		r.Close()
+	})
+	defer stop()
+
+	streamProgress(r, output)
+	return cmd.Wait()
+}
+
+// RepoSize returns how many bytes the objects of a repository take on disk,
+// loose and packed
+func RepoSize(repoPath string) (int64, error) {
+	out, err := runGit(repoPath, "count-objects", "-v")
+	if err != nil {
+		return 0, err
+	}
+	var kib int64
+	for _, line := range strings.Split(out, "\n") {
+		key, value, _ := strings.Cut(line, ": ")
+		if key == "size" || key == "size-pack" || key == "size-garbage" {
+			n, _ := strconv.ParseInt(value, 10, 64)
+			kib += n
+		}
+	}
+	return kib * 1024, nil
+}
+
+// ScheduledMaintenance reports whether git maintenance runs in the
+// background for the repository, set up by git maintenance start
+func ScheduledMaintenance(repoPath string) bool {
+	out, err := runGit(repoPath, "config", "--global", "--get-all", "maintenance.repo")
+	if err != nil {
+		return false
+	}
+	root, err := runGit(repoPath, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return false
+	}
+	root, _ = filepath.EvalSymlinks(root)
+	return slices.ContainsFunc(strings.Split(out, "\n"), func(repo string) bool {
+		repo, _ = filepath.EvalSymlinks(repo)
+		return repo == root
+	})
+}
+
+// ScheduleMaintenance has git run maintenance for the repository in the
+// background, or stops doing so for it
+func ScheduleMaintenance(repoPath string, on bool) error {
+	if err := Supports(FeatureMaintenance); err != nil {
+		return err
+	}
+	if on {
+		_, err := runGit(repoPath, "maintenance", "start")
+		return err
+	}
+	_, err := runGit(repoPath, "maintenance", "unregister")
+	return err
+}
@@ -0,0 +1,39 @@
+package git
+
+import "strings"
+
+// transientFailures are what git and ssh say when a remote can't be reached
+// for now, as opposed to e.g. a rejected push or missing permissions
+var transientFailures = []string{
+	"could not resolve host",
+	"could not resolve hostname",
+	"connection timed out",
+	"operation timed out",
+	"timed out after",
+	"connection refused",
+	"connection reset",
+	"network is unreachable",
+	"no route to host",
+	"temporary failure in name resolution",
+	"the remote end hung up unexpectedly",
+	"early eof",
+	"unexpected disconnect",
+	"http 502",
+	"http 503",
+	"http 504",
+}
+
+// IsTransient reports whether err looks like a network failure that may go
+// away if the operation is tried again later
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, failure := range transientFailures {
+		if strings.Contains(msg, failure) {
+			return true
+		}
+	}
+	return false
+}
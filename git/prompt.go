@@ -0,0 +1,64 @@
+package git
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// PromptEnv routes the credential and passphrase prompts of fetch, pull,
+// push and clone to a program that can answer them, e.g. GIT_ASKPASS and
+// SSH_ASKPASS. Without it those commands fail instead of prompting, since
+// the terminal belongs to kvist.
+var PromptEnv []string
+
+// promptTimeout is how much longer than their own timeout fetch, pull and
+// push may take while prompts wait, leaving time to type a password
+const promptTimeout = 3 * time.Minute
+
+// promptsWaiting counts the prompts waiting for the user to answer them
+var promptsWaiting atomic.Int32
+
+// PromptWaiting tells that a prompt started (true) or stopped (false)
+// waiting for the user. Timeouts of commands that may prompt are paused
+// while any prompt waits, since which command asked isn't known.
+func PromptWaiting(waiting bool) {
+	if waiting {
+		promptsWaiting.Add(1)
+	} else {
+		promptsWaiting.Add(-1)
+	}
+}
+
+// promptEnv is the environment of a command run in dir that may need
+// credentials
+func promptEnv(dir string) []string {
+	if len(PromptEnv) == 0 {
+		return append(envFor(dir), "GIT_TERMINAL_PROMPT=0")
+	}
+	return append(append(envFor(dir), "GIT_TERMINAL_PROMPT=0"), PromptEnv...)
+}
+
+// withPromptTimeout is context.WithTimeout for commands that may prompt:
+// the time prompts spend waiting for the user doesn't count, up to
+// promptTimeout of it
+func withPromptTimeout(timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout+promptTimeout)
+	const step = 100 * time.Millisecond
+	go func() {
+		ticker := time.NewTicker(step)
+		defer ticker.Stop()
+		for left := timeout; left > 0; {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if promptsWaiting.Load() == 0 {
+					left -= step
+				}
+			}
+		}
+		cancel()
+	}()
+	return ctx, cancel
+}
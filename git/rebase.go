@@ -0,0 +1,102 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// ErrStopped means a rebase, cherry-pick or revert stopped partway, usually
+// on conflicts, and waits to be continued, skipped past or aborted
+var ErrStopped = errors.New("stopped partway")
+
+// TransplantCommits returns the commits of HEAD after oldBase, newest first:
+// those a rebase --onto from oldBase moves
+func TransplantCommits(repoPath, oldBase string, limit int) ([]Commit, error) {
+	return getCommits(repoPath, limit, oldBase+"..HEAD", "--")
+}
+
+// RebaseOnto moves the commits of the current branch after oldBase onto
+// newBase
+func RebaseOnto(repoPath, newBase, oldBase string) error {
+	return runSequence(repoPath, "rebase", "--onto", newBase, oldBase)
+}
+
+// CherryPickRange copies the commits from oldest to newest onto HEAD
+func CherryPickRange(repoPath, oldest, newest string) error {
+	revs, err := commitRange(repoPath, oldest, newest)
+	if err != nil {
+		return err
+	}
+	return runSequence(repoPath, "cherry-pick", revs...)
+}
+
+// RevertRange reverts the commits from oldest to newest, newest first, with a
+// commit for each
+func RevertRange(repoPath, oldest, newest string) error {
+	revs, err := commitRange(repoPath, oldest, newest)
+	if err != nil {
+		return err
+	}
+	return runSequence(repoPath, "revert", append([]string{"--no-edit"}, revs...)...)
+}
+
+// commitRange returns the revisions a cherry-pick or revert takes to walk
+// the commits from oldest to newest. A root commit has no parent to start
+// after, so then everything newest reaches is walked.
+func commitRange(repoPath, oldest, newest string) ([]string, error) {
+	parent, err := parentOf(repoPath, oldest)
+	if err != nil {
+		return nil, err
+	}
+	if parent == "" {
+		return []string{"--do-walk", newest}, nil
+	}
+	return []string{parent + ".." + newest}, nil
+}
+
+// ContinueSequence goes on with a stopped op ("rebase", "cherry-pick" or
+// "revert") once its conflicts are resolved and staged
+func ContinueSequence(repoPath, op string) error {
+	return runSequence(repoPath, op, "--continue")
+}
+
+// SkipSequence drops the commit a stopped op is at and goes on
+func SkipSequence(repoPath, op string) error {
+	return runSequence(repoPath, op, "--skip")
+}
+
+// AbortSequence puts the branch back where it was before a stopped op
+func AbortSequence(repoPath, op string) error {
+	return runSequence(repoPath, op, "--abort")
+}
+
+// runSequence runs git op with args, keeping the commit messages as they
+// are. When op stops partway the error wraps ErrStopped.
+func runSequence(repoPath, op string, args ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", append([]string{op}, args...)...)
+	cmd.Dir = repoPath
+	cmd.Env = append(envFor(repoPath), "GIT_EDITOR=true")
+	var out bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &out
+	err := runCmd(cmd)
+	if err == nil {
+		return nil
+	}
+	if inProgress, _ := InProgressOperation(repoPath); inProgress == op {
+		err = fmt.Errorf("%w: %w", ErrStopped, err)
+	}
+	// The last lines say why, e.g. which file conflicts
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if msg := strings.Join(lines[max(len(lines)-3, 0):], "; "); msg != "" {
+		return fmt.Errorf("%w: %s", err, msg)
+	}
+	return err
+}
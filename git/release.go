@@ -0,0 +1,42 @@
+package git
+
+import "strings"
+
+// Tags returns the names of the repository's tags, newest first
+func Tags(repoPath string) ([]string, error) {
+	out, err := runGit(repoPath, "tag", "--list", "--sort=-creatordate")
+	if err != nil || out == "" {
+		return nil, err
+	}
+	return strings.Split(out, "\n"), nil
+}
+
+// LatestTag returns the nearest tag rev contains, "" when it contains none
+func LatestTag(repoPath, rev string) (string, error) {
+	tag, err := runGit(repoPath, "describe", "--tags", "--abbrev=0", rev)
+	if err != nil && (strings.Contains(err.Error(), "No names found") || strings.Contains(err.Error(), "No tags can describe")) {
+		return "", nil
+	}
+	return tag, err
+}
+
+// PreviousTag returns the tag of the release before tag: the nearest tag
+// the parent of its commit contains, "" for the first release
+func PreviousTag(repoPath, tag string) (string, error) {
+	prev, err := LatestTag(repoPath, tag+"^")
+	if err != nil && strings.Contains(err.Error(), "Not a valid object name") {
+		return "", nil // tagged the root commit
+	}
+	return prev, err
+}
+
+// ReleaseCommits returns the commits made since from up to to, newest
+// first and leaving out merges, at most limit of them. With from empty
+// it's the whole history of to, for the first release.
+func ReleaseCommits(repoPath, from, to string, limit int) ([]Commit, error) {
+	rev := to
+	if from != "" {
+		rev = from + ".." + to
+	}
+	return getCommits(repoPath, limit, "--no-merges", rev, "--")
+}
@@ -0,0 +1,62 @@
+package git
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// RenameOptions controls how git pairs removed and added files into renames
+// and copies
+type RenameOptions struct {
+	// Similarity is the percentage of content two files must share to be
+	// paired; 0 leaves git's default of 50
+	Similarity int
+	// Copies also looks for files copied from modified files
+	Copies bool
+	// Off shows renames as a deletion and an addition
+	Off bool
+}
+
+// renames are the options in use, swapped whole so commands running while
+// they change read either the old or the new ones
+var renames atomic.Pointer[RenameOptions]
+
+// SetRenames sets the options status and diffs detect renames with from now on
+func SetRenames(o RenameOptions) {
+	renames.Store(&o)
+}
+
+// Renames returns the options status and diffs detect renames with
+func Renames() RenameOptions {
+	if o := renames.Load(); o != nil {
+		return *o
+	}
+	return RenameOptions{}
+}
+
+// statusFlags are the git status flags for o
+func (o RenameOptions) statusFlags() []string {
+	switch {
+	case o.Off:
+		return []string{"--no-renames"}
+	case o.Similarity > 0:
+		return []string{fmt.Sprintf("--find-renames=%d", o.Similarity)}
+	}
+	return nil
+}
+
+// diffFlags are the git diff, show and log flags for o
+func (o RenameOptions) diffFlags() []string {
+	if o.Off {
+		return []string{"--no-renames"}
+	}
+	threshold := ""
+	if o.Similarity > 0 {
+		threshold = fmt.Sprintf("%d%%", o.Similarity)
+	}
+	flags := []string{"-M" + threshold}
+	if o.Copies {
+		flags = append(flags, "-C"+threshold)
+	}
+	return flags
+}
@@ -0,0 +1,132 @@
+package git
+
+import (
+	"slices"
+	"strings"
+)
+
+// Config keys that turn signing on
+const (
+	CommitSigningKey = "commit.gpgsign"
+	TagSigningKey    = "tag.gpgsign"
+	PushSigningKey   = "push.gpgsign"
+)
+
+// signingPattern matches the config keys Signing is read from
+const signingPattern = `^(commit\.gpgsign|tag\.gpgsign|push\.gpgsign|gpg\.format|user\.signingkey)$`
+
+// Signing is how a repository's git config has commits, tags and pushes
+// signed, counting the global config too
+type Signing struct {
+	Commits bool
+	Tags    bool
+	Push    string // "true", "if-asked", or "" when pushes aren't signed
+	Format  string // gpg.format: openpgp, x509 or ssh; "" means openpgp
+	Key     string // user.signingkey; "" lets git pick by the committer
+	// Local are the signing keys set in the repository's own config,
+	// overriding the global config
+	Local []string
+}
+
+// IsLocal reports whether key is set in the repository's own config
+func (s Signing) IsLocal(key string) bool {
+	return slices.Contains(s.Local, key)
+}
+
+// configScopeVersion is the first git whose config --show-scope says which
+// config each value comes from
+var configScopeVersion = Version{Major: 2, Minor: 26}
+
+// GetSigning reads the signing config of the repository at repoPath. The
+// workspace scan reads it for every repository, so it takes one git command
+// unless git is too old to say where each value is set.
+func GetSigning(repoPath string) (Signing, error) {
+	var s Signing
+	version, _ := Installed()
+	scoped := version.AtLeast(configScopeVersion)
+	args := []string{"config", "--get-regexp", signingPattern}
+	if scoped {
+		args = []string{"config", "--show-scope", "--get-regexp", signingPattern}
+	}
+	out, err := runGitAllowExit1(repoPath, args...)
+	if err != nil {
+		return s, err
+	}
+	// Later scopes come last and win
+	for _, line := range strings.Split(out, "\n") {
+		var scope string
+		if scoped {
+			scope, line, _ = strings.Cut(line, "\t")
+		}
+		key, value, _ := strings.Cut(line, " ")
+		if scope == "local" && strings.HasSuffix(key, ".gpgsign") && !s.IsLocal(key) {
+			s.Local = append(s.Local, key)
+		}
+		switch key {
+		case CommitSigningKey:
+			s.Commits = configBool(value)
+		case TagSigningKey:
+			s.Tags = configBool(value)
+		case PushSigningKey:
+			s.Push = ""
+			if value == "if-asked" {
+				s.Push = value
+			} else if configBool(value) {
+				s.Push = "true"
+			}
+		case "gpg.format":
+			s.Format = value
+		case "user.signingkey":
+			s.Key = value
+		}
+	}
+	if scoped {
+		return s, nil
+	}
+
+	out, err = runGitAllowExit1(repoPath, "config", "--local", "--name-only", "--get-regexp", `^(commit|tag|push)\.gpgsign$`)
+	if err != nil {
+		return s, err
+	}
+	for _, key := range strings.Fields(out) {
+		if !s.IsLocal(key) {
+			s.Local = append(s.Local, key)
+		}
+	}
+	return s, nil
+}
+
+// configBool reads a git config boolean; a key without a value is true
+func configBool(value string) bool {
+	switch strings.ToLower(value) {
+	case "", "true", "yes", "on", "1":
+		return true
+	}
+	return false
+}
+
+// SetLocalConfig sets key to value in the repository's own config, or
+// removes it there when value is "" so the global config applies again
+func SetLocalConfig(repoPath, key, value string) error {
+	if value == "" {
+		_, err := runGitAllowExit1(repoPath, "config", "--local", "--unset-all", key)
+		return err
+	}
+	_, err := runGit(repoPath, "config", "--local", key, value)
+	return err
+}
+
+// CreateTag tags rev as name with an annotated tag, its message name when
+// message is "". With sign the tag is signed with the configured key;
+// without, git still signs it when tag.gpgsign is on.
+func CreateTag(repoPath, name, rev, message string, sign bool) error {
+	if message == "" {
+		message = name
+	}
+	flag := "--annotate"
+	if sign {
+		flag = "--sign"
+	}
+	_, err := runGit(repoPath, "tag", flag, "--message", message, "--", name, rev)
+	return err
+}
@@ -0,0 +1,140 @@
+package git
+
+import (
+	"bufio"
+	"context"
+	"io/fs"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Blob is a file version stored in a repository
+type Blob struct {
+	Hash string
+	// Path is a path the blob was committed at
+	Path string
+	Size int64
+}
+
+// RepoSizes is where the disk space of a repository goes
+type RepoSizes struct {
+	WorkTree int64 // files outside .git
+	GitDir   int64 // the .git directory: history, index and the rest
+	// Largest are the biggest blobs anywhere in history, biggest first
+	Largest []Blob
+}
+
+// GetRepoSizes measures the work tree and .git directory of a repository
+// and finds the n largest blobs reachable from any ref
+func GetRepoSizes(repoPath string, n int) (*RepoSizes, error) {
+	gitDir, err := absoluteGitDir(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	largest, err := largestBlobs(repoPath, n)
+	if err != nil {
+		return nil, err
+	}
+	return &RepoSizes{
+		WorkTree: dirSize(repoPath, gitDir),
+		GitDir:   dirSize(gitDir, ""),
+		Largest:  largest,
+	}, nil
+}
+
+// largestBlobs feeds every object of rev-list --objects --all to cat-file
+// --batch-check and keeps the n largest blobs
+func largestBlobs(repoPath string, n int) ([]Blob, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	list := exec.CommandContext(ctx, "git", "rev-list", "--objects", "--all")
+	list.Dir = repoPath
+	check := exec.CommandContext(ctx, "git", "cat-file", "--batch-check=%(objecttype) %(objectname) %(objectsize) %(rest)")
+	check.Dir = repoPath
+	objects, err := list.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	check.Stdin = objects
+	out, err := check.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	listStarted, err := startCmd(list)
+	if err != nil {
+		return nil, err
+	}
+	checkStarted, err := startCmd(check)
+	if err != nil {
+		list.Process.Kill()
+		waitCmd(list, listStarted)
+		return nil, err
+	}
+
+	var blobs []Blob
+	scanner := bufio.NewScanner(out)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		// "blob <hash> <size> <path>"; the path is empty for blobs rev-list
+		// found no name for
+		fields := strings.SplitN(scanner.Text(), " ", 4)
+		if len(fields) < 3 || fields[0] != "blob" {
+			continue
+		}
+		size, _ := strconv.ParseInt(fields[2], 10, 64)
+		blob := Blob{Hash: fields[1], Size: size}
+		if len(fields) == 4 {
+			blob.Path = fields[3]
+		}
+		blobs = append(blobs, blob)
+		// Keep the list short on repositories with millions of objects
+		if len(blobs) > 4*n+1000 {
+			blobs = biggest(blobs, n)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		// cat-file blocks on a full pipe once nothing reads it; stop both
+		// before waiting on them
+		cancel()
+		waitCmd(list, listStarted)
+		waitCmd(check, checkStarted)
+		return nil, err
+	}
+	listErr := waitCmd(list, listStarted)
+	if err := waitCmd(check, checkStarted); err != nil {
+		return nil, err
+	}
+	if listErr != nil {
+		return nil, listErr
+	}
+	return biggest(blobs, n), nil
+}
+
+// biggest returns the n largest of blobs, largest first
+func biggest(blobs []Blob, n int) []Blob {
+	sort.Slice(blobs, func(i, j int) bool { return blobs[i].Size > blobs[j].Size })
+	return blobs[:min(n, len(blobs))]
+}
+
+// dirSize adds up the sizes of the files under dir, leaving out skip
+func dirSize(dir, skip string) int64 {
+	var total int64
+	filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.IsDir() && (path == skip || (path != dir && entry.Name() == ".git")) {
+			return filepath.SkipDir
+		}
+		if info, err := entry.Info(); err == nil && info.Mode().IsRegular() {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total
+}
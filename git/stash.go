@@ -0,0 +1,81 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// StashFiles lists the tracked files a stash changes
+func StashFiles(repoPath, stash string) ([]string, error) {
+	out, err := runGit(repoPath, "stash", "show", "--name-only", "--no-renames", stash)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, line := range strings.Split(out, "\n") {
+		if line != "" {
+			files = append(files, line)
+		}
+	}
+	return files, nil
+}
+
+// StashFileDiff returns the changes a stash makes to path as a patch that
+// applies, without textconv filters and with the a/ and b/ prefixes git
+// apply expects whatever the config says
+func StashFileDiff(repoPath, stash, path string) (string, error) {
+	return runGitUntrimmed(repoPath, "diff", "--no-color", "--no-ext-diff", "--no-textconv", "--no-renames",
+		"--src-prefix=a/", "--dst-prefix=b/", stash+"^1", stash, "--", path)
+}
+
+// ApplyStash applies all of a stash, keeping it in the stash list
+func ApplyStash(repoPath, stash string) error {
+	_, err := runGit(repoPath, "stash", "apply", stash)
+	return err
+}
+
+// CheckoutStashFile replaces path in the index and work tree with its
+// version in a stash
+func CheckoutStashFile(repoPath, stash, path string) error {
+	_, err := runGit(repoPath, "checkout", stash, "--", path)
+	return err
+}
+
+// SplitHunks splits the diff of one file into its header and its hunks, so
+// the header and any one hunk make a patch of their own
+func SplitHunks(diff string) (header string, hunks []string) {
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			hunks = append(hunks, line)
+		case len(hunks) == 0:
+			header += line
+		default:
+			hunks[len(hunks)-1] += line
+		}
+	}
+	return header, hunks
+}
+
+// ApplyPatch applies a patch to the work tree, leaving the index alone
+func ApplyPatch(repoPath, patch string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", "apply", "--whitespace=nowarn", "-")
+	cmd.Dir = repoPath
+	cmd.Stdin = strings.NewReader(patch)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := runCmd(cmd); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
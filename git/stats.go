@@ -0,0 +1,79 @@
+package git
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AuthorCount is how many commits an author made
+type AuthorCount struct {
+	Name    string
+	Commits int
+}
+
+// FileChurn is how many commits changed a file
+type FileChurn struct {
+	Path    string
+	Commits int
+}
+
+// RepoStats summarizes who works on a repository, how much and where
+type RepoStats struct {
+	// Authors are all authors of HEAD's history, most commits first
+	Authors []AuthorCount
+	// Weeks counts the commits of each of the last weeks, oldest first
+	Weeks []int
+	// Files are the files changed most often in those weeks, most first
+	Files []FileChurn
+}
+
+// GetRepoStats collects the statistics of HEAD's history, counting activity
+// and changed files over the given number of weeks before now
+func GetRepoStats(repoPath string, weeks, maxFiles int, now time.Time) (*RepoStats, error) {
+	out, err := runGit(repoPath, "shortlog", "-sn", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	stats := &RepoStats{Weeks: make([]int, weeks)}
+	for _, line := range strings.Split(out, "\n") {
+		count, name, ok := strings.Cut(strings.TrimSpace(line), "\t")
+		if n, err := strconv.Atoi(count); ok && err == nil {
+			stats.Authors = append(stats.Authors, AuthorCount{Name: name, Commits: n})
+		}
+	}
+
+	// An RS and the author time per commit, then the files it changed
+	since := now.Add(-time.Duration(weeks) * 7 * 24 * time.Hour)
+	out, err = runGit(repoPath, "-c", "core.quotePath=false", "log", "--no-merges", "--name-only", "--format=%x1e%at",
+		"--since="+since.Format(time.RFC3339), "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	changes := make(map[string]int)
+	for _, line := range strings.Split(out, "\n") {
+		if ts, ok := strings.CutPrefix(line, "\x1e"); ok {
+			secs, _ := strconv.ParseInt(ts, 10, 64)
+			week := weeks - 1 - int(now.Sub(time.Unix(secs, 0))/(7*24*time.Hour))
+			if week >= 0 && week < weeks {
+				stats.Weeks[week]++
+			}
+		} else if line != "" {
+			changes[line]++
+		}
+	}
+	for path, n := range changes {
+		stats.Files = append(stats.Files, FileChurn{Path: path, Commits: n})
+	}
+	sort.Slice(stats.Files, func(i, j int) bool {
+		if stats.Files[i].Commits != stats.Files[j].Commits {
+			return stats.Files[i].Commits > stats.Files[j].Commits
+		}
+		return stats.Files[i].Path < stats.Files[j].Path
+	})
+	if len(stats.Files) > maxFiles {
+		stats.Files = stats.Files[:maxFiles]
+	}
+	return stats, nil
+}
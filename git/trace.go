@@ -0,0 +1,142 @@
+package git
+
+import (
+	"errors"
+	"os/exec"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// tracer is the function SetTracer set, swapped whole so commands finishing
+// while it changes see either the old or the new one
+var tracer atomic.Pointer[func(dir string, args []string, elapsed time.Duration, exitCode int)]
+
+// SetTracer sets a function told about every git command once it has
+// finished: where it ran, its arguments, how long it took and its exit code
+// (-1 when it didn't run to completion). It is called from many goroutines.
+// With nil, commands aren't reported.
+func SetTracer(trace func(dir string, args []string, elapsed time.Duration, exitCode int)) {
+	if trace == nil {
+		tracer.Store(nil)
+		return
+	}
+	tracer.Store(&trace)
+}
+
+// CommandRun is a git command that is running or has run
+type CommandRun struct {
+	Dir      string
+	Args     []string
+	Started  time.Time
+	Elapsed  time.Duration // so far, for commands still running
+	ExitCode int
+}
+
+// recentRunsKept is how many finished commands RecentRuns remembers
+const recentRunsKept = 50
+
+var runs = struct {
+	sync.Mutex
+	running map[*exec.Cmd]time.Time
+	recent  []CommandRun // oldest first
+}{running: make(map[*exec.Cmd]time.Time)}
+
+// RunningCommands returns the git commands in flight, longest running first
+func RunningCommands() []CommandRun {
+	runs.Lock()
+	defer runs.Unlock()
+	now := time.Now()
+	running := make([]CommandRun, 0, len(runs.running))
+	for cmd, started := range runs.running {
+		running = append(running, CommandRun{Dir: cmd.Dir, Args: cmd.Args[1:], Started: started, Elapsed: now.Sub(started)})
+	}
+	sort.Slice(running, func(i, j int) bool { return running[i].Started.Before(running[j].Started) })
+	return running
+}
+
+// RecentRuns returns the last finished git commands, newest first
+func RecentRuns() []CommandRun {
+	runs.Lock()
+	defer runs.Unlock()
+	recent := make([]CommandRun, len(runs.recent))
+	for i, run := range runs.recent {
+		recent[len(recent)-1-i] = run
+	}
+	return recent
+}
+
+// runCmd is cmd.Run, recorded and reported to the tracer
+func runCmd(cmd *exec.Cmd) error {
+	withEnv(cmd)
+	started := begin(cmd)
+	err := cmd.Run()
+	finish(cmd, started, err)
+	return explain(err)
+}
+
+// cmdOutput is cmd.Output, recorded and reported to the tracer
+func cmdOutput(cmd *exec.Cmd) ([]byte, error) {
+	withEnv(cmd)
+	started := begin(cmd)
+	out, err := cmd.Output()
+	finish(cmd, started, err)
+	return out, explain(err)
+}
+
+// startCmd is cmd.Start; the command is recorded until waitCmd
+func startCmd(cmd *exec.Cmd) (time.Time, error) {
+	withEnv(cmd)
+	started := begin(cmd)
+	err := cmd.Start()
+	if err != nil {
+		finish(cmd, started, err)
+	}
+	return started, explain(err)
+}
+
+// waitCmd is cmd.Wait for a command begun with startCmd
+func waitCmd(cmd *exec.Cmd, started time.Time) error {
+	err := cmd.Wait()
+	finish(cmd, started, err)
+	return err
+}
+
+func begin(cmd *exec.Cmd) time.Time {
+	started := time.Now()
+	runs.Lock()
+	runs.running[cmd] = started
+	runs.Unlock()
+	return started
+}
+
+func finish(cmd *exec.Cmd, started time.Time, err error) {
+	code := 0
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code = exitErr.ExitCode()
+	} else if err != nil {
+		code = -1
+	}
+	runs.Lock()
+	delete(runs.running, cmd)
+	runs.Unlock()
+	record(cmd.Dir, cmd.Args[1:], started, code)
+}
+
+// record remembers a finished command and reports it to the tracer
+func record(dir string, args []string, started time.Time, exitCode int) {
+	run := CommandRun{Dir: dir, Args: args, Started: started, Elapsed: time.Since(started), ExitCode: exitCode}
+
+	runs.Lock()
+	runs.recent = append(runs.recent, run)
+	if len(runs.recent) > recentRunsKept {
+		runs.recent = runs.recent[len(runs.recent)-recentRunsKept:]
+	}
+	runs.Unlock()
+
+	if trace := tracer.Load(); trace != nil {
+		(*trace)(run.Dir, run.Args, run.Elapsed, run.ExitCode)
+	}
+}
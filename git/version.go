@@ -0,0 +1,134 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrGitMissing means there is no git binary on PATH
+var ErrGitMissing = errors.New("git is not installed or not on PATH")
+
+// Version is a git release, e.g. 2.39.2
+type Version struct {
+	Major, Minor, Patch int
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// AtLeast reports whether v is other or a later release
+func (v Version) AtLeast(other Version) bool {
+	if v.Major != other.Major {
+		return v.Major > other.Major
+	}
+	if v.Minor != other.Minor {
+		return v.Minor > other.Minor
+	}
+	return v.Patch >= other.Patch
+}
+
+// ParseVersion reads the output of git version, e.g. "git version 2.39.2"
+// or "git version 2.45.1.windows.1"
+func ParseVersion(out string) (Version, error) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(out), "git version ")
+	if !ok {
+		return Version{}, fmt.Errorf("unexpected git version output %q", out)
+	}
+	rest, _, _ = strings.Cut(rest, " ")
+	parts := strings.SplitN(rest, ".", 4)
+	var numbers [3]int
+	for i := range min(len(parts), 3) {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil {
+			if i == 0 {
+				return Version{}, fmt.Errorf("unexpected git version output %q", out)
+			}
+			break
+		}
+		numbers[i] = n
+	}
+	return Version{Major: numbers[0], Minor: numbers[1], Patch: numbers[2]}, nil
+}
+
+// MinimumVersion is the oldest git kvist works with: reading the status
+// needs git status --porcelain=v2
+var MinimumVersion = Version{Major: 2, Minor: 11}
+
+// Feature is something kvist does that needs a newer git than
+// MinimumVersion
+type Feature struct {
+	Name  string
+	Since Version
+}
+
+var (
+	// FeatureStashPush is stashing only some files
+	FeatureStashPush = Feature{Name: "stashing selected files", Since: Version{Major: 2, Minor: 13}}
+	// FeatureRestore is restoring a file from another commit
+	FeatureRestore = Feature{Name: "restoring a file from a commit", Since: Version{Major: 2, Minor: 23}}
+	// FeatureWorktreeRepair is repairing moved worktrees
+	FeatureWorktreeRepair = Feature{Name: "repairing worktrees", Since: Version{Major: 2, Minor: 29}}
+	// FeatureMaintenance is scheduling background maintenance
+	FeatureMaintenance = Feature{Name: "scheduled maintenance", Since: Version{Major: 2, Minor: 30}}
+)
+
+var gitMissing = sync.OnceValue(func() bool {
+	_, err := exec.LookPath("git")
+	return err != nil
+})
+
+var installed = sync.OnceValues(func() (Version, error) {
+	if gitMissing() {
+		return Version{}, ErrGitMissing
+	}
+	out, err := cmdOutput(exec.Command("git", "version"))
+	if err != nil {
+		return Version{}, err
+	}
+	return ParseVersion(string(out))
+})
+
+// Installed returns the version of the git binary on PATH, ErrGitMissing
+// when there is none
+func Installed() (Version, error) {
+	return installed()
+}
+
+// CheckInstalled returns why the git on PATH can't be used, nil when it can
+func CheckInstalled() error {
+	version, err := Installed()
+	if err != nil {
+		return err
+	}
+	if !version.AtLeast(MinimumVersion) {
+		return fmt.Errorf("git %s is too old; kvist needs git %s or newer", version, MinimumVersion)
+	}
+	return nil
+}
+
+// Supports returns nil when the installed git can do feature, otherwise an
+// error saying which version it needs
+func Supports(feature Feature) error {
+	version, err := Installed()
+	if err != nil {
+		return err
+	}
+	if !version.AtLeast(feature.Since) {
+		return fmt.Errorf("%s needs git %s or newer; this is git %s", feature.Name, feature.Since, version)
+	}
+	return nil
+}
+
+// explain replaces the cryptic error of starting a git binary that isn't
+// there with ErrGitMissing
+func explain(err error) error {
+	if errors.Is(err, exec.ErrNotFound) {
+		return ErrGitMissing
+	}
+	return err
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/asbjornb/kvist/git"
+	"github.com/asbjornb/kvist/workspace"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	// minSubjectWidth is how much room for the subject the other columns of
+	// the history list leave before some are left out
+	minSubjectWidth = 20
+	// authorWidth is the width of the author column
+	authorWidth = 14
+)
+
+// maxRefLabels is how many branches and tags are named at a commit in the
+// history list before the rest are only counted
+const maxRefLabels = 3
+
+// refKind is the kind of a ref named in the history list, in the order
+// they are listed
+type refKind int
+
+const (
+	refHead refKind = iota
+	refBranch
+	refTag
+	refRemote
+)
+
+// shrinkOrder is the order columns of the history list are left out in
+// when a row doesn't fit
+var shrinkOrder = []string{"author", "date", "initials", "refs", "time"}
+
+// commitCell is a column of a commit's row in the history list
+type commitCell struct {
+	column string
+	text   string // rendered
+}
+
+// historyColumns returns the configured columns of the history list
+func (m model) historyColumns() []string {
+	if m.workspaceConfig != nil {
+		return m.workspaceConfig.History.ColumnsOrDefault()
+	}
+	return workspace.DefaultHistoryColumns
+}
+
+// commitRow renders a commit's row of the history list in width, leaving
+// out columns in shrinkOrder until the subject has room
+func (m model) commitRow(commit git.Commit, width int, hashStyle lipgloss.Style) string {
+	dim := lipgloss.NewStyle().Foreground(lipgloss.Color("242"))
+	authorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("110"))
+
+	var cells []commitCell
+	for _, column := range m.historyColumns() {
+		text := ""
+		switch column {
+		case "hash":
+			text = hashStyle.Render(commit.ShortHash)
+			if badge := m.ciBadge(commit.Hash); badge != "" {
+				text += " " + badge
+			}
+		case "refs":
+			text = m.refLabels(commit.Hash)
+		case "time":
+			text = dim.Render(git.FormatRelativeTime(commit.Time))
+		case "date":
+			text = dim.Render(commit.Time.Format("2006-01-02"))
+		case "author":
+			text = authorStyle.Render(padRight(commit.Author, authorWidth))
+		case "initials":
+			text = authorStyle.Render(padRight(initials(commit.Author), 2))
+		case "subject":
+			text = commit.Subject
+		}
+		if text != "" {
+			cells = append(cells, commitCell{column: column, text: text})
+		}
+	}
+
+	// Room taken by everything but the subject, with a space after each
+	taken := func() int {
+		n := 0
+		for _, cell := range cells {
+			if cell.column != "subject" {
+				n += lipgloss.Width(cell.text) + 1
+			}
+		}
+		return n
+	}
+	for _, column := range shrinkOrder {
+		if taken()+minSubjectWidth <= width {
+			break
+		}
+		cells = slices.DeleteFunc(cells, func(cell commitCell) bool { return cell.column == column })
+	}
+
+	parts := make([]string, len(cells))
+	for i, cell := range cells {
+		parts[i] = cell.text
+		if cell.column == "subject" {
+			parts[i] = truncate(cell.text, width-taken())
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// refLabels renders the branches and tags at a commit, colored by kind:
+// the current branch first as HEAD -> branch, then the other local
+// branches, the tags and the remote branches. Past maxRefLabels the rest
+// are counted as +N. It returns "" when there are none.
+func (m model) refLabels(hash string) string {
+	refs := m.refs[hash]
+	if len(refs) == 0 {
+		return ""
+	}
+	currentBranch := ""
+	if m.repo != nil {
+		currentBranch = m.repo.CurrentBranch
+	}
+
+	styles := [...]lipgloss.Style{
+		refHead:   lipgloss.NewStyle().Foreground(lipgloss.Color("228")).Bold(true),
+		refBranch: lipgloss.NewStyle().Foreground(lipgloss.Color("114")).Bold(true),
+		refTag:    lipgloss.NewStyle().Foreground(lipgloss.Color("214")).Bold(true),
+		refRemote: lipgloss.NewStyle().Foreground(lipgloss.Color("174")),
+	}
+	dim := lipgloss.NewStyle().Foreground(lipgloss.Color("242"))
+
+	type label struct {
+		kind refKind
+		name string
+	}
+	labels := make([]label, 0, len(refs))
+	for _, ref := range refs {
+		kind := m.refKind(ref)
+		if kind == refBranch && ref == currentBranch {
+			kind, ref = refHead, "HEAD -> "+ref
+		}
+		labels = append(labels, label{kind, ref})
+	}
+	slices.SortStableFunc(labels, func(a, b label) int { return int(a.kind) - int(b.kind) })
+
+	parts := make([]string, 0, min(len(labels), maxRefLabels)+1)
+	for _, l := range labels[:min(len(labels), maxRefLabels)] {
+		parts = append(parts, styles[l.kind].Render(l.name))
+	}
+	if hidden := len(labels) - maxRefLabels; hidden > 0 {
+		parts = append(parts, dim.Render(fmt.Sprintf("+%d", hidden)))
+	}
+	return dim.Render("(") + strings.Join(parts, dim.Render(", ")) + dim.Render(")")
+}
+
+// refKind tells what kind of ref a name GetRefs returned is: a tag, a
+// branch of one of the remotes or a local branch
+func (m model) refKind(ref string) refKind {
+	if strings.HasPrefix(ref, git.TagRefPrefix) {
+		return refTag
+	}
+	for _, remote := range m.remotes {
+		if strings.HasPrefix(ref, remote.Name+"/") {
+			return refRemote
+		}
+	}
+	return refBranch
+}
+
+// initials returns the first letters of the first and last word of a name,
+// or the first two letters of a one word name
+func initials(name string) string {
+	words := strings.Fields(name)
+	switch len(words) {
+	case 0:
+		return "?"
+	case 1:
+		runes := []rune(words[0])
+		return string(unicode.ToUpper(runes[0])) + string(runes[1:min(2, len(runes))])
+	}
+	first, last := []rune(words[0]), []rune(words[len(words)-1])
+	return string(unicode.ToUpper(first[0])) + string(unicode.ToUpper(last[0]))
+}
+
+// padRight cuts or pads s to width characters
+func padRight(s string, width int) string {
+	runes := []rune(s)
+	if width <= 0 {
+		return ""
+	}
+	if len(runes) > width {
+		return string(runes[:width-1]) + "…"
+	}
+	return s + strings.Repeat(" ", width-len(runes))
+}
+
+// truncate cuts s to width characters, ending in ... when cut
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) <= width || width <= 3 {
+		return s
+	}
+	return string(runes[:width-3]) + "..."
+}
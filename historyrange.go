@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/asbjornb/kvist/git"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// commitRange returns the indices of the newest and oldest commits of the
+// range selected in history, or just the selected commit without a range
+func (m model) commitRange() (newest, oldest int, ok bool) {
+	if m.selectedCommit >= len(m.commits) {
+		return 0, 0, false
+	}
+	if !m.rangeActive || m.rangeAnchor >= len(m.commits) {
+		return m.selectedCommit, m.selectedCommit, true
+	}
+	return min(m.rangeAnchor, m.selectedCommit), max(m.rangeAnchor, m.selectedCommit), true
+}
+
+// inRange reports whether the commit at index i is in the selected range
+func (m model) inRange(i int) bool {
+	newest, oldest, ok := m.commitRange()
+	return m.rangeActive && ok && i >= newest && i <= oldest
+}
+
+// extendRange moves the selection by delta, starting a range at the
+// selected commit if none is selected
+func (m *model) extendRange(delta int) tea.Cmd {
+	if len(m.commits) == 0 {
+		return nil
+	}
+	if !m.rangeActive {
+		m.rangeActive, m.rangeAnchor = true, m.selectedCommit
+	}
+	m.selectedCommit = max(0, min(m.selectedCommit+delta, len(m.commits)-1))
+	return m.selectionChanged()
+}
+
+// rangeItems offers what can be done with the selected range of commits
+func (m model) rangeItems() []menuItem {
+	newestIdx, oldestIdx, ok := m.commitRange()
+	if !ok || m.repo == nil {
+		return nil
+	}
+	repoPath := m.repo.Path
+	newest, oldest := m.commits[newestIdx].Hash, m.commits[oldestIdx].Hash
+	count := oldestIdx - newestIdx + 1
+	name := fmt.Sprintf("%d commit(s)", count)
+
+	var branches []menuItem
+	for _, branch := range m.branches {
+		if branch.IsCurrent || strings.HasSuffix(branch.Name, " (remote)") {
+			continue
+		}
+		branches = append(branches, menuItem{key: menuKey(len(branches)), label: branch.Name, action: func(*model) tea.Cmd {
+			return sequenceOperation(repoPath, "cherry-pick", "cherry-pick "+name+" onto "+branch.Name, true, func() error {
+				if err := git.CheckoutBranch(repoPath, branch.Name); err != nil {
+					return err
+				}
+				return git.CherryPickRange(repoPath, oldest, newest)
+			})
+		}})
+	}
+
+	return []menuItem{
+		{key: "d", label: "Show the combined diff", action: func(m *model) tea.Cmd {
+			ctx, gen := m.newDiffRequest()
+			m.diffScrollOffset = 0
+			return loadRangeDiff(ctx, gen, repoPath, oldest, newest)
+		}},
+		{key: "c", label: "Cherry-pick onto another branch (checks it out)", action: func(m *model) tea.Cmd {
+			if len(branches) == 0 {
+				m.log.add(logWarn, "no other local branch to cherry-pick onto")
+				return nil
+			}
+			m.openMenu("🍒 Cherry-pick "+name+" onto", branches)
+			return nil
+		}},
+		{key: "r", label: "Revert, a commit for each", action: func(m *model) tea.Cmd {
+			m.openConfirmation("↩️  Revert", fmt.Sprintf("Revert %s on %s?", name, m.repo.CurrentBranch), func(*model) tea.Cmd {
+				return sequenceOperation(repoPath, "revert", "revert "+name, true, func() error {
+					return git.RevertRange(repoPath, oldest, newest)
+				})
+			})
+			return nil
+		}},
+		{key: "p", label: "Export as patch files (format-patch)", action: func(m *model) tea.Cmd {
+			m.openPrompt("📤 Write patches to directory", "patches", func(_ *model, dir string) tea.Cmd {
+				if dir = strings.TrimSpace(dir); dir == "" {
+					return nil
+				}
+				return formatPatchOperation(repoPath, oldest, newest, dir)
+			})
+			return nil
+		}},
+	}
+}
+
+func loadRangeDiff(ctx context.Context, gen int, repoPath, oldest, newest string) tea.Cmd {
+	return func() tea.Msg {
+		diff, err := git.GetRangeDiffContext(ctx, repoPath, oldest, newest)
+		if errors.Is(err, git.ErrTruncated) {
+			base, _ := git.RangeBase(repoPath, oldest)
+			return diffLoadedMsg{gen: gen, diff: diff, full: []string{"diff", "--no-ext-diff", base, newest}}
+		}
+		return diffLoadedMsg{gen: gen, diff: diff, err: err}
+	}
+}
+
+// rangeSummary describes the selected range in place of a commit's details
+func (m model) rangeSummary() []string {
+	newest, oldest, _ := m.commitRange()
+	authors := make(map[string]bool)
+	for _, commit := range m.commits[newest : oldest+1] {
+		authors[commit.Author] = true
+	}
+	return []string{
+		fmt.Sprintf("%d commits by %d author(s)", oldest-newest+1, len(authors)),
+		"",
+		"From: " + m.commits[oldest].ShortHash + " " + m.commits[oldest].Subject,
+		"To:   " + m.commits[newest].ShortHash + " " + m.commits[newest].Subject,
+		"",
+		"x: cherry-pick, revert, export or diff the range • esc: clear",
+	}
+}
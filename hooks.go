@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// hookTimeout is how long a hook command may run
+const hookTimeout = 10 * time.Minute
+
+// hookFinishedMsg reports the hook commands of an event, run in order
+// until one failed
+type hookFinishedMsg struct {
+	event    string
+	repoPath string
+	command  string   // the last command run
+	output   []string // what it printed
+	err      error
+}
+
+// runHooks runs the hook commands of event, "open", "pull" or "commit", in
+// repoPath in the background, with KVIST_EVENT and KVIST_REPO set for them
+func (m model) runHooks(event, repoPath string) tea.Cmd {
+	commands := m.hookCommands(event)
+	if len(commands) == 0 || m.safeMode {
+		return nil
+	}
+	env := append(m.commandEnv(repoPath), "KVIST_EVENT="+event, "KVIST_REPO="+repoPath)
+	return func() tea.Msg {
+		msg := hookFinishedMsg{event: event, repoPath: repoPath}
+		for _, command := range commands {
+			ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+			msg.command, msg.output = command, nil
+			msg.err = runShellStreaming(ctx, repoPath, command, env, func(line string) {
+				msg.output = append(msg.output, line)
+			})
+			cancel()
+			if msg.err != nil {
+				break
+			}
+		}
+		return msg
+	}
+}
+
+// handleHookFinished logs how the hooks of an event went, with the end of
+// the output of a failed one
+func (m *model) handleHookFinished(msg hookFinishedMsg) {
+	name := filepath.Base(msg.repoPath)
+	if msg.err == nil {
+		m.log.add(logInfo, "%s hooks of %s done", msg.event, name)
+		return
+	}
+	m.log.add(logError, "%s hook %q of %s failed: %v", msg.event, msg.command, name, msg.err)
+	for _, line := range msg.output[max(len(msg.output)-5, 0):] {
+		m.log.add(logError, "  %s", line)
+	}
+}
+
+// hookCommands returns the hook commands configured for event
+func (m model) hookCommands(event string) []string {
+	if m.workspaceConfig == nil {
+		return nil
+	}
+	hooks := m.workspaceConfig.Hooks
+	switch event {
+	case "open":
+		return hooks.Open
+	case "pull":
+		return hooks.Pull
+	case "commit":
+		return hooks.Commit
+	}
+	return nil
+}
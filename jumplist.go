@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/asbjornb/kvist/workspace"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// jumpList is the state of the recently opened repositories overlay
+type jumpList struct {
+	filter   string
+	selected int
+}
+
+// openJumpList shows the recently opened repositories, with the one before
+// the current repository selected so a single Enter switches back to it
+func (m *model) openJumpList() {
+	if m.scanner == nil {
+		return
+	}
+	recent := m.scanner.RecentRepos()
+	if len(recent) == 0 {
+		m.log.add(logInfo, "no recently opened repositories yet")
+		return
+	}
+	m.jumpList = jumpList{}
+	if m.repo != nil && recent[0].Path == m.repo.Path && len(recent) > 1 {
+		m.jumpList.selected = 1
+	}
+	m.showingModal = true
+	m.modalMode = jumpListModal
+}
+
+// jumpListMatches returns the recent repositories matching the filter by
+// name or path, best match first
+func (m model) jumpListMatches() []workspace.RepoInfo {
+	if m.scanner == nil {
+		return nil
+	}
+	recent := m.scanner.RecentRepos()
+	if m.jumpList.filter == "" {
+		return recent
+	}
+	scores := make(map[string]int)
+	var matches []workspace.RepoInfo
+	for _, repo := range recent {
+		score, _, ok := fuzzyMatch(m.jumpList.filter, repo.Name)
+		if !ok {
+			// Path matches rank below name matches
+			if _, _, ok = fuzzyMatch(m.jumpList.filter, repo.Path); !ok {
+				continue
+			}
+			score = -1
+		}
+		scores[repo.Path] = score
+		matches = append(matches, repo)
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return scores[matches[i].Path] > scores[matches[j].Path]
+	})
+	return matches
+}
+
+// updateJumpList handles keys in the jump list: typing filters, the arrows
+// or ctrl+o move and Enter opens the repository
+func (m model) updateJumpList(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	matches := m.jumpListMatches()
+	switch key := msg.String(); key {
+	case "ctrl+c", "esc":
+		m.showingModal = false
+	case "up":
+		m.jumpList.selected = moveIndex(m.jumpList.selected, -1, len(matches))
+	case "down", "ctrl+o":
+		if m.jumpList.selected == len(matches)-1 {
+			m.jumpList.selected = 0
+		} else {
+			m.jumpList.selected = moveIndex(m.jumpList.selected, 1, len(matches))
+		}
+	case "enter":
+		if m.jumpList.selected < len(matches) {
+			m.showingModal = false
+			return m, m.openRepo(matches[m.jumpList.selected])
+		}
+	case "backspace":
+		if len(m.jumpList.filter) > 0 {
+			runes := []rune(m.jumpList.filter)
+			m.jumpList.filter = string(runes[:len(runes)-1])
+			m.jumpList.selected = 0
+		}
+	default:
+		if msg.Type == tea.KeyRunes {
+			m.jumpList.filter += string(msg.Runes)
+			m.jumpList.selected = 0
+		}
+	}
+	return m, nil
+}
+
+// renderJumpList draws the jump list modal
+func (m model) renderJumpList(modalStyle, titleStyle, itemStyle, selectedStyle lipgloss.Style) string {
+	branchStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("39"))
+	pathStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+	content := []string{
+		titleStyle.Render("🕘 Recent repositories"),
+		"",
+		fmt.Sprintf("  > %s█", m.jumpList.filter),
+		"",
+	}
+
+	matches := m.jumpListMatches()
+	if len(matches) == 0 {
+		content = append(content, itemStyle.Render(pathStyle.Render("no match")))
+	}
+	matchStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("214")).Underline(true)
+	for i, repo := range matches {
+		_, positions, _ := fuzzyMatch(m.jumpList.filter, repo.Name)
+		line := highlightMatches(repo.Name, positions, lipgloss.NewStyle(), matchStyle)
+		if repo.Branch != "" {
+			line += " " + branchStyle.Render("("+repo.Branch+")")
+		}
+		line += "  " + pathStyle.Render(repo.Path)
+		if i == m.jumpList.selected {
+			content = append(content, selectedStyle.Width(64).Render("▶ "+line))
+		} else {
+			content = append(content, itemStyle.Render("  "+line))
+		}
+	}
+	content = append(content, "", "  type to filter • ↑↓/Ctrl+O: navigate • Enter: open • Esc: close")
+	return modalStyle.Render(strings.Join(content, "\n"))
+}
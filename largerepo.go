@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/asbjornb/kvist/git"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	// largeRepoThreshold is how slow git status has to be to count as slow
+	largeRepoThreshold = 2 * time.Second
+	// largeRepoSlowRuns is how many slow git status runs in a row it takes
+	// to offer large repo mode, so one run on a cold disk cache doesn't
+	largeRepoSlowRuns = 3
+	// largeRepoRefresh is how often the status refreshes in large repo mode
+	largeRepoRefresh = 30 * time.Second
+	// largeRepoAsked marks a repository whose user was offered large repo
+	// mode this session, so they aren't asked again
+	largeRepoAsked = -1
+)
+
+// commitGraphWrittenMsg reports writing the commit-graph for a large repo
+type commitGraphWrittenMsg struct {
+	repoPath string
+	err      error
+}
+
+// noticeSlowStatus counts the git status runs of a repository that took
+// longer than largeRepoThreshold, offering large repo mode once enough of
+// them came in a row
+func (m *model) noticeSlowStatus(repoPath string, elapsed time.Duration) {
+	runs := m.slowStatuses[repoPath]
+	if git.IsLargeRepo(repoPath) || runs == largeRepoAsked {
+		return
+	}
+	if elapsed < largeRepoThreshold {
+		delete(m.slowStatuses, repoPath)
+		return
+	}
+	if m.slowStatuses == nil {
+		m.slowStatuses = make(map[string]int)
+	}
+	m.slowStatuses[repoPath] = runs + 1
+	// With another dialog open, one of the next slow runs asks
+	if runs+1 < largeRepoSlowRuns || m.showingModal {
+		return
+	}
+	m.slowStatuses[repoPath] = largeRepoAsked
+	m.openMenu(fmt.Sprintf("🐘 git status took %s, %d times in a row", elapsed.Round(100*time.Millisecond), runs+1), []menuItem{
+		{key: "l", label: fmt.Sprintf("Large repo mode: hide untracked files, refresh every %s", largeRepoRefresh), action: func(m *model) tea.Cmd {
+			m.setLargeRepo(repoPath, true)
+			return nil
+		}},
+		{key: "g", label: "Large repo mode, and write the commit-graph so history loads faster", action: func(m *model) tea.Cmd {
+			m.setLargeRepo(repoPath, true)
+			return writeCommitGraph(repoPath)
+		}},
+		{key: "n", label: "Not now; don't ask again until kvist restarts", action: func(*model) tea.Cmd { return nil }},
+	})
+}
+
+// setLargeRepo turns large repo mode on or off and remembers it
+func (m *model) setLargeRepo(repoPath string, large bool) {
+	git.SetLargeRepo(repoPath, large)
+	if m.scanner != nil {
+		m.scanner.SetLargeRepo(repoPath, large)
+		if err := m.scanner.SaveCache(); err != nil {
+			m.log.add(logError, "saving repository cache failed: %v", err)
+		}
+	}
+	if large {
+		m.log.add(logInfo, "%s is in large repo mode", filepath.Base(repoPath))
+	}
+}
+
+// writeCommitGraph writes the commit-graph of a repository so its history
+// loads faster
+func writeCommitGraph(repoPath string) tea.Cmd {
+	return func() tea.Msg {
+		return commitGraphWrittenMsg{repoPath: repoPath, err: git.WriteCommitGraph(repoPath)}
+	}
+}
+
+// scheduleRefresh schedules the next status refresh, less often in large
+// repo mode
+func (m *model) scheduleRefresh() tea.Cmd {
+	if m.repo != nil && git.IsLargeRepo(m.repo.Path) {
+		return m.scheduleAfter(autoRefreshTimer, largeRepoRefresh)
+	}
+	return m.schedule(autoRefreshTimer)
+}
+
+// largeRepoBanner tells that the open repository is in large repo mode
+func (m model) largeRepoBanner() string {
+	if m.repo == nil || !git.IsLargeRepo(m.repo.Path) {
+		return ""
+	}
+	return lipgloss.NewStyle().Foreground(lipgloss.Color("214")).Render("  🐘 large repo mode: untracked files hidden")
+}
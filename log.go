@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+type logLevel int
+
+const (
+	logInfo logLevel = iota
+	logWarn
+	logError
+)
+
+// maxLogEntries bounds the message log ring buffer
+const maxLogEntries = 200
+
+type logEntry struct {
+	time    time.Time
+	level   logLevel
+	message string
+}
+
+// messageLog is a fixed-size ring buffer of operation results, warnings and errors
+type messageLog struct {
+	entries []logEntry
+	next    int // index the next entry is written to once the buffer is full
+	unseen  int // warnings/errors added since the log was last opened
+}
+
+func (l *messageLog) add(level logLevel, format string, args ...interface{}) {
+	entry := logEntry{time: time.Now(), level: level, message: fmt.Sprintf(format, args...)}
+	if len(l.entries) < maxLogEntries {
+		l.entries = append(l.entries, entry)
+	} else {
+		l.entries[l.next] = entry
+		l.next = (l.next + 1) % maxLogEntries
+	}
+	if level != logInfo {
+		l.unseen++
+	}
+}
+
+// ordered returns the log entries oldest first
+func (l *messageLog) ordered() []logEntry {
+	if len(l.entries) < maxLogEntries {
+		return l.entries
+	}
+	return append(append([]logEntry{}, l.entries[l.next:]...), l.entries[:l.next]...)
+}
+
+// logVisibleEntries is the number of log lines shown at once in the log modal
+const logVisibleEntries = 20
+
+// renderLogLines renders the visible window of the message log
+func (m model) renderLogLines(width int) []string {
+	timeStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("242"))
+	infoStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("252"))
+	warnStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+	errorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+
+	entries := m.log.ordered()
+	if len(entries) == 0 {
+		return []string{"  No messages yet"}
+	}
+
+	start := m.logScrollOffset
+	if start >= len(entries) {
+		start = len(entries) - 1
+	}
+	end := min(start+logVisibleEntries, len(entries))
+
+	var lines []string
+	for _, entry := range entries[start:end] {
+		style := infoStyle
+		marker := " "
+		switch entry.level {
+		case logWarn:
+			style = warnStyle
+			marker = "!"
+		case logError:
+			style = errorStyle
+			marker = "✗"
+		}
+
+		message := strings.ReplaceAll(entry.message, "\n", " ")
+		if maxLen := width - 14; maxLen > 3 && len(message) > maxLen {
+			message = message[:maxLen-3] + "..."
+		}
+		lines = append(lines, timeStyle.Render(entry.time.Format("15:04:05"))+" "+style.Render(marker+" "+message))
+	}
+
+	if len(entries) > logVisibleEntries {
+		lines = append(lines, timeStyle.Render(fmt.Sprintf("[%d-%d of %d]", start+1, end, len(entries))))
+	}
+	return lines
+}
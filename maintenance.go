@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/asbjornb/kvist/git"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maintenanceStateMsg is the size of a repository and whether git
+// maintenance runs for it, to offer housekeeping with
+type maintenanceStateMsg struct {
+	repoPath  string
+	name      string
+	size      int64
+	scheduled bool
+	err       error
+}
+
+func loadMaintenanceState(repoPath, name string) tea.Cmd {
+	return func() tea.Msg {
+		size, err := git.RepoSize(repoPath)
+		return maintenanceStateMsg{repoPath: repoPath, name: name, size: size, scheduled: git.ScheduledMaintenance(repoPath), err: err}
+	}
+}
+
+// openMaintenance offers the housekeeping tasks for a repository
+func (m *model) openMaintenance(msg maintenanceStateMsg) {
+	if msg.err != nil {
+		m.log.add(logError, "reading the size of %s failed: %v", msg.name, msg.err)
+		return
+	}
+	repoPath, name := msg.repoPath, msg.name
+	task := func(task git.MaintenanceTask) func(*model) tea.Cmd {
+		return func(*model) tea.Cmd { return maintenanceOperation(repoPath, name, task) }
+	}
+	schedule := "Run git maintenance in the background (maintenance start)"
+	if msg.scheduled {
+		schedule = "Stop background git maintenance (maintenance unregister)"
+	}
+	m.openMenu(fmt.Sprintf("🧰 Maintain %s (%s)", name, formatSize(msg.size)), []menuItem{
+		{key: "g", label: "Garbage collect (gc)", action: task(git.TaskGC)},
+		{key: "p", label: "Prune unreachable loose objects (prune)", action: func(m *model) tea.Cmd {
+			m.openConfirmation("🧰 git prune",
+				fmt.Sprintf("Delete all unreachable loose objects of %s? Dropped commits can't be recovered from the reflog afterwards.", name),
+				task(git.TaskPrune))
+			return nil
+		}},
+		{key: "r", label: "Repack into a single pack (repack -a -d)", action: task(git.TaskRepack)},
+		{key: "f", label: "Check for corruption (fsck)", action: task(git.TaskFsck)},
+		{key: "s", label: schedule, action: func(m *model) tea.Cmd {
+			if err := git.Supports(git.FeatureMaintenance); err != nil {
+				m.log.add(logWarn, "%v", err)
+				return nil
+			}
+			return scheduleMaintenanceOperation(repoPath, name, !msg.scheduled)
+		}},
+	})
+}
+
+// maintenanceOperation runs task, logging its output and how much it shrank
+// the repository
+func maintenanceOperation(repoPath, name string, task git.MaintenanceTask) tea.Cmd {
+	output := make(chan string, 64)
+	return requestOperation(operation{
+		desc:     fmt.Sprintf("%s %s", task, name),
+		repoPath: repoPath,
+		output:   output,
+		run: func() error {
+			before, _ := git.RepoSize(repoPath)
+			if err := git.RunMaintenance(repoPath, task, func(line string) { output <- line }); err != nil {
+				return err
+			}
+			after, err := git.RepoSize(repoPath)
+			if err == nil {
+				output <- fmt.Sprintf("size %s → %s", formatSize(before), formatSize(after))
+			}
+			return nil
+		},
+	})
+}
+
+func scheduleMaintenanceOperation(repoPath, name string, on bool) tea.Cmd {
+	desc := "start background maintenance for " + name
+	if !on {
+		desc = "stop background maintenance for " + name
+	}
+	return requestOperation(operation{
+		desc:     desc,
+		repoPath: repoPath,
+		run:      func() error { return git.ScheduleMaintenance(repoPath, on) },
+	})
+}
+
+// formatSize renders a byte count as e.g. "12.3 MB"
+func formatSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,769 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/asbjornb/kvist/git"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type modalType int
+
+const (
+	workspacePickerModal modalType = iota // workspace selection modal
+	logModal                              // operation/error log viewer
+	safeModeModal                         // crash recovery notice shown in safe mode
+	gitSetupModal                         // git missing or too old at startup
+	promptModal                           // generic single-line text input
+	globalSearchModal                     // cross-repo search results
+	confirmModal                          // generic yes/no confirmation
+	menuModal                             // generic key-driven action submenu
+	checklistModal                        // generic multi-select list
+	jumpListModal                         // recently opened repositories
+)
+
+// confirmation is the state of the generic confirmation modal
+type confirmation struct {
+	title   string
+	message string
+	confirm func(m *model) tea.Cmd
+}
+
+// prompt is the state of the generic text input modal
+type prompt struct {
+	title  string
+	input  string
+	submit func(m *model, value string) tea.Cmd
+	cancel func(m *model) // run on Esc, if set
+	secret bool           // the input is shown as dots
+}
+
+// updateOverlayModal handles key input for the single-purpose modals
+// (everything except the workspace picker)
+func (m model) updateOverlayModal(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	key := msg.String()
+
+	switch m.modalMode {
+	case jumpListModal:
+		return m.updateJumpList(msg)
+	case logModal:
+		key, pending := m.resolveKeySequence(key)
+		if pending {
+			return m, nil
+		}
+		if delta, ok := navigationDelta(key, logVisibleEntries); ok {
+			m.logScrollOffset = moveIndex(m.logScrollOffset, delta, len(m.log.entries))
+			return m, nil
+		}
+		switch key {
+		case "ctrl+c", "esc", "q", "L":
+			m.showingModal = false
+		}
+	case safeModeModal:
+		switch key {
+		case "c":
+			// Start over with an empty repository cache
+			if m.repoCache != nil {
+				m.repoCache.Reset()
+				m.repos = nil
+				m.updateFilteredRepos()
+				if err := m.scanner.SaveCache(); err != nil {
+					m.log.add(logError, "clearing repository cache failed: %v", err)
+				} else {
+					m.log.add(logInfo, "repository cache cleared")
+				}
+			}
+			m.showingModal = false
+		case "ctrl+c", "esc", "q", "enter":
+			m.showingModal = false
+		}
+	case gitSetupModal:
+		switch key {
+		case "ctrl+c", "esc", "q", "enter":
+			m.showingModal = false
+		}
+	case promptModal:
+		switch key {
+		case "ctrl+c", "esc":
+			p := m.prompt
+			m.showingModal = false
+			m.prompt = prompt{}
+			if p.cancel != nil {
+				p.cancel(&m)
+			}
+		case "enter":
+			p := m.prompt
+			m.showingModal = false
+			m.prompt = prompt{}
+			if p.submit != nil {
+				return m, p.submit(&m, p.input)
+			}
+		case "backspace":
+			if len(m.prompt.input) > 0 {
+				m.prompt.input = m.prompt.input[:len(m.prompt.input)-1]
+			}
+		default:
+			if msg.Type == tea.KeyRunes || key == " " {
+				m.prompt.input += string(msg.Runes)
+			}
+		}
+	case confirmModal:
+		switch key {
+		case "y", "Y", "enter":
+			c := m.confirmation
+			m.showingModal = false
+			m.confirmation = confirmation{}
+			if c.confirm != nil {
+				return m, c.confirm(&m)
+			}
+		case "n", "N", "ctrl+c", "esc", "q":
+			m.showingModal = false
+			m.confirmation = confirmation{}
+		}
+	case menuModal:
+		// Item keys win over navigation so menus can use any letter
+		for _, item := range m.menu.items {
+			if item.key == key {
+				m.showingModal = false
+				m.menu = menu{}
+				return m, item.action(&m)
+			}
+		}
+		if delta, ok := navigationDelta(key, len(m.menu.items)); ok {
+			m.menu.selected = moveIndex(m.menu.selected, delta, len(m.menu.items))
+			return m, nil
+		}
+		switch key {
+		case "ctrl+c", "esc", "q":
+			m.showingModal = false
+			m.menu = menu{}
+		case "enter":
+			item := m.menu.items[m.menu.selected]
+			m.showingModal = false
+			m.menu = menu{}
+			return m, item.action(&m)
+		}
+	case checklistModal:
+		if delta, ok := navigationDelta(key, m.height/2); ok {
+			m.checklist.selected = moveIndex(m.checklist.selected, delta, len(m.checklist.items))
+			return m, nil
+		}
+		switch key {
+		case "ctrl+c", "esc", "q":
+			m.showingModal = false
+			m.checklist = checklist{}
+		case " ":
+			item := &m.checklist.items[m.checklist.selected]
+			item.checked = !item.checked
+		case "a":
+			// Check everything, or clear everything if it's all checked
+			all := true
+			for _, item := range m.checklist.items {
+				all = all && item.checked
+			}
+			for i := range m.checklist.items {
+				m.checklist.items[i].checked = !all
+			}
+		case "enter":
+			c := m.checklist
+			m.showingModal = false
+			m.checklist = checklist{}
+			var chosen []string
+			for _, item := range c.items {
+				if item.checked {
+					chosen = append(chosen, item.value)
+				}
+			}
+			if len(chosen) > 0 {
+				return m, c.submit(&m, chosen)
+			}
+		}
+	case globalSearchModal:
+		key, pending := m.resolveKeySequence(key)
+		if pending {
+			return m, nil
+		}
+		if delta, ok := navigationDelta(key, m.height/2); ok {
+			m.selectedSearchMatch = moveIndex(m.selectedSearchMatch, delta, m.globalSearchMatchCount())
+			return m, nil
+		}
+		switch key {
+		case "ctrl+c", "esc", "q":
+			m.showingModal = false
+		case " ", "enter":
+			if repo, match, ok := m.selectedGlobalSearchMatch(); ok {
+				m.showingModal = false
+				cmd := m.openRepo(repo)
+				if m.repo != nil && m.repo.Path == repo.Path {
+					// Already open in a tab; no need to wait for it to load
+					return m, tea.Batch(cmd, m.viewMatch(match, filesMode))
+				}
+				m.pendingMatch = &match
+				return m, cmd
+			}
+		}
+	}
+	return m, nil
+}
+
+// openPrompt shows a single-line text input modal; submit runs with the entered text on Enter
+func (m *model) openPrompt(title, initial string, submit func(m *model, value string) tea.Cmd) {
+	m.prompt = prompt{title: title, input: initial, submit: submit}
+	m.showingModal = true
+	m.modalMode = promptModal
+}
+
+// menuItem is an entry in a key-driven action menu
+type menuItem struct {
+	key    string
+	label  string
+	action func(m *model) tea.Cmd
+}
+
+// menu is a small submenu of actions, each runnable by its key or by Enter
+type menu struct {
+	title    string
+	items    []menuItem
+	selected int
+}
+
+// openMenu shows a submenu; it does nothing when there are no items
+func (m *model) openMenu(title string, items []menuItem) {
+	if len(items) == 0 {
+		return
+	}
+	m.menu = menu{title: title, items: items}
+	m.showingModal = true
+	m.modalMode = menuModal
+}
+
+// checkItem is an entry of a checklist
+type checkItem struct {
+	value   string
+	label   string
+	checked bool
+}
+
+// checklist is a list of items to pick several of; submit runs with the
+// values of the checked items
+type checklist struct {
+	title    string
+	items    []checkItem
+	selected int
+	submit   func(m *model, chosen []string) tea.Cmd
+}
+
+// openChecklist shows a multi-select modal; it does nothing when there are no items
+func (m *model) openChecklist(title string, items []checkItem, submit func(m *model, chosen []string) tea.Cmd) {
+	if len(items) == 0 {
+		return
+	}
+	m.checklist = checklist{title: title, items: items, submit: submit}
+	m.showingModal = true
+	m.modalMode = checklistModal
+}
+
+// openConfirmation shows a yes/no modal; confirm runs when the user accepts
+func (m *model) openConfirmation(title, message string, confirm func(m *model) tea.Cmd) {
+	m.confirmation = confirmation{title: title, message: message, confirm: confirm}
+	m.showingModal = true
+	m.modalMode = confirmModal
+}
+
+func (m model) renderBranchMenuOverlay(background string) string {
+	menuStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("170")).
+		Background(lipgloss.Color("235")).
+		Padding(1).
+		Width(60).
+		Height(min(len(m.branches)+9, m.height-4))
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("170")).
+		Align(lipgloss.Center)
+
+	itemStyle := lipgloss.NewStyle().
+		PaddingLeft(2)
+
+	selectedStyle := lipgloss.NewStyle().
+		PaddingLeft(1).
+		Background(lipgloss.Color("238")).
+		Foreground(lipgloss.Color("170")).
+		Bold(true)
+
+	currentStyle := lipgloss.NewStyle().
+		PaddingLeft(2).
+		Foreground(lipgloss.Color("214"))
+
+	trackingStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	title := titleStyle.Render("Branch Operations")
+	content := []string{title, ""}
+
+	// Add "Create new branch" option
+	createStyle := itemStyle
+	if m.selectedBranchMenu == 0 {
+		createStyle = selectedStyle
+	}
+	content = append(content, createStyle.Render("✨ Create new branch"))
+	content = append(content, "")
+
+	// Add existing branches
+	for i, branch := range m.branches {
+		menuIndex := i + 1
+		style := itemStyle
+		if m.selectedBranchMenu == menuIndex {
+			style = selectedStyle
+		}
+
+		prefix := "  "
+		branchName := branch.Name
+		if branch.IsCurrent {
+			style = currentStyle
+			prefix = "● "
+			branchName += " (current)"
+		}
+
+		// Add ahead/behind indicators
+		if branch.Ahead > 0 {
+			branchName += fmt.Sprintf(" ↑%d", branch.Ahead)
+		}
+		if branch.Behind > 0 {
+			branchName += fmt.Sprintf(" ↓%d", branch.Behind)
+		}
+
+		tracking := ""
+		switch {
+		case branch.Gone:
+			tracking = " " + branch.Upstream + " gone"
+		case branch.Upstream == "" && !strings.HasSuffix(branch.Name, " (remote)"):
+			tracking = " local only"
+		}
+
+		content = append(content, style.Render(prefix+branchName)+trackingStyle.Render(tracking))
+	}
+
+	content = append(content, "", "↑↓/jk: navigate • Enter: select • Esc: cancel", "x: clean up merged • X: prune and clean up", "u: set upstream • U: unset upstream")
+
+	menu := menuStyle.Render(strings.Join(content, "\n"))
+
+	// Position menu in center as a proper modal overlay
+	menuTop := (m.height - lipgloss.Height(menu)) / 2
+
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Top, background) +
+		lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Top,
+			strings.Repeat("\n", menuTop)+menu)
+}
+
+func (m model) renderModalOverlay(background string) string {
+	modalStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("170")).
+		Background(lipgloss.Color("235")).
+		Padding(1).
+		Width(70).
+		Height(min(15, m.height-4))
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("170")).
+		Align(lipgloss.Center)
+
+	itemStyle := lipgloss.NewStyle().
+		PaddingLeft(2)
+
+	selectedStyle := lipgloss.NewStyle().
+		PaddingLeft(1).
+		Background(lipgloss.Color("238")).
+		Foreground(lipgloss.Color("170")).
+		Bold(true)
+
+	switch m.modalMode {
+	case workspacePickerModal:
+		titleText := "📂 Select Workspace"
+		if m.editingWorkspace {
+			if m.editingWorkspaceIdx >= 0 {
+				titleText = "✏️  Edit Workspace"
+			} else {
+				titleText = "➕ Add Workspace"
+			}
+		}
+		title := titleStyle.Render(titleText)
+		content := []string{title, ""}
+
+		if m.editingWorkspace {
+			// Show workspace editing/creation form
+			nameLabel := "Name:"
+			pathLabel := "Path:"
+
+			nameCursor := ""
+			pathCursor := ""
+			if m.editingField == 0 {
+				nameCursor = "█"
+			} else {
+				pathCursor = "█"
+			}
+
+			content = append(content,
+				fmt.Sprintf("  %s %s%s", nameLabel, m.newWorkspaceName, nameCursor),
+				fmt.Sprintf("  %s %s%s", pathLabel, m.newWorkspacePath, pathCursor),
+			)
+
+			// Show directory suggestions if in path field
+			if m.editingField == 1 && len(m.dirSuggestions) > 0 {
+				content = append(content, "")
+				suggestionStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+				selectedSuggestionStyle := lipgloss.NewStyle().
+					Foreground(lipgloss.Color("214")).
+					Background(lipgloss.Color("238"))
+
+				maxVisible := 5
+				totalSuggestions := len(m.dirSuggestions)
+
+				// Calculate scroll window to keep selected item visible
+				scrollOffset := 0
+				if m.selectedSuggestion >= maxVisible {
+					scrollOffset = m.selectedSuggestion - maxVisible + 1
+				}
+				endIdx := scrollOffset + maxVisible
+				if endIdx > totalSuggestions {
+					endIdx = totalSuggestions
+				}
+
+				// Show range indicator if there are more items
+				if totalSuggestions > maxVisible {
+					rangeText := fmt.Sprintf("  [%d-%d of %d]", scrollOffset+1, endIdx, totalSuggestions)
+					content = append(content, suggestionStyle.Render(rangeText))
+				}
+
+				for i := scrollOffset; i < endIdx; i++ {
+					suggestion := m.dirSuggestions[i]
+					if i == m.selectedSuggestion {
+						content = append(content, selectedSuggestionStyle.Render("  ▶ "+suggestion))
+					} else {
+						content = append(content, suggestionStyle.Render("    "+suggestion))
+					}
+				}
+			}
+
+			helpAction := "create"
+			if m.editingWorkspaceIdx >= 0 {
+				helpAction = "update"
+			}
+			content = append(content,
+				"",
+				fmt.Sprintf("  Tab: autocomplete/next field • ↑↓: navigate • Enter: %s • Esc: cancel", helpAction),
+			)
+		} else {
+			// Show workspace list
+			if m.workspaceConfig != nil {
+				for i, ws := range m.workspaceConfig.Workspaces {
+					text := fmt.Sprintf("📂 %s (%s)", ws.Name, ws.Path)
+					if i == m.selectedWorkspace {
+						content = append(content, selectedStyle.Render("▶ "+text))
+					} else {
+						content = append(content, itemStyle.Render("  "+text))
+					}
+				}
+
+				// Add "New Workspace" option
+				addText := "➕ Add New Workspace"
+				if m.selectedWorkspace == len(m.workspaceConfig.Workspaces) {
+					content = append(content, selectedStyle.Render("▶ "+addText))
+				} else {
+					content = append(content, itemStyle.Render("  "+addText))
+				}
+			}
+
+			content = append(content, "", "  Enter: select • e: edit • d: delete • Esc: close")
+		}
+
+		modal := modalStyle.Render(strings.Join(content, "\n"))
+
+		// Position modal in center
+		overlayHeight := modalStyle.GetHeight()
+		overlayTop := (m.height - overlayHeight) / 2
+
+		return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Top, background) +
+			lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Top,
+				strings.Repeat("\n", overlayTop)+modal)
+	case safeModeModal:
+		warnStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+		content := []string{
+			titleStyle.Render("🛟 Safe Mode"),
+			"",
+			warnStyle.Render("  kvist did not shut down cleanly the last few times."),
+			"",
+			"  Auto-refresh, background scans and session restore are",
+			"  disabled until kvist is restarted. Press r to scan manually.",
+			"",
+			"  If the problem persists, clearing the repository cache",
+			"  often gets things working again.",
+			"",
+			"  c: clear repository cache • Enter/Esc: continue",
+		}
+
+		modal := modalStyle.Render(strings.Join(content, "\n"))
+		overlayTop := (m.height - lipgloss.Height(modal)) / 2
+
+		return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Top, background) +
+			lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Top,
+				strings.Repeat("\n", overlayTop)+modal)
+	case gitSetupModal:
+		warnStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+		problem := "kvist needs git, but there is no git on PATH."
+		if !errors.Is(m.gitProblem, git.ErrGitMissing) {
+			problem = fmt.Sprintf("%v.", m.gitProblem)
+		}
+		content := []string{
+			titleStyle.Render("🧰 Git Setup"),
+			"",
+			warnStyle.Render("  " + problem),
+			"",
+			"  Install or update git, then restart kvist:",
+			"",
+			"    macOS:    brew install git",
+			"    Debian:   sudo apt install git",
+			"    Fedora:   sudo dnf install git",
+			"    Windows:  winget install Git.Git",
+			"    Others:   https://git-scm.com/downloads",
+			"",
+			"  Until then most panels stay empty and git actions fail.",
+			"",
+			"  Enter/Esc: continue",
+		}
+
+		modal := modalStyle.Render(strings.Join(content, "\n"))
+		overlayTop := (m.height - lipgloss.Height(modal)) / 2
+
+		return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Top, background) +
+			lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Top,
+				strings.Repeat("\n", overlayTop)+modal)
+	case promptModal:
+		modalStyle = modalStyle.Height(0)
+		input := m.prompt.input
+		if m.prompt.secret {
+			input = strings.Repeat("•", len([]rune(input)))
+		}
+		content := []string{
+			titleStyle.Render(m.prompt.title),
+			"",
+			fmt.Sprintf("  %s█", input),
+			"",
+			"  Enter: confirm • Esc: cancel",
+		}
+
+		modal := modalStyle.Render(strings.Join(content, "\n"))
+		overlayTop := (m.height - lipgloss.Height(modal)) / 2
+
+		return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Top, background) +
+			lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Top,
+				strings.Repeat("\n", overlayTop)+modal)
+	case confirmModal:
+		modalStyle = modalStyle.Height(0)
+		content := []string{
+			titleStyle.Render(m.confirmation.title),
+			"",
+			lipgloss.NewStyle().Width(64).Render(m.confirmation.message),
+			"",
+			"  y/Enter: confirm • n/Esc: cancel",
+		}
+
+		modal := modalStyle.Render(strings.Join(content, "\n"))
+		overlayTop := (m.height - lipgloss.Height(modal)) / 2
+
+		return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Top, background) +
+			lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Top,
+				strings.Repeat("\n", overlayTop)+modal)
+	case globalSearchModal:
+		modalWidth := min(110, m.width-4)
+		modalStyle = modalStyle.
+			Width(modalWidth).
+			Height(m.height - 4)
+
+		content := []string{titleStyle.Render(fmt.Sprintf("🔎 Search: %s", m.globalSearchPattern)), ""}
+		content = append(content, m.renderGlobalSearchResults(modalWidth-4, m.height-12)...)
+		content = append(content, "", "  ↑↓/jk: navigate • Enter: open repository • Esc: close")
+
+		modal := modalStyle.Render(strings.Join(content, "\n"))
+		overlayTop := (m.height - lipgloss.Height(modal)) / 2
+
+		return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Top, background) +
+			lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Top,
+				strings.Repeat("\n", overlayTop)+modal)
+	case jumpListModal:
+		modal := m.renderJumpList(modalStyle.Height(0), titleStyle, itemStyle, selectedStyle)
+		overlayTop := (m.height - lipgloss.Height(modal)) / 2
+
+		return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Top, background) +
+			lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Top,
+				strings.Repeat("\n", overlayTop)+modal)
+	case menuModal:
+		modalStyle = modalStyle.Width(50).Height(0)
+		keyStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("214")).Bold(true)
+		content := []string{titleStyle.Render(m.menu.title), ""}
+
+		// Long menus scroll to keep the selection in view
+		visible := max(1, m.height-14)
+		start := max(0, m.menu.selected-visible+1)
+		end := min(len(m.menu.items), start+visible)
+		for i := start; i < end; i++ {
+			item := m.menu.items[i]
+			key := item.key
+			if key == "" {
+				key = " " // only reachable with the arrows
+			}
+			line := keyStyle.Render(key) + "  " + item.label
+			if i == m.menu.selected {
+				content = append(content, selectedStyle.Width(44).Render(line))
+			} else {
+				content = append(content, itemStyle.Render(line))
+			}
+		}
+		content = append(content, "", "  key/Enter: select • ↑↓: navigate • Esc: close")
+
+		modal := modalStyle.Render(strings.Join(content, "\n"))
+		overlayTop := (m.height - lipgloss.Height(modal)) / 2
+
+		return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Top, background) +
+			lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Top,
+				strings.Repeat("\n", overlayTop)+modal)
+	case checklistModal:
+		modalStyle = modalStyle.Width(70).Height(0)
+		checkStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("42")).Bold(true)
+		content := []string{titleStyle.Render(m.checklist.title), ""}
+
+		// Keep the selection in view
+		visible := max(1, m.height-14)
+		start := max(0, m.checklist.selected-visible+1)
+		end := min(len(m.checklist.items), start+visible)
+		for i := start; i < end; i++ {
+			item := m.checklist.items[i]
+			box := "[ ]"
+			if item.checked {
+				box = checkStyle.Render("[x]")
+			}
+			line := box + " " + item.label
+			if i == m.checklist.selected {
+				content = append(content, selectedStyle.Width(64).Render(line))
+			} else {
+				content = append(content, itemStyle.Render(line))
+			}
+		}
+		content = append(content, "", "  Space: toggle • a: all • Enter: confirm • Esc: cancel")
+
+		modal := modalStyle.Render(strings.Join(content, "\n"))
+		overlayTop := (m.height - lipgloss.Height(modal)) / 2
+
+		return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Top, background) +
+			lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Top,
+				strings.Repeat("\n", overlayTop)+modal)
+	case logModal:
+		modalStyle = modalStyle.
+			Width(min(110, m.width-4)).
+			Height(min(logVisibleEntries+6, m.height-4))
+
+		content := []string{titleStyle.Render("📜 Message Log"), ""}
+		content = append(content, m.renderLogLines(min(110, m.width-4)-4)...)
+		content = append(content, "", "  ↑↓/jk: scroll • L/Esc: close")
+
+		modal := modalStyle.Render(strings.Join(content, "\n"))
+		overlayTop := (m.height - lipgloss.Height(modal)) / 2
+
+		return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Top, background) +
+			lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Top,
+				strings.Repeat("\n", overlayTop)+modal)
+	}
+
+	return background
+}
+
+// updateBranchMenu handles keys while the branch menu is open
+func (m model) updateBranchMenu(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "esc":
+		m.showingBranchMenu = false
+		m.selectedBranchMenu = 0
+	case "up", "k", "down", "j", "ctrl+u", "ctrl+d", "pgup", "pgdown", "home", "end", "G":
+		delta, _ := navigationDelta(msg.String(), m.height/2)
+		maxOptions := len(m.branches) + 1 // +1 for "Create new branch" option
+		m.selectedBranchMenu = moveIndex(m.selectedBranchMenu, delta, maxOptions)
+	case "x", "X":
+		// X prunes first so branches whose upstream was deleted show up
+		if m.repo != nil {
+			m.showingBranchMenu = false
+			return m, loadBranchCleanup(m.repo.Path, msg.String() == "X" && m.networkAllowed("prune"))
+		}
+	case "u", "U":
+		// Upstream of the current branch: u picks one, U removes it
+		if m.repo == nil || m.repo.CurrentBranch == "" {
+			m.log.add(logInfo, "check out a branch to set its upstream")
+			return m, nil
+		}
+		m.showingBranchMenu = false
+		if msg.String() == "U" {
+			return m, setUpstreamOperation(m.repo.Path, m.repo.CurrentBranch, "")
+		}
+		return m, loadUpstreamCandidates(m.repo.Path, m.repo.CurrentBranch)
+	case "enter":
+		if m.selectedBranchMenu == 0 {
+			// Create new branch option
+			m.showingBranchMenu = false
+			m.startBranchInput("")
+		} else {
+			// Switch to selected branch
+			branchIndex := m.selectedBranchMenu - 1
+			if branchIndex < len(m.branches) {
+				branch := m.branches[branchIndex]
+				if !branch.IsCurrent && m.repo != nil {
+					m.showingBranchMenu = false
+					return m, checkoutBranchOperation(m.repo.Path, branch.Name)
+				}
+			}
+		}
+	}
+	return m, nil
+}
+
+// startBranchInput opens the branch name input for a branch at startPoint,
+// or at HEAD when startPoint is empty
+func (m *model) startBranchInput(startPoint string) {
+	m.creatingBranch = true
+	m.branchInput = ""
+	m.branchStartPoint = startPoint
+	m.branchCheckout = true
+}
+
+// updateBranchInput handles keys while typing a new branch name
+func (m model) updateBranchInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "esc":
+		m.creatingBranch = false
+		m.branchInput = ""
+	case "enter":
+		if m.branchInput != "" && m.repo != nil {
+			m.creatingBranch = false
+			branchName := m.branchInput
+			m.branchInput = ""
+			return m, createBranchOperation(m.repo.Path, branchName, m.branchStartPoint, m.branchCheckout)
+		}
+	case "tab":
+		m.branchCheckout = !m.branchCheckout
+	case "backspace":
+		if len(m.branchInput) > 0 {
+			m.branchInput = m.branchInput[:len(m.branchInput)-1]
+		}
+	default:
+		// Add printable characters to branch name
+		if len(msg.String()) == 1 && msg.String()[0] >= 32 && msg.String()[0] <= 126 {
+			m.branchInput += msg.String()
+		}
+	}
+	return m, nil
+}
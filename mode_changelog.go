@@ -0,0 +1,286 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/asbjornb/kvist/export"
+	"github.com/asbjornb/kvist/git"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	// changelogCommitLimit is the most commits a changelog is written from
+	changelogCommitLimit = 1000
+	// changelogReleases is how many tagged releases are offered
+	changelogReleases = 8
+	// changelogFile is where the changelog is exported to, in the work tree
+	changelogFile = "CHANGELOG.md"
+)
+
+// changelogTagsMsg lists the tags to write the changelog of a release for,
+// newest first, with the tag of the release before each. The release
+// before "HEAD" is the latest one, which the unreleased commits follow.
+type changelogTagsMsg struct {
+	repoPath string
+	tags     []string
+	previous map[string]string
+	err      error
+}
+
+func loadChangelogTags(repoPath string) tea.Cmd {
+	return func() tea.Msg {
+		tags, err := git.Tags(repoPath)
+		if err != nil || len(tags) == 0 {
+			return changelogTagsMsg{repoPath: repoPath, err: err}
+		}
+		tags = tags[:min(len(tags), changelogReleases)]
+		// The history decides which release came before, not tag dates
+		previous := make(map[string]string, len(tags)+1)
+		if previous["HEAD"], err = git.LatestTag(repoPath, "HEAD"); err != nil {
+			return changelogTagsMsg{repoPath: repoPath, err: err}
+		}
+		for _, tag := range tags {
+			if previous[tag], err = git.PreviousTag(repoPath, tag); err != nil {
+				return changelogTagsMsg{repoPath: repoPath, err: err}
+			}
+		}
+		return changelogTagsMsg{repoPath: repoPath, tags: tags, previous: previous}
+	}
+}
+
+type changelogLoadedMsg struct {
+	repoPath string
+	from, to string
+	commits  []git.Commit
+	err      error
+}
+
+func loadChangelog(repoPath, from, to string) tea.Cmd {
+	return func() tea.Msg {
+		commits, err := git.ReleaseCommits(repoPath, from, to, changelogCommitLimit)
+		return changelogLoadedMsg{repoPath: repoPath, from: from, to: to, commits: commits, err: err}
+	}
+}
+
+// handleChangelogTags offers the releases to write the changelog of: what
+// is unreleased since the latest release, then each tag since the release
+// before it
+func (m *model) handleChangelogTags(msg changelogTagsMsg) tea.Cmd {
+	if msg.err != nil {
+		m.log.add(logError, "listing tags failed: %v", msg.err)
+		return nil
+	}
+	if m.repo == nil || m.repo.Path != msg.repoPath {
+		return nil
+	}
+	if len(msg.tags) == 0 {
+		m.log.add(logInfo, "no tags yet; the changelog covers the whole history")
+		return m.openChangelog("", "HEAD")
+	}
+	latest, label := msg.previous["HEAD"], "Unreleased  whole history"
+	if latest != "" {
+		label = "Unreleased  " + latest + "..HEAD"
+	}
+	items := []menuItem{{key: menuKey(0), label: label, action: func(m *model) tea.Cmd {
+		return m.openChangelog(latest, "HEAD")
+	}}}
+	for _, tag := range msg.tags {
+		from, label := msg.previous[tag], tag+"  first release"
+		if from != "" {
+			label = tag + "  " + from + ".." + tag
+		}
+		items = append(items, menuItem{key: menuKey(len(items)), label: label, action: func(m *model) tea.Cmd {
+			return m.openChangelog(from, tag)
+		}})
+	}
+	m.openMenu("📜 Changelog of", items)
+	return nil
+}
+
+// openChangelog shows the changelog of the commits since from up to to in
+// the shown repository, going back to the current mode when closed
+func (m *model) openChangelog(from, to string) tea.Cmd {
+	if m.currentMode != changelogMode {
+		m.changelogReturnMode = m.currentMode
+	}
+	m.currentMode = changelogMode
+	m.activePanel = topPanel
+	m.changelogRepo = m.repo.Path
+	m.changelogFrom, m.changelogTo = from, to
+	m.changelogEntries = nil
+	m.changelogLine = 0
+	m.changelogLoading = true
+	return loadChangelog(m.changelogRepo, from, to)
+}
+
+// changelog groups the loaded commits as chosen
+func (m model) changelog() export.Changelog {
+	if m.changelogByAuthor {
+		return export.ChangelogByAuthor(m.changelogTitle, m.changelogEntries)
+	}
+	return export.ChangelogByType(m.changelogTitle, m.changelogEntries)
+}
+
+// changelogRange names the commits the changelog is of
+func (m model) changelogRange() string {
+	if m.changelogFrom == "" {
+		return "up to " + m.changelogTo
+	}
+	return m.changelogFrom + ".." + m.changelogTo
+}
+
+// exportChangelogOperation adds the changelog at the top of CHANGELOG.md
+// in the work tree of repoPath
+func exportChangelogOperation(repoPath string, log export.Changelog) tea.Cmd {
+	return requestOperation(operation{
+		desc:     "add " + log.Title + " to " + changelogFile,
+		repoPath: repoPath,
+		refresh:  refreshStatus,
+		run:      func() error { return export.PrependChangelog(log, filepath.Join(repoPath, changelogFile)) },
+	})
+}
+
+// renderChangelog shows the changelog as the Markdown it exports to
+func (m model) renderChangelog(width, height int) string {
+	panelStyle := lipgloss.NewStyle().
+		Width(width).
+		Height(height).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("170"))
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("170"))
+
+	sectionStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("214"))
+
+	grouping := "by type"
+	if m.changelogByAuthor {
+		grouping = "by author"
+	}
+	content := []string{titleStyle.Render(fmt.Sprintf("Changelog %s, %s", m.changelogRange(), grouping)), ""}
+	if m.changelogLoading {
+		content = append(content, "  Loading...")
+		return panelStyle.Render(strings.Join(content, "\n"))
+	}
+
+	lines := strings.Split(strings.TrimSuffix(m.changelog().Markdown(), "\n"), "\n")
+	visible := max(height-3, 1)
+	start := min(m.changelogLine, max(len(lines)-visible, 0))
+	for _, line := range lines[start:min(start+visible, len(lines))] {
+		line = truncate(line, width-3)
+		switch {
+		case strings.HasPrefix(line, "## "):
+			line = titleStyle.Render(line)
+		case strings.HasPrefix(line, "### "):
+			line = sectionStyle.Render(line)
+		}
+		content = append(content, " "+line)
+	}
+	return panelStyle.Render(strings.Join(content, "\n"))
+}
+
+// renderChangelogSummary counts what the changelog is made of
+func (m model) renderChangelogSummary(width, height int) string {
+	panelStyle := lipgloss.NewStyle().
+		Width(width).
+		Height(height).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("240"))
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("170"))
+
+	content := []string{titleStyle.Render("Release"), ""}
+	if !m.changelogLoading {
+		authors := make(map[string]bool)
+		for _, entry := range m.changelogEntries {
+			authors[entry.Author] = true
+		}
+		content = append(content, fmt.Sprintf("  %d commits by %d authors, merges left out", len(m.changelogEntries), len(authors)))
+		if len(m.changelogEntries) == changelogCommitLimit {
+			content = append(content, fmt.Sprintf("  Only the newest %d commits are listed", changelogCommitLimit))
+		}
+	}
+	content = append(content, "", fmt.Sprintf("  e adds it at the top of %s in %s", changelogFile, filepath.Base(m.changelogRepo)))
+	return panelStyle.Render(strings.Join(content, "\n"))
+}
+
+// changelogController handles changelog mode: the notes of a release
+// written from its commits
+type changelogController struct{ baseController }
+
+func (changelogController) handleKey(m *model, key string) (tea.Cmd, bool) {
+	switch key {
+	case "W", "esc":
+		m.currentMode = m.changelogReturnMode
+		return nil, true
+	case "a":
+		m.changelogByAuthor = !m.changelogByAuthor
+		m.changelogLine = 0
+		return nil, true
+	case "t":
+		return loadChangelogTags(m.changelogRepo), true
+	case "r":
+		return m.openChangelog(m.changelogFrom, m.changelogTo), true
+	}
+	if m.changelogLoading {
+		return nil, false
+	}
+	switch key {
+	case "y":
+		return copyToClipboard("changelog", m.changelog().Markdown()), true
+	case "e":
+		log, repoPath := m.changelog(), m.changelogRepo
+		m.openConfirmation("Export changelog", fmt.Sprintf("Add %s at the top of %s?", log.Title, changelogFile), func(m *model) tea.Cmd {
+			return exportChangelogOperation(repoPath, log)
+		})
+		return nil, true
+	}
+	return nil, false
+}
+
+func (changelogController) handleMsg(m *model, msg tea.Msg) (tea.Cmd, bool) {
+	loaded, ok := msg.(changelogLoadedMsg)
+	if !ok {
+		return nil, false
+	}
+	if loaded.repoPath != m.changelogRepo || loaded.from != m.changelogFrom || loaded.to != m.changelogTo {
+		return nil, true
+	}
+	m.changelogLoading = false
+	if loaded.err != nil {
+		m.log.add(logError, "reading the commits of %s failed: %v", m.changelogRange(), loaded.err)
+		return nil, true
+	}
+	m.changelogTitle = "Unreleased"
+	if loaded.to != "HEAD" {
+		m.changelogTitle = loaded.to
+		if len(loaded.commits) > 0 {
+			m.changelogTitle += " (" + loaded.commits[0].Time.Format("2006-01-02") + ")"
+		}
+	}
+	m.changelogEntries = make([]export.ChangelogEntry, 0, len(loaded.commits))
+	for _, commit := range loaded.commits {
+		m.changelogEntries = append(m.changelogEntries, export.ChangelogEntry{
+			ShortHash: commit.ShortHash,
+			Author:    commit.Author,
+			Subject:   commit.Subject,
+			Body:      commit.Body,
+		})
+	}
+	return nil, true
+}
+
+func (changelogController) selection(m *model) (*int, int) {
+	if m.changelogLoading {
+		return &m.changelogLine, 0
+	}
+	return &m.changelogLine, strings.Count(m.changelog().Markdown(), "\n")
+}
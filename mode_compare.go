@@ -0,0 +1,346 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/asbjornb/kvist/git"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// compareCommitLimit is how much history of each repository is compared
+const compareCommitLimit = 300
+
+// compareSide is one of the two repositories shown side by side
+type compareSide struct {
+	path     string
+	repo     *git.Repository
+	status   *git.Status
+	commits  []git.Commit
+	err      error
+	loading  bool
+	selected int
+}
+
+type compareLoadedMsg struct {
+	path    string
+	repo    *git.Repository
+	status  *git.Status
+	commits []git.Commit
+	err     error
+}
+
+func loadCompareSide(path string) tea.Cmd {
+	return func() tea.Msg {
+		repo, err := git.OpenRepository(path)
+		if err != nil {
+			return compareLoadedMsg{path: path, err: err}
+		}
+		status, err := readStatus(repo.Path, filesAll)
+		if err != nil {
+			return compareLoadedMsg{path: path, err: err}
+		}
+		commits, err := git.GetCommits(repo.Path, compareCommitLimit)
+		return compareLoadedMsg{path: path, repo: repo, status: status, commits: commits, err: err}
+	}
+}
+
+// openCompareMenu lists the repositories to compare the open one with: the
+// other tabs first, then the recently opened ones
+func (m *model) openCompareMenu() {
+	if m.repo == nil {
+		return
+	}
+	seen := map[string]bool{m.repo.Path: true}
+	var paths []string
+	for _, tab := range m.tabs {
+		if !seen[tab.path] {
+			seen[tab.path] = true
+			paths = append(paths, tab.path)
+		}
+	}
+	if m.scanner != nil {
+		for _, repo := range m.scanner.RecentRepos() {
+			if !seen[repo.Path] {
+				seen[repo.Path] = true
+				paths = append(paths, repo.Path)
+			}
+		}
+	}
+	if len(paths) == 0 {
+		m.log.add(logInfo, "open another repository to compare %s with", m.repo.Name)
+		return
+	}
+	items := make([]menuItem, 0, len(paths))
+	for i, path := range paths[:min(len(paths), 9)] {
+		items = append(items, menuItem{key: menuKey(i), label: filepath.Base(path) + "  " + path, action: func(m *model) tea.Cmd {
+			return m.openCompare(path)
+		}})
+	}
+	m.openMenu("⇔ Compare "+m.repo.Name+" with", items)
+}
+
+// openCompare shows the open repository and the one at other side by side,
+// going back to the current mode when closed
+func (m *model) openCompare(other string) tea.Cmd {
+	if m.currentMode != compareMode {
+		m.compareReturnMode = m.currentMode
+	}
+	m.currentMode = compareMode
+	m.activePanel = topPanel
+	m.compareFocus = 0
+	m.compare = [2]compareSide{{path: m.repo.Path}, {path: other}}
+	return m.reloadCompare()
+}
+
+// reloadCompare loads both repositories again
+func (m *model) reloadCompare() tea.Cmd {
+	cmds := make([]tea.Cmd, 0, len(m.compare))
+	for i := range m.compare {
+		m.compare[i].loading = true
+		cmds = append(cmds, loadCompareSide(m.compare[i].path))
+	}
+	return tea.Batch(cmds...)
+}
+
+// compareSubjects returns the subjects of the commits of side, to tell
+// which commits the other side has a counterpart of
+func (m model) compareSubjects(side int) map[string]bool {
+	subjects := make(map[string]bool, len(m.compare[side].commits))
+	for _, commit := range m.compare[side].commits {
+		subjects[commit.Subject] = true
+	}
+	return subjects
+}
+
+// compareFiles returns the paths of the changed files of side
+func (m model) compareFiles(side int) map[string]bool {
+	files := make(map[string]bool)
+	if status := m.compare[side].status; status != nil {
+		for _, file := range status.Files {
+			files[file.Path] = true
+		}
+	}
+	return files
+}
+
+// compareName names side by its repository
+func (m model) compareName(side int) string {
+	if repo := m.compare[side].repo; repo != nil {
+		return repo.Name
+	}
+	return filepath.Base(m.compare[side].path)
+}
+
+// renderCompare shows the two repositories side by side, marking what only
+// one of them has
+func (m model) renderCompare(width, height int) string {
+	leftWidth := width / 2
+	widths := [2]int{leftWidth, width - leftWidth}
+	panels := make([]string, 0, len(m.compare))
+	for i := range m.compare {
+		panels = append(panels, m.renderCompareSide(i, widths[i], height))
+	}
+	return lipgloss.JoinHorizontal(lipgloss.Top, panels...)
+}
+
+func (m model) renderCompareSide(side, width, height int) string {
+	borderColor := lipgloss.Color("240")
+	if side == m.compareFocus {
+		borderColor = lipgloss.Color("170")
+	}
+	panelStyle := lipgloss.NewStyle().
+		Width(width).
+		Height(height).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(borderColor)
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("170"))
+
+	onlyStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("214"))
+
+	sharedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("242"))
+
+	selectedStyle := lipgloss.NewStyle().
+		Background(lipgloss.Color("238"))
+
+	s := m.compare[side]
+	title := m.compareName(side)
+	if s.repo != nil {
+		title += "  🌿 " + s.repo.CurrentBranch
+	}
+	content := []string{titleStyle.Render(title), ""}
+	switch {
+	case s.err != nil:
+		content = append(content, "  "+s.err.Error())
+		return panelStyle.Render(strings.Join(content, "\n"))
+	case s.repo == nil && s.loading:
+		content = append(content, "  Loading...")
+		return panelStyle.Render(strings.Join(content, "\n"))
+	}
+
+	// Rows of the side, marked by whether the other side has them too
+	var rows []string
+	var shared []bool
+	if m.compareHistory {
+		other := m.compareSubjects(1 - side)
+		for _, commit := range s.commits {
+			rows = append(rows, commit.ShortHash+" "+commit.Subject)
+			shared = append(shared, other[commit.Subject])
+		}
+	} else if s.status != nil {
+		other := m.compareFiles(1 - side)
+		for _, file := range s.status.Files {
+			rows = append(rows, file.XY+" "+file.Path)
+			shared = append(shared, other[file.Path])
+		}
+	}
+	if len(rows) == 0 {
+		if m.compareHistory {
+			content = append(content, "  No commits")
+		} else {
+			content = append(content, "  Working tree clean")
+		}
+		return panelStyle.Render(strings.Join(content, "\n"))
+	}
+
+	visible := max(height-3, 1)
+	start := 0
+	if s.selected >= visible {
+		start = s.selected - visible + 1
+	}
+	for i := start; i < min(start+visible, len(rows)); i++ {
+		mark, style := "● ", onlyStyle
+		if shared[i] {
+			mark, style = "= ", sharedStyle
+		}
+		line := style.Render(mark + truncate(rows[i], width-5))
+		if side == m.compareFocus && i == s.selected {
+			line = selectedStyle.Width(width - 2).Render(line)
+		}
+		content = append(content, " "+line)
+	}
+	return panelStyle.Render(strings.Join(content, "\n"))
+}
+
+// renderCompareSummary counts what only one side has and tells where the
+// selected row is on the other side
+func (m model) renderCompareSummary(width, height int) string {
+	panelStyle := lipgloss.NewStyle().
+		Width(width).
+		Height(height).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("240"))
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("170"))
+
+	content := []string{titleStyle.Render("Differences"), ""}
+	for side := range m.compare {
+		s := m.compare[side]
+		if m.compareHistory {
+			other := m.compareSubjects(1 - side)
+			only := 0
+			for _, commit := range s.commits {
+				if !other[commit.Subject] {
+					only++
+				}
+			}
+			content = append(content, fmt.Sprintf("  %s: %d of its last %d commits have no counterpart in %s",
+				m.compareName(side), only, len(s.commits), m.compareName(1-side)))
+		} else if s.status != nil {
+			other := m.compareFiles(1 - side)
+			only := 0
+			for _, file := range s.status.Files {
+				if !other[file.Path] {
+					only++
+				}
+			}
+			content = append(content, fmt.Sprintf("  %s: %d changed files, %d not changed in %s",
+				m.compareName(side), len(s.status.Files), only, m.compareName(1-side)))
+		}
+	}
+
+	s := m.compare[m.compareFocus]
+	other := m.compareName(1 - m.compareFocus)
+	if m.compareHistory && s.selected < len(s.commits) {
+		commit := s.commits[s.selected]
+		content = append(content, "")
+		match := "not in " + other
+		for _, c := range m.compare[1-m.compareFocus].commits {
+			if c.Subject == commit.Subject {
+				match = fmt.Sprintf("also in %s as %s", other, c.ShortHash)
+				break
+			}
+		}
+		content = append(content, fmt.Sprintf("  %s %s, %s: %s", commit.ShortHash, commit.Author, git.FormatRelativeTime(commit.Time), match))
+	}
+	content = append(content, "", "  ● only on this side  = on both sides (commits match by subject, files by path)")
+	return panelStyle.Render(strings.Join(content, "\n"))
+}
+
+// compareController handles compare mode: two repositories side by side
+type compareController struct{ baseController }
+
+func (compareController) handleKey(m *model, key string) (tea.Cmd, bool) {
+	switch key {
+	case "=", "esc":
+		m.currentMode = m.compareReturnMode
+		return nil, true
+	case "tab", "shift+tab":
+		m.compareFocus = 1 - m.compareFocus
+		return nil, true
+	case "v":
+		m.compareHistory = !m.compareHistory
+		for i := range m.compare {
+			m.compare[i].selected = 0
+		}
+		return nil, true
+	case "x":
+		m.compare[0], m.compare[1] = m.compare[1], m.compare[0]
+		return nil, true
+	case "r":
+		return m.reloadCompare(), true
+	}
+	return nil, false
+}
+
+func (compareController) handleMsg(m *model, msg tea.Msg) (tea.Cmd, bool) {
+	loaded, ok := msg.(compareLoadedMsg)
+	if !ok {
+		return nil, false
+	}
+	i := slices.IndexFunc(m.compare[:], func(s compareSide) bool { return s.path == loaded.path })
+	if i < 0 {
+		return nil, true
+	}
+	s := &m.compare[i]
+	s.loading = false
+	s.err = loaded.err
+	if loaded.repo != nil {
+		s.repo, s.status, s.commits = loaded.repo, loaded.status, loaded.commits
+	}
+	if loaded.err != nil {
+		m.log.add(logError, "comparing %s failed: %v", filepath.Base(loaded.path), loaded.err)
+	}
+	return nil, true
+}
+
+func (compareController) selection(m *model) (*int, int) {
+	s := &m.compare[m.compareFocus]
+	if m.compareHistory {
+		return &s.selected, len(s.commits)
+	}
+	if s.status == nil {
+		return &s.selected, 0
+	}
+	return &s.selected, len(s.status.Files)
+}
@@ -0,0 +1,637 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/asbjornb/kvist/git"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// fileFilter narrows the files mode list down to one kind of file
+type fileFilter int
+
+const (
+	filesAll       fileFilter = iota // every change, ignored files excluded
+	filesUntracked                   // untracked files only
+	filesIgnored                     // ignored files only
+)
+
+func (f fileFilter) String() string {
+	switch f {
+	case filesUntracked:
+		return "untracked"
+	case filesIgnored:
+		return "ignored"
+	}
+	return "all"
+}
+
+// readStatus reads the work tree status narrowed down by filter
+func readStatus(repoPath string, filter fileFilter) (*git.Status, error) {
+	if filter == filesIgnored {
+		files, err := git.GetIgnored(repoPath)
+		if err != nil {
+			return nil, err
+		}
+		return &git.Status{Files: files}, nil
+	}
+	status, err := git.GetStatus(repoPath)
+	if err != nil || filter == filesAll {
+		return status, err
+	}
+	untracked := []git.FileStatus{}
+	for _, file := range status.Files {
+		if file.IsUntracked() {
+			untracked = append(untracked, file)
+		}
+	}
+	return &git.Status{Files: untracked}, nil
+}
+
+// stashItems offers ways to stash local changes; each asks for an optional
+// message first
+func (m model) stashItems() []menuItem {
+	repoPath := m.repo.Path
+	stash := func(opts git.StashOptions) func(*model) tea.Cmd {
+		return func(m *model) tea.Cmd {
+			m.openPrompt("📦 Stash message (optional)", "", func(_ *model, value string) tea.Cmd {
+				opts.Message = strings.TrimSpace(value)
+				return stashOperation(repoPath, opts)
+			})
+			return nil
+		}
+	}
+	items := []menuItem{
+		{key: "s", label: "All tracked changes", action: stash(git.StashOptions{})},
+		{key: "u", label: "All changes, untracked files too (--include-untracked)", action: stash(git.StashOptions{IncludeUntracked: true})},
+		{key: "k", label: "All tracked changes, keeping staged ones in the index (--keep-index)", action: stash(git.StashOptions{KeepIndex: true})},
+	}
+
+	// The selected file, or the files of the selected section or directory
+	var files []git.FileStatus
+	name := ""
+	switch {
+	case m.selectedGroup != nil:
+		files = m.groupFiles(*m.selectedGroup)
+		name = m.selectedGroup.dir
+		if name == "" {
+			name = strings.ToLower(m.selectedGroup.side.String()) + " files"
+		}
+	case m.status != nil && m.selectedFile < len(m.status.Files):
+		files = m.status.Files[m.selectedFile : m.selectedFile+1]
+		name = files[0].Path
+	}
+	if len(files) > 0 {
+		var opts git.StashOptions
+		for _, file := range files {
+			opts.Paths = append(opts.Paths, file.Path)
+			if file.OldPath != "" {
+				opts.Paths = append(opts.Paths, file.OldPath)
+			}
+			opts.IncludeUntracked = opts.IncludeUntracked || file.IsUntracked()
+		}
+		items = append(items, menuItem{key: "f", label: "Only " + name, action: stash(opts)})
+	}
+	return items
+}
+
+// gitignoreItems offers patterns to ignore an untracked file by: the file
+// itself, its extension, or its directory
+func gitignoreItems(repoPath, file string) []menuItem {
+	ignore := func(pattern string) func(*model) tea.Cmd {
+		return func(*model) tea.Cmd {
+			return gitignoreOperation(repoPath, pattern)
+		}
+	}
+	// Anchored to the root so they don't match elsewhere in the tree
+	items := []menuItem{{key: "f", label: "This file: /" + file, action: ignore("/" + gitignoreEscape(file))}}
+	if ext := path.Ext(file); ext != "" && ext != file {
+		items = append(items, menuItem{key: "e", label: "All *" + ext + " files", action: ignore("*" + gitignoreEscape(ext))})
+	}
+	if dir := path.Dir(strings.TrimSuffix(file, "/")); dir != "." {
+		items = append(items, menuItem{key: "d", label: "Its directory: /" + dir + "/", action: ignore("/" + gitignoreEscape(dir) + "/")})
+	}
+	return items
+}
+
+// gitignoreEscape makes a slash-separated path match only itself as a
+// .gitignore pattern, escaping glob characters, a leading ! or # and
+// trailing spaces
+func gitignoreEscape(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if strings.ContainsRune(`\[]*?`, r) || i == 0 && (r == '!' || r == '#') ||
+			r == ' ' && strings.TrimRight(name[i:], " ") == "" {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// maxCleanPreview is how many paths the clean confirmation lists by name
+const maxCleanPreview = 15
+
+type cleanPreviewMsg struct {
+	repoPath string
+	mode     git.CleanMode
+	paths    []string
+	err      error
+}
+
+func loadCleanPreview(repoPath string, mode git.CleanMode) tea.Cmd {
+	return func() tea.Msg {
+		paths, err := git.CleanPreview(repoPath, mode)
+		return cleanPreviewMsg{repoPath: repoPath, mode: mode, paths: paths, err: err}
+	}
+}
+
+// cleanItems offers the kinds of git clean; each shows a dry run first
+func (m model) cleanItems() []menuItem {
+	preview := func(mode git.CleanMode) func(*model) tea.Cmd {
+		return func(m *model) tea.Cmd {
+			return loadCleanPreview(m.repo.Path, mode)
+		}
+	}
+	return []menuItem{
+		{key: "u", label: "Untracked files, keep ignored (clean -d)", action: preview(git.CleanUntracked)},
+		{key: "i", label: "Ignored files only, e.g. build output (clean -dX)", action: preview(git.CleanIgnored)},
+		{key: "a", label: "Untracked and ignored files (clean -dx)", action: preview(git.CleanAll)},
+	}
+}
+
+// confirmClean shows what git clean would delete and asks before deleting it
+func (m *model) confirmClean(msg cleanPreviewMsg) {
+	if msg.err != nil {
+		m.log.add(logError, "git clean preview failed: %v", msg.err)
+		return
+	}
+	if m.repo == nil || m.repo.Path != msg.repoPath {
+		return
+	}
+	if len(msg.paths) == 0 {
+		m.log.add(logInfo, "nothing to clean")
+		return
+	}
+	shown := msg.paths[:min(len(msg.paths), maxCleanPreview)]
+	message := fmt.Sprintf("Permanently delete %d path(s)? This can't be undone.\n\n%s", len(msg.paths), strings.Join(shown, "\n"))
+	if len(msg.paths) > len(shown) {
+		message += fmt.Sprintf("\n...and %d more", len(msg.paths)-len(shown))
+	}
+	repoPath, mode := msg.repoPath, msg.mode
+	m.openConfirmation("🧹 git clean", message, func(*model) tea.Cmd {
+		return cleanOperation(repoPath, mode, msg.paths)
+	})
+}
+
+// maxFileCommits is how many commits the restore picker offers
+const maxFileCommits = 50
+
+type fileCommitsMsg struct {
+	repoPath string
+	path     string
+	commits  []git.FileCommit
+	err      error
+}
+
+func loadFileCommits(repoPath, path string) tea.Cmd {
+	return func() tea.Msg {
+		commits, err := git.GetFileCommits(repoPath, path, maxFileCommits)
+		return fileCommitsMsg{repoPath: repoPath, path: path, commits: commits, err: err}
+	}
+}
+
+// confirmRestore asks before overwriting the work tree copy of path with
+// the content of from, its path at commit, at commit
+func (m *model) confirmRestore(path, from, commit string) {
+	repoPath := m.repo.Path
+	source := shortHash(commit)
+	if from != path {
+		source += " (as " + from + ")"
+	}
+	m.openConfirmation("Restore file",
+		fmt.Sprintf("Replace %s with its content at %s? Uncommitted changes to it are lost and can't be undone.", path, source),
+		func(*model) tea.Cmd {
+			return restoreFileOperation(repoPath, commit, from, path)
+		})
+}
+
+// pickRestoreCommit lists the commits that touched a file to restore it from
+func (m *model) pickRestoreCommit(msg fileCommitsMsg) {
+	if msg.err != nil {
+		m.log.add(logError, "listing commits of %s failed: %v", msg.path, msg.err)
+		return
+	}
+	if m.repo == nil || m.repo.Path != msg.repoPath {
+		return
+	}
+	if len(msg.commits) == 0 {
+		m.log.add(logWarn, "%s has never been committed", msg.path)
+		return
+	}
+	items := make([]menuItem, len(msg.commits))
+	for i, commit := range msg.commits {
+		key := ""
+		if i < 9 {
+			key = fmt.Sprint(i + 1)
+		}
+		label := fmt.Sprintf("%s %s (%s)", commit.ShortHash, commit.Subject, git.FormatRelativeTime(commit.Time))
+		if commit.RenamedFrom != "" {
+			label += " ← renamed from " + commit.RenamedFrom
+		}
+		items[i] = menuItem{key: key, label: label, action: func(m *model) tea.Cmd {
+			m.confirmRestore(msg.path, commit.Path, commit.Hash)
+			return nil
+		}}
+	}
+	m.openMenu("⏪ Restore "+msg.path+" from", items)
+}
+
+func (m model) renderFiles(width, height int) string {
+	panelStyle := lipgloss.NewStyle().
+		Width(width).
+		Height(height).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color(func() string {
+			if m.activePanel == topPanel && m.currentMode == filesMode {
+				return "170"
+			}
+			return "240"
+		}()))
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("170"))
+
+	itemStyle := lipgloss.NewStyle().
+		PaddingLeft(1)
+
+	selectedStyle := lipgloss.NewStyle().
+		PaddingLeft(1).
+		Background(lipgloss.Color("238"))
+
+	stagedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("42"))
+
+	unstagedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("214"))
+
+	untrackedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	conflictStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("196"))
+
+	title := titleStyle.Render("Files")
+	if m.filesFilter != filesAll {
+		title += untrackedStyle.Render(fmt.Sprintf(" (%s only, U: show all)", m.filesFilter))
+	}
+	content := []string{title, ""}
+
+	if m.status == nil || len(m.status.Files) == 0 {
+		content = append(content, "  No changes")
+	} else {
+		visibleItems := height - 3 // Reserve space for title and margins
+
+		// mark is a file's status in git's short format: the index letter,
+		// then the work tree one. The letter of the section the file is
+		// listed in is colored, the other dimmed.
+		mark := func(file git.FileStatus, side fileSide) string {
+			xy := file.XY + "  "
+			index, worktree := untrackedStyle.Render(xy[:1]), untrackedStyle.Render(xy[1:2])
+			switch side {
+			case conflictSide:
+				index, worktree = conflictStyle.Render(xy[:1]), conflictStyle.Render(xy[1:2])
+			case stagedSide:
+				index = stagedStyle.Render(xy[:1])
+			case unstagedSide:
+				worktree = unstagedStyle.Render(xy[1:2])
+			}
+			return index + worktree
+		}
+
+		content = append(content, m.renderFileRows(width, visibleItems, mark, itemStyle, selectedStyle, titleStyle, untrackedStyle)...)
+	}
+
+	return panelStyle.Render(strings.Join(content, "\n"))
+}
+
+func (m model) renderFileDiff(width, height int) string {
+	panelStyle := lipgloss.NewStyle().
+		Width(width).
+		Height(height).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color(func() string {
+			if m.activePanel == bottomPanel {
+				return "170"
+			}
+			return "240"
+		}()))
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("170"))
+
+	addStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("42"))
+
+	removeStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("196"))
+
+	lineNumStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("242"))
+
+	headerStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("214"))
+
+	if m.selectedGroup != nil {
+		content := append([]string{titleStyle.Render(m.selectedGroup.side.String()), ""}, m.groupSummary()...)
+		return panelStyle.Render(strings.Join(content, "\n"))
+	}
+	if m.status == nil || len(m.status.Files) == 0 || m.selectedFile >= len(m.status.Files) {
+		title := titleStyle.Render("Diff")
+		content := title + "\n\n" + "  No file selected"
+		return panelStyle.Render(content)
+	}
+
+	file := m.status.Files[m.selectedFile]
+
+	// Show filename in title, and which side of the index the diff is
+	title := titleStyle.Render("Diff: " + file.Path)
+	switch m.selectedSide {
+	case stagedSide:
+		title += titleStyle.Render(" (staged)")
+	case conflictSide:
+		title += titleStyle.Render(" (" + file.Conflict + ")")
+	}
+
+	// Header info
+	content := []string{title, ""}
+
+	// If we have diff content, show it
+	if m.currentDiff != "" {
+		// Check if this is a binary file (our loadDiff function returns this format)
+		if strings.HasPrefix(m.currentDiff, "Binary file ") {
+			content = append(content, "", "  📄 Binary file", "", "  This appears to be a binary file and cannot be displayed as text.")
+		} else {
+			diffLines := strings.Split(m.currentDiff, "\n")
+
+			// Calculate visible lines (leave more space for content)
+			availableLines := height - 3 // Account for title and border
+			startLine := m.diffScrollOffset
+			endLine := startLine + availableLines
+
+			if endLine > len(diffLines) {
+				endLine = len(diffLines)
+			}
+
+			// Add scroll indicator if needed
+			if len(diffLines) > availableLines {
+				scrollInfo := fmt.Sprintf(" [%d-%d/%d lines]", startLine+1, min(endLine, len(diffLines)), len(diffLines))
+				content[0] = title + lineNumStyle.Render(scrollInfo)
+			}
+
+			for i := startLine; i < endLine; i++ {
+				if i >= len(diffLines) {
+					break
+				}
+
+				line := diffLines[i]
+
+				// Style the line based on its prefix
+				var styledLine string
+				maxWidth := width - 4 // Account for border
+
+				switch {
+				case strings.HasPrefix(line, "+++") || strings.HasPrefix(line, "---"):
+					if len(line) > maxWidth {
+						line = line[:maxWidth-3] + "..."
+					}
+					styledLine = headerStyle.Render(line)
+				case strings.HasPrefix(line, "@@"):
+					if len(line) > maxWidth {
+						line = line[:maxWidth-3] + "..."
+					}
+					styledLine = lineNumStyle.Render(line)
+				case strings.HasPrefix(line, "+"):
+					if len(line) > maxWidth {
+						line = line[:maxWidth-3] + "..."
+					}
+					// Show whitespace changes more clearly
+					lineContent := line[1:] // Remove the + prefix
+					if len(strings.TrimSpace(lineContent)) == 0 && len(lineContent) > 0 {
+						// Show what kind of whitespace
+						whitespaceDesc := ""
+						if strings.Contains(lineContent, "\t") {
+							whitespaceDesc += "tabs "
+						}
+						if strings.Contains(lineContent, " ") {
+							whitespaceDesc += "spaces "
+						}
+						if strings.Contains(lineContent, "\r") {
+							whitespaceDesc += "CR "
+						}
+						if whitespaceDesc == "" {
+							whitespaceDesc = fmt.Sprintf("%d chars ", len(lineContent))
+						}
+						styledLine = addStyle.Render(fmt.Sprintf("+ (%s)", strings.TrimSpace(whitespaceDesc)))
+					} else {
+						styledLine = addStyle.Render(line)
+					}
+				case strings.HasPrefix(line, "-"):
+					if len(line) > maxWidth {
+						line = line[:maxWidth-3] + "..."
+					}
+					// Show whitespace changes more clearly
+					lineContent := line[1:] // Remove the - prefix
+					if len(strings.TrimSpace(lineContent)) == 0 && len(lineContent) > 0 {
+						// Show what kind of whitespace
+						whitespaceDesc := ""
+						if strings.Contains(lineContent, "\t") {
+							whitespaceDesc += "tabs "
+						}
+						if strings.Contains(lineContent, " ") {
+							whitespaceDesc += "spaces "
+						}
+						if strings.Contains(lineContent, "\r") {
+							whitespaceDesc += "CR "
+						}
+						if whitespaceDesc == "" {
+							whitespaceDesc = fmt.Sprintf("%d chars ", len(lineContent))
+						}
+						styledLine = removeStyle.Render(fmt.Sprintf("- (%s)", strings.TrimSpace(whitespaceDesc)))
+					} else {
+						styledLine = removeStyle.Render(line)
+					}
+				default:
+					if len(line) > maxWidth {
+						line = line[:maxWidth-3] + "..."
+					}
+					styledLine = line
+				}
+
+				content = append(content, styledLine)
+			}
+		}
+	} else if file.Unstaged == "untracked" {
+		content = append(content, "", "  Loading file contents...")
+	} else {
+		// Show if we're waiting for diff or if there's no diff
+		status := "staged"
+		if m.selectedSide != stagedSide {
+			status = "modified"
+		}
+		content = append(content, "", fmt.Sprintf("  No diff available for %s file", status))
+		content = append(content, "", "  (File may have no changes or loading failed)")
+	}
+
+	return panelStyle.Render(strings.Join(content, "\n"))
+}
+
+// filesController handles files mode: the work tree status and its diffs
+type filesController struct{ baseController }
+
+func (filesController) handleKey(m *model, key string) (tea.Cmd, bool) {
+	switch key {
+	case "e":
+		if m.repo == nil || m.status == nil || m.selectedFile >= len(m.status.Files) {
+			return nil, true
+		}
+		file := m.status.Files[m.selectedFile]
+		absPath := filepath.Join(m.repo.Path, file.Path)
+		if _, err := os.Stat(absPath); err != nil {
+			m.log.add(logWarn, "cannot edit %s: %v", file.Path, err)
+			return nil, true
+		}
+		cmd := editorCommand(absPath, git.FirstChangedLine(m.currentDiff))
+		cmd.Dir = m.repo.Path
+		cmd.Env = m.commandEnv(m.repo.Path)
+		return tea.ExecProcess(cmd, func(err error) tea.Msg {
+			return editorFinishedMsg{path: file.Path, err: err}
+		}), true
+	case "c":
+		return m.startCommit(false), true
+	case "C":
+		return m.startCommit(true), true
+	case "U":
+		if m.repo == nil {
+			return nil, true
+		}
+		// Cycle through all changes, untracked only and ignored only
+		m.filesFilter = (m.filesFilter + 1) % 3
+		m.selectedFile, m.selectedRow, m.selectedGroup = 0, 0, nil
+		m.currentDiff = ""
+		return m.reload(refreshStatus), true
+	case "t":
+		if m.status == nil {
+			return nil, true
+		}
+		return m.toggleFileTree(), true
+	case "left", "right":
+		if m.status == nil {
+			return nil, true
+		}
+		return m.setGroupCollapsed(key == "left"), true
+	case "M":
+		if m.repo == nil {
+			return nil, true
+		}
+		// Show renames as the deletion and addition they are made of, or pair them again
+		renames := git.Renames()
+		renames.Off = !renames.Off
+		git.SetRenames(renames)
+		if renames.Off {
+			m.log.add(logInfo, "rename detection off: renames show as a deletion and an addition")
+		} else {
+			m.log.add(logInfo, "rename detection on")
+		}
+		diffs.clear()
+		m.currentDiff = ""
+		return m.reload(refreshStatus), true
+	case "S":
+		if m.repo == nil || m.status == nil || len(m.status.Files) == 0 {
+			return nil, true
+		}
+		m.openMenu("📦 Stash changes", m.stashItems())
+		return nil, true
+	case "z":
+		if m.repo == nil {
+			return nil, true
+		}
+		m.pickStash()
+		return nil, true
+	case "X":
+		if m.repo == nil {
+			return nil, true
+		}
+		m.openMenu("🧹 Clean work tree", m.cleanItems())
+		return nil, true
+	case "i":
+		if m.repo == nil || m.status == nil || m.selectedFile >= len(m.status.Files) {
+			return nil, true
+		}
+		file := m.status.Files[m.selectedFile]
+		if file.Unstaged != "untracked" {
+			m.log.add(logWarn, "only untracked files can be ignored; %s is tracked", file.Path)
+			return nil, true
+		}
+		m.openMenu("🙈 Add to .gitignore", gitignoreItems(m.repo.Path, file.Path))
+		return nil, true
+	case "H":
+		if m.repo == nil || m.status == nil || m.selectedFile >= len(m.status.Files) {
+			return nil, true
+		}
+		return loadFileCommits(m.repo.Path, m.status.Files[m.selectedFile].Path), true
+	case "v":
+		if m.repo == nil || m.status == nil || m.selectedFile >= len(m.status.Files) || len(m.commits) == 0 {
+			return nil, true
+		}
+		// The committed version, to compare with the work tree
+		return m.viewFileAt(m.commits[0].Hash, m.status.Files[m.selectedFile].Path, filesMode), true
+	case " ", "enter":
+		if m.activePanel == topPanel && m.repo != nil && m.selectedGroup != nil {
+			return stageGroupOperation(m.repo.Path, *m.selectedGroup, m.groupFiles(*m.selectedGroup)), true
+		}
+		if m.activePanel == topPanel && m.repo != nil && m.status != nil && m.selectedFile < len(m.status.Files) {
+			return stageOperation(m.repo.Path, m.status.Files[m.selectedFile], m.selectedSide), true
+		}
+		return nil, true
+	}
+	return nil, false
+}
+
+func (filesController) handleMsg(m *model, msg tea.Msg) (tea.Cmd, bool) {
+	switch msg := msg.(type) {
+	case fileCommitsMsg:
+		m.pickRestoreCommit(msg)
+		return nil, true
+	case cleanPreviewMsg:
+		m.confirmClean(msg)
+		return nil, true
+	case stashFilesMsg:
+		m.pickStashFile(msg)
+		return nil, true
+	case stashFileDiffMsg:
+		m.pickStashHunk(msg)
+		return nil, true
+	}
+	return nil, false
+}
+
+func (filesController) selection(m *model) (*int, int) {
+	if m.status == nil {
+		return nil, 0
+	}
+	return &m.selectedRow, len(m.fileRows())
+}
+
+func (filesController) selectionChanged(m *model) tea.Cmd {
+	m.selectFileRow()
+	m.diffScrollOffset = 0
+	return m.loadSelectedDiff()
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/asbjornb/kvist/git"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	defaultCommitLimit = 50    // history entries loaded by default
+	maxCommitLimit     = 10000 // how far back jump-to-commit will page
+)
+
+type commitJumpMsg struct {
+	repoPath string
+	ref      string
+	hash     string
+	commits  []git.Commit
+	limit    int
+	err      error
+}
+
+// jumpToCommit resolves ref and loads enough history, page by page, to contain it
+func jumpToCommit(repoPath, ref string, loaded int) tea.Cmd {
+	return func() tea.Msg {
+		hash, err := git.ResolveCommit(repoPath, ref)
+		if err != nil {
+			return commitJumpMsg{repoPath: repoPath, ref: ref, err: err}
+		}
+		if ok, err := git.IsAncestor(repoPath, hash, "HEAD"); err != nil || !ok {
+			return commitJumpMsg{repoPath: repoPath, ref: ref, err: fmt.Errorf("%s is not in the history of HEAD", shortHash(hash))}
+		}
+
+		for limit := max(loaded*2, 2*defaultCommitLimit); ; limit *= 2 {
+			limit = min(limit, maxCommitLimit)
+			commits, err := git.GetCommits(repoPath, limit)
+			if err != nil {
+				return commitJumpMsg{repoPath: repoPath, ref: ref, err: err}
+			}
+			if indexOfCommit(commits, hash) >= 0 {
+				return commitJumpMsg{repoPath: repoPath, ref: ref, hash: hash, commits: commits, limit: limit}
+			}
+			if len(commits) < limit || limit == maxCommitLimit {
+				return commitJumpMsg{repoPath: repoPath, ref: ref, err: fmt.Errorf("%s is more than %d commits back", shortHash(hash), len(commits))}
+			}
+		}
+	}
+}
+
+// indexOfCommit returns the index of the commit with the given hash, or -1
+func indexOfCommit(commits []git.Commit, hash string) int {
+	for i, c := range commits {
+		if c.Hash == hash {
+			return i
+		}
+	}
+	return -1
+}
+
+// indexOfCommitPrefix returns the index of the only commit whose hash starts
+// with prefix, or -1 if there is none or the prefix is ambiguous
+func indexOfCommitPrefix(commits []git.Commit, prefix string) int {
+	if len(prefix) < 4 {
+		return -1
+	}
+	found := -1
+	for i, c := range commits {
+		if strings.HasPrefix(c.Hash, strings.ToLower(prefix)) {
+			if found >= 0 {
+				return -1
+			}
+			found = i
+		}
+	}
+	return found
+}
+
+func (m model) renderCommits(width, height int) string {
+	panelStyle := lipgloss.NewStyle().
+		Width(width).
+		Height(height).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color(func() string {
+			if m.activePanel == topPanel {
+				return "170"
+			}
+			return "240"
+		}()))
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("170"))
+
+	hashStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("214"))
+
+	itemStyle := lipgloss.NewStyle().
+		PaddingLeft(1)
+
+	selectedStyle := lipgloss.NewStyle().
+		PaddingLeft(1).
+		Background(lipgloss.Color("238"))
+
+	rangeStyle := lipgloss.NewStyle().
+		PaddingLeft(1).
+		Background(lipgloss.Color("236"))
+
+	title := titleStyle.Render(func() string {
+		if m.currentMode == historyMode {
+			return "History"
+		}
+		return "Commits"
+	}())
+	content := []string{title, ""}
+
+	// Calculate scroll window to keep selected commit visible
+	visibleItems := height - 3
+	startIdx := 0
+	if m.selectedCommit >= visibleItems {
+		startIdx = m.selectedCommit - visibleItems + 1
+	}
+	endIdx := min(startIdx+visibleItems, len(m.commits))
+
+	for i := startIdx; i < endIdx; i++ {
+		commit := m.commits[i]
+
+		style := itemStyle
+		if m.activePanel == topPanel && i == m.selectedCommit {
+			style = selectedStyle
+		} else if m.currentMode == historyMode && m.inRange(i) {
+			style = rangeStyle
+		}
+
+		line := m.commitRow(commit, width-4, hashStyle)
+		content = append(content, style.Width(width-2).Render(line))
+	}
+
+	return panelStyle.Render(strings.Join(content, "\n"))
+}
+
+func (m model) renderCommitDetails(width, height int) string {
+	panelStyle := lipgloss.NewStyle().
+		Width(width).
+		Height(height).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color(func() string {
+			if m.activePanel == middlePanel {
+				return "170"
+			}
+			return "240"
+		}()))
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("170"))
+
+	hashStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("214"))
+
+	authorStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("242"))
+
+	title := titleStyle.Render("Details")
+
+	if len(m.commits) == 0 || m.selectedCommit >= len(m.commits) {
+		content := title + "\n\n" + "  No commit selected"
+		return panelStyle.Render(content)
+	}
+
+	if newest, oldest, _ := m.commitRange(); m.rangeActive && newest != oldest {
+		content := append([]string{titleStyle.Render("Range"), ""}, m.rangeSummary()...)
+		return panelStyle.Render(strings.Join(content, "\n"))
+	}
+
+	commit := m.commits[m.selectedCommit]
+
+	timeStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("114"))
+
+	content := []string{
+		title,
+		"",
+		hashStyle.Render("Hash: " + commit.ShortHash),
+		authorStyle.Render("Author: " + commit.Author),
+		timeStyle.Render("Date: " + commit.Time.Format("2006-01-02 15:04:05") + " (" + git.FormatRelativeTime(commit.Time) + ")"),
+		"",
+		"Subject:",
+		lipgloss.NewStyle().PaddingLeft(2).Render(commit.Subject),
+	}
+
+	if commit.Body != "" && strings.TrimSpace(commit.Body) != "" {
+		content = append(content, "", "Body:")
+		bodyLines := strings.Split(strings.TrimSpace(commit.Body), "\n")
+		for _, line := range bodyLines {
+			if len(content) >= height-3 {
+				content = append(content, lipgloss.NewStyle().PaddingLeft(2).Render("..."))
+				break
+			}
+			content = append(content, lipgloss.NewStyle().PaddingLeft(2).Width(width-4).Render(line))
+		}
+	}
+
+	return panelStyle.Render(strings.Join(content, "\n"))
+}
+
+func (m model) renderCommitDiff(width, height int) string {
+	panelStyle := lipgloss.NewStyle().
+		Width(width).
+		Height(height).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color(func() string {
+			if m.activePanel == bottomPanel {
+				return "170"
+			}
+			return "240"
+		}()))
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("170"))
+
+	addStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("42")) // Green
+
+	removeStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("196")) // Red
+
+	lineNumStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("242")) // Gray
+
+	headerStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("214")) // Orange
+
+	diffHeaderStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("226")).Bold(true) // Yellow
+
+	title := titleStyle.Render("Diff")
+	content := []string{title, ""}
+
+	if m.currentDiff == "" {
+		content = append(content, lineNumStyle.Render("  Loading diff..."))
+		return panelStyle.Render(strings.Join(content, "\n"))
+	}
+
+	// Render diff with syntax highlighting
+	lines := strings.Split(m.currentDiff, "\n")
+	maxDiffLines := height - 4 // Leave space for title and padding
+
+	// Apply scroll offset
+	startLine := m.diffScrollOffset
+	endLine := startLine + maxDiffLines
+	if endLine > len(lines) {
+		endLine = len(lines)
+	}
+
+	if startLine < len(lines) {
+		for i := startLine; i < endLine; i++ {
+			line := lines[i]
+			var styledLine string
+			maxWidth := width - 6 // Account for padding and borders
+
+			// Syntax highlighting for diff
+			switch {
+			case strings.HasPrefix(line, "+++") || strings.HasPrefix(line, "---"):
+				// File headers
+				if len(line) > maxWidth {
+					line = line[:maxWidth-3] + "..."
+				}
+				styledLine = headerStyle.Render(line)
+			case strings.HasPrefix(line, "@@"):
+				// Hunk headers
+				if len(line) > maxWidth {
+					line = line[:maxWidth-3] + "..."
+				}
+				styledLine = lineNumStyle.Render(line)
+			case strings.HasPrefix(line, "+"):
+				// Additions
+				if len(line) > maxWidth {
+					line = line[:maxWidth-3] + "..."
+				}
+				styledLine = addStyle.Render(line)
+			case strings.HasPrefix(line, "-"):
+				// Deletions
+				if len(line) > maxWidth {
+					line = line[:maxWidth-3] + "..."
+				}
+				styledLine = removeStyle.Render(line)
+			case strings.HasPrefix(line, "diff --git"):
+				// Diff headers
+				if len(line) > maxWidth {
+					line = line[:maxWidth-3] + "..."
+				}
+				styledLine = diffHeaderStyle.Render(line)
+			default:
+				if len(line) > maxWidth {
+					line = line[:maxWidth-3] + "..."
+				}
+				styledLine = line
+			}
+
+			content = append(content, lipgloss.NewStyle().PaddingLeft(2).Render(styledLine))
+		}
+
+		// Show scroll indicator if there's more content
+		if endLine < len(lines) || startLine > 0 {
+			scrollInfo := fmt.Sprintf("[%d-%d/%d lines]", startLine+1, endLine, len(lines))
+			content = append(content, "", lineNumStyle.Render(scrollInfo))
+		}
+	}
+
+	return panelStyle.Render(strings.Join(content, "\n"))
+}
+
+// historyController handles history mode: commits, their details and diffs
+type historyController struct{ baseController }
+
+func (historyController) panels() int { return 3 }
+
+func (historyController) handleKey(m *model, key string) (tea.Cmd, bool) {
+	switch key {
+	case "t":
+		if m.repo == nil || m.selectedCommit >= len(m.commits) {
+			return nil, true
+		}
+		// Browse the repository as of the selected commit
+		return m.openTree(m.commits[m.selectedCommit].Hash, historyMode), true
+	case "v":
+		if m.repo == nil || m.selectedCommit >= len(m.commits) {
+			return nil, true
+		}
+		return loadCommitFiles(m.repo.Path, m.commits[m.selectedCommit].Hash), true
+	case "n":
+		if m.repo == nil || m.selectedCommit >= len(m.commits) {
+			return nil, true
+		}
+		// Branch off the selected commit
+		m.startBranchInput(m.commits[m.selectedCommit].Hash)
+		return nil, true
+	case "C":
+		if m.repo == nil || m.selectedCommit >= len(m.commits) {
+			return nil, true
+		}
+		return checkoutCommitOperation(m.repo.Path, m.commits[m.selectedCommit].Hash), true
+	case "B":
+		if m.repo == nil || m.selectedCommit >= len(m.commits) {
+			return nil, true
+		}
+		// Move the commits after the selected one onto another base
+		return loadTransplantState(m.repo.Path, m.commits[m.selectedCommit].Hash), true
+	case "A":
+		if m.repo == nil || m.selectedCommit >= len(m.commits) {
+			return nil, true
+		}
+		commit := m.commits[m.selectedCommit]
+		m.openMenu("📦 Export "+commit.ShortHash, archiveItems(m.repo.Path, commit.Hash, commit.ShortHash))
+		return nil, true
+	case "J", "shift+down":
+		return m.extendRange(1), true
+	case "K", "shift+up":
+		return m.extendRange(-1), true
+	case "esc":
+		if !m.rangeActive {
+			return nil, false
+		}
+		m.rangeActive = false
+		return m.selectionChanged(), true
+	case "x":
+		m.openMenu("Selected commits", m.rangeItems())
+		return nil, true
+	case "ctrl+g":
+		if m.repo == nil {
+			return nil, true
+		}
+		m.openPrompt("Go to commit (hash or ref)", "", func(m *model, value string) tea.Cmd {
+			value = strings.TrimSpace(value)
+			if value == "" || m.repo == nil {
+				return nil
+			}
+			if idx := indexOfCommitPrefix(m.commits, value); idx >= 0 {
+				// Already loaded; no need to ask git
+				m.selectedCommit = idx
+				return m.selectionChanged()
+			}
+			return jumpToCommit(m.repo.Path, value, len(m.commits))
+		})
+		return nil, true
+	}
+	return nil, false
+}
+
+func (historyController) handleMsg(m *model, msg tea.Msg) (tea.Cmd, bool) {
+	switch msg := msg.(type) {
+	case transplantStateMsg:
+		m.startTransplant(msg)
+		return nil, true
+	case transplantPreviewMsg:
+		m.confirmTransplant(msg)
+		return nil, true
+	case commitJumpMsg:
+		if m.repo == nil || msg.repoPath != m.repo.Path {
+			return nil, true
+		}
+		if msg.err != nil {
+			m.log.add(logError, "go to commit %s: %v", msg.ref, msg.err)
+			return nil, true
+		}
+		m.commits = msg.commits
+		m.commitLimit = msg.limit
+		m.selectedCommit = indexOfCommit(m.commits, msg.hash)
+		m.currentMode = historyMode
+		m.activePanel = topPanel
+		return m.selectionChanged(), true
+	}
+	return nil, false
+}
+
+func (historyController) selection(m *model) (*int, int) {
+	return &m.selectedCommit, len(m.commits)
+}
+
+func (historyController) selectionChanged(m *model) tea.Cmd {
+	m.diffScrollOffset = 0
+	if m.repo == nil || m.selectedCommit >= len(m.commits) {
+		m.currentDiff = ""
+		return nil
+	}
+	hash := m.commits[m.selectedCommit].Hash
+	ctx, gen := m.newDiffRequest()
+	return tea.Batch(loadCommitDiff(ctx, gen, m.repo.Path, hash), m.requestChecks(hash))
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/asbjornb/kvist/git"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const maxIncomingCommits = 500
+
+type incomingLoadedMsg struct {
+	repoPath string
+	upstream string
+	commits  []git.Commit
+	err      error
+}
+
+func loadIncoming(repoPath string) tea.Cmd {
+	return func() tea.Msg {
+		upstream, commits, err := git.GetIncomingCommits(repoPath, maxIncomingCommits)
+		return incomingLoadedMsg{repoPath: repoPath, upstream: upstream, commits: commits, err: err}
+	}
+}
+
+// startIncoming lists what origin's default branch has that HEAD doesn't
+func (m *model) startIncoming() tea.Cmd {
+	if m.repo == nil {
+		return nil
+	}
+	m.incomingLoading = true
+	return loadIncoming(m.repo.Path)
+}
+
+func (m model) renderIncoming(width, height int) string {
+	panelStyle := lipgloss.NewStyle().
+		Width(width).
+		Height(height).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color(func() string {
+			if m.activePanel == topPanel {
+				return "170"
+			}
+			return "240"
+		}()))
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("170"))
+
+	hashStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("214"))
+
+	metaStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("242"))
+
+	itemStyle := lipgloss.NewStyle().
+		PaddingLeft(1)
+
+	selectedStyle := lipgloss.NewStyle().
+		PaddingLeft(1).
+		Background(lipgloss.Color("238"))
+
+	upstream := m.incomingUpstream
+	if upstream == "" {
+		upstream = "upstream"
+	}
+	title := titleStyle.Render(fmt.Sprintf("New on %s (%d)", upstream, len(m.incomingCommits)))
+	content := []string{title, ""}
+
+	if len(m.incomingCommits) == 0 {
+		if m.incomingLoading {
+			content = append(content, "  Loading...")
+		} else {
+			content = append(content, "  Nothing new upstream. Fetch (f) to check again.")
+		}
+		return panelStyle.Render(strings.Join(content, "\n"))
+	}
+
+	// Calculate scroll window to keep selected commit visible
+	visibleItems := height - 3
+	startIdx := 0
+	if m.selectedIncoming >= visibleItems {
+		startIdx = m.selectedIncoming - visibleItems + 1
+	}
+	endIdx := min(startIdx+visibleItems, len(m.incomingCommits))
+
+	for i := startIdx; i < endIdx; i++ {
+		commit := m.incomingCommits[i]
+		style := itemStyle
+		if m.activePanel == topPanel && i == m.selectedIncoming {
+			style = selectedStyle
+		}
+		meta := fmt.Sprintf("  %s, %s", commit.Author, git.FormatRelativeTime(commit.Time))
+		subject := commit.Subject
+		if maxSubject := width - 6 - len(commit.ShortHash) - len(meta); len(subject) > maxSubject && maxSubject > 3 {
+			subject = subject[:maxSubject-3] + "..."
+		}
+		content = append(content, style.Width(width-2).Render(hashStyle.Render(commit.ShortHash)+" "+subject+metaStyle.Render(meta)))
+	}
+
+	return panelStyle.Render(strings.Join(content, "\n"))
+}
+
+// incomingController handles incoming mode: commits waiting upstream, to
+// review before pulling
+type incomingController struct{ baseController }
+
+func (incomingController) handleKey(m *model, key string) (tea.Cmd, bool) {
+	switch key {
+	case "I", "esc":
+		m.currentMode = historyMode
+		m.activePanel = topPanel
+		return m.selectionChanged(), true
+	case "r":
+		return m.startIncoming(), true
+	}
+	return nil, false
+}
+
+func (incomingController) handleMsg(m *model, msg tea.Msg) (tea.Cmd, bool) {
+	loaded, ok := msg.(incomingLoadedMsg)
+	if !ok {
+		return nil, false
+	}
+	if m.repo == nil || loaded.repoPath != m.repo.Path {
+		return nil, true
+	}
+	m.incomingLoading = false
+	if loaded.err != nil {
+		m.log.add(logError, "loading incoming commits failed: %v", loaded.err)
+		return nil, true
+	}
+	m.incomingUpstream = loaded.upstream
+	m.incomingCommits = loaded.commits
+	m.selectedIncoming = min(m.selectedIncoming, max(len(m.incomingCommits)-1, 0))
+	if m.currentMode != incomingMode {
+		return nil, true
+	}
+	return m.selectionChanged(), true
+}
+
+func (incomingController) selection(m *model) (*int, int) {
+	return &m.selectedIncoming, len(m.incomingCommits)
+}
+
+func (incomingController) selectionChanged(m *model) tea.Cmd {
+	m.diffScrollOffset = 0
+	m.currentDiff = ""
+	if m.repo == nil || m.selectedIncoming >= len(m.incomingCommits) {
+		return nil
+	}
+	ctx, gen := m.newDiffRequest()
+	return loadCommitDiff(ctx, gen, m.repo.Path, m.incomingCommits[m.selectedIncoming].Hash)
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/asbjornb/kvist/plugin"
+	"github.com/asbjornb/kvist/workspace"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// pluginResponseMsg is a plugin's answer to a render or action request
+type pluginResponseMsg struct {
+	name string
+	seq  int
+	resp *plugin.Response
+	err  error
+}
+
+// plugins returns the configured plugins
+func (m model) plugins() []workspace.Plugin {
+	if m.workspaceConfig == nil {
+		return nil
+	}
+	return m.workspaceConfig.Plugins
+}
+
+// pluginClient returns the client of the plugin for repositories of the
+// workspace repoPath is in, starting none yet. Each workspace gets its own
+// plugin process, as each has its own environment.
+func (m *model) pluginClient(p workspace.Plugin, repoPath string) *plugin.Client {
+	key := p.Name
+	if m.workspaceConfig != nil {
+		if ws := m.workspaceConfig.WorkspaceForRepo(repoPath); ws != nil {
+			key += "\x00" + ws.Name
+		}
+	}
+	if client, ok := m.pluginClients[key]; ok {
+		return client
+	}
+	if m.pluginClients == nil {
+		m.pluginClients = make(map[string]*plugin.Client)
+	}
+	env := m.commandEnv(repoPath)
+	client := plugin.New(func() *exec.Cmd {
+		cmd := shellCommand(p.Command)
+		cmd.Env = env
+		return cmd
+	})
+	m.pluginClients[key] = client
+	return client
+}
+
+// closePlugins stops the plugin processes
+func (m model) closePlugins() {
+	for _, client := range m.pluginClients {
+		client.Close()
+	}
+}
+
+// openPlugins lists the plugins to open, or opens the only one
+func (m *model) openPlugins() tea.Cmd {
+	plugins := m.plugins()
+	switch len(plugins) {
+	case 0:
+		m.log.add(logInfo, "no plugins; add them under plugins in the config")
+		return nil
+	case 1:
+		return m.openPlugin(plugins[0])
+	}
+	items := make([]menuItem, 0, len(plugins))
+	for i, p := range plugins {
+		key := p.Key
+		if key == "" {
+			key = menuKey(i)
+		}
+		items = append(items, menuItem{key: key, label: p.Name, action: func(m *model) tea.Cmd {
+			return m.openPlugin(p)
+		}})
+	}
+	m.openMenu("🧩 Plugins", items)
+	return nil
+}
+
+// pluginForKey returns the plugin bound to key
+func (m model) pluginForKey(key string) (workspace.Plugin, bool) {
+	for _, p := range m.plugins() {
+		if p.Key == key {
+			return p, true
+		}
+	}
+	return workspace.Plugin{}, false
+}
+
+// openPlugin shows the panel of p for what is selected now, going back to
+// the current mode when closed
+func (m *model) openPlugin(p workspace.Plugin) tea.Cmd {
+	if m.safeMode {
+		m.log.add(logInfo, "plugins don't run in safe mode")
+		return nil
+	}
+	values := m.customCommandValues()
+	if values["repoPath"] == "" {
+		m.log.add(logInfo, "select a repository to open %s for", p.Name)
+		return nil
+	}
+	if m.currentMode != pluginMode {
+		m.pluginReturnMode = m.currentMode
+	}
+	m.currentMode = pluginMode
+	m.activePanel = topPanel
+	m.plugin = p
+	m.pluginSelection = plugin.Selection{
+		Repo:   values["repoPath"],
+		Branch: values["branch"],
+		Commit: values["commitHash"],
+		File:   values["selectedFile"],
+	}
+	m.pluginView = nil
+	m.pluginLine = 0
+	return m.requestPlugin("")
+}
+
+// requestPlugin asks the open plugin for its panel, or to run the action
+// with id first
+func (m *model) requestPlugin(id string) tea.Cmd {
+	m.pluginSeq++
+	m.pluginLoading = true
+	name, seq, sel := m.plugin.Name, m.pluginSeq, m.pluginSelection
+	client := m.pluginClient(m.plugin, sel.Repo)
+	width, height := m.width-4, max(m.height-12, 1)
+	return func() tea.Msg {
+		var resp *plugin.Response
+		var err error
+		if id == "" {
+			resp, err = client.Render(sel, width, height)
+		} else {
+			resp, err = client.Run(id, sel, width, height)
+		}
+		return pluginResponseMsg{name: name, seq: seq, resp: resp, err: err}
+	}
+}
+
+// renderPluginPanel shows the lines the open plugin answered with
+func (m model) renderPluginPanel(width, height int) string {
+	panelStyle := lipgloss.NewStyle().
+		Width(width).
+		Height(height).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("170"))
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("170"))
+
+	title := m.plugin.Name
+	if m.pluginView != nil && m.pluginView.Title != "" {
+		title += ": " + m.pluginView.Title
+	}
+	if m.pluginLoading {
+		title += " …"
+	}
+	content := []string{titleStyle.Render(title), ""}
+	if m.pluginView != nil {
+		visible := max(height-3, 1)
+		start := min(m.pluginLine, max(len(m.pluginView.Lines)-visible, 0))
+		for _, line := range m.pluginView.Lines[start:min(start+visible, len(m.pluginView.Lines))] {
+			content = append(content, " "+truncate(line, width-3))
+		}
+	}
+	return panelStyle.Render(strings.Join(content, "\n"))
+}
+
+// renderPluginActions lists what the open plugin offers to do
+func (m model) renderPluginActions(width, height int) string {
+	panelStyle := lipgloss.NewStyle().
+		Width(width).
+		Height(height).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("240"))
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("170"))
+
+	keyStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("214"))
+
+	content := []string{titleStyle.Render("Actions"), ""}
+	if m.pluginView == nil || len(m.pluginView.Actions) == 0 {
+		content = append(content, "  None")
+	} else {
+		for _, action := range m.pluginView.Actions {
+			content = append(content, fmt.Sprintf("  %s  %s", keyStyle.Render(action.Key), action.Label))
+		}
+	}
+	return panelStyle.Render(strings.Join(content, "\n"))
+}
+
+// pluginController handles plugin mode: the panel of an external plugin
+type pluginController struct{ baseController }
+
+func (pluginController) handleKey(m *model, key string) (tea.Cmd, bool) {
+	switch key {
+	case "+", "esc":
+		m.currentMode = m.pluginReturnMode
+		return nil, true
+	}
+	if m.pluginView != nil {
+		for _, action := range m.pluginView.Actions {
+			if action.Key == key {
+				return m.requestPlugin(action.ID), true
+			}
+		}
+	}
+	if key == "r" {
+		return m.requestPlugin(""), true
+	}
+	return nil, false
+}
+
+func (pluginController) handleMsg(m *model, msg tea.Msg) (tea.Cmd, bool) {
+	resp, ok := msg.(pluginResponseMsg)
+	if !ok {
+		return nil, false
+	}
+	if resp.name != m.plugin.Name || resp.seq != m.pluginSeq {
+		return nil, true
+	}
+	m.pluginLoading = false
+	if resp.err != nil {
+		m.log.add(logError, "%s: %v", resp.name, resp.err)
+	}
+	if resp.resp == nil {
+		return nil, true
+	}
+	if resp.resp.Message != "" {
+		m.log.add(logInfo, "%s: %s", resp.name, resp.resp.Message)
+	}
+	if resp.err == nil {
+		m.pluginView = resp.resp
+		m.pluginLine = min(m.pluginLine, max(len(resp.resp.Lines)-1, 0))
+	}
+	return nil, true
+}
+
+func (pluginController) selection(m *model) (*int, int) {
+	if m.pluginView == nil {
+		return &m.pluginLine, 0
+	}
+	return &m.pluginLine, len(m.pluginView.Lines)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/asbjornb/kvist/git"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// maxLargestBlobs is how many of the biggest blobs in history are listed
+const maxLargestBlobs = 100
+
+type sizesLoadedMsg struct {
+	repoPath string
+	sizes    *git.RepoSizes
+	err      error
+}
+
+func loadSizes(repoPath string) tea.Cmd {
+	return func() tea.Msg {
+		sizes, err := git.GetRepoSizes(repoPath, maxLargestBlobs)
+		return sizesLoadedMsg{repoPath: repoPath, sizes: sizes, err: err}
+	}
+}
+
+// openSizes shows where the disk space of the repository at repoPath goes,
+// going back to the current mode when closed
+func (m *model) openSizes(repoPath string) tea.Cmd {
+	if m.currentMode != sizeMode {
+		m.sizesReturnMode = m.currentMode
+	}
+	m.currentMode = sizeMode
+	m.activePanel = topPanel
+	if m.sizesRepo != repoPath {
+		m.sizes = nil
+		m.selectedBlob = 0
+	}
+	m.sizesRepo = repoPath
+	m.sizesLoading = true
+	return loadSizes(repoPath)
+}
+
+// renderSizes lists the largest blobs in history
+func (m model) renderSizes(width, height int) string {
+	panelStyle := lipgloss.NewStyle().
+		Width(width).
+		Height(height).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("170"))
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("170"))
+
+	sizeStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("214"))
+
+	hashStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("242"))
+
+	itemStyle := lipgloss.NewStyle().
+		PaddingLeft(1)
+
+	selectedStyle := lipgloss.NewStyle().
+		PaddingLeft(1).
+		Background(lipgloss.Color("238"))
+
+	content := []string{titleStyle.Render("Largest files in history of " + filepath.Base(m.sizesRepo)), ""}
+	if m.sizes == nil {
+		if m.sizesLoading {
+			content = append(content, "  Going through every object in history...")
+		}
+		return panelStyle.Render(strings.Join(content, "\n"))
+	}
+	if len(m.sizes.Largest) == 0 {
+		content = append(content, "  No files committed yet")
+		return panelStyle.Render(strings.Join(content, "\n"))
+	}
+
+	visibleItems := height - 3
+	startIdx := 0
+	if m.selectedBlob >= visibleItems {
+		startIdx = m.selectedBlob - visibleItems + 1
+	}
+	endIdx := min(startIdx+visibleItems, len(m.sizes.Largest))
+	for i, blob := range m.sizes.Largest[startIdx:endIdx] {
+		style := itemStyle
+		if startIdx+i == m.selectedBlob {
+			style = selectedStyle
+		}
+		path := blob.Path
+		if path == "" {
+			path = "(no path)"
+		}
+		line := fmt.Sprintf("%s %s %s", sizeStyle.Render(fmt.Sprintf("%9s", formatSize(blob.Size))), hashStyle.Render(shortHash(blob.Hash)), path)
+		content = append(content, style.Width(width-2).Render(line))
+	}
+	return panelStyle.Render(strings.Join(content, "\n"))
+}
+
+// renderSizeSummary shows how big the work tree and .git directory are
+func (m model) renderSizeSummary(width, height int) string {
+	panelStyle := lipgloss.NewStyle().
+		Width(width).
+		Height(height).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("240"))
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("170"))
+
+	labelStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("242"))
+
+	content := []string{titleStyle.Render("Disk usage"), ""}
+	if m.sizes != nil {
+		var history int64
+		for _, blob := range m.sizes.Largest {
+			history += blob.Size
+		}
+		content = append(content,
+			labelStyle.Render("Work tree: ")+formatSize(m.sizes.WorkTree),
+			labelStyle.Render(".git:      ")+formatSize(m.sizes.GitDir),
+			"",
+			labelStyle.Render(fmt.Sprintf("The %d largest files in history add up to %s uncompressed.", len(m.sizes.Largest), formatSize(history))),
+			labelStyle.Render("Deleting a file in a new commit keeps it in history; only rewriting history"),
+			labelStyle.Render("(e.g. with git filter-repo) makes .git smaller."),
+		)
+	}
+	return panelStyle.Render(strings.Join(content, "\n"))
+}
+
+// sizeController handles size mode: the disk usage of a repository and the
+// largest files in its history
+type sizeController struct{ baseController }
+
+func (sizeController) handleKey(m *model, key string) (tea.Cmd, bool) {
+	switch key {
+	case "Z", "esc":
+		m.currentMode = m.sizesReturnMode
+		return nil, true
+	case "r":
+		return m.openSizes(m.sizesRepo), true
+	}
+	return nil, false
+}
+
+func (sizeController) handleMsg(m *model, msg tea.Msg) (tea.Cmd, bool) {
+	loaded, ok := msg.(sizesLoadedMsg)
+	if !ok {
+		return nil, false
+	}
+	if loaded.repoPath != m.sizesRepo {
+		return nil, true
+	}
+	m.sizesLoading = false
+	if loaded.err != nil {
+		m.log.add(logError, "measuring the repository failed: %v", loaded.err)
+		return nil, true
+	}
+	m.sizes = loaded.sizes
+	m.selectedBlob = min(m.selectedBlob, max(len(m.sizes.Largest)-1, 0))
+	return nil, true
+}
+
+func (sizeController) selection(m *model) (*int, int) {
+	if m.sizes == nil {
+		return &m.selectedBlob, 0
+	}
+	return &m.selectedBlob, len(m.sizes.Largest)
+}
+
+func (sizeController) panels() int { return 1 }
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/asbjornb/kvist/git"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	// statsWeeks is how many weeks back activity and changed files count
+	statsWeeks = 12
+	// maxStatsFiles is how many of the most changed files are listed
+	maxStatsFiles = 50
+)
+
+type statsLoadedMsg struct {
+	repoPath string
+	stats    *git.RepoStats
+	err      error
+}
+
+func loadStats(repoPath string) tea.Cmd {
+	return func() tea.Msg {
+		stats, err := git.GetRepoStats(repoPath, statsWeeks, maxStatsFiles, time.Now())
+		return statsLoadedMsg{repoPath: repoPath, stats: stats, err: err}
+	}
+}
+
+// openStats shows the commit statistics of the repository at repoPath,
+// going back to the current mode when closed
+func (m *model) openStats(repoPath string) tea.Cmd {
+	if m.currentMode != statsMode {
+		m.statsReturnMode = m.currentMode
+	}
+	m.currentMode = statsMode
+	m.activePanel = topPanel
+	if m.statsRepo != repoPath {
+		m.stats = nil
+	}
+	m.statsRepo = repoPath
+	m.statsLoading = true
+	return loadStats(repoPath)
+}
+
+// bar is a horizontal bar of up to width cells for n out of most
+func bar(n, most, width int) string {
+	if most == 0 || width <= 0 {
+		return ""
+	}
+	cells := n * width / most
+	if cells == 0 && n > 0 {
+		return "▏"
+	}
+	return strings.Repeat("█", cells)
+}
+
+// barRows renders a labelled bar per count in width, labels padded to the
+// longest one up to a third of the width
+func barRows(labels []string, counts []int, width int, style lipgloss.Style) []string {
+	most, labelWidth := 0, 0
+	for i, n := range counts {
+		most = max(most, n)
+		labelWidth = max(labelWidth, lipgloss.Width(labels[i]))
+	}
+	labelWidth = min(labelWidth, width/3)
+	countWidth := len(fmt.Sprint(most))
+	barWidth := width - labelWidth - countWidth - 3
+	rows := make([]string, len(counts))
+	for i, n := range counts {
+		rows[i] = fmt.Sprintf("%s %*d %s", padRight(labels[i], labelWidth), countWidth, n, style.Render(bar(n, most, barWidth)))
+	}
+	return rows
+}
+
+// renderStats shows who committed how much next to the weekly activity
+func (m model) renderStats(width, height int) string {
+	leftWidth := width / 2
+	rightWidth := width - leftWidth
+
+	panelStyle := lipgloss.NewStyle().
+		Height(height).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("240"))
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("170"))
+
+	authorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("110"))
+	activityStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
+
+	name := filepath.Base(m.statsRepo)
+	authors := []string{titleStyle.Render("Contributors to " + name), ""}
+	activity := []string{titleStyle.Render(fmt.Sprintf("Commits per week, last %d weeks", statsWeeks)), ""}
+
+	if m.stats == nil {
+		if m.statsLoading {
+			authors = append(authors, "  Loading...")
+		}
+	} else {
+		visible := max(height-3, 0)
+		var labels []string
+		var counts []int
+		for _, author := range m.stats.Authors[:min(len(m.stats.Authors), visible)] {
+			labels = append(labels, author.Name)
+			counts = append(counts, author.Commits)
+		}
+		authors = append(authors, barRows(labels, counts, leftWidth-4, authorStyle)...)
+		if len(m.stats.Authors) > visible {
+			authors[len(authors)-1] = fmt.Sprintf("...and %d more", len(m.stats.Authors)-visible+1)
+		}
+
+		// Newest week at the bottom, labelled by the date it starts
+		labels, counts = nil, nil
+		now := time.Now()
+		for i, n := range m.stats.Weeks {
+			start := now.AddDate(0, 0, -7*(len(m.stats.Weeks)-i))
+			labels = append(labels, start.Format("Jan 02"))
+			counts = append(counts, n)
+		}
+		activity = append(activity, barRows(labels, counts, rightWidth-4, activityStyle)...)
+	}
+
+	left := panelStyle.Width(leftWidth).BorderForeground(lipgloss.Color("170")).Render(strings.Join(authors, "\n"))
+	right := panelStyle.Width(rightWidth).Render(strings.Join(activity, "\n"))
+	return lipgloss.JoinHorizontal(lipgloss.Top, left, right)
+}
+
+// renderStatsFiles shows the files changed in the most commits lately
+func (m model) renderStatsFiles(width, height int) string {
+	panelStyle := lipgloss.NewStyle().
+		Width(width).
+		Height(height).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("240"))
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("170"))
+
+	content := []string{titleStyle.Render(fmt.Sprintf("Most changed files, last %d weeks", statsWeeks)), ""}
+	if m.stats != nil {
+		if len(m.stats.Files) == 0 {
+			content = append(content, "  No commits in that time")
+		}
+		var labels []string
+		var counts []int
+		for _, file := range m.stats.Files[:min(len(m.stats.Files), max(height-3, 0))] {
+			labels = append(labels, file.Path)
+			counts = append(counts, file.Commits)
+		}
+		content = append(content, barRows(labels, counts, width-4, lipgloss.NewStyle().Foreground(lipgloss.Color("214")))...)
+	}
+	return panelStyle.Render(strings.Join(content, "\n"))
+}
+
+// statsController handles stats mode: commits per author, weekly activity
+// and the most changed files of a repository
+type statsController struct{ baseController }
+
+func (statsController) handleKey(m *model, key string) (tea.Cmd, bool) {
+	switch key {
+	case "%", "esc":
+		m.currentMode = m.statsReturnMode
+		return nil, true
+	case "r":
+		return m.openStats(m.statsRepo), true
+	}
+	return nil, false
+}
+
+func (statsController) handleMsg(m *model, msg tea.Msg) (tea.Cmd, bool) {
+	loaded, ok := msg.(statsLoadedMsg)
+	if !ok {
+		return nil, false
+	}
+	if loaded.repoPath != m.statsRepo {
+		return nil, true
+	}
+	m.statsLoading = false
+	if loaded.err != nil {
+		m.log.add(logError, "collecting statistics failed: %v", loaded.err)
+		return nil, true
+	}
+	m.stats = loaded.stats
+	return nil, true
+}
+
+func (statsController) panels() int { return 1 }
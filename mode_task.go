@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/asbjornb/kvist/workspace"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// maxTaskLines is how much of a task's output is kept; older lines go
+const maxTaskLines = 5000
+
+// taskRun is a project task started from task mode and what it printed
+type taskRun struct {
+	id       int
+	task     workspace.Task
+	repoPath string
+	lines    []string
+	running  bool
+	err      error
+	started  time.Time
+	elapsed  time.Duration
+	cancel   context.CancelFunc
+}
+
+// taskOutputMsg is a line printed by a running task
+type taskOutputMsg struct {
+	run    int
+	line   string
+	events <-chan tea.Msg // where the task's next line or exit comes from
+}
+
+// taskDoneMsg reports that a task exited
+type taskDoneMsg struct {
+	run     int
+	err     error
+	elapsed time.Duration
+}
+
+// waitForTask waits for the next line or the exit of a running task
+func waitForTask(events <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		return <-events
+	}
+}
+
+// openTasks lists the tasks of the repository at repoPath, going back to
+// the current mode when closed
+func (m *model) openTasks(repoPath string) tea.Cmd {
+	if m.currentMode != taskMode {
+		m.tasksReturnMode = m.currentMode
+	}
+	m.currentMode = taskMode
+	m.activePanel = topPanel
+	m.tasksRepo = repoPath
+	m.tasks = workspace.DetectTasks(repoPath)
+	m.selectedTask = min(m.selectedTask, max(len(m.tasks)-1, 0))
+	return nil
+}
+
+// startTask runs task in the repository, stopping any task still running
+func (m *model) startTask(task workspace.Task) tea.Cmd {
+	if m.task != nil && m.task.running {
+		m.task.cancel()
+	}
+	m.taskSeq++
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan tea.Msg, 64)
+	run := &taskRun{
+		id:       m.taskSeq,
+		task:     task,
+		repoPath: m.tasksRepo,
+		running:  true,
+		started:  time.Now(),
+		cancel:   cancel,
+	}
+	m.task = run
+	m.taskFollow = true
+	m.activePanel = bottomPanel
+	m.log.add(logInfo, "running %s in %s", task.Command, filepath.Base(run.repoPath))
+
+	id, dir, env := run.id, run.repoPath, m.commandEnv(run.repoPath)
+	go func() {
+		defer cancel()
+		err := runShellStreaming(ctx, dir, task.Command, env, func(text string) {
+			events <- taskOutputMsg{run: id, line: text, events: events}
+		})
+		if ctx.Err() != nil {
+			err = errors.New("stopped")
+		}
+		events <- taskDoneMsg{run: id, err: err, elapsed: time.Since(run.started)}
+	}()
+	return waitForTask(events)
+}
+
+// renderTasks lists the tasks found in the repository
+func (m model) renderTasks(width, height int) string {
+	borderColor := lipgloss.Color("240")
+	if m.activePanel == topPanel {
+		borderColor = lipgloss.Color("170")
+	}
+	panelStyle := lipgloss.NewStyle().
+		Width(width).
+		Height(height).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(borderColor)
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("170"))
+
+	runnerStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("242"))
+
+	itemStyle := lipgloss.NewStyle().
+		PaddingLeft(1)
+
+	selectedStyle := lipgloss.NewStyle().
+		PaddingLeft(1).
+		Background(lipgloss.Color("238"))
+
+	content := []string{titleStyle.Render("Tasks in " + filepath.Base(m.tasksRepo)), ""}
+	if len(m.tasks) == 0 {
+		content = append(content, "  No Makefile, package.json scripts, justfile or go.mod found")
+		return panelStyle.Render(strings.Join(content, "\n"))
+	}
+
+	visibleItems := height - 3
+	startIdx := 0
+	if m.selectedTask >= visibleItems {
+		startIdx = m.selectedTask - visibleItems + 1
+	}
+	endIdx := min(startIdx+visibleItems, len(m.tasks))
+	for i, task := range m.tasks[startIdx:endIdx] {
+		style := itemStyle
+		if startIdx+i == m.selectedTask {
+			style = selectedStyle
+		}
+		line := fmt.Sprintf("%s %s", runnerStyle.Render(fmt.Sprintf("%-5s", task.Runner)), task.Name)
+		content = append(content, style.Width(width-2).Render(line))
+	}
+	return panelStyle.Render(strings.Join(content, "\n"))
+}
+
+// renderTaskOutput shows what the last task printed, following new output
+// until scrolled up
+func (m model) renderTaskOutput(width, height int) string {
+	borderColor := lipgloss.Color("240")
+	if m.activePanel == bottomPanel {
+		borderColor = lipgloss.Color("170")
+	}
+	panelStyle := lipgloss.NewStyle().
+		Width(width).
+		Height(height).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(borderColor)
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("170"))
+
+	if m.task == nil {
+		return panelStyle.Render(titleStyle.Render("Output") + "\n\n  Enter runs the selected task")
+	}
+
+	status := fmt.Sprintf("running %s", time.Since(m.task.started).Round(time.Second))
+	statusStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+	switch {
+	case m.task.running:
+	case m.task.err != nil:
+		status = fmt.Sprintf("✗ %v after %s", m.task.err, m.task.elapsed.Round(time.Millisecond))
+		statusStyle = statusStyle.Foreground(lipgloss.Color("196"))
+	default:
+		status = fmt.Sprintf("✓ done in %s", m.task.elapsed.Round(time.Millisecond))
+		statusStyle = statusStyle.Foreground(lipgloss.Color("42"))
+	}
+	content := []string{titleStyle.Render("$ "+m.task.task.Command) + "  " + statusStyle.Render(status), ""}
+
+	visible := max(height-3, 1)
+	start := min(m.diffScrollOffset, max(len(m.task.lines)-visible, 0))
+	if m.taskFollow {
+		start = max(len(m.task.lines)-visible, 0)
+	}
+	for _, line := range m.task.lines[start:min(start+visible, len(m.task.lines))] {
+		content = append(content, truncate(line, width-2))
+	}
+	return panelStyle.Render(strings.Join(content, "\n"))
+}
+
+// taskController handles task mode: the project tasks of a repository and
+// the output of the one last run
+type taskController struct{ baseController }
+
+func (taskController) handleKey(m *model, key string) (tea.Cmd, bool) {
+	switch key {
+	case "!", "esc":
+		m.currentMode = m.tasksReturnMode
+		return nil, true
+	case "enter":
+		if m.selectedTask < len(m.tasks) {
+			return m.startTask(m.tasks[m.selectedTask]), true
+		}
+		return nil, true
+	case "R":
+		if m.task != nil {
+			return m.startTask(m.task.task), true
+		}
+		return nil, true
+	case "x":
+		if m.task != nil && m.task.running {
+			m.task.cancel()
+		}
+		return nil, true
+	case "r":
+		return m.openTasks(m.tasksRepo), true
+	}
+	return nil, false
+}
+
+func (taskController) handleMsg(m *model, msg tea.Msg) (tea.Cmd, bool) {
+	switch msg := msg.(type) {
+	case taskOutputMsg:
+		if m.task == nil || msg.run != m.task.id {
+			// A task replaced by a newer one; drain it until it exits
+			return waitForTask(msg.events), true
+		}
+		m.task.lines = append(m.task.lines, msg.line)
+		if over := len(m.task.lines) - maxTaskLines; over > 0 {
+			m.task.lines = m.task.lines[over:]
+		}
+		return waitForTask(msg.events), true
+	case taskDoneMsg:
+		if m.task == nil || msg.run != m.task.id {
+			return nil, true
+		}
+		m.task.running = false
+		m.task.err = msg.err
+		m.task.elapsed = msg.elapsed
+		if msg.err != nil {
+			m.log.add(logError, "%s failed: %v", m.task.task.Command, msg.err)
+		} else {
+			m.log.add(logInfo, "%s finished in %s", m.task.task.Command, msg.elapsed.Round(time.Millisecond))
+		}
+		return nil, true
+	}
+	return nil, false
+}
+
+func (taskController) selection(m *model) (*int, int) {
+	return &m.selectedTask, len(m.tasks)
+}
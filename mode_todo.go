@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asbjornb/kvist/git"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const maxTodoMarkers = 1000
+
+var defaultTodoPatterns = []string{"TODO", "FIXME", "HACK"}
+
+// todoMarker is a TODO-style comment found in the work tree
+type todoMarker struct {
+	git.GrepMatch
+	authored time.Time // zero when the line isn't committed
+}
+
+type todoScanMsg struct {
+	repoPath string
+	markers  []todoMarker
+	err      error
+}
+
+// scanTodos greps the work tree for marker words and dates each hit with blame
+func scanTodos(repoPath string, patterns []string) tea.Cmd {
+	return func() tea.Msg {
+		matches, err := git.GrepMarkers(repoPath, patterns, maxTodoMarkers)
+		if err != nil {
+			return todoScanMsg{repoPath: repoPath, err: err}
+		}
+
+		// One blame per file, matches from git grep are already grouped by file
+		byFile := make(map[string][]int)
+		var files []string
+		for _, match := range matches {
+			if _, ok := byFile[match.Path]; !ok {
+				files = append(files, match.Path)
+			}
+			byFile[match.Path] = append(byFile[match.Path], match.Line)
+		}
+
+		times := make([]map[int]time.Time, len(files))
+		var wg sync.WaitGroup
+		semaphore := make(chan struct{}, 8)
+		for i, file := range files {
+			wg.Add(1)
+			go func(i int, file string) {
+				defer wg.Done()
+				semaphore <- struct{}{}
+				defer func() { <-semaphore }()
+				// Untracked files can't be blamed; they just stay undated
+				times[i], _ = git.BlameTimes(repoPath, file, byFile[file])
+			}(i, file)
+		}
+		wg.Wait()
+
+		fileIdx := make(map[string]int, len(files))
+		for i, file := range files {
+			fileIdx[file] = i
+		}
+		markers := make([]todoMarker, len(matches))
+		for i, match := range matches {
+			markers[i] = todoMarker{GrepMatch: match, authored: times[fileIdx[match.Path]][match.Line]}
+		}
+		return todoScanMsg{repoPath: repoPath, markers: markers}
+	}
+}
+
+// startTodoScan rescans the open repository for TODO markers
+func (m *model) startTodoScan() tea.Cmd {
+	if m.repo == nil {
+		return nil
+	}
+	patterns := defaultTodoPatterns
+	if m.workspaceConfig != nil && len(m.workspaceConfig.TodoPatterns) > 0 {
+		patterns = m.workspaceConfig.TodoPatterns
+	}
+	m.todoScanning = true
+	return scanTodos(m.repo.Path, patterns)
+}
+
+func (m model) renderTodos(width, height int) string {
+	panelStyle := lipgloss.NewStyle().
+		Width(width).
+		Height(height).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color(func() string {
+			if m.activePanel == topPanel {
+				return "170"
+			}
+			return "240"
+		}()))
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("170"))
+
+	fileStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("117")).
+		Bold(true)
+
+	itemStyle := lipgloss.NewStyle().
+		PaddingLeft(3)
+
+	selectedStyle := lipgloss.NewStyle().
+		PaddingLeft(3).
+		Background(lipgloss.Color("238"))
+
+	ageStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("242"))
+
+	title := titleStyle.Render(fmt.Sprintf("TODOs (%d)", len(m.todoMarkers)))
+	content := []string{title, ""}
+
+	if len(m.todoMarkers) == 0 {
+		if m.todoScanning {
+			content = append(content, "  Scanning work tree...")
+		} else {
+			content = append(content, "  No markers found 🎉")
+		}
+		return panelStyle.Render(strings.Join(content, "\n"))
+	}
+
+	// Interleave a header row before each file's markers
+	type row struct {
+		file   string
+		marker int // -1 for file headers
+	}
+	var rows []row
+	selectedRow := 0
+	for i, marker := range m.todoMarkers {
+		if i == 0 || marker.Path != m.todoMarkers[i-1].Path {
+			rows = append(rows, row{file: marker.Path, marker: -1})
+		}
+		if i == m.selectedTodo {
+			selectedRow = len(rows)
+		}
+		rows = append(rows, row{marker: i})
+	}
+
+	// Calculate scroll window to keep selected marker visible
+	visibleItems := height - 3
+	startIdx := 0
+	if selectedRow >= visibleItems {
+		startIdx = selectedRow - visibleItems + 1
+	}
+	endIdx := min(startIdx+visibleItems, len(rows))
+
+	for _, r := range rows[startIdx:endIdx] {
+		if r.marker < 0 {
+			content = append(content, " "+fileStyle.Render(r.file))
+			continue
+		}
+		marker := m.todoMarkers[r.marker]
+		style := itemStyle
+		if m.activePanel == topPanel && r.marker == m.selectedTodo {
+			style = selectedStyle
+		}
+
+		age := "uncommitted"
+		if !marker.authored.IsZero() {
+			age = git.FormatRelativeTime(marker.authored)
+		}
+		prefix := fmt.Sprintf("%4d  ", marker.Line)
+		suffix := "  " + age
+		text := strings.TrimSpace(strings.ReplaceAll(marker.Text, "\t", " "))
+		if maxText := width - 8 - len(prefix) - len(suffix); len(text) > maxText && maxText > 3 {
+			text = text[:maxText-3] + "..."
+		}
+		content = append(content, style.Width(width-2).Render(prefix+text+ageStyle.Render(suffix)))
+	}
+
+	return panelStyle.Render(strings.Join(content, "\n"))
+}
+
+func (m model) renderTodoDetails(width, height int) string {
+	panelStyle := lipgloss.NewStyle().
+		Width(width).
+		Height(height).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("240"))
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("170"))
+
+	labelStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("242"))
+
+	if m.selectedTodo >= len(m.todoMarkers) {
+		return panelStyle.Render(titleStyle.Render("Marker") + "\n\n" + "  No marker selected")
+	}
+
+	marker := m.todoMarkers[m.selectedTodo]
+	authored := "not committed yet"
+	if !marker.authored.IsZero() {
+		authored = fmt.Sprintf("%s (%s)", marker.authored.Format("2006-01-02"), git.FormatRelativeTime(marker.authored))
+	}
+	content := []string{
+		titleStyle.Render(fmt.Sprintf("%s:%d", marker.Path, marker.Line)),
+		"",
+		labelStyle.Render("Written: ") + authored,
+		"",
+		lipgloss.NewStyle().Width(width - 4).Render(strings.TrimSpace(marker.Text)),
+		"",
+		labelStyle.Render("Press enter to open it in your editor"),
+	}
+	return panelStyle.Render(strings.Join(content, "\n"))
+}
+
+// todoController handles todo mode: TODO markers found in the work tree
+type todoController struct{ baseController }
+
+func (todoController) handleKey(m *model, key string) (tea.Cmd, bool) {
+	switch key {
+	case "T", "esc":
+		m.currentMode = filesMode
+		m.activePanel = topPanel
+		return nil, true
+	case "r":
+		return m.startTodoScan(), true
+	case " ", "enter":
+		if m.repo == nil || m.selectedTodo >= len(m.todoMarkers) {
+			return nil, true
+		}
+		// Jump to the marker in the editor
+		marker := m.todoMarkers[m.selectedTodo]
+		cmd := editorCommand(filepath.Join(m.repo.Path, marker.Path), marker.Line)
+		cmd.Dir = m.repo.Path
+		cmd.Env = m.commandEnv(m.repo.Path)
+		return tea.ExecProcess(cmd, func(err error) tea.Msg {
+			return editorFinishedMsg{path: marker.Path, err: err}
+		}), true
+	}
+	return nil, false
+}
+
+func (todoController) handleMsg(m *model, msg tea.Msg) (tea.Cmd, bool) {
+	scan, ok := msg.(todoScanMsg)
+	if !ok {
+		return nil, false
+	}
+	if m.repo == nil || scan.repoPath != m.repo.Path {
+		return nil, true
+	}
+	m.todoScanning = false
+	if scan.err != nil {
+		m.log.add(logError, "TODO scan failed: %v", scan.err)
+		return nil, true
+	}
+	m.todoMarkers = scan.markers
+	m.selectedTodo = min(m.selectedTodo, max(len(m.todoMarkers)-1, 0))
+	if len(m.todoMarkers) >= maxTodoMarkers {
+		m.log.add(logWarn, "TODO scan stopped after %d markers", maxTodoMarkers)
+	}
+	return nil, true
+}
+
+func (todoController) selection(m *model) (*int, int) {
+	return &m.selectedTodo, len(m.todoMarkers)
+}
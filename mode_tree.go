@@ -0,0 +1,356 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/asbjornb/kvist/git"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type treeLoadedMsg struct {
+	rev     string
+	dir     string
+	entries []git.TreeEntry
+	err     error
+}
+
+func loadTree(repoPath, rev, dir string) tea.Cmd {
+	return func() tea.Msg {
+		entries, err := git.ListTree(repoPath, rev, dir)
+		return treeLoadedMsg{rev: rev, dir: dir, entries: entries, err: err}
+	}
+}
+
+type treeFileLoadedMsg struct {
+	rev     string
+	path    string
+	content string
+	err     error
+}
+
+func loadTreeFile(repoPath, rev, path string) tea.Cmd {
+	return func() tea.Msg {
+		content, err := git.GetFileAtRevision(repoPath, rev, path)
+		if err == nil && git.IsBinaryContent(content) {
+			content = fmt.Sprintf("Binary file %s (not shown)", path)
+		}
+		return treeFileLoadedMsg{rev: rev, path: path, content: content, err: err}
+	}
+}
+
+type commitFilesMsg struct {
+	repoPath string
+	rev      string
+	files    []string
+	err      error
+}
+
+func loadCommitFiles(repoPath, rev string) tea.Cmd {
+	return func() tea.Msg {
+		files, err := git.GetCommitFiles(repoPath, rev)
+		return commitFilesMsg{repoPath: repoPath, rev: rev, files: files, err: err}
+	}
+}
+
+// openTree switches to tree mode browsing rev; leaving it returns to returnTo
+func (m *model) openTree(rev string, returnTo viewMode) tea.Cmd {
+	m.currentMode = treeMode
+	m.treeReturnMode = returnTo
+	m.activePanel = topPanel
+	m.treeRev = rev
+	m.treeDir = ""
+	m.treeEntries = nil
+	m.treeFilePath = ""
+	m.treeFileContent = ""
+	m.diffScrollOffset = 0
+	return loadTree(m.repo.Path, rev, "")
+}
+
+// viewFileAt opens path as of rev in the tree viewer, with its directory
+// listed alongside
+func (m *model) viewFileAt(rev, path string, returnTo viewMode) tea.Cmd {
+	dir := filepath.Dir(path)
+	if dir == "." {
+		dir = ""
+	}
+	m.openTree(rev, returnTo)
+	m.activePanel = bottomPanel
+	m.treeDir = dir
+	m.treeFilePath = path
+	return tea.Batch(loadTree(m.repo.Path, rev, dir), loadTreeFile(m.repo.Path, rev, path))
+}
+
+// viewMatch opens the file of a search match as of HEAD in the tree viewer,
+// scrolled to the matching line
+func (m *model) viewMatch(match git.GrepMatch, returnTo viewMode) tea.Cmd {
+	cmd := m.viewFileAt("HEAD", match.Path, returnTo)
+	m.diffScrollOffset = max(match.Line-1, 0)
+	return cmd
+}
+
+// pickFileAt lets the user choose which of the files a commit touched to view
+func (m *model) pickFileAt(msg commitFilesMsg, returnTo viewMode) tea.Cmd {
+	if msg.err != nil {
+		m.log.add(logError, "listing files of %s failed: %v", shortHash(msg.rev), msg.err)
+		return nil
+	}
+	if m.repo == nil || m.repo.Path != msg.repoPath {
+		return nil
+	}
+	switch len(msg.files) {
+	case 0:
+		// Nothing added or modified (e.g. only deletions); browse the whole tree
+		return m.openTree(msg.rev, returnTo)
+	case 1:
+		return m.viewFileAt(msg.rev, msg.files[0], returnTo)
+	}
+	items := make([]menuItem, len(msg.files))
+	for i, file := range msg.files {
+		key := ""
+		if i < 9 {
+			key = fmt.Sprint(i + 1)
+		}
+		items[i] = menuItem{key: key, label: file, action: func(m *model) tea.Cmd {
+			return m.viewFileAt(msg.rev, file, returnTo)
+		}}
+	}
+	m.openMenu("📄 View file at "+shortHash(msg.rev), items)
+	return nil
+}
+
+// previewTreeEntry loads the selected tree entry into the content panel if it is a file
+func (m *model) previewTreeEntry() tea.Cmd {
+	if m.repo == nil || m.selectedTreeEntry >= len(m.treeEntries) {
+		return nil
+	}
+	entry := m.treeEntries[m.selectedTreeEntry]
+	if entry.Type != "blob" || entry.Path == m.treeFilePath {
+		return nil
+	}
+	m.treeFilePath = entry.Path
+	m.treeFileContent = ""
+	m.diffScrollOffset = 0
+	return loadTreeFile(m.repo.Path, m.treeRev, entry.Path)
+}
+
+func (m model) renderTree(width, height int) string {
+	panelStyle := lipgloss.NewStyle().
+		Width(width).
+		Height(height).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color(func() string {
+			if m.activePanel == topPanel {
+				return "170"
+			}
+			return "240"
+		}()))
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("170"))
+
+	itemStyle := lipgloss.NewStyle().
+		PaddingLeft(1)
+
+	selectedStyle := lipgloss.NewStyle().
+		PaddingLeft(1).
+		Background(lipgloss.Color("238"))
+
+	dirStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("117"))
+
+	sizeStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("242"))
+
+	dir := "/" + m.treeDir
+	title := titleStyle.Render(fmt.Sprintf("Tree @ %s: %s", shortHash(m.treeRev), dir))
+	content := []string{title, ""}
+
+	if len(m.treeEntries) == 0 {
+		content = append(content, "  Loading tree...")
+		return panelStyle.Render(strings.Join(content, "\n"))
+	}
+
+	// Calculate scroll window to keep selected entry visible
+	visibleItems := height - 3
+	startIdx := 0
+	if m.selectedTreeEntry >= visibleItems {
+		startIdx = m.selectedTreeEntry - visibleItems + 1
+	}
+	endIdx := min(startIdx+visibleItems, len(m.treeEntries))
+
+	for i := startIdx; i < endIdx; i++ {
+		entry := m.treeEntries[i]
+
+		style := itemStyle
+		if m.activePanel == topPanel && i == m.selectedTreeEntry {
+			style = selectedStyle
+		}
+
+		var line string
+		switch {
+		case entry.IsDir():
+			line = "📁 " + dirStyle.Render(entry.Name+"/")
+		case entry.Type == "commit":
+			line = "📦 " + entry.Name + sizeStyle.Render(" (submodule)")
+		default:
+			line = "📄 " + entry.Name + sizeStyle.Render(fmt.Sprintf(" (%d bytes)", entry.Size))
+		}
+		content = append(content, style.Width(width-2).Render(line))
+	}
+
+	return panelStyle.Render(strings.Join(content, "\n"))
+}
+
+func (m model) renderTreeFile(width, height int) string {
+	panelStyle := lipgloss.NewStyle().
+		Width(width).
+		Height(height).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color(func() string {
+			if m.activePanel == bottomPanel {
+				return "170"
+			}
+			return "240"
+		}()))
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("170"))
+
+	lineNumStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("242"))
+
+	if m.treeFilePath == "" {
+		return panelStyle.Render(titleStyle.Render("File") + "\n\n" + "  No file selected")
+	}
+
+	title := titleStyle.Render("File: " + m.treeFilePath)
+	content := []string{title, ""}
+
+	if m.treeFileContent == "" {
+		content = append(content, lineNumStyle.Render("  Loading file..."))
+		return panelStyle.Render(strings.Join(content, "\n"))
+	}
+
+	lines := strings.Split(strings.TrimSuffix(m.treeFileContent, "\n"), "\n")
+	availableLines := height - 3
+	startLine := min(m.diffScrollOffset, len(lines))
+	endLine := min(startLine+availableLines, len(lines))
+
+	if len(lines) > availableLines {
+		scrollInfo := fmt.Sprintf(" [%d-%d/%d lines]", startLine+1, endLine, len(lines))
+		content[0] = title + lineNumStyle.Render(scrollInfo)
+	}
+
+	numWidth := len(fmt.Sprintf("%d", len(lines)))
+	maxWidth := width - numWidth - 6
+	for i := startLine; i < endLine; i++ {
+		line := strings.ReplaceAll(lines[i], "\t", "    ")
+		if len(line) > maxWidth && maxWidth > 3 {
+			line = line[:maxWidth-3] + "..."
+		}
+		content = append(content, lineNumStyle.Render(fmt.Sprintf("%*d ", numWidth, i+1))+line)
+	}
+
+	return panelStyle.Render(strings.Join(content, "\n"))
+}
+
+// treeController handles tree mode: browsing the files of a commit
+type treeController struct{ baseController }
+
+func (treeController) handleKey(m *model, key string) (tea.Cmd, bool) {
+	switch key {
+	case "t", "esc":
+		m.currentMode = m.treeReturnMode
+		m.activePanel = topPanel
+		m.diffScrollOffset = 0
+		return m.selectionChanged(), true
+	case "H":
+		// Restore the file being viewed from this commit
+		if m.repo != nil && m.treeFilePath != "" {
+			m.confirmRestore(m.treeFilePath, m.treeFilePath, m.treeRev)
+		}
+		return nil, true
+	case "backspace":
+		if m.treeDir == "" || m.repo == nil {
+			return nil, true
+		}
+		parent := filepath.Dir(m.treeDir)
+		if parent == "." {
+			parent = ""
+		}
+		m.treeDir = parent
+		return loadTree(m.repo.Path, m.treeRev, parent), true
+	case " ", "enter":
+		if m.activePanel == topPanel && m.repo != nil && m.selectedTreeEntry < len(m.treeEntries) {
+			entry := m.treeEntries[m.selectedTreeEntry]
+			if entry.IsDir() {
+				m.treeDir = entry.Path
+				return loadTree(m.repo.Path, m.treeRev, entry.Path), true
+			}
+		}
+		return nil, true
+	}
+	return nil, false
+}
+
+func (treeController) handleMsg(m *model, msg tea.Msg) (tea.Cmd, bool) {
+	switch msg := msg.(type) {
+	case treeLoadedMsg:
+		if msg.rev != m.treeRev || msg.dir != m.treeDir {
+			return nil, true // user moved on
+		}
+		if msg.err != nil {
+			m.log.add(logError, "listing %s at %s failed: %v", msg.dir, shortHash(msg.rev), msg.err)
+			return nil, true
+		}
+		m.treeEntries = msg.entries
+		if msg.dir != "" {
+			// Synthetic parent entry for navigating up
+			parent := filepath.Dir(msg.dir)
+			if parent == "." {
+				parent = ""
+			}
+			m.treeEntries = append([]git.TreeEntry{{Name: "..", Path: parent, Type: "tree", Size: -1}}, m.treeEntries...)
+		}
+		m.selectedTreeEntry = 0
+		for i, entry := range m.treeEntries {
+			if entry.Path == m.treeFilePath {
+				// Opened on a file; keep it selected
+				m.selectedTreeEntry = i
+			}
+		}
+		return m.previewTreeEntry(), true
+	case commitFilesMsg:
+		// Every controller sees the message; it only counts while history
+		// still shows the commit it was asked for
+		if m.currentMode != historyMode || m.selectedCommit >= len(m.commits) || m.commits[m.selectedCommit].Hash != msg.rev {
+			return nil, true
+		}
+		return m.pickFileAt(msg, historyMode), true
+	case treeFileLoadedMsg:
+		if msg.rev != m.treeRev || msg.path != m.treeFilePath {
+			return nil, true
+		}
+		if msg.err != nil {
+			m.treeFileContent = fmt.Sprintf("Error loading file: %v", msg.err)
+			m.log.add(logError, "loading %s at %s failed: %v", msg.path, shortHash(msg.rev), msg.err)
+		} else {
+			m.treeFileContent = msg.content
+		}
+		return nil, true
+	}
+	return nil, false
+}
+
+func (treeController) selection(m *model) (*int, int) {
+	return &m.selectedTreeEntry, len(m.treeEntries)
+}
+
+func (treeController) selectionChanged(m *model) tea.Cmd {
+	return m.previewTreeEntry()
+}
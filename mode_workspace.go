@@ -0,0 +1,1681 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/asbjornb/kvist/git"
+	"github.com/asbjornb/kvist/workspace"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type globalSearchMsg struct {
+	pattern string
+	results []workspace.RepoSearchResult
+}
+
+// maxSearchMatchesPerRepo caps how many grep hits are kept for each repository
+const maxSearchMatchesPerRepo = 50
+
+func globalSearch(repos []workspace.RepoInfo, pattern string) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		results := workspace.SearchRepos(ctx, repos, pattern, maxSearchMatchesPerRepo)
+		return globalSearchMsg{pattern: pattern, results: results}
+	}
+}
+
+// openRepo switches to files mode for the given repository in a new tab,
+// or to its tab when it is open already
+func (m *model) openRepo(repo workspace.RepoInfo) tea.Cmd {
+	if i := m.tabIndex(repo.Path); i >= 0 {
+		// Already open in a tab; go back to where it was left
+		delete(m.fetchNotices, repo.Path)
+		return m.switchTab(i)
+	}
+	m.newTab(repo.Path)
+	m.currentMode = filesMode
+	m.selectedFile, m.selectedRow, m.selectedGroup = 0, 0, nil
+	m.selectedCommit = 0
+	m.commitLimit = 0
+	m.filesFilter = filesAll
+	m.diffScrollOffset = 0
+	m.loadingRepo = true
+	m.loadingMetadata = true
+	// Drop the previous repo's lists so selections aren't re-matched against them
+	m.status = nil
+	m.commits = nil
+	m.restoreView(repo.Path)
+
+	delete(m.fetchNotices, repo.Path)
+
+	// Track this as the last accessed repository
+	if scanner := m.scanner; scanner != nil {
+		scanner.UpdateLastRepo(repo.Path)
+		// Save state to disk (best effort, don't block on errors)
+		go func() {
+			_ = scanner.SaveCache()
+		}()
+	}
+
+	return tea.Batch(loadRepositoryIncremental(repo.Path, 0, filesAll), m.runHooks("open", repo.Path))
+}
+
+// smartStartup determines the best startup mode based on cached session state
+func (m *model) smartStartup() tea.Cmd {
+	if m.startPath != "" {
+		// A directory given on the command line beats the session state
+		m.currentMode = filesMode
+		m.loadingRepo = true
+		return openStartRepo(m.scanner, m.workspaceConfig, m.startPath)
+	}
+
+	// Check if we have session state
+	if m.repoCache.LastRepoPath != "" {
+		if repo, exists := m.repoCache.Repos[m.repoCache.LastRepoPath]; exists {
+			switch m.workspaceConfig.Startup {
+			case workspace.StartupResume:
+				return m.resumeRepo(repo)
+			case workspace.StartupPicker:
+			default:
+				if m.showingModal {
+					break // something more pressing is being asked
+				}
+				// Show the picker, with a prompt to resume in front of it
+				cmd := m.startupPicker()
+				m.openMenu("Resume session", []menuItem{
+					{key: "enter", label: "Resume " + repo.Name, action: func(m *model) tea.Cmd { return m.resumeRepo(repo) }},
+					{key: "w", label: "Choose a workspace", action: func(*model) tea.Cmd { return nil }},
+				})
+				return cmd
+			}
+		}
+	}
+	return m.startupPicker()
+}
+
+// resumeRepo opens repo, the repository open when the last session ended,
+// where the user left it
+func (m *model) resumeRepo(repo workspace.RepoInfo) tea.Cmd {
+	// Set the workspace context
+	for _, ws := range m.workspaceConfig.Workspaces {
+		if ws.Name == repo.WorkspaceName {
+			m.currentWorkspace = &ws
+			break
+		}
+	}
+
+	// Load the repository and go to files mode
+	m.currentMode = filesMode
+	m.selectedFile, m.selectedRow, m.selectedGroup = 0, 0, nil
+	m.diffScrollOffset = 0
+	m.loadingRepo = true
+	m.loadingMetadata = true
+	m.openingRepo = repo.Path
+	m.restoreView(repo.Path)
+
+	m.updateFilteredRepos()
+	// Return command to load the last repository
+	return tea.Batch(loadRepositoryIncremental(repo.Path, m.commitLimit, filesAll), m.runHooks("open", repo.Path))
+}
+
+// startupPicker shows the last workspace, or the workspaces to pick from
+func (m *model) startupPicker() tea.Cmd {
+	// Fallback: If we have a last workspace, open that workspace
+	if m.repoCache.LastWorkspace != "" {
+		for _, ws := range m.workspaceConfig.Workspaces {
+			if ws.Name == m.repoCache.LastWorkspace {
+				m.currentWorkspace = &ws
+				m.currentMode = workspaceMode
+				m.updateFilteredRepos()
+				return nil
+			}
+		}
+	}
+
+	// Final fallback: Show workspace selection if we have workspaces
+	if len(m.workspaceConfig.Workspaces) > 0 {
+		m.currentMode = workspaceManageMode
+		m.selectedWorkspace = 0
+		m.editingWorkspace = false
+	} else {
+		// No workspaces configured, stay in mixed mode
+		m.currentMode = workspaceMode
+		m.currentWorkspace = nil
+		m.updateFilteredRepos()
+	}
+
+	return nil
+}
+
+// startRepoMsg carries the repository given on the command line
+type startRepoMsg struct {
+	repo workspace.RepoInfo
+	err  error
+}
+
+// openStartRepo finds the repository containing dir. Repositories in a
+// workspace that the cache doesn't know yet are added to it.
+func openStartRepo(scanner *workspace.Scanner, config *workspace.Config, dir string) tea.Cmd {
+	return func() tea.Msg {
+		repo, err := git.OpenRepository(dir)
+		if err != nil {
+			return startRepoMsg{err: fmt.Errorf("%s: %w", dir, err)}
+		}
+		info := workspace.RepoInfo{Path: repo.Path, Name: repo.Name}
+		if scanner == nil {
+			return startRepoMsg{repo: info}
+		}
+		if cached, ok := scanner.GetRepo(repo.Path); ok {
+			return startRepoMsg{repo: cached}
+		}
+		if config != nil && config.WorkspaceForRepo(repo.Path) != nil {
+			if err := addClonedRepo(scanner, repo.Path); err == nil {
+				info, _ = scanner.GetRepo(repo.Path)
+			}
+		}
+		return startRepoMsg{repo: info}
+	}
+}
+
+// handleStartRepo opens the repository given on the command line, or falls
+// back to the usual startup when there is none at that path
+func (m *model) handleStartRepo(msg startRepoMsg) tea.Cmd {
+	m.startPath = ""
+	m.loadingRepo = false
+	if msg.err != nil {
+		m.log.add(logError, "can't open %v", msg.err)
+		return m.smartStartup()
+	}
+	if m.scanner != nil {
+		m.repos = m.scanner.GetCachedRepos()
+	}
+	m.currentWorkspace = nil
+	for i, ws := range m.workspaceConfig.Workspaces {
+		if ws.Name == msg.repo.WorkspaceName {
+			m.currentWorkspace = &m.workspaceConfig.Workspaces[i]
+			break
+		}
+	}
+	m.updateFilteredRepos()
+	m.selectRepo(msg.repo.Path)
+	return m.openRepo(msg.repo)
+}
+
+func (m *model) startWorkspaceScan() tea.Cmd {
+	if m.scanner == nil {
+		return nil
+	}
+
+	if m.currentWorkspace == nil {
+		if m.workspaceConfig == nil || m.repoCache == nil || len(m.workspaceConfig.Workspaces) == 0 {
+			return nil
+		}
+	}
+
+	if m.incrementalCancel != nil {
+		m.incrementalCancel()
+		m.incrementalCancel = nil
+	}
+	m.incrementalScanCh = nil
+
+	var scanCmd tea.Cmd
+	if m.currentWorkspace != nil {
+		scanCmd = scanSingleWorkspaceIncremental(m.scanner, m.currentWorkspace)
+	} else {
+		scanCmd = scanWorkspaces(m.scanner)
+	}
+	if scanCmd == nil {
+		return nil
+	}
+
+	m.scanning = true
+	return tea.Batch(scanCmd, m.schedule(cursorTimer))
+}
+
+// globalSearchMatchCount returns the total number of matches across all searched repos
+func (m model) globalSearchMatchCount() int {
+	count := 0
+	for _, res := range m.globalSearchResults {
+		count += len(res.Matches)
+	}
+	return count
+}
+
+// selectedGlobalSearchMatch returns the repository and match under the search cursor
+func (m model) selectedGlobalSearchMatch() (workspace.RepoInfo, git.GrepMatch, bool) {
+	idx := m.selectedSearchMatch
+	for _, res := range m.globalSearchResults {
+		if idx < len(res.Matches) {
+			return res.Repo, res.Matches[idx], true
+		}
+		idx -= len(res.Matches)
+	}
+	return workspace.RepoInfo{}, git.GrepMatch{}, false
+}
+
+// renderGlobalSearchResults renders search matches grouped by repository,
+// keeping the selected match within the visible window
+func (m model) renderGlobalSearchResults(width, height int) []string {
+	repoStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("214")).Bold(true)
+	pathStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("117"))
+	dimStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("242"))
+	selectedStyle := lipgloss.NewStyle().Background(lipgloss.Color("238"))
+
+	if m.globalSearching {
+		return []string{dimStyle.Render("  Searching...")}
+	}
+	if len(m.globalSearchResults) == 0 {
+		return []string{dimStyle.Render("  No matches")}
+	}
+
+	var lines []string
+	selectedLine := 0
+	matchIndex := 0
+	for _, res := range m.globalSearchResults {
+		header := "📁 " + res.Repo.Name
+		if res.Error != nil {
+			header += dimStyle.Render(fmt.Sprintf(" (error: %v)", res.Error))
+		} else if len(res.Matches) >= maxSearchMatchesPerRepo {
+			header += dimStyle.Render(fmt.Sprintf(" (first %d matches)", maxSearchMatchesPerRepo))
+		}
+		lines = append(lines, repoStyle.Render(header))
+
+		for _, match := range res.Matches {
+			text := strings.TrimSpace(match.Text)
+			location := fmt.Sprintf("%s:%d", match.Path, match.Line)
+			if maxLen := width - len(location) - 6; maxLen > 3 && len(text) > maxLen {
+				text = text[:maxLen-3] + "..."
+			}
+			line := "  " + pathStyle.Render(location) + " " + text
+			if matchIndex == m.selectedSearchMatch {
+				line = selectedStyle.Width(width).Render(line)
+				selectedLine = len(lines)
+			}
+			lines = append(lines, line)
+			matchIndex++
+		}
+	}
+
+	// Scroll so the selected match stays visible
+	if height > 0 && len(lines) > height {
+		start := 0
+		if selectedLine >= height {
+			start = selectedLine - height + 1
+		}
+		lines = lines[start:min(start+height, len(lines))]
+	}
+	return lines
+}
+
+func (m model) renderWorkspaces(width, height int) string {
+	panelStyle := lipgloss.NewStyle().
+		Width(width).
+		Height(height).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color(func() string {
+			if m.activePanel == topPanel {
+				return "170"
+			}
+			return "240"
+		}()))
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("170"))
+
+	workspaceStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("214")).
+		Bold(true)
+
+	repoNameStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("117"))
+
+	branchStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("84"))
+
+	statusStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("203"))
+
+	itemStyle := lipgloss.NewStyle().
+		PaddingLeft(1)
+
+	selectedStyle := lipgloss.NewStyle().
+		PaddingLeft(1).
+		Background(lipgloss.Color("238"))
+
+	title := titleStyle.Render(func() string {
+		if m.scanning {
+			// Add a simple spinner animation
+			spinners := []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+			spinner := spinners[int(time.Now().UnixMilli()/100)%len(spinners)]
+			if m.currentWorkspace != nil {
+				return fmt.Sprintf("%s Scanning '%s'... (%d found)", spinner, m.currentWorkspace.Name, len(m.repos))
+			}
+			return fmt.Sprintf("%s Scanning Workspaces... (%d found)", spinner, len(m.repos))
+		}
+		lastScan := ""
+		if !m.lastScanTime.IsZero() {
+			lastScan = fmt.Sprintf(" • Last scan: %s ago", formatRelativeTime(m.lastScanTime))
+		}
+
+		// Calculate workspace-specific repo count
+		var workspaceRepos int
+		if m.currentWorkspace != nil {
+			for _, repo := range m.repos {
+				if repo.WorkspaceName == m.currentWorkspace.Name {
+					workspaceRepos++
+				}
+			}
+		} else {
+			workspaceRepos = len(m.repos)
+		}
+
+		displayedRepos := len(m.filteredRepos)
+
+		if m.currentWorkspace != nil {
+			if displayedRepos != workspaceRepos || m.filterText != "" || m.favoritesOnly || m.repoState != anyRepoState {
+				return fmt.Sprintf("📂 %s (%d/%d repos)%s", m.currentWorkspace.Name, displayedRepos, workspaceRepos, lastScan)
+			}
+			return fmt.Sprintf("📂 %s (%d repos)%s", m.currentWorkspace.Name, workspaceRepos, lastScan)
+		}
+		if displayedRepos != workspaceRepos || m.filterText != "" || m.favoritesOnly || m.repoState != anyRepoState {
+			return fmt.Sprintf("📁 All Repositories (%d/%d)%s", displayedRepos, workspaceRepos, lastScan)
+		}
+		return fmt.Sprintf("📁 All Repositories (%d)%s", workspaceRepos, lastScan)
+	}())
+
+	content := []string{title, ""}
+
+	// Initialize filtered repos if needed
+	if len(m.filteredRepos) == 0 && len(m.repos) > 0 {
+		m.updateFilteredRepos()
+	}
+
+	// Show search mode or filter text if active
+	if m.searchMode {
+		searchStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+		cursor := ""
+		if time.Now().UnixMilli()/500%2 == 0 {
+			cursor = "█"
+		} else {
+			cursor = "_"
+		}
+		content = append(content, searchStyle.Render(fmt.Sprintf("Search: %s%s", m.filterText, cursor)))
+		content = append(content, "")
+	} else if m.filterText != "" {
+		filterStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+		content = append(content, filterStyle.Render(fmt.Sprintf("Filter: %s (press / to edit)", m.filterText)))
+		content = append(content, "")
+	}
+	if m.favoritesOnly {
+		favoritesStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("220"))
+		content = append(content, favoritesStyle.Render("★ Favorites only (press F to show all)"), "")
+	}
+	if m.showArchived {
+		archivedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("244"))
+		content = append(content, archivedStyle.Render("🗄 Showing archived repositories (press A to hide them)"), "")
+	}
+	if m.repoState != anyRepoState {
+		stateStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+		content = append(content, stateStyle.Render(fmt.Sprintf("Showing repos %s (press D for the overview, esc for all)", m.repoState)), "")
+	}
+
+	if len(m.filteredRepos) == 0 {
+		if !m.scanning {
+			if len(m.repos) == 0 {
+				if m.workspaceConfig != nil && len(m.workspaceConfig.Workspaces) == 0 {
+					// No workspaces configured - guide user to setup
+					content = append(content, itemStyle.Render("👋 Welcome to kvist!"))
+					content = append(content, itemStyle.Render(""))
+					content = append(content, itemStyle.Render("No workspaces configured yet."))
+					content = append(content, itemStyle.Render(""))
+					content = append(content, itemStyle.Render("🎯 Press 'w' to add your first workspace"))
+					content = append(content, itemStyle.Render(""))
+					content = append(content, itemStyle.Render("A workspace is a directory containing your Git repositories"))
+					content = append(content, itemStyle.Render("(e.g., ~/code, ~/projects, /mnt/c/code)"))
+				} else {
+					// Workspaces configured but no repos found
+					content = append(content, itemStyle.Render("No repositories found"))
+					content = append(content, itemStyle.Render(""))
+					if m.currentWorkspace != nil {
+						content = append(content, itemStyle.Render(fmt.Sprintf("Press 'r' to scan workspace '%s'", m.currentWorkspace.Name)))
+					} else {
+						content = append(content, itemStyle.Render("Press 'w' to select a workspace, then press 'r' to scan"))
+					}
+				}
+			} else {
+				// Filtering resulted in no matches
+				content = append(content, itemStyle.Render("No repositories match your filter"))
+				if m.favoritesOnly {
+					content = append(content, itemStyle.Render("Press * to pin a repository, F to show all"))
+				} else {
+					content = append(content, itemStyle.Render("Press Esc to clear filter"))
+				}
+			}
+		}
+	} else {
+		// Calculate scrolling bounds
+		visibleItems := height - len(content) - 3 // Reserve space for title and margins
+
+		// Calculate scroll window
+		startIdx := 0
+		if m.selectedRepo >= visibleItems {
+			startIdx = m.selectedRepo - visibleItems + 1
+		}
+		endIdx := startIdx + visibleItems
+		if endIdx > len(m.filteredRepos) {
+			endIdx = len(m.filteredRepos)
+		}
+
+		currentWorkspace := ""
+		displayIndex := 0
+
+		for i, repo := range m.filteredRepos[startIdx:endIdx] {
+			actualIndex := startIdx + i
+
+			// Add workspace header if changed (only for multi-workspace view,
+			// and not while filtering, which orders repos by match)
+			if m.currentWorkspace == nil && m.filterText == "" && repo.WorkspaceName != currentWorkspace {
+				currentWorkspace = repo.WorkspaceName
+				if displayIndex > 0 { // Add spacing between workspaces
+					content = append(content, "")
+				}
+				content = append(content, workspaceStyle.Render("📂 "+currentWorkspace))
+				displayIndex++
+			}
+
+			// Format repo line, starring pinned repos
+			marker := "  "
+			if m.scanner != nil && m.scanner.IsPinned(repo.Path) {
+				marker = lipgloss.NewStyle().Foreground(lipgloss.Color("220")).Render("★ ")
+			}
+			repoLine := marker
+			if depth := m.repoNesting[repo.Path]; depth > 0 {
+				repoLine += strings.Repeat("  ", depth-1) + lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Render("└ ")
+			}
+			if m.showArchived && m.scanner != nil && m.scanner.IsArchived(repo.Path) {
+				repoLine += lipgloss.NewStyle().Foreground(lipgloss.Color("244")).Strikethrough(true).Render(repo.Name) + " 🗄"
+			} else {
+				repoLine += highlightMatches(repo.Name, m.repoMatches[repo.Path], repoNameStyle, repoNameStyle.Foreground(lipgloss.Color("214")).Underline(true))
+			}
+
+			// Add branch info or loading indicator
+			if repo.Kind == workspace.ProjectDir {
+				projectStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Italic(true)
+				repoLine += " " + projectStyle.Render("(not a repo)")
+			} else if repo.Branch != "" || repo.Detached {
+				branch := repo.Branch
+				if repo.Detached {
+					branch = "detached"
+				}
+				repoLine += " " + branchStyle.Render("("+branch+")")
+
+				// Add status info
+				var statusParts []string
+				if repo.Ahead > 0 {
+					statusParts = append(statusParts, fmt.Sprintf("↑%d", repo.Ahead))
+				}
+				if repo.Behind > 0 {
+					statusParts = append(statusParts, fmt.Sprintf("↓%d", repo.Behind))
+				}
+				if len(statusParts) > 0 {
+					repoLine += " " + statusStyle.Render(strings.Join(statusParts, " "))
+				}
+				repoLine += renderDirtyMarker(repo)
+				if len(m.repoWarnings(repo)) > 0 {
+					repoLine += " " + lipgloss.NewStyle().Foreground(lipgloss.Color("203")).Bold(true).Render("⚠")
+				}
+				if n := m.fetchNotices[repo.Path]; n > 0 {
+					noticeStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("0")).Background(lipgloss.Color("39"))
+					repoLine += " " + noticeStyle.Render(fmt.Sprintf(" %d new ", n))
+				}
+			} else {
+				// Show loading indicator for repos without metadata yet
+				loadingStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+				repoLine += " " + loadingStyle.Render("⋯")
+			}
+
+			if m.repoNote(repo.Path) != "" {
+				noteStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("180"))
+				repoLine += " " + noteStyle.Render("✎")
+			}
+
+			// Add freshness indicator
+			if !repo.LastScanned.IsZero() {
+				age := time.Since(repo.LastScanned)
+				if age > 10*time.Minute {
+					staleStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+					repoLine += " " + staleStyle.Render("⚠")
+				}
+			}
+
+			// Apply selection style
+			var line string
+			if actualIndex == m.selectedRepo {
+				line = selectedStyle.Render(repoLine)
+			} else {
+				line = itemStyle.Render(repoLine)
+			}
+			content = append(content, line)
+			displayIndex++
+		}
+
+		// Show scroll indicators if needed
+		if startIdx > 0 || endIdx < len(m.filteredRepos) {
+			scrollInfo := fmt.Sprintf("(%d-%d of %d)", startIdx+1, endIdx, len(m.filteredRepos))
+			scrollStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+			content = append(content, scrollStyle.Render(scrollInfo))
+		}
+	}
+
+	return panelStyle.Render(strings.Join(content, "\n"))
+}
+
+func (m model) renderRepoDetails(width, height int) string {
+	panelStyle := lipgloss.NewStyle().
+		Width(width).
+		Height(height).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color(func() string {
+			if m.activePanel == bottomPanel {
+				return "170"
+			}
+			return "240"
+		}()))
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("170"))
+
+	labelStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("244")).
+		Bold(true)
+
+	valueStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("252"))
+
+	pathStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	content := []string{titleStyle.Render("📋 Repository Details"), ""}
+
+	if len(m.filteredRepos) == 0 || m.selectedRepo >= len(m.filteredRepos) {
+		content = append(content, "No repository selected")
+	} else {
+		repo := m.filteredRepos[m.selectedRepo]
+
+		content = append(content,
+			labelStyle.Render("Name: ")+valueStyle.Render(repo.Name),
+			labelStyle.Render("Path: ")+pathStyle.Render(repo.Path),
+			labelStyle.Render("Workspace: ")+valueStyle.Render(repo.WorkspaceName),
+		)
+		if repo.Parent != "" {
+			content = append(content, labelStyle.Render("Nested in: ")+pathStyle.Render(repo.Parent))
+		}
+		if m.scanner != nil && m.scanner.IsArchived(repo.Path) {
+			content = append(content, labelStyle.Render("Archived: ")+valueStyle.Render("hidden from the list, skipped by search and auto-fetch"))
+		}
+
+		if note := m.repoNote(repo.Path); note != "" {
+			noteStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("180")).Width(max(20, width-8))
+			content = append(content, labelStyle.Render("Note: ")+noteStyle.Render(note))
+		}
+
+		if repo.Branch != "" {
+			content = append(content, labelStyle.Render("Branch: ")+valueStyle.Render(repo.Branch))
+		}
+		warningStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("203"))
+		for _, warning := range m.repoWarnings(repo) {
+			content = append(content, warningStyle.Render("⚠ "+warning))
+		}
+
+		if repo.HasUpstream {
+			var statusParts []string
+			if repo.Ahead > 0 {
+				statusParts = append(statusParts, fmt.Sprintf("%d commit(s) ahead", repo.Ahead))
+			}
+			if repo.Behind > 0 {
+				statusParts = append(statusParts, fmt.Sprintf("%d commit(s) behind", repo.Behind))
+			}
+			if len(statusParts) == 0 {
+				statusParts = append(statusParts, "up to date")
+			}
+			content = append(content, labelStyle.Render("Status: ")+valueStyle.Render(strings.Join(statusParts, ", ")))
+		}
+
+		switch repo.Kind {
+		case workspace.BareRepo:
+			content = append(content, labelStyle.Render("Kind: ")+valueStyle.Render("bare repository, no work tree"))
+		case workspace.LinkedWorktree:
+			content = append(content, labelStyle.Render("Kind: ")+valueStyle.Render("linked worktree"))
+		case workspace.Submodule:
+			content = append(content, labelStyle.Render("Kind: ")+valueStyle.Render("submodule"))
+		case workspace.ProjectDir:
+			content = append(content, labelStyle.Render("Kind: ")+valueStyle.Render("project directory, not under git yet; press Enter to initialize it"))
+		}
+
+		if (repo.Branch != "" || repo.Detached) && repo.Kind != workspace.BareRepo {
+			changes := "clean"
+			if repo.Dirty() {
+				changes = fmt.Sprintf("%d changed, %d untracked", repo.Changed, repo.Untracked)
+			}
+			content = append(content, labelStyle.Render("Changes: ")+valueStyle.Render(changes))
+		}
+
+		if repo.Unpushed > 0 {
+			content = append(content, labelStyle.Render("Unpushed: ")+valueStyle.Render(fmt.Sprintf("%d commit(s) on no remote", repo.Unpushed)))
+		}
+		if repo.Stashes > 0 {
+			content = append(content, labelStyle.Render("Stashes: ")+valueStyle.Render(fmt.Sprintf("%d", repo.Stashes)))
+		}
+		if repo.StaleBranches > 0 {
+			content = append(content, labelStyle.Render("Stale branches: ")+valueStyle.Render(fmt.Sprintf("%d merged into %s", repo.StaleBranches, repo.DefaultBranch)))
+		}
+
+		if repo.Kind != workspace.ProjectDir {
+			signing := "off"
+			switch {
+			case repo.SignsCommits && repo.SignsTags:
+				signing = "commits and tags"
+			case repo.SignsCommits:
+				signing = "commits"
+			case repo.SignsTags:
+				signing = "tags"
+			}
+			content = append(content, labelStyle.Render("Signing: ")+valueStyle.Render(signing))
+		}
+
+		if !repo.LastCommitTime.IsZero() {
+			lastCommit := repo.LastCommitTime.Format("2006-01-02 15:04:05")
+			if repo.LastAuthor != "" {
+				lastCommit += " by " + repo.LastAuthor
+			}
+			content = append(content, labelStyle.Render("Last Commit: ")+valueStyle.Render(lastCommit))
+			if repo.LastSubject != "" {
+				subjectStyle := valueStyle.Italic(true).Width(max(20, width-8))
+				content = append(content, subjectStyle.Render("  "+repo.LastSubject))
+			}
+			content = append(content, labelStyle.Render("Last Scanned: ")+valueStyle.Render(repo.LastScanned.Format("2006-01-02 15:04:05")))
+		}
+
+		// Add navigation hint
+		content = append(content, "",
+			pathStyle.Render("Press Enter to open this repository • m for quick actions (s for signing) • n to edit its note • * to pin • F for favorites • a to archive • A to show archived • D for an overview • % for its statistics • Z for its size • c to clone • Ctrl+F to search all repositories"))
+	}
+
+	return panelStyle.Render(strings.Join(content, "\n"))
+}
+
+// renderDirtyMarker shows uncommitted work: an orange dot with the number of
+// changed files, and a grey count of untracked ones
+func renderDirtyMarker(repo workspace.RepoInfo) string {
+	marker := ""
+	if repo.Changed > 0 {
+		marker += " " + lipgloss.NewStyle().Foreground(lipgloss.Color("208")).Render(fmt.Sprintf("●%d", repo.Changed))
+	}
+	if repo.Untracked > 0 {
+		marker += " " + lipgloss.NewStyle().Foreground(lipgloss.Color("244")).Render(fmt.Sprintf("?%d", repo.Untracked))
+	}
+	return marker
+}
+
+// repoNote returns the user's note for the repository at path, if any
+func (m model) repoNote(path string) string {
+	if m.scanner == nil {
+		return ""
+	}
+	return m.scanner.RepoNote(path)
+}
+
+// repoWarnings explains what makes repo risky to leave as it is
+func (m model) repoWarnings(repo workspace.RepoInfo) []string {
+	age := time.Duration(workspace.DefaultUnpushedWarnDays) * 24 * time.Hour
+	if m.workspaceConfig != nil {
+		age = m.workspaceConfig.UnpushedWarnAge()
+	}
+	return repo.Warnings(age)
+}
+
+// selectRepo moves the selection to the repository at path if it is listed
+func (m *model) selectRepo(path string) {
+	for i, repo := range m.filteredRepos {
+		if repo.Path == path {
+			m.selectedRepo = i
+			return
+		}
+	}
+}
+
+// selectedRepoPath is the repository selected in the list in workspace
+// mode, and the open repository otherwise
+func (m model) selectedRepoPath() string {
+	if m.currentMode == workspaceMode {
+		if m.selectedRepo < len(m.filteredRepos) {
+			return m.filteredRepos[m.selectedRepo].Path
+		}
+		return ""
+	}
+	if m.repo != nil {
+		return m.repo.Path
+	}
+	return ""
+}
+
+func (m *model) updateFilteredRepos() {
+	// First, filter by workspace if we're in a specific workspace
+	candidateRepos := m.reposInView()
+
+	// Then keep favorites and repos in the state picked on the dashboard
+	if m.favoritesOnly || m.repoState != anyRepoState {
+		var kept []workspace.RepoInfo
+		for _, repo := range candidateRepos {
+			if m.favoritesOnly && (m.scanner == nil || !m.scanner.IsPinned(repo.Path)) {
+				continue
+			}
+			if m.repoState.matches(repo) {
+				kept = append(kept, repo)
+			}
+		}
+		candidateRepos = kept
+	}
+
+	// Then fuzzy match the filter text on the workspace-filtered results,
+	// best matches first; nesting is only shown for the unfiltered list
+	m.repoMatches = nil
+	if m.filterText == "" {
+		m.filteredRepos, m.repoNesting = workspace.NestChildren(candidateRepos)
+	} else {
+		m.filteredRepos = make([]workspace.RepoInfo, 0)
+		m.repoNesting = nil
+		m.repoMatches = make(map[string][]int)
+		scores := make(map[string]int)
+		filter := strings.ToLower(m.filterText)
+		for _, repo := range candidateRepos {
+			score, positions, ok := fuzzyMatch(m.filterText, repo.Name)
+			if !ok {
+				// Path and note matches rank below any name match
+				if !strings.Contains(strings.ToLower(repo.Path), filter) &&
+					!strings.Contains(strings.ToLower(m.repoNote(repo.Path)), filter) {
+					continue
+				}
+				score = -1
+			}
+			scores[repo.Path] = score
+			m.repoMatches[repo.Path] = positions
+			m.filteredRepos = append(m.filteredRepos, repo)
+		}
+		sort.SliceStable(m.filteredRepos, func(i, j int) bool {
+			return scores[m.filteredRepos[i].Path] > scores[m.filteredRepos[j].Path]
+		})
+	}
+
+	// Adjust selection if it's out of bounds
+	if m.selectedRepo >= len(m.filteredRepos) {
+		m.selectedRepo = len(m.filteredRepos) - 1
+	}
+	if m.selectedRepo < 0 {
+		m.selectedRepo = 0
+	}
+}
+
+// updateDirSuggestions updates directory suggestions based on current path input
+func (m *model) updateDirSuggestions() {
+	m.dirSuggestions = workspace.GetDirectorySuggestions(m.newWorkspacePath)
+	m.selectedSuggestion = 0
+}
+
+func formatRelativeTime(t time.Time) string {
+	duration := time.Since(t)
+	if duration < time.Minute {
+		return fmt.Sprintf("%ds", int(duration.Seconds()))
+	} else if duration < time.Hour {
+		return fmt.Sprintf("%dm", int(duration.Minutes()))
+	} else if duration < 24*time.Hour {
+		return fmt.Sprintf("%dh", int(duration.Hours()))
+	} else {
+		return fmt.Sprintf("%dd", int(duration.Hours()/24))
+	}
+}
+
+func (m model) renderWorkspaceManager(width, height int) string {
+	panelStyle := lipgloss.NewStyle().
+		Width(width).
+		Height(height).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color(func() string {
+			if m.activePanel == topPanel {
+				return "170"
+			}
+			return "240"
+		}()))
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("170"))
+
+	itemStyle := lipgloss.NewStyle().
+		PaddingLeft(1)
+
+	selectedStyle := lipgloss.NewStyle().
+		PaddingLeft(1).
+		Background(lipgloss.Color("238"))
+
+	title := titleStyle.Render("⚙️  Workspace Management")
+	content := []string{title, ""}
+
+	if m.editingWorkspace {
+		nameIndicator := "  "
+		pathIndicator := "  "
+		if m.editingField == 0 {
+			nameIndicator = "▶ "
+		} else {
+			pathIndicator = "▶ "
+		}
+
+		// Add a blinking cursor to show where typing will happen
+		cursor := "_"
+		if time.Now().UnixMilli()/500%2 == 0 {
+			cursor = "█"
+		}
+
+		// Build name field with cursor
+		nameValue := m.newWorkspaceName
+		if m.editingField == 0 {
+			if nameValue == "" {
+				nameValue = cursor
+			} else {
+				nameValue = nameValue + cursor
+			}
+		}
+
+		// Build path field with cursor
+		pathValue := m.newWorkspacePath
+		if m.editingField == 1 {
+			if pathValue == "" {
+				pathValue = cursor
+			} else {
+				pathValue = pathValue + cursor
+			}
+		}
+
+		// Calculate padding for alignment
+		nameFieldLen := len("Name: " + m.newWorkspaceName)
+		pathFieldLen := len("Path: " + m.newWorkspacePath)
+
+		// Ensure minimum spacing before help text
+		minPadding := 30
+		namePadding := minPadding - nameFieldLen
+		if namePadding < 4 {
+			namePadding = 4
+		}
+		pathPadding := minPadding - pathFieldLen
+		if pathPadding < 4 {
+			pathPadding = 4
+		}
+
+		// Help text positioned to the right with proper spacing
+		nameHelp := strings.Repeat(" ", namePadding) + "(e.g., Code, Projects, Work)"
+		pathHelp := strings.Repeat(" ", pathPadding) + "(type to see suggestions)"
+
+		content = append(content,
+			"📝 Add New Workspace:",
+			"",
+			fmt.Sprintf("%sName: %s%s", nameIndicator, nameValue, nameHelp),
+			fmt.Sprintf("%sPath: %s%s", pathIndicator, pathValue, pathHelp),
+		)
+
+		// Show directory suggestions if in path field
+		if m.editingField == 1 && len(m.dirSuggestions) > 0 {
+			content = append(content, "")
+			suggestionStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+			selectedSuggestionStyle := lipgloss.NewStyle().
+				Foreground(lipgloss.Color("214")).
+				Background(lipgloss.Color("238"))
+
+			maxVisible := 5
+			totalSuggestions := len(m.dirSuggestions)
+
+			// Calculate scroll window to keep selected item visible
+			scrollOffset := 0
+			if m.selectedSuggestion >= maxVisible {
+				scrollOffset = m.selectedSuggestion - maxVisible + 1
+			}
+			endIdx := scrollOffset + maxVisible
+			if endIdx > totalSuggestions {
+				endIdx = totalSuggestions
+			}
+
+			// Show range indicator if there are more items
+			if totalSuggestions > maxVisible {
+				rangeText := fmt.Sprintf("  [%d-%d of %d]", scrollOffset+1, endIdx, totalSuggestions)
+				content = append(content, suggestionStyle.Render(rangeText))
+			}
+
+			for i := scrollOffset; i < endIdx; i++ {
+				suggestion := m.dirSuggestions[i]
+				if i == m.selectedSuggestion {
+					content = append(content, selectedSuggestionStyle.Render("  ▶ "+suggestion))
+				} else {
+					content = append(content, suggestionStyle.Render("    "+suggestion))
+				}
+			}
+		}
+
+		content = append(content,
+			"",
+			"Tab: autocomplete/next field • ↑↓: navigate • Enter: Save • Esc: Cancel",
+		)
+	} else {
+		if m.workspaceConfig != nil {
+			for i, ws := range m.workspaceConfig.Workspaces {
+				if len(content) >= height-3 {
+					break
+				}
+
+				// Count repos in this workspace
+				repoCount := 0
+				if m.scanner != nil {
+					allRepos := m.scanner.GetCachedRepos()
+					for _, repo := range allRepos {
+						if repo.WorkspaceName == ws.Name {
+							repoCount++
+						}
+					}
+				}
+
+				line := fmt.Sprintf("  📂 %s (%d repos)", ws.Name, repoCount)
+				line += fmt.Sprintf(" - %s", ws.Path)
+
+				if i == m.selectedWorkspace {
+					content = append(content, selectedStyle.Render(line))
+				} else {
+					content = append(content, itemStyle.Render(line))
+				}
+			}
+
+			// Add "Add New Workspace" option
+			addNewLine := "  ➕ Add New Workspace"
+			if m.selectedWorkspace == len(m.workspaceConfig.Workspaces) {
+				content = append(content, selectedStyle.Render(addNewLine))
+			} else {
+				content = append(content, itemStyle.Render(addNewLine))
+			}
+		}
+	}
+
+	return panelStyle.Render(strings.Join(content, "\n"))
+}
+
+func (m model) renderWorkspaceHelp(width, height int) string {
+	panelStyle := lipgloss.NewStyle().
+		Width(width).
+		Height(height).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color(func() string {
+			if m.activePanel == bottomPanel {
+				return "170"
+			}
+			return "240"
+		}()))
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("170"))
+
+	helpStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("244")).
+		PaddingLeft(1)
+
+	content := []string{titleStyle.Render("🎯 Workspace Commands"), ""}
+
+	if m.editingWorkspace {
+		content = append(content,
+			helpStyle.Render("Tab: Switch between Name and Path fields"),
+			helpStyle.Render("Enter: Save workspace (both fields required)"),
+			helpStyle.Render("Backspace: Delete characters"),
+			helpStyle.Render("Esc: Cancel without saving"),
+		)
+	} else {
+		content = append(content,
+			helpStyle.Render("↑↓/jk: Navigate workspaces"),
+			helpStyle.Render("Space/Enter: Open workspace/Add new"),
+			helpStyle.Render("d: Delete workspace"),
+			helpStyle.Render("w: Back to workspace view"),
+			helpStyle.Render("q: Quit"),
+		)
+	}
+
+	return panelStyle.Render(strings.Join(content, "\n"))
+}
+
+// updateWorkspaceEditor handles keys while adding or editing a workspace
+func (m model) updateWorkspaceEditor(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "esc":
+		m.editingWorkspace = false
+		m.newWorkspaceName = ""
+		m.newWorkspacePath = ""
+		m.editingField = 0
+		m.dirSuggestions = nil
+		m.selectedSuggestion = 0
+	case "tab":
+		// If in path field and have suggestions, autocomplete
+		if m.editingField == 1 && len(m.dirSuggestions) > 0 && m.selectedSuggestion < len(m.dirSuggestions) {
+			m.newWorkspacePath = m.dirSuggestions[m.selectedSuggestion]
+			// Add trailing slash if not present to allow continuing
+			if m.newWorkspacePath[len(m.newWorkspacePath)-1] != '/' {
+				m.newWorkspacePath += "/"
+			}
+			m.updateDirSuggestions()
+		} else {
+			// Switch between name and path fields
+			m.editingField = (m.editingField + 1) % 2
+			if m.editingField == 1 {
+				m.updateDirSuggestions()
+			}
+		}
+	case "up":
+		// Navigate suggestions if in path field
+		if m.editingField == 1 && len(m.dirSuggestions) > 0 {
+			if m.selectedSuggestion > 0 {
+				m.selectedSuggestion--
+			}
+		}
+	case "down":
+		// Navigate suggestions if in path field
+		if m.editingField == 1 && len(m.dirSuggestions) > 0 {
+			if m.selectedSuggestion < len(m.dirSuggestions)-1 {
+				m.selectedSuggestion++
+			}
+		}
+	case "enter":
+		if m.newWorkspaceName != "" && m.newWorkspacePath != "" && m.workspaceConfig != nil {
+			// Add new workspace
+			if err := m.workspaceConfig.AddWorkspace(m.newWorkspaceName, m.newWorkspacePath); err == nil {
+				m.editingWorkspace = false
+				m.newWorkspaceName = ""
+				m.newWorkspacePath = ""
+				m.editingField = 0
+				m.dirSuggestions = nil
+				m.selectedSuggestion = 0
+				// Refresh repos if we have a scanner
+				if m.scanner != nil {
+					m.repos = m.scanner.GetCachedRepos()
+				}
+			} else {
+				m.err = err
+			}
+		}
+	case "backspace":
+		// Remove from the active field
+		if m.editingField == 0 && len(m.newWorkspaceName) > 0 {
+			m.newWorkspaceName = m.newWorkspaceName[:len(m.newWorkspaceName)-1]
+		} else if m.editingField == 1 && len(m.newWorkspacePath) > 0 {
+			m.newWorkspacePath = m.newWorkspacePath[:len(m.newWorkspacePath)-1]
+			m.updateDirSuggestions()
+		}
+	default:
+		// Add printable characters to the active field
+		if len(msg.String()) == 1 && msg.String()[0] >= 32 && msg.String()[0] <= 126 {
+			if m.editingField == 0 {
+				m.newWorkspaceName += msg.String()
+			} else {
+				m.newWorkspacePath += msg.String()
+				m.updateDirSuggestions()
+			}
+		}
+	}
+	return m, nil
+}
+
+// updateRepoFilter handles keys while typing the repository filter. Keys it
+// doesn't use (like navigation) are left to the normal bindings.
+func (m *model) updateRepoFilter(msg tea.KeyMsg) bool {
+	switch msg.String() {
+	case "enter":
+		// Exit search mode
+		m.searchMode = false
+		return true
+	case "backspace":
+		if len(m.filterText) > 0 {
+			m.filterText = m.filterText[:len(m.filterText)-1]
+			m.updateFilteredRepos()
+		}
+		return true
+	case "ctrl+c", "esc":
+		// Exit search mode and clear filter
+		m.searchMode = false
+		m.filterText = ""
+		m.updateFilteredRepos()
+		return true
+	default:
+		// Add printable characters to filter
+		if len(msg.String()) == 1 && msg.String()[0] >= 32 && msg.String()[0] <= 126 {
+			m.filterText += msg.String()
+			m.updateFilteredRepos()
+			return true
+		}
+	}
+	return false
+}
+
+// updateWorkspacePicker handles keys in the workspace picker modal
+func (m model) updateWorkspacePicker(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "esc", "q":
+		// Close modal
+		m.showingModal = false
+		return m, nil
+	case "up", "k":
+		if m.modalMode == workspacePickerModal {
+			if m.editingWorkspace && m.editingField == 1 && len(m.dirSuggestions) > 0 {
+				// Navigate suggestions
+				if m.selectedSuggestion > 0 {
+					m.selectedSuggestion--
+				}
+			} else if !m.editingWorkspace && m.selectedWorkspace > 0 {
+				m.selectedWorkspace--
+			}
+		}
+	case "down", "j":
+		if m.modalMode == workspacePickerModal {
+			if m.editingWorkspace && m.editingField == 1 && len(m.dirSuggestions) > 0 {
+				// Navigate suggestions
+				if m.selectedSuggestion < len(m.dirSuggestions)-1 {
+					m.selectedSuggestion++
+				}
+			} else if !m.editingWorkspace {
+				maxWorkspaces := len(m.workspaceConfig.Workspaces)
+				maxWorkspaces++ // Add 1 for "Add New Workspace" option
+				if m.selectedWorkspace < maxWorkspaces-1 {
+					m.selectedWorkspace++
+				}
+			}
+		}
+	case " ", "enter":
+		if m.modalMode == workspacePickerModal && m.workspaceConfig != nil {
+			if m.editingWorkspace {
+				if m.newWorkspaceName != "" && m.newWorkspacePath != "" {
+					if m.editingWorkspaceIdx >= 0 {
+						// Update existing workspace
+						expandedPath := workspace.ExpandPath(m.newWorkspacePath)
+
+						// Verify path exists and is a directory
+						if stat, err := os.Stat(expandedPath); err != nil {
+							m.err = fmt.Errorf("path does not exist: %w", err)
+						} else if !stat.IsDir() {
+							m.err = fmt.Errorf("path is not a directory: %s", expandedPath)
+						} else {
+							m.workspaceConfig.Workspaces[m.editingWorkspaceIdx].Name = m.newWorkspaceName
+							m.workspaceConfig.Workspaces[m.editingWorkspaceIdx].Path = expandedPath
+							if err := m.workspaceConfig.Save(); err == nil {
+								m.editingWorkspace = false
+								m.editingWorkspaceIdx = -1
+								m.newWorkspaceName = ""
+								m.newWorkspacePath = ""
+								m.dirSuggestions = nil
+								m.selectedSuggestion = 0
+								// Refresh repos if we have a scanner
+								if m.scanner != nil {
+									m.repos = m.scanner.GetCachedRepos()
+									m.updateFilteredRepos()
+								}
+							} else {
+								m.err = err
+							}
+						}
+					} else {
+						// Create new workspace
+						err := m.workspaceConfig.AddWorkspace(m.newWorkspaceName, m.newWorkspacePath)
+						if err != nil {
+							m.err = err
+						} else {
+							// Find the newly added workspace and select it
+							for i, ws := range m.workspaceConfig.Workspaces {
+								if ws.Name == m.newWorkspaceName {
+									m.currentWorkspace = &m.workspaceConfig.Workspaces[i]
+									break
+								}
+							}
+
+							// Track this as the last accessed workspace
+							if m.scanner != nil {
+								m.scanner.UpdateLastWorkspace(m.currentWorkspace.Name)
+								// Save state to disk (best effort, don't block on errors)
+								go func() {
+									if m.scanner != nil {
+										_ = m.scanner.SaveCache()
+									}
+								}()
+							}
+
+							// Close modal and load repos
+							m.showingModal = false
+							m.editingWorkspace = false
+							m.editingWorkspaceIdx = -1
+
+							if m.scanner != nil {
+								m.repos = m.scanner.GetCachedRepos()
+								m.updateFilteredRepos()
+							}
+						}
+					}
+				}
+			} else if m.selectedWorkspace == len(m.workspaceConfig.Workspaces) {
+				// "Add New Workspace" selected
+				m.editingWorkspace = true
+				m.editingWorkspaceIdx = -1
+				m.newWorkspaceName = ""
+				m.newWorkspacePath = "~/"
+				m.editingField = 0 // Start with name field
+				m.updateDirSuggestions()
+				return m, m.schedule(cursorTimer) // Continue ticking for cursor animation
+			} else if m.selectedWorkspace < len(m.workspaceConfig.Workspaces) {
+				// Open workspace - show repos from this workspace only
+				m.currentWorkspace = &m.workspaceConfig.Workspaces[m.selectedWorkspace]
+				m.showingModal = false // Close modal
+
+				// Track this as the last accessed workspace
+				if m.scanner != nil {
+					m.scanner.UpdateLastWorkspace(m.currentWorkspace.Name)
+					// Save state to disk (best effort, don't block on errors)
+					scanner := m.scanner
+					go scanner.SaveCache()
+				}
+
+				// Load all cached repos and let updateFilteredRepos() handle workspace filtering
+				if m.scanner != nil {
+					m.repos = m.scanner.GetCachedRepos()
+					m.updateFilteredRepos()
+				}
+			}
+		}
+	case "tab":
+		// Switch between name and path fields when editing
+		if m.modalMode == workspacePickerModal && m.editingWorkspace {
+			// If in path field and have suggestions, autocomplete
+			if m.editingField == 1 && len(m.dirSuggestions) > 0 && m.selectedSuggestion < len(m.dirSuggestions) {
+				m.newWorkspacePath = m.dirSuggestions[m.selectedSuggestion]
+				// Add trailing slash if not present to allow continuing
+				if m.newWorkspacePath[len(m.newWorkspacePath)-1] != '/' {
+					m.newWorkspacePath += "/"
+				}
+				m.updateDirSuggestions()
+			} else {
+				// Switch between name and path fields
+				m.editingField = (m.editingField + 1) % 2
+				if m.editingField == 1 {
+					m.updateDirSuggestions()
+				}
+			}
+		}
+	case "d":
+		// Delete workspace from modal
+		if m.modalMode == workspacePickerModal && !m.editingWorkspace && m.workspaceConfig != nil {
+			if m.selectedWorkspace < len(m.workspaceConfig.Workspaces) {
+				// Delete the selected workspace
+				workspaces := m.workspaceConfig.Workspaces
+				m.workspaceConfig.Workspaces = append(workspaces[:m.selectedWorkspace], workspaces[m.selectedWorkspace+1:]...)
+				if err := m.workspaceConfig.Save(); err == nil {
+					// Adjust selection if needed
+					if m.selectedWorkspace >= len(m.workspaceConfig.Workspaces) && m.selectedWorkspace > 0 {
+						m.selectedWorkspace--
+					}
+					// Refresh repos if we have a scanner
+					if m.scanner != nil {
+						m.repos = m.scanner.GetCachedRepos()
+						m.updateFilteredRepos()
+					}
+				} else {
+					m.err = err
+				}
+			}
+		}
+	case "e":
+		// Edit workspace from modal
+		if m.modalMode == workspacePickerModal && !m.editingWorkspace && m.workspaceConfig != nil {
+			if m.selectedWorkspace < len(m.workspaceConfig.Workspaces) {
+				// Enter edit mode for selected workspace
+				ws := m.workspaceConfig.Workspaces[m.selectedWorkspace]
+				m.editingWorkspace = true
+				m.editingWorkspaceIdx = m.selectedWorkspace
+				m.newWorkspaceName = ws.Name
+				m.newWorkspacePath = ws.Path
+				m.editingField = 0
+				m.updateDirSuggestions()
+				return m, m.schedule(cursorTimer)
+			}
+		}
+	case "backspace":
+		if m.modalMode == workspacePickerModal && m.editingWorkspace {
+			if m.editingField == 0 && len(m.newWorkspaceName) > 0 {
+				m.newWorkspaceName = m.newWorkspaceName[:len(m.newWorkspaceName)-1]
+			} else if m.editingField == 1 && len(m.newWorkspacePath) > 0 {
+				m.newWorkspacePath = m.newWorkspacePath[:len(m.newWorkspacePath)-1]
+				m.updateDirSuggestions()
+			}
+		}
+	default:
+		// Handle text input for workspace editing
+		if m.modalMode == workspacePickerModal && m.editingWorkspace {
+			if len(msg.String()) == 1 && msg.String()[0] >= 32 && msg.String()[0] <= 126 {
+				if m.editingField == 0 {
+					m.newWorkspaceName += msg.String()
+				} else {
+					m.newWorkspacePath += msg.String()
+					m.updateDirSuggestions()
+				}
+			}
+		}
+	}
+	return m, nil // Modal consumes all input
+}
+
+// workspaceController handles workspace mode: the repositories of the
+// current workspace, repository discovery and cross-repo search
+type workspaceController struct{ baseController }
+
+func (workspaceController) handleKey(m *model, key string) (tea.Cmd, bool) {
+	switch key {
+	case "r":
+		return m.startWorkspaceScan(), true
+	case "w":
+		// Show workspace picker modal
+		m.showingModal = true
+		m.modalMode = workspacePickerModal
+		m.selectedWorkspace = 0
+		m.editingWorkspace = false
+		m.newWorkspaceName = ""
+		m.newWorkspacePath = ""
+		m.editingField = 0
+		return m.schedule(cursorTimer), true // Start ticking for cursor animation
+	case "ctrl+f":
+		if m.scanner == nil {
+			return nil, true
+		}
+		m.openPrompt("Search all repositories (git grep)", m.globalSearchPattern, func(m *model, value string) tea.Cmd {
+			if value == "" {
+				return nil
+			}
+			// Search the repos in view, ignoring the text filter; archived
+			// repos are never searched
+			repos := m.scanner.Unarchived(m.reposInView())
+			m.globalSearchPattern = value
+			m.globalSearchResults = nil
+			m.globalSearching = true
+			m.showingModal = true
+			m.modalMode = globalSearchModal
+			return globalSearch(repos, value)
+		})
+		return nil, true
+	case "/":
+		// Enter search mode
+		m.searchMode = true
+		m.filterText = ""
+		m.updateFilteredRepos()
+		return m.schedule(cursorTimer), true // Start cursor animation
+	case "o":
+		if m.selectedRepo < len(m.filteredRepos) {
+			return m.openRepoExternally(m.filteredRepos[m.selectedRepo].Path), true
+		}
+		return nil, true
+	case "%":
+		if m.selectedRepo < len(m.filteredRepos) {
+			return m.openStats(m.filteredRepos[m.selectedRepo].Path), true
+		}
+		return nil, true
+	case "Z":
+		if m.selectedRepo < len(m.filteredRepos) {
+			return m.openSizes(m.filteredRepos[m.selectedRepo].Path), true
+		}
+		return nil, true
+	case "!":
+		if m.selectedRepo < len(m.filteredRepos) {
+			return m.openTasks(m.filteredRepos[m.selectedRepo].Path), true
+		}
+		return nil, true
+	case "*":
+		if m.scanner == nil || m.selectedRepo >= len(m.filteredRepos) {
+			return nil, true
+		}
+		repo := m.filteredRepos[m.selectedRepo]
+		m.scanner.SetPinned(repo.Path, !m.scanner.IsPinned(repo.Path))
+		if err := m.scanner.SaveCache(); err != nil {
+			m.log.add(logError, "saving pin for %s failed: %v", repo.Name, err)
+		}
+		m.repos = m.scanner.GetCachedRepos()
+		m.updateFilteredRepos()
+		m.selectRepo(repo.Path)
+		return nil, true
+	case "c":
+		m.startClone()
+		return nil, true
+	case "m":
+		m.openRepoActions()
+		return nil, true
+	case "D":
+		m.openDashboard()
+		return nil, true
+	case "esc":
+		if m.repoState == anyRepoState {
+			return nil, false
+		}
+		m.repoState = anyRepoState
+		m.selectedRepo = 0
+		m.updateFilteredRepos()
+		return nil, true
+	case "a":
+		if m.scanner == nil || m.selectedRepo >= len(m.filteredRepos) {
+			return nil, true
+		}
+		repo := m.filteredRepos[m.selectedRepo]
+		archived := !m.scanner.IsArchived(repo.Path)
+		m.scanner.SetArchived(repo.Path, archived)
+		if err := m.scanner.SaveCache(); err != nil {
+			m.log.add(logError, "saving archived state for %s failed: %v", repo.Name, err)
+		}
+		if archived {
+			m.log.add(logInfo, "archived %s; press A to show archived repositories", repo.Name)
+		} else {
+			m.log.add(logInfo, "restored %s", repo.Name)
+		}
+		m.updateFilteredRepos()
+		return nil, true
+	case "A":
+		m.showArchived = !m.showArchived
+		m.updateFilteredRepos()
+		return nil, true
+	case "F":
+		m.favoritesOnly = !m.favoritesOnly
+		m.selectedRepo = 0
+		m.updateFilteredRepos()
+		return nil, true
+	case "n":
+		if m.scanner == nil || m.selectedRepo >= len(m.filteredRepos) {
+			return nil, true
+		}
+		repo := m.filteredRepos[m.selectedRepo]
+		m.openPrompt("📝 Note for "+repo.Name+" (empty removes it)", m.repoNote(repo.Path), func(m *model, value string) tea.Cmd {
+			m.scanner.SetRepoNote(repo.Path, strings.TrimSpace(value))
+			if err := m.scanner.SaveCache(); err != nil {
+				m.log.add(logError, "saving note for %s failed: %v", repo.Name, err)
+			}
+			m.updateFilteredRepos()
+			return nil
+		})
+		return nil, true
+	case " ", "enter":
+		if m.selectedRepo < len(m.filteredRepos) {
+			repo := m.filteredRepos[m.selectedRepo]
+			if repo.Kind == workspace.ProjectDir {
+				m.confirmAdopt(repo)
+				return nil, true
+			}
+			// Switch to selected repository with incremental loading
+			return m.openRepo(repo), true
+		}
+		return nil, true
+	}
+	return nil, false
+}
+
+func (workspaceController) handleMsg(m *model, msg tea.Msg) (tea.Cmd, bool) {
+	switch msg := msg.(type) {
+	case incrementalScanInitMsg:
+		m.incrementalScanCh = msg.channel
+		m.incrementalCancel = msg.cancel
+		if m.scanner != nil && m.incrementalScanCh != nil {
+			return incrementalScanNextCmd(m.scanner, m.incrementalScanCh, m.incrementalCancel), true
+		}
+		return nil, true
+	case repoDiscoveredMsg:
+		if msg.err != nil {
+			m.log.add(logError, "repository discovery failed: %v", msg.err)
+			m.err = msg.err
+			return nil, true
+		}
+		if m.scanner != nil {
+			m.repos = m.scanner.GetCachedRepos()
+			m.updateFilteredRepos()
+		}
+		if m.incrementalScanCh != nil && m.scanner != nil {
+			return incrementalScanNextCmd(m.scanner, m.incrementalScanCh, m.incrementalCancel), true
+		}
+		return nil, true
+	case repoCacheUpdatedMsg:
+		if msg.err != nil {
+			m.log.add(logWarn, "refreshing repository metadata failed: %v", msg.err)
+			if m.err == nil {
+				m.err = msg.err
+			}
+			return nil, true
+		}
+		if m.scanner != nil {
+			m.repos = m.scanner.GetCachedRepos()
+			m.updateFilteredRepos()
+		}
+		return nil, true
+	case workspaceScanMsg:
+		if m.incrementalCancel != nil {
+			m.incrementalCancel = nil
+		}
+		m.incrementalScanCh = nil
+		m.scanning = false
+		m.lastScanTime = time.Now()
+		if msg.err == nil {
+			m.err = nil
+			// Always load the complete cached repo list
+			// Let updateFilteredRepos() handle workspace filtering for display
+			m.repos = m.scanner.GetCachedRepos()
+			m.updateFilteredRepos()
+			m.log.add(logInfo, "workspace scan finished (%d repos)", len(m.repos))
+		} else {
+			m.log.add(logError, "workspace scan failed: %v", msg.err)
+			if m.err == nil {
+				m.err = msg.err
+			}
+		}
+		return m.scheduleAutoScan(), true
+	case globalSearchMsg:
+		if msg.pattern != m.globalSearchPattern {
+			return nil, true // superseded by a newer search
+		}
+		m.globalSearching = false
+		m.globalSearchResults = msg.results
+		m.selectedSearchMatch = 0
+		matches := 0
+		for _, res := range msg.results {
+			if res.Error != nil {
+				m.log.add(logWarn, "search in %s failed: %v", res.Repo.Name, res.Error)
+			}
+			matches += len(res.Matches)
+		}
+		m.log.add(logInfo, "search for %q found %d matches in %d repos", msg.pattern, matches, len(msg.results))
+		return nil, true
+	}
+	return nil, false
+}
+
+func (workspaceController) selection(m *model) (*int, int) {
+	if len(m.filteredRepos) == 0 {
+		m.updateFilteredRepos() // Ensure filtered list is initialized
+	}
+	return &m.selectedRepo, len(m.filteredRepos)
+}
+
+// workspaceManageController handles workspace management mode
+type workspaceManageController struct{ baseController }
+
+func (workspaceManageController) handleKey(m *model, key string) (tea.Cmd, bool) {
+	switch key {
+	case "d":
+		if m.workspaceConfig == nil || m.selectedWorkspace >= len(m.workspaceConfig.Workspaces) {
+			return nil, true
+		}
+		// Delete selected workspace
+		workspaces := m.workspaceConfig.Workspaces
+		m.workspaceConfig.Workspaces = append(workspaces[:m.selectedWorkspace], workspaces[m.selectedWorkspace+1:]...)
+		if err := m.workspaceConfig.Save(); err == nil {
+			// Adjust selection if needed
+			if m.selectedWorkspace >= len(m.workspaceConfig.Workspaces) && m.selectedWorkspace > 0 {
+				m.selectedWorkspace--
+			}
+			// Refresh repos if we have a scanner
+			if m.scanner != nil {
+				m.repos = m.scanner.GetCachedRepos()
+			}
+		}
+		return nil, true
+	case " ", "enter":
+		if m.workspaceConfig == nil {
+			return nil, true
+		}
+		if m.selectedWorkspace == len(m.workspaceConfig.Workspaces) {
+			// "Add New Workspace" selected
+			m.editingWorkspace = true
+			m.newWorkspaceName = ""
+			m.newWorkspacePath = "~/"
+			m.editingField = 0 // Start with name field
+			m.updateDirSuggestions()
+			return m.schedule(cursorTimer), true // Start tick for cursor animation
+		}
+		if m.selectedWorkspace > len(m.workspaceConfig.Workspaces) {
+			return nil, true
+		}
+		// Open workspace - show repos from this workspace only
+		m.currentWorkspace = &m.workspaceConfig.Workspaces[m.selectedWorkspace]
+		m.currentMode = workspaceMode
+
+		// Track this as the last accessed workspace
+		if scanner := m.scanner; scanner != nil {
+			scanner.UpdateLastWorkspace(m.currentWorkspace.Name)
+			// Save state to disk (best effort, don't block on errors)
+			go func() {
+				_ = scanner.SaveCache()
+			}()
+		}
+
+		// Load all cached repos and let updateFilteredRepos() handle workspace filtering
+		if m.scanner != nil {
+			m.repos = m.scanner.GetCachedRepos()
+			m.updateFilteredRepos()
+		}
+		return m.startWorkspaceScan(), true
+	}
+	return nil, false
+}
+
+func (workspaceManageController) selection(m *model) (*int, int) {
+	if m.workspaceConfig == nil {
+		return nil, 0
+	}
+	return &m.selectedWorkspace, len(m.workspaceConfig.Workspaces) + 1 // +1 for "Add New Workspace"
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// networkAllowed reports whether what, e.g. "push", may use the network,
+// noting in the log when offline mode skips it
+func (m *model) networkAllowed(what string) bool {
+	if m.offline {
+		m.log.add(logInfo, "offline: %s skipped (N goes back online)", what)
+	}
+	return !m.offline
+}
+
+// toggleOffline switches offline mode, in which nothing touches the
+// network: no fetch, pull, push or clone, no auto-fetch and no forge or
+// update check calls. Going back online resumes the background work.
+func (m *model) toggleOffline() tea.Cmd {
+	m.offline = !m.offline
+	if m.offline {
+		m.log.add(logInfo, "offline mode: network operations are off and ahead/behind counts may be stale")
+		return nil
+	}
+	m.log.add(logInfo, "back online")
+	return tea.Batch(m.scheduleAutoFetch(), m.requestVisibleChecks())
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/asbjornb/kvist/git"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	// hudRunningRuns is how many git commands in flight the HUD lists, the
+	// longest running ones; a scan can start far more than fit
+	hudRunningRuns = 6
+	// hudRecentRuns is how many finished git commands the HUD lists
+	hudRecentRuns = 6
+)
+
+// slowGitCommand is how long a git command may take before the HUD
+// highlights it
+const slowGitCommand = 500 * time.Millisecond
+
+// timerNames label the timers in the HUD
+var timerNames = [timerKinds]string{
+	cursorTimer:      "cursor",
+	autoRefreshTimer: "refresh",
+	autoScanTimer:    "scan",
+	ciTimer:          "ci",
+	autoFetchTimer:   "fetch",
+	hudTimer:         "hud",
+}
+
+// toggleHUD shows or hides the performance HUD, a hidden debugging aid
+func (m *model) toggleHUD() tea.Cmd {
+	m.showHUD = !m.showHUD
+	if !m.showHUD {
+		return nil
+	}
+	return m.schedule(hudTimer)
+}
+
+// renderHUD shows the git commands in flight, the last ones to finish,
+// the timers and the goroutine count
+func (m model) renderHUD(width int) string {
+	boxStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("240")).
+		Width(width-2).
+		Padding(0, 1)
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("170"))
+	dimStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("242"))
+	slowStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+	runningStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+
+	running := git.RunningCommands()
+	lines := []string{titleStyle.Render("⏱ Performance") + dimStyle.Render(fmt.Sprintf(
+		"  goroutines %d • git in flight %d • F12 hides", runtime.NumGoroutine(), len(running)))}
+
+	describe := func(run git.CommandRun) string {
+		line := fmt.Sprintf("%7s  %-16s git %s", formatElapsed(run.Elapsed), filepath.Base(run.Dir), strings.Join(run.Args, " "))
+		return truncate(line, width-6)
+	}
+	for _, run := range running[:min(len(running), hudRunningRuns)] {
+		lines = append(lines, runningStyle.Render("▶ "+describe(run)))
+	}
+	if len(running) > hudRunningRuns {
+		lines = append(lines, runningStyle.Render(fmt.Sprintf("▶ and %d more", len(running)-hudRunningRuns)))
+	}
+	recent := git.RecentRuns()
+	if len(recent) > hudRecentRuns {
+		recent = recent[:hudRecentRuns]
+	}
+	for _, run := range recent {
+		line := "  " + describe(run)
+		if run.ExitCode != 0 {
+			line += dimStyle.Render(fmt.Sprintf(" (exit %d)", run.ExitCode))
+		}
+		if run.Elapsed >= slowGitCommand {
+			line = slowStyle.Render(line)
+		}
+		lines = append(lines, line)
+	}
+
+	var timers []string
+	for kind := timerKind(0); kind < timerKinds; kind++ {
+		interval := timerIntervals[kind]
+		if kind == autoFetchTimer {
+			interval = 0
+			if m.workspaceConfig != nil {
+				interval = time.Duration(m.workspaceConfig.AutoFetchMinutes) * time.Minute
+			}
+		}
+		if interval == 0 {
+			timers = append(timers, timerNames[kind]+" off")
+			continue
+		}
+		entry := timerNames[kind] + " " + interval.String()
+		if fired := m.timerFired[kind]; !fired.IsZero() {
+			entry += fmt.Sprintf(" (%s ago)", formatElapsed(time.Since(fired)))
+		}
+		timers = append(timers, entry)
+	}
+	lines = append(lines, dimStyle.Render("timers: "+strings.Join(timers, " • ")))
+	return boxStyle.Render(strings.Join(lines, "\n"))
+}
+
+// formatElapsed rounds a duration for display
+func formatElapsed(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return d.Round(time.Millisecond).String()
+	case d < time.Minute:
+		return d.Round(100 * time.Millisecond).String()
+	}
+	return d.Round(time.Second).String()
+}
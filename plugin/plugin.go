@@ -0,0 +1,172 @@
+package plugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Timeout is how long a plugin may take to answer a request
+var Timeout = 10 * time.Second
+
+// ErrTimeout is returned when a plugin doesn't answer in time; the plugin
+// is stopped and started again on the next request
+var ErrTimeout = errors.New("plugin did not answer in time")
+
+// Selection is what is selected in kvist when a plugin is asked for its
+// panel or to run an action. Fields are empty when nothing of the kind is
+// selected.
+type Selection struct {
+	Repo   string `json:"repo"`
+	Branch string `json:"branch,omitempty"`
+	Commit string `json:"commit,omitempty"`
+	File   string `json:"file,omitempty"`
+}
+
+// Request is a line of JSON kvist writes to the standard input of a plugin.
+// Type is "render" to ask for the panel, or "action" to run the action
+// with the id in Action. The plugin answers each request with a Response
+// as a line of JSON on its standard output, and keeps running for the
+// next one.
+type Request struct {
+	Type      string    `json:"type"`
+	Action    string    `json:"action,omitempty"`
+	Selection Selection `json:"selection"`
+	Width     int       `json:"width"`
+	Height    int       `json:"height"`
+}
+
+// Action is something the user can have a plugin do from its panel
+type Action struct {
+	ID    string `json:"id"`
+	Key   string `json:"key"`
+	Label string `json:"label"`
+}
+
+// Response is a plugin's answer: the panel to show, the actions it offers
+// and optionally a message for the log. A response with Error set reports
+// a failure.
+type Response struct {
+	Title   string   `json:"title,omitempty"`
+	Lines   []string `json:"lines"`
+	Actions []Action `json:"actions,omitempty"`
+	Message string   `json:"message,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// Client talks to a plugin process, starting it on the first request and
+// again after it exits. Requests are sent one at a time.
+type Client struct {
+	start func() *exec.Cmd
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+}
+
+// New returns a client for the plugin that start makes the command of
+func New(start func() *exec.Cmd) *Client {
+	return &Client{start: start}
+}
+
+// Render asks the plugin for its panel for sel, sized width by height
+func (c *Client) Render(sel Selection, width, height int) (*Response, error) {
+	return c.send(Request{Type: "render", Selection: sel, Width: width, Height: height})
+}
+
+// Run asks the plugin to run the action with id for sel. The response is
+// the panel after the action.
+func (c *Client) Run(id string, sel Selection, width, height int) (*Response, error) {
+	return c.send(Request{Type: "action", Action: id, Selection: sel, Width: width, Height: height})
+}
+
+// Close stops the plugin process
+func (c *Client) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stop()
+}
+
+func (c *Client) send(req Request) (*Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cmd == nil {
+		if err := c.launch(); err != nil {
+			return nil, err
+		}
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.stdin.Write(append(data, '\n')); err != nil {
+		c.stop()
+		return nil, fmt.Errorf("plugin exited: %w", err)
+	}
+
+	type answer struct {
+		line []byte
+		err  error
+	}
+	answered := make(chan answer, 1)
+	stdout := c.stdout
+	go func() {
+		line, err := stdout.ReadBytes('\n')
+		answered <- answer{line, err}
+	}()
+	var got answer
+	select {
+	case got = <-answered:
+	case <-time.After(Timeout):
+		c.stop()
+		return nil, ErrTimeout
+	}
+	if got.err != nil {
+		c.stop()
+		return nil, fmt.Errorf("plugin exited: %w", got.err)
+	}
+
+	var resp Response
+	if err := json.Unmarshal(got.line, &resp); err != nil {
+		return nil, fmt.Errorf("plugin answered with invalid JSON: %w", err)
+	}
+	if resp.Error != "" {
+		return &resp, errors.New(resp.Error)
+	}
+	return &resp, nil
+}
+
+func (c *Client) launch() error {
+	cmd := c.start()
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	c.cmd, c.stdin, c.stdout = cmd, stdin, bufio.NewReader(stdout)
+	return nil
+}
+
+// stop ends the plugin process; the next request starts it again
+func (c *Client) stop() {
+	if c.cmd == nil {
+		return
+	}
+	c.stdin.Close()
+	c.cmd.Process.Kill()
+	c.cmd.Wait()
+	c.cmd, c.stdin, c.stdout = nil, nil, nil
+}
@@ -0,0 +1,59 @@
+package plugin
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+const testPlugin = `while read -r line; do
+  case "$line" in
+    *'"type":"action"'*) echo '{"lines":["done"],"message":"ran"}' ;;
+    *'"repo":"/r"'*) echo '{"title":"T","lines":["a","b"],"actions":[{"id":"x","key":"o","label":"Open"}]}' ;;
+    *'"repo":"/exit"'*) exit 0 ;;
+    *'"repo":"/slow"'*) sleep 5 ;;
+    *) echo '{"error":"no repo"}' ;;
+  esac
+done`
+
+func TestClient(t *testing.T) {
+	starts := 0
+	client := New(func() *exec.Cmd {
+		starts++
+		return exec.Command("sh", "-c", testPlugin)
+	})
+	defer client.Close()
+
+	resp, err := client.Render(Selection{Repo: "/r", Commit: "abc"}, 80, 20)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if resp.Title != "T" || len(resp.Lines) != 2 || len(resp.Actions) != 1 || resp.Actions[0].Key != "o" {
+		t.Errorf("Unexpected panel %+v", resp)
+	}
+	resp, err = client.Run("x", Selection{Repo: "/r"}, 80, 20)
+	if err != nil || resp.Message != "ran" || resp.Lines[0] != "done" {
+		t.Errorf("Unexpected action result %+v, %v", resp, err)
+	}
+	if _, err := client.Render(Selection{Repo: "/other"}, 80, 20); err == nil || err.Error() != "no repo" {
+		t.Errorf("Expected the plugin's error, got %v", err)
+	}
+	if starts != 1 {
+		t.Errorf("Expected one plugin process for all requests, started %d", starts)
+	}
+
+	// A plugin that exits is started again on the next request
+	if _, err := client.Render(Selection{Repo: "/exit"}, 80, 20); err == nil {
+		t.Error("Expected an error when the plugin exits")
+	}
+	if _, err := client.Render(Selection{Repo: "/r"}, 80, 20); err != nil || starts != 2 {
+		t.Errorf("Expected the plugin restarted, got %v after %d starts", err, starts)
+	}
+
+	Timeout = 100 * time.Millisecond
+	defer func() { Timeout = 10 * time.Second }()
+	if _, err := client.Render(Selection{Repo: "/slow"}, 80, 20); !errors.Is(err, ErrTimeout) {
+		t.Errorf("Expected ErrTimeout, got %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/asbjornb/kvist/forge"
+	"github.com/asbjornb/kvist/git"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type pullRequestsLoadedMsg struct {
+	repoPath string
+	prs      []forge.PullRequest
+	err      error
+}
+
+type pullRequestCreatedMsg struct {
+	pr  *forge.PullRequest
+	err error
+}
+
+func loadPullRequests(repoPath string, kinds map[string]string) tea.Cmd {
+	return func() tea.Msg {
+		provider, err := forge.ForRepo(repoPath, kinds)
+		if err != nil {
+			return pullRequestsLoadedMsg{repoPath: repoPath, err: err}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		prs, err := provider.ListPRs(ctx)
+		return pullRequestsLoadedMsg{repoPath: repoPath, prs: prs, err: err}
+	}
+}
+
+// createPullRequest opens a pull request from branch into the repository's
+// default branch
+func createPullRequest(repoPath string, kinds map[string]string, title, branch string) tea.Cmd {
+	return func() tea.Msg {
+		provider, err := forge.ForRepo(repoPath, kinds)
+		if err != nil {
+			return pullRequestCreatedMsg{err: err}
+		}
+		base, err := git.DefaultBranch(repoPath)
+		if err != nil {
+			return pullRequestCreatedMsg{err: err}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		pr, err := provider.CreatePR(ctx, forge.NewPullRequest{Title: title, Branch: branch, Base: base})
+		return pullRequestCreatedMsg{pr: pr, err: err}
+	}
+}
+
+// requestPullRequests starts loading the open pull requests of the current
+// repository; the menu opens once they arrive
+func (m *model) requestPullRequests() tea.Cmd {
+	if m.repo == nil || m.safeMode || !m.networkAllowed("loading pull requests") {
+		return nil
+	}
+	m.log.add(logInfo, "loading pull requests")
+	return loadPullRequests(m.repo.Path, m.forgeKinds())
+}
+
+func (m *model) handlePullRequests(msg pullRequestsLoadedMsg) {
+	if msg.err != nil {
+		m.log.add(logError, "loading pull requests failed: %v", msg.err)
+		return
+	}
+	if m.repo == nil || m.repo.Path != msg.repoPath {
+		return
+	}
+
+	var items []menuItem
+	if branch := m.repo.CurrentBranch; branch != "" {
+		repoPath, kinds := m.repo.Path, m.forgeKinds()
+		title := branch
+		if len(m.commits) > 0 {
+			title = m.commits[0].Subject
+		}
+		items = append(items, menuItem{key: "n", label: "New pull request from " + branch, action: func(m *model) tea.Cmd {
+			m.openPrompt("New pull request title", title, func(m *model, value string) tea.Cmd {
+				if value == "" {
+					return nil
+				}
+				return createPullRequest(repoPath, kinds, value, branch)
+			})
+			return nil
+		}})
+	}
+	for i, pr := range msg.prs {
+		if i == 9 {
+			break
+		}
+		url := pr.URL
+		label := fmt.Sprintf("#%d %s (%s → %s, %s)", pr.Number, pr.Title, pr.Branch, pr.Base, pr.Author)
+		if pr.Draft {
+			label += " [draft]"
+		}
+		items = append(items, menuItem{key: strconv.Itoa(i + 1), label: label, action: func(*model) tea.Cmd {
+			return func() tea.Msg {
+				return browserOpenedMsg{url: url, err: openExternal(url)}
+			}
+		}})
+	}
+	if len(msg.prs) == 0 {
+		m.log.add(logInfo, "no open pull requests")
+	}
+	m.openMenu("🔀 Pull requests", items)
+}
+
+func (m *model) handlePullRequestCreated(msg pullRequestCreatedMsg) tea.Cmd {
+	if msg.err != nil {
+		m.log.add(logError, "creating pull request failed: %v", msg.err)
+		return nil
+	}
+	m.log.add(logInfo, "created pull request #%d", msg.pr.Number)
+	url := msg.pr.URL
+	return func() tea.Msg {
+		return browserOpenedMsg{url: url, err: openExternal(url)}
+	}
+}
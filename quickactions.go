@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/asbjornb/kvist/forge"
+	"github.com/asbjornb/kvist/git"
+	"github.com/asbjornb/kvist/workspace"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// openRepoActions shows the actions that work on the selected repository
+// without opening it
+func (m *model) openRepoActions() {
+	if m.selectedRepo >= len(m.filteredRepos) {
+		return
+	}
+	repo := m.filteredRepos[m.selectedRepo]
+	m.openMenu("⚡ "+repo.Name, m.repoActionItems(repo))
+}
+
+// repoActionItems lists the quick actions for repo
+func (m model) repoActionItems(repo workspace.RepoInfo) []menuItem {
+	// Operations on a repository that isn't open refresh its list entry
+	// instead of the open repository
+	var run func(op git.GitOp) func(*model) tea.Cmd
+	run = func(op git.GitOp) func(*model) tea.Cmd {
+		return func(m *model) tea.Cmd {
+			if !m.networkAllowed(op.String()) {
+				return nil
+			}
+			return requestOperation(operation{
+				desc:     op.String() + " " + repo.Name,
+				repoPath: repo.Path,
+				snapshot: op == git.OpPull,
+				refresh:  refreshRepo,
+				run:      func() error { return git.ExecuteGitOp(repo.Path, op) },
+				finish: func(m *model) tea.Cmd {
+					var hooks tea.Cmd
+					if op == git.OpPull {
+						hooks = m.runHooks("pull", repo.Path)
+					}
+					if m.scanner == nil {
+						return hooks
+					}
+					return tea.Batch(refreshRepoMetadata(m.scanner, repo.Path), hooks)
+				},
+				failed: offerRetry(op.String()+" "+repo.Name, run(op)),
+			})
+		}
+	}
+	kinds := m.forgeKinds()
+
+	if repo.Kind == workspace.ProjectDir {
+		return []menuItem{
+			{key: "i", label: "Initialize a git repository", action: func(m *model) tea.Cmd {
+				m.confirmAdopt(repo)
+				return nil
+			}},
+			{key: "e", label: "Open in editor", action: func(*model) tea.Cmd {
+				return tea.ExecProcess(editorCommand(repo.Path, 0), func(err error) tea.Msg {
+					return editorFinishedMsg{path: repo.Path, err: err}
+				})
+			}},
+			{key: "o", label: "Open with the configured command", action: func(m *model) tea.Cmd { return m.openRepoExternally(repo.Path) }},
+			{key: "y", label: "Copy path", action: func(*model) tea.Cmd { return copyToClipboard("project path", repo.Path) }},
+		}
+	}
+
+	items := []menuItem{
+		{key: "i", label: "Open in kvist", action: func(m *model) tea.Cmd { return m.openRepo(repo) }},
+		{key: "f", label: "Fetch", action: run(git.OpFetch)},
+	}
+	if repo.Kind != workspace.BareRepo {
+		items = append(items,
+			menuItem{key: "p", label: "Pull", action: run(git.OpPull)},
+			menuItem{key: "e", label: "Open in editor", action: func(*model) tea.Cmd {
+				return tea.ExecProcess(editorCommand(repo.Path, 0), func(err error) tea.Msg {
+					return editorFinishedMsg{path: repo.Path, err: err}
+				})
+			}},
+		)
+	}
+	items = append(items,
+		menuItem{key: "o", label: "Open with the configured command", action: func(m *model) tea.Cmd { return m.openRepoExternally(repo.Path) }},
+		menuItem{key: "r", label: "Open remote in browser", action: func(*model) tea.Cmd {
+			return openInBrowser(repo.Path, kinds, func(p forge.Provider) (string, error) {
+				return p.BrowseURL(forge.Page{}), nil
+			})
+		}},
+		menuItem{key: "y", label: "Copy path", action: func(*model) tea.Cmd { return copyToClipboard("repository path", repo.Path) }},
+		menuItem{key: "w", label: "Assign to another workspace", action: func(m *model) tea.Cmd {
+			m.pickAssignWorkspace(repo)
+			return nil
+		}},
+		menuItem{key: "M", label: "Move on disk to another workspace", action: func(m *model) tea.Cmd {
+			m.pickWorkspace("🚚 Move "+repo.Name+" into", repo.WorkspaceName, func(m *model, ws workspace.Workspace) tea.Cmd {
+				m.promptMoveRepo(repo, ws)
+				return nil
+			})
+			return nil
+		}},
+		menuItem{key: "s", label: "Signing of commits and tags", action: func(*model) tea.Cmd {
+			return loadSigning(repo.Path, repo.Name)
+		}},
+		menuItem{key: "k", label: "Maintenance (gc, prune, repack, fsck)", action: func(*model) tea.Cmd {
+			return loadMaintenanceState(repo.Path, repo.Name)
+		}},
+		menuItem{key: "a", label: "Export as zip, tar.gz or bundle", action: func(m *model) tea.Cmd {
+			label := repo.Branch
+			if label == "" {
+				label = "HEAD"
+			}
+			m.openMenu("📦 Export "+repo.Name, archiveItems(repo.Path, "HEAD", strings.ReplaceAll(label, "/", "-")))
+			return nil
+		}},
+		menuItem{key: "X", label: "Delete from disk", action: func(m *model) tea.Cmd {
+			m.promptDeleteRepo(repo)
+			return nil
+		}},
+	)
+	if repo.Kind != workspace.BareRepo {
+		large := git.IsLargeRepo(repo.Path)
+		label := "Large repo mode: hide untracked files, refresh less often"
+		if large {
+			label = "Leave large repo mode"
+		}
+		items = append(items, menuItem{key: "L", label: label, action: func(m *model) tea.Cmd {
+			m.setLargeRepo(repo.Path, !large)
+			return nil
+		}})
+		if large {
+			items = append(items, menuItem{key: "G", label: "Write the commit-graph so history loads faster", action: func(*model) tea.Cmd {
+				return writeCommitGraph(repo.Path)
+			}})
+		}
+	}
+	return items
+}
+
+// promptDeleteRepo deletes repo from disk once its name is typed back.
+// Repositories with uncommitted changes, stashes or unpushed commits are
+// refused unless the name is followed by !.
+func (m *model) promptDeleteRepo(repo workspace.RepoInfo) {
+	title := fmt.Sprintf("🗑 Delete %s from disk? Type %s to confirm (%s! to delete even with unsaved work)", repo.Path, repo.Name, repo.Name)
+	m.openPrompt(title, "", func(m *model, value string) tea.Cmd {
+		value = strings.TrimSpace(value)
+		force := strings.HasSuffix(value, "!")
+		if strings.TrimSuffix(value, "!") != repo.Name {
+			m.log.add(logWarn, "delete cancelled: the name didn't match %s", repo.Name)
+			return nil
+		}
+		return deleteRepoOperation(m.scanner, repo.Path, force)
+	})
+}
+
+// confirmAdopt offers to put a project directory under git
+func (m *model) confirmAdopt(project workspace.RepoInfo) {
+	m.openConfirmation("🌱 Initialize "+project.Name+"?",
+		fmt.Sprintf("Run git init in %s and commit everything that isn't ignored as the first commit?", project.Path),
+		func(m *model) tea.Cmd { return adoptOperation(m.scanner, project.Path) })
+}
+
+// pickAssignWorkspace lists repo under a workspace of the user's choice
+// without moving it; an assigned repo can also go back to where it was found
+func (m *model) pickAssignWorkspace(repo workspace.RepoInfo) {
+	if m.scanner == nil {
+		return
+	}
+	assign := func(m *model, name string) tea.Cmd {
+		m.scanner.AssignRepo(repo.Path, name)
+		if err := m.scanner.SaveCache(); err != nil {
+			m.log.add(logError, "saving workspace assignment for %s failed: %v", repo.Name, err)
+		}
+		m.repos = m.scanner.GetCachedRepos()
+		m.updateFilteredRepos()
+		if updated, ok := m.scanner.GetRepo(repo.Path); ok {
+			m.log.add(logInfo, "%s is now listed under %s", repo.Name, updated.WorkspaceName)
+		}
+		return nil
+	}
+	if m.scanner.AssignedWorkspace(repo.Path) != "" {
+		m.openMenu("📌 "+repo.Name+" is assigned to "+repo.WorkspaceName, []menuItem{
+			{key: "r", label: "Return to the workspace it is in", action: func(m *model) tea.Cmd { return assign(m, "") }},
+			{key: "w", label: "Assign to another workspace", action: func(m *model) tea.Cmd {
+				m.pickWorkspace("📌 Assign "+repo.Name+" to", repo.WorkspaceName, func(m *model, ws workspace.Workspace) tea.Cmd {
+					return assign(m, ws.Name)
+				})
+				return nil
+			}},
+		})
+		return
+	}
+	m.pickWorkspace("📌 Assign "+repo.Name+" to", repo.WorkspaceName, func(m *model, ws workspace.Workspace) tea.Cmd {
+		return assign(m, ws.Name)
+	})
+}
+
+// promptMoveRepo asks for the directory inside ws to move repo to, then
+// confirms the move
+func (m *model) promptMoveRepo(repo workspace.RepoInfo, ws workspace.Workspace) {
+	m.openPrompt("🚚 Move "+repo.Name+" into "+ws.Name+": directory", repo.Name, func(m *model, value string) tea.Cmd {
+		name := strings.TrimSpace(value)
+		if name == "" {
+			return nil
+		}
+		newPath := filepath.Join(workspace.ExpandPath(ws.Path), name)
+		m.openConfirmation("🚚 Move repository?", fmt.Sprintf("Move %s\nto %s?", repo.Path, newPath), func(m *model) tea.Cmd {
+			return moveRepoOperation(m.scanner, repo.Path, newPath, ws.Name)
+		})
+		return nil
+	})
+}
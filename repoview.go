@@ -0,0 +1,104 @@
+package main
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// rememberView records where the user is in the shown repository, for
+// putting them back there when they open it again, in this session or a
+// later one
+func (m model) rememberView() {
+	if m.scanner == nil || m.repo == nil {
+		return
+	}
+	view, _ := m.scanner.RepoView(m.repo.Path)
+	switch m.currentMode {
+	case filesMode:
+		view.Mode = "files"
+	case historyMode:
+		view.Mode = "history"
+	default:
+		// Keep the mode it was last left in
+		if view.Mode == "" {
+			view.Mode = "files"
+		}
+	}
+	if m.currentMode == filesMode || m.currentMode == historyMode {
+		view.DiffScroll = m.diffScrollOffset
+	}
+	view.File, view.Section = "", ""
+	if m.status != nil && m.selectedFile < len(m.status.Files) && m.selectedGroup == nil {
+		view.File = m.status.Files[m.selectedFile].Path
+		view.Section = m.selectedSide.String()
+	}
+	if m.selectedCommit < len(m.commits) {
+		view.Commit = m.commits[m.selectedCommit].Hash
+	}
+	m.scanner.SetRepoView(m.repo.Path, view)
+}
+
+// restoreView puts the user back where they were in the repository at
+// repoPath, which is being opened: the mode now, the selection and diff
+// scroll offset once the repository has loaded
+func (m *model) restoreView(repoPath string) {
+	m.pendingView = nil
+	if m.scanner == nil {
+		return
+	}
+	view, ok := m.scanner.RepoView(repoPath)
+	if !ok {
+		return
+	}
+	if view.Mode == "history" {
+		m.currentMode = historyMode
+	}
+	m.pendingView = &view
+}
+
+// restoreFile returns the file selection to restore once the status of a
+// repository being opened has loaded, consuming it
+func (m *model) restoreFile() (fileSelection, bool) {
+	view := m.pendingView
+	if view == nil || view.File == "" {
+		return fileSelection{}, false
+	}
+	sel := fileSelection{path: view.File, side: unstagedSide}
+	for side := range numSides {
+		if side.String() == view.Section {
+			sel.side = side
+		}
+	}
+	view.File = ""
+	if view.Commit == "" {
+		m.pendingView = nil
+	}
+	if m.currentMode != filesMode {
+		return fileSelection{}, false
+	}
+	m.diffScrollOffset = view.DiffScroll
+	return sel, true
+}
+
+// restoreCommit selects the commit to restore once the history of a
+// repository being opened has loaded, consuming it
+func (m *model) restoreCommit() tea.Cmd {
+	view := m.pendingView
+	if view == nil || view.Commit == "" {
+		return nil
+	}
+	idx := indexOfCommit(m.commits, view.Commit)
+	view.Commit = ""
+	if view.File == "" {
+		m.pendingView = nil
+	}
+	if idx < 0 {
+		return nil
+	}
+	m.selectedCommit = idx
+	if m.currentMode != historyMode {
+		return nil
+	}
+	cmd := m.selectionChanged()
+	m.diffScrollOffset = view.DiffScroll
+	return cmd
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/asbjornb/kvist/git"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// signingMsg is the signing config of a repository, for its settings menu
+type signingMsg struct {
+	repoPath string
+	name     string
+	signing  git.Signing
+	err      error
+}
+
+func loadSigning(repoPath, name string) tea.Cmd {
+	return func() tea.Msg {
+		signing, err := git.GetSigning(repoPath)
+		return signingMsg{repoPath: repoPath, name: name, signing: signing, err: err}
+	}
+}
+
+// signingState describes whether a kind of signing is on and whether the
+// repository or the global config says so
+func signingState(on, local bool) string {
+	state := "off"
+	if on {
+		state = "on"
+	}
+	if local {
+		return state + " in this repository"
+	}
+	return state + " globally"
+}
+
+// handleSigning shows the signing settings of a repository, each item
+// turning one on or off in the repository's own config. The menu opens
+// again after a change to show the new state.
+func (m *model) handleSigning(msg signingMsg) {
+	if msg.err != nil {
+		m.log.add(logError, "reading the signing config of %s failed: %v", msg.name, msg.err)
+		return
+	}
+	s := msg.signing
+	set := func(key, value string) func(*model) tea.Cmd {
+		return func(*model) tea.Cmd {
+			return setSigningOperation(msg.repoPath, msg.name, key, value)
+		}
+	}
+
+	pushValue, pushState := "if-asked", "off"
+	if s.Push != "" {
+		pushValue, pushState = "false", "on ("+s.Push+")"
+	}
+	if s.IsLocal(git.PushSigningKey) {
+		pushState += " in this repository"
+	} else {
+		pushState += " globally"
+	}
+
+	items := []menuItem{
+		{key: "c", label: "Sign commits: " + signingState(s.Commits, s.IsLocal(git.CommitSigningKey)), action: set(git.CommitSigningKey, strconv.FormatBool(!s.Commits))},
+		{key: "t", label: "Sign tags: " + signingState(s.Tags, s.IsLocal(git.TagSigningKey)), action: set(git.TagSigningKey, strconv.FormatBool(!s.Tags))},
+		{key: "p", label: "Sign pushes when the remote asks: " + pushState, action: set(git.PushSigningKey, pushValue)},
+		{key: "s", label: "Create a signed tag at HEAD", action: func(m *model) tea.Cmd {
+			m.promptSignedTag(msg.repoPath, msg.name)
+			return nil
+		}},
+	}
+	if len(s.Local) > 0 {
+		items = append(items, menuItem{key: "g", label: "Use the global settings again", action: func(*model) tea.Cmd {
+			return setSigningOperation(msg.repoPath, msg.name, strings.Join(s.Local, " "), "")
+		}})
+	}
+
+	title := "🔏 Signing in " + msg.name
+	if s.Format != "" && s.Format != "openpgp" {
+		title += " (" + s.Format + ")"
+	}
+	if s.Key == "" {
+		title += ", no user.signingkey set"
+	}
+	m.openMenu(title, items)
+}
+
+// setSigningOperation sets the space-separated signing config keys to value
+// in the repository's own config, or removes them there when value is "",
+// showing the signing settings again afterwards
+func setSigningOperation(repoPath, name, keys, value string) tea.Cmd {
+	desc := "set " + keys + " to " + value + " in " + name
+	if value == "" {
+		desc = "use the global " + keys + " in " + name
+	}
+	return requestOperation(operation{
+		desc:     desc,
+		repoPath: repoPath,
+		refresh:  refreshRepo,
+		run: func() error {
+			for _, key := range strings.Fields(keys) {
+				if err := git.SetLocalConfig(repoPath, key, value); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		finish: func(*model) tea.Cmd { return loadSigning(repoPath, name) },
+	})
+}
+
+// promptSignedTag asks for the name and message of a signed tag at HEAD
+func (m *model) promptSignedTag(repoPath, name string) {
+	m.openPrompt("🏷 Signed tag at HEAD of "+name+": name", "", func(m *model, tag string) tea.Cmd {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			return nil
+		}
+		m.openPrompt("🏷 Message of "+tag+" (empty for the tag name)", "", func(m *model, message string) tea.Cmd {
+			return requestOperation(operation{
+				desc:     "create signed tag " + tag + " in " + name,
+				repoPath: repoPath,
+				refresh:  refreshRepo,
+				run:      func() error { return git.CreateTag(repoPath, tag, "HEAD", strings.TrimSpace(message), true) },
+			})
+		})
+		return nil
+	})
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/asbjornb/kvist/git"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// stashFilesMsg lists the files a stash changes, to pick one to apply
+type stashFilesMsg struct {
+	repoPath string
+	stash    git.Stash
+	files    []string
+	err      error
+}
+
+// stashFileDiffMsg is the stashed change to one file, to apply it whole or
+// by hunk
+type stashFileDiffMsg struct {
+	repoPath string
+	stash    git.Stash
+	path     string
+	diff     string
+	err      error
+}
+
+func loadStashFiles(repoPath string, stash git.Stash) tea.Cmd {
+	return func() tea.Msg {
+		files, err := git.StashFiles(repoPath, stash.Hash)
+		return stashFilesMsg{repoPath: repoPath, stash: stash, files: files, err: err}
+	}
+}
+
+func loadStashFileDiff(repoPath string, stash git.Stash, path string) tea.Cmd {
+	return func() tea.Msg {
+		diff, err := git.StashFileDiff(repoPath, stash.Hash, path)
+		return stashFileDiffMsg{repoPath: repoPath, stash: stash, path: path, diff: diff, err: err}
+	}
+}
+
+// menuKey is the key of the i:th numbered entry of a menu; entries past the
+// ninth are picked with the arrows
+func menuKey(i int) string {
+	if i < 9 {
+		return fmt.Sprint(i + 1)
+	}
+	return ""
+}
+
+// pickStash lists the stashes to drill into
+func (m *model) pickStash() {
+	if len(m.stashes) == 0 {
+		m.log.add(logInfo, "no stashes")
+		return
+	}
+	repoPath := m.repo.Path
+	items := make([]menuItem, len(m.stashes))
+	for i, stash := range m.stashes {
+		items[i] = menuItem{key: menuKey(i), label: stash.Index + " " + stash.Message, action: func(*model) tea.Cmd {
+			return loadStashFiles(repoPath, stash)
+		}}
+	}
+	m.openMenu("📦 Stashes", items)
+}
+
+// pickStashFile offers to apply the whole stash or to go on to one of its files
+func (m *model) pickStashFile(msg stashFilesMsg) {
+	if msg.err != nil {
+		m.log.add(logError, "listing the files of %s failed: %v", msg.stash.Index, msg.err)
+		return
+	}
+	if m.repo == nil || m.repo.Path != msg.repoPath {
+		return
+	}
+	repoPath, stash := msg.repoPath, msg.stash
+	items := []menuItem{{key: "a", label: "Apply the whole stash", action: func(*model) tea.Cmd {
+		return applyStashOperation(repoPath, stash)
+	}}}
+	for i, path := range msg.files {
+		items = append(items, menuItem{key: menuKey(i), label: path, action: func(*model) tea.Cmd {
+			return loadStashFileDiff(repoPath, stash, path)
+		}})
+	}
+	m.openMenu("📦 Apply from "+stash.Index, items)
+}
+
+// pickStashHunk offers to apply a stashed file whole, all its changes, or
+// one of its hunks
+func (m *model) pickStashHunk(msg stashFileDiffMsg) {
+	if msg.err != nil {
+		m.log.add(logError, "reading %s from %s failed: %v", msg.path, msg.stash.Index, msg.err)
+		return
+	}
+	if m.repo == nil || m.repo.Path != msg.repoPath {
+		return
+	}
+	repoPath, stash, path := msg.repoPath, msg.stash, msg.path
+	items := []menuItem{{key: "f", label: "Check out the stashed file, replacing local changes", action: func(m *model) tea.Cmd {
+		m.openConfirmation("Check out from stash",
+			fmt.Sprintf("Replace %s with its version in %s? Uncommitted changes to it are lost and can't be undone.", path, stash.Index),
+			func(*model) tea.Cmd {
+				return stashFileOperation(repoPath, stash, path)
+			})
+		return nil
+	}}}
+	header, hunks := git.SplitHunks(msg.diff)
+	if len(hunks) > 0 {
+		items = append(items, menuItem{key: "p", label: "Apply all of its changes as a patch", action: func(*model) tea.Cmd {
+			return applyPatchOperation(repoPath, fmt.Sprintf("apply %s from %s", path, stash.Index), msg.diff)
+		}})
+	}
+	for i, hunk := range hunks {
+		title, _, _ := strings.Cut(hunk, "\n")
+		items = append(items, menuItem{key: menuKey(i), label: "Hunk " + title, action: func(*model) tea.Cmd {
+			return applyPatchOperation(repoPath, fmt.Sprintf("apply hunk %d of %s from %s", i+1, path, stash.Index), header+hunk)
+		}})
+	}
+	m.openMenu("📦 "+path+" in "+stash.Index, items)
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/asbjornb/kvist/git"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// maxTabs is how many repositories can be open at once, one per number key
+const maxTabs = 9
+
+// repoTab is a repository kept open in a tab: what was loaded of it and
+// where the user was in it, put back when switching to the tab
+type repoTab struct {
+	path string // where the repository is, as git reports it once loaded
+
+	repo     *git.Repository
+	commits  []git.Commit
+	branches []git.Branch
+	status   *git.Status
+	remotes  []git.Remote
+	stashes  []git.Stash
+	refs     map[string][]string
+
+	mode             viewMode
+	activePanel      panel
+	selectedCommit   int
+	selectedBranch   int
+	selectedFile     int
+	rangeActive      bool
+	rangeAnchor      int
+	currentDiff      string
+	diffScrollOffset int
+	fullDiff         []string
+	selectedRow      int
+	selectedSide     fileSide
+	selectedGroup    *fileGroup
+	collapsedGroups  map[fileGroup]bool
+	filesFilter      fileFilter
+	commitLimit      int
+
+	treeRev           string
+	treeDir           string
+	treeEntries       []git.TreeEntry
+	selectedTreeEntry int
+	treeFilePath      string
+	treeFileContent   string
+	treeReturnMode    viewMode
+
+	incomingCommits  []git.Commit
+	incomingUpstream string
+	selectedIncoming int
+}
+
+// tabMode reports whether mode keeps its state in the tab; other modes
+// show kvist-wide state and switching tabs leaves them
+func tabMode(mode viewMode) bool {
+	switch mode {
+	case filesMode, historyMode, treeMode, incomingMode:
+		return true
+	}
+	return false
+}
+
+// tabIndex returns the tab of the repository at path, or -1
+func (m model) tabIndex(path string) int {
+	return slices.IndexFunc(m.tabs, func(tab repoTab) bool { return tab.path == path })
+}
+
+// shownLoad reports whether a load of the repository at path is for what
+// is shown: the shown tab, or the repository being opened without a tab
+// yet. Loads of tabs switched away from or closed while they ran are
+// dropped.
+func (m model) shownLoad(path string) bool {
+	if path == m.openingRepo {
+		return true
+	}
+	return m.activeTab < len(m.tabs) && m.tabs[m.activeTab].path == path
+}
+
+// saveTab keeps the state of the shown repository in its tab
+func (m *model) saveTab() {
+	// Until it has loaded, the repository shown is still the one before
+	if m.activeTab >= len(m.tabs) || m.repo == nil || m.tabs[m.activeTab].path != m.repo.Path {
+		return
+	}
+	m.rememberView()
+	tab := &m.tabs[m.activeTab]
+	tab.repo, tab.commits, tab.branches, tab.status = m.repo, m.commits, m.branches, m.status
+	tab.remotes, tab.stashes, tab.refs = m.remotes, m.stashes, m.refs
+	if tabMode(m.currentMode) {
+		tab.mode = m.currentMode
+		tab.activePanel = m.activePanel
+	}
+	tab.selectedCommit, tab.selectedBranch, tab.selectedFile = m.selectedCommit, m.selectedBranch, m.selectedFile
+	tab.rangeActive, tab.rangeAnchor = m.rangeActive, m.rangeAnchor
+	tab.currentDiff, tab.diffScrollOffset, tab.fullDiff = m.currentDiff, m.diffScrollOffset, m.fullDiff
+	tab.selectedRow, tab.selectedSide, tab.selectedGroup = m.selectedRow, m.selectedSide, m.selectedGroup
+	tab.collapsedGroups, tab.filesFilter, tab.commitLimit = m.collapsedGroups, m.filesFilter, m.commitLimit
+	tab.treeRev, tab.treeDir, tab.treeEntries = m.treeRev, m.treeDir, m.treeEntries
+	tab.selectedTreeEntry, tab.treeFilePath, tab.treeFileContent = m.selectedTreeEntry, m.treeFilePath, m.treeFileContent
+	tab.treeReturnMode = m.treeReturnMode
+	tab.incomingCommits, tab.incomingUpstream, tab.selectedIncoming = m.incomingCommits, m.incomingUpstream, m.selectedIncoming
+}
+
+// newTab opens a tab for the repository at path after keeping the state of
+// the shown one. With every tab taken the shown tab is reused.
+func (m *model) newTab(path string) {
+	m.saveTab()
+	m.openingRepo = ""
+	tab := repoTab{path: path, mode: filesMode, activePanel: topPanel}
+	if len(m.tabs) >= maxTabs {
+		m.log.add(logInfo, "all %d tabs are open; %s replaces %s", maxTabs, filepath.Base(path), m.tabName(m.activeTab))
+		m.tabs[m.activeTab] = tab
+		return
+	}
+	m.tabs = append(m.tabs, tab)
+	m.activeTab = len(m.tabs) - 1
+}
+
+// trackTab records where the repository being loaded in the shown tab is,
+// opening the first tab for one loaded at startup
+func (m *model) trackTab(repo *git.Repository) {
+	if m.activeTab >= len(m.tabs) {
+		m.tabs = append(m.tabs, repoTab{mode: filesMode, activePanel: topPanel})
+		m.activeTab = len(m.tabs) - 1
+	}
+	m.tabs[m.activeTab].path = repo.Path
+}
+
+// switchTab shows the repository of tab i as it was left, reloading it to
+// catch up with what changed meanwhile
+func (m *model) switchTab(i int) tea.Cmd {
+	if i >= len(m.tabs) {
+		return nil
+	}
+	if i == m.activeTab && tabMode(m.currentMode) {
+		return nil
+	}
+	m.saveTab()
+	return m.showTab(i)
+}
+
+// showTab puts back the state kept in tab i
+func (m *model) showTab(i int) tea.Cmd {
+	tab := m.tabs[i]
+	m.activeTab = i
+	m.openingRepo = ""
+	m.repo, m.commits, m.branches, m.status = tab.repo, tab.commits, tab.branches, tab.status
+	m.remotes, m.stashes, m.refs = tab.remotes, tab.stashes, tab.refs
+	m.currentMode, m.activePanel = tab.mode, tab.activePanel
+	m.selectedCommit, m.selectedBranch, m.selectedFile = tab.selectedCommit, tab.selectedBranch, tab.selectedFile
+	m.rangeActive, m.rangeAnchor = tab.rangeActive, tab.rangeAnchor
+	m.currentDiff, m.diffScrollOffset, m.fullDiff = tab.currentDiff, tab.diffScrollOffset, tab.fullDiff
+	m.selectedRow, m.selectedSide, m.selectedGroup = tab.selectedRow, tab.selectedSide, tab.selectedGroup
+	m.collapsedGroups, m.filesFilter, m.commitLimit = tab.collapsedGroups, tab.filesFilter, tab.commitLimit
+	m.treeRev, m.treeDir, m.treeEntries = tab.treeRev, tab.treeDir, tab.treeEntries
+	m.selectedTreeEntry, m.treeFilePath, m.treeFileContent = tab.selectedTreeEntry, tab.treeFilePath, tab.treeFileContent
+	m.treeReturnMode = tab.treeReturnMode
+	m.incomingCommits, m.incomingUpstream, m.selectedIncoming = tab.incomingCommits, tab.incomingUpstream, tab.selectedIncoming
+	m.err = nil
+	m.pendingView = nil
+	m.pendingMatch = nil
+
+	// A diff still loading is of the tab left behind
+	if m.diffCancel != nil {
+		m.diffCancel()
+		m.diffCancel = nil
+	}
+	m.diffGen++
+
+	if m.scanner != nil {
+		m.scanner.UpdateLastRepo(tab.path)
+	}
+	m.loadingRepo = true
+	m.loadingMetadata = true
+	cmds := []tea.Cmd{loadRepositoryIncremental(tab.path, m.commitLimit, m.filesFilter)}
+	if m.currentDiff == "" && m.repo != nil {
+		cmds = append(cmds, m.selectionChanged())
+	}
+	return tea.Batch(cmds...)
+}
+
+// closeTab closes tab i without showing another one
+func (m *model) closeTab(i int) {
+	if i < 0 || i >= len(m.tabs) {
+		return
+	}
+	m.tabs = slices.Delete(m.tabs, i, i+1)
+	if m.activeTab > i || m.activeTab >= len(m.tabs) {
+		m.activeTab = max(m.activeTab-1, 0)
+	}
+}
+
+// closeShownTab closes the tab of the shown repository and shows the tab
+// next to it, or the workspace list after the last tab
+func (m *model) closeShownTab() tea.Cmd {
+	if m.activeTab >= len(m.tabs) {
+		return nil
+	}
+	m.log.add(logInfo, "closed %s", m.tabName(m.activeTab))
+	m.closeTab(m.activeTab)
+	if len(m.tabs) == 0 {
+		m.repo, m.status, m.commits = nil, nil, nil
+		m.currentDiff = ""
+		m.currentMode = workspaceMode
+		return nil
+	}
+	return m.showTab(m.activeTab)
+}
+
+// tabName is the name tab i is listed under
+func (m model) tabName(i int) string {
+	if repo := m.tabs[i].repo; repo != nil {
+		return repo.Name
+	}
+	return filepath.Base(m.tabs[i].path)
+}
+
+// renderTabBar lists the open repositories by their number key, when more
+// than one is open
+func (m model) renderTabBar() string {
+	if len(m.tabs) < 2 {
+		return ""
+	}
+	tabStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("245")).
+		Padding(0, 1)
+
+	activeStyle := tabStyle.
+		Foreground(lipgloss.Color("230")).
+		Background(lipgloss.Color("62")).
+		Bold(true)
+
+	parts := make([]string, 0, len(m.tabs))
+	for i := range m.tabs {
+		style := tabStyle
+		if i == m.activeTab && m.repo != nil {
+			style = activeStyle
+		}
+		parts = append(parts, style.Render(fmt.Sprintf("%d %s", i+1, truncate(m.tabName(i), 20))))
+	}
+	return "  " + strings.Join(parts, " ")
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// tourStep is one step of the onboarding tour. A step is done once the model
+// reaches the state it asks for; the final step has no condition and ends on Enter.
+type tourStep struct {
+	text string
+	done func(m model) bool
+}
+
+var tourSteps = []tourStep{
+	{
+		text: "Workspaces are folders kvist scans for repositories. Press w to open the workspace picker (twice if a repository is open).",
+		done: func(m model) bool { return m.showingModal && m.modalMode == workspacePickerModal },
+	},
+	{
+		text: "Choose \"Add New Workspace\", enter a name and the folder holding your repositories, or pick an existing workspace. Press esc when done.",
+		done: func(m model) bool {
+			return !m.showingModal && m.workspaceConfig != nil && len(m.workspaceConfig.Workspaces) > 0
+		},
+	},
+	{
+		text: "Select a repository with j/k and press enter to open it.",
+		done: func(m model) bool { return m.repo != nil && m.currentMode == filesMode && !m.loadingRepo },
+	},
+	{
+		text: "Changed files are listed at the top with their diff below. Select a file and press space to stage it (ctrl+n to skip if nothing changed).",
+		done: func(m model) bool {
+			if m.status == nil {
+				return false
+			}
+			for _, f := range m.status.Files {
+				if f.Staged != "" {
+					return true
+				}
+			}
+			return false
+		},
+	},
+	{
+		text: "Press h to see the commit history.",
+		done: func(m model) bool { return m.currentMode == historyMode },
+	},
+	{
+		text: "j/k moves through commits and tab cycles between the list, the commit details and the diff. Press tab.",
+		done: func(m model) bool { return m.currentMode == historyMode && m.activePanel != topPanel },
+	},
+	{
+		text: "Errors and results of background operations end up in the message log. Press L to open it.",
+		done: func(m model) bool { return m.showingModal && m.modalMode == logModal },
+	},
+	{
+		text: "That's the basics: s for files, h for history, b for branches, w for workspaces and q to quit. Press enter to finish the tour.",
+	},
+}
+
+// offerTour asks once, on first launch, whether to start the onboarding tour
+func (m *model) offerTour() {
+	if m.safeMode || m.workspaceConfig == nil || m.workspaceConfig.TourSeen {
+		return
+	}
+	m.workspaceConfig.TourSeen = true
+	if err := m.workspaceConfig.Save(); err != nil {
+		m.log.add(logError, "saving config failed: %v", err)
+	}
+	// Existing setups already know their way around
+	if len(m.workspaceConfig.Workspaces) > 0 {
+		return
+	}
+	m.openConfirmation("Welcome to kvist", "Take a quick guided tour of the basics?", func(m *model) tea.Cmd {
+		m.tourActive = true
+		m.tourStep = 0
+		return nil
+	})
+}
+
+func (m model) renderTourCard() string {
+	cardStyle := lipgloss.NewStyle().
+		Width(m.width-2).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("214")).
+		Padding(0, 1)
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("214"))
+
+	helpStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	step := tourSteps[m.tourStep]
+	title := titleStyle.Render(fmt.Sprintf("Tour %d/%d", m.tourStep+1, len(tourSteps)))
+	footer := helpStyle.Render("ctrl+n: skip step • ctrl+x: end tour")
+
+	return cardStyle.Render(title + "  " + step.text + "\n" + footer)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/asbjornb/kvist/git"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxTransplantPreview is how many of the commits to move the confirmation
+// lists by subject
+const maxTransplantPreview = 15
+
+// transplantStateMsg reports whether the repository is stopped in the middle
+// of something before a transplant starts
+type transplantStateMsg struct {
+	repoPath   string
+	oldBase    string
+	inProgress string
+	err        error
+}
+
+// transplantPreviewMsg lists the commits a transplant would move
+type transplantPreviewMsg struct {
+	repoPath string
+	newBase  string
+	oldBase  string
+	commits  []git.Commit
+	err      error
+}
+
+func loadTransplantState(repoPath, oldBase string) tea.Cmd {
+	return func() tea.Msg {
+		op, err := git.InProgressOperation(repoPath)
+		return transplantStateMsg{repoPath: repoPath, oldBase: oldBase, inProgress: op, err: err}
+	}
+}
+
+func loadTransplantPreview(repoPath, newBase, oldBase string) tea.Cmd {
+	return func() tea.Msg {
+		commits, err := git.TransplantCommits(repoPath, oldBase, maxCommitLimit)
+		return transplantPreviewMsg{repoPath: repoPath, newBase: newBase, oldBase: oldBase, commits: commits, err: err}
+	}
+}
+
+// startTransplant moves on from the state check: to the rebase still
+// stopped, or to picking the new base for the commits after oldBase
+func (m *model) startTransplant(msg transplantStateMsg) {
+	if msg.err != nil {
+		m.log.add(logError, "checking the repository failed: %v", msg.err)
+		return
+	}
+	if m.repo == nil || m.repo.Path != msg.repoPath {
+		return
+	}
+	switch {
+	case msg.inProgress == "rebase":
+		m.openStoppedMenu(msg.repoPath, msg.inProgress)
+		return
+	case msg.inProgress != "":
+		m.log.add(logWarn, "a %s is in progress; finish it before transplanting", msg.inProgress)
+		return
+	case m.repo.CurrentBranch == "":
+		m.log.add(logWarn, "HEAD is detached; check out the branch to transplant first")
+		return
+	}
+
+	repoPath, oldBase := msg.repoPath, msg.oldBase
+	preview := func(newBase string) func(*model) tea.Cmd {
+		return func(*model) tea.Cmd {
+			return loadTransplantPreview(repoPath, newBase, oldBase)
+		}
+	}
+	items := []menuItem{{key: "r", label: "Type a branch, tag or commit", action: func(m *model) tea.Cmd {
+		m.openPrompt("🌱 New base (branch, tag or commit)", "", func(_ *model, value string) tea.Cmd {
+			if value = strings.TrimSpace(value); value == "" {
+				return nil
+			}
+			return loadTransplantPreview(repoPath, value, oldBase)
+		})
+		return nil
+	}}}
+	for _, branch := range m.branches {
+		if branch.IsCurrent {
+			continue
+		}
+		ref := branch.Name
+		if name, remote := strings.CutSuffix(ref, " (remote)"); remote {
+			ref = "origin/" + name
+		}
+		items = append(items, menuItem{key: menuKey(len(items) - 1), label: ref, action: preview(ref)})
+	}
+	m.openMenu(fmt.Sprintf("🌱 Transplant %s after %s onto", m.repo.CurrentBranch, shortHash(oldBase)), items)
+}
+
+// confirmTransplant shows the commits that would move and asks before
+// running git rebase --onto
+func (m *model) confirmTransplant(msg transplantPreviewMsg) {
+	if msg.err != nil {
+		m.log.add(logError, "listing the commits to transplant failed: %v", msg.err)
+		return
+	}
+	if m.repo == nil || m.repo.Path != msg.repoPath {
+		return
+	}
+	if len(msg.commits) == 0 {
+		m.log.add(logInfo, "no commits after %s to transplant", shortHash(msg.oldBase))
+		return
+	}
+	var lines []string
+	for _, commit := range msg.commits[:min(len(msg.commits), maxTransplantPreview)] {
+		lines = append(lines, commit.ShortHash+" "+commit.Subject)
+	}
+	message := fmt.Sprintf("Move %d commit(s) of %s from %s onto %s?\n\n%s",
+		len(msg.commits), m.repo.CurrentBranch, shortHash(msg.oldBase), msg.newBase, strings.Join(lines, "\n"))
+	if len(msg.commits) > len(lines) {
+		message += fmt.Sprintf("\n...and %d more", len(msg.commits)-len(lines))
+	}
+	repoPath, branch, newBase, oldBase := msg.repoPath, m.repo.CurrentBranch, msg.newBase, msg.oldBase
+	m.openConfirmation("🌱 git rebase --onto", message, func(*model) tea.Cmd {
+		return sequenceOperation(repoPath, "rebase", fmt.Sprintf("transplant %s onto %s", branch, newBase), true, func() error {
+			return git.RebaseOnto(repoPath, newBase, oldBase)
+		})
+	})
+}
+
+// openStoppedMenu offers the ways on from a stopped op: "rebase",
+// "cherry-pick" or "revert"
+func (m *model) openStoppedMenu(repoPath, op string) {
+	m.openMenu("⚠️  "+strings.ToUpper(op[:1])+op[1:]+" stopped (resolve conflicts in files mode, then continue)", []menuItem{
+		{key: "c", label: "Continue, with the conflicts resolved and staged", action: func(*model) tea.Cmd {
+			return sequenceOperation(repoPath, op, "continue "+op, false, func() error { return git.ContinueSequence(repoPath, op) })
+		}},
+		{key: "s", label: "Skip the commit it stopped at", action: func(*model) tea.Cmd {
+			return sequenceOperation(repoPath, op, "skip commit in "+op, false, func() error { return git.SkipSequence(repoPath, op) })
+		}},
+		{key: "a", label: "Abort, putting the branch back where it was", action: func(*model) tea.Cmd {
+			return sequenceOperation(repoPath, op, "abort "+op, false, func() error { return git.AbortSequence(repoPath, op) })
+		}},
+	})
+}
+
+// sequenceOperation runs a step of a rebase, cherry-pick or revert (op);
+// snapshot is for its first step, so undo goes back to before it. When op
+// stops on conflicts the status is reloaded to show them and the way on is
+// offered.
+func sequenceOperation(repoPath, op, desc string, snapshot bool, run func() error) tea.Cmd {
+	return requestOperation(operation{
+		desc:     desc,
+		repoPath: repoPath,
+		snapshot: snapshot,
+		refresh:  refreshRepo,
+		run:      run,
+		failed: func(m *model, err error) tea.Cmd {
+			if errors.Is(err, git.ErrStopped) {
+				m.openStoppedMenu(repoPath, op)
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,673 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/asbjornb/kvist/git"
+	"github.com/asbjornb/kvist/workspace"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	traceMsg(msg)
+	if m.tourActive {
+		if key, ok := msg.(tea.KeyMsg); ok {
+			switch key.String() {
+			case "ctrl+x":
+				m.tourActive = false
+				m.log.add(logInfo, "tour ended")
+				return m, nil
+			case "ctrl+n":
+				m.tourStep++
+				m.tourActive = m.tourStep < len(tourSteps)
+				return m, nil
+			case "enter":
+				if tourSteps[m.tourStep].done == nil {
+					m.tourActive = false
+					return m, nil
+				}
+			}
+		}
+	}
+
+	updated, cmd := m.update(msg)
+	if um, ok := updated.(model); ok && len(um.askpassPending) > 0 {
+		// A queued credential prompt shows once the modal before it closes
+		um.showAskpass()
+		updated = um
+	}
+	if um, ok := updated.(model); ok && um.tourActive {
+		// Steps complete when the real key handlers reach the state they ask for
+		for um.tourStep < len(tourSteps)-1 && tourSteps[um.tourStep].done(um) {
+			um.tourStep++
+		}
+		updated = um
+	}
+	return updated, cmd
+}
+
+// update routes a message: open overlays take all keys, then the active
+// mode's controller, then the global bindings. Results of background work
+// are handled here when they concern the whole model, and otherwise offered
+// to each mode's controller.
+func (m model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if m.showingBranchMenu {
+			return m.updateBranchMenu(msg)
+		}
+		if m.creatingBranch {
+			return m.updateBranchInput(msg)
+		}
+		if m.editingWorkspace {
+			return m.updateWorkspaceEditor(msg)
+		}
+		if m.currentMode == workspaceMode && m.searchMode && m.updateRepoFilter(msg) {
+			return m, nil
+		}
+		if cmd, handled := m.handleConfigErrorKey(msg.String()); handled {
+			return m, cmd
+		}
+		if m.showingModal {
+			if m.modalMode != workspacePickerModal {
+				return m.updateOverlayModal(msg)
+			}
+			return m.updateWorkspacePicker(msg)
+		}
+
+		key, pending := m.resolveKeySequence(msg.String())
+		if pending {
+			return m, nil
+		}
+		if cmd, handled := m.controller().handleKey(&m, key); handled {
+			return m, cmd
+		}
+		return m, m.handleGlobalKey(key)
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		if !m.ready {
+			m.ready = true
+		}
+	case repoLoadedMsg:
+		m.repo = msg.repo
+		m.commits = msg.commits
+		m.branches = msg.branches
+		m.status = msg.status
+		m.remotes = msg.remotes
+		m.stashes = msg.stashes
+		m.err = msg.err
+		// Load diff for first file if in files mode
+		if m.currentMode == filesMode && m.repo != nil && m.status != nil && len(m.status.Files) > 0 {
+			m.resetFileSelection()
+			return m, m.loadSelectedDiff()
+		}
+	case repoBasicsLoadedMsg:
+		if !m.shownLoad(msg.path) {
+			return m, nil // of a tab switched away from or closed while it loaded
+		}
+		// Fast loading: repository and status loaded - can show files immediately
+		m.loadingRepo = false
+		if msg.err != nil {
+			m.log.add(logError, "loading repository failed: %v", msg.err)
+			m.err = msg.err
+			return m, nil
+		}
+
+		// Remember the selection by name so it survives files being added or removed
+		prev := m.fileSelection()
+		if sel, ok := m.restoreFile(); ok {
+			prev = sel
+		}
+
+		m.trackTab(msg.repo)
+		m.noticeSlowStatus(msg.repo.Path, msg.statusTime)
+		delta := git.DiffStatus(m.status, msg.status)
+		m.repo = msg.repo
+		if m.status == nil || !delta.Empty() {
+			m.status = msg.status
+		}
+		if match := m.pendingMatch; match != nil {
+			m.pendingMatch = nil
+			return m, tea.Batch(m.viewMatch(*match, filesMode), m.scheduleRefresh())
+		}
+
+		// Load diff for currently selected file to preserve user's view during auto-refresh
+		if m.currentMode == filesMode && m.repo != nil && m.status != nil && len(m.status.Files) > 0 {
+			m.syncFileRow(prev)
+			if m.selectedFile >= len(m.status.Files) {
+				// A section or directory is selected; it has no diff
+				return m, m.scheduleRefresh()
+			}
+			file := m.status.Files[m.selectedFile]
+			// Keep the reading position only while the same diff is shown
+			if file.Path != prev.path || m.selectedSide != prev.side || slices.Contains(delta.Changed, file.Path) {
+				m.diffScrollOffset = 0
+			} else if m.currentDiff != "" {
+				// Only other files changed, if any; the diff shown is current
+				return m, m.scheduleRefresh()
+			}
+			return m, tea.Batch(
+				m.loadSelectedDiff(),
+				m.scheduleRefresh(), // Start auto-refresh timer
+			)
+		}
+		// Start auto-refresh even if no files to diff
+		return m, m.scheduleRefresh()
+	case repoMetadataLoadedMsg:
+		if !m.shownLoad(msg.path) {
+			return m, nil
+		}
+		// Slow loading: commits, branches, etc loaded - history view now available
+		m.loadingMetadata = false
+		if msg.err != nil {
+			m.log.add(logWarn, "loading repository metadata failed: %v", msg.err)
+			// Don't overwrite existing error, just log metadata loading failure
+			if m.err == nil {
+				m.err = fmt.Errorf("failed to load repository metadata: %w", msg.err)
+			}
+			return m, nil
+		}
+
+		// Re-match the selected commit by hash; new commits shift the indices
+		var prevHash string
+		if m.selectedCommit < len(m.commits) {
+			prevHash = m.commits[m.selectedCommit].Hash
+		}
+
+		m.commits = msg.commits
+		m.rangeActive = false // its indices would be stale
+		m.branches = msg.branches
+		m.remotes = msg.remotes
+		m.stashes = msg.stashes
+		m.refs = msg.refs
+
+		if idx := indexOfCommit(m.commits, prevHash); idx >= 0 {
+			m.selectedCommit = idx
+		} else if m.selectedCommit >= len(m.commits) {
+			m.selectedCommit = max(len(m.commits)-1, 0)
+		}
+		if cmd := m.restoreCommit(); cmd != nil {
+			return m, tea.Batch(cmd, m.requestVisibleChecks())
+		}
+		if m.currentMode == historyMode && m.repo != nil && m.selectedCommit < len(m.commits) &&
+			prevHash != "" && m.commits[m.selectedCommit].Hash != prevHash {
+			// The selected commit is gone (e.g. after an amend or reset); show its replacement
+			return m, tea.Batch(m.selectionChanged(), m.requestVisibleChecks())
+		}
+		return m, m.requestVisibleChecks()
+	case commitGraphWrittenMsg:
+		if msg.err != nil {
+			m.log.add(logWarn, "writing the commit-graph for %s failed: %v", msg.repoPath, msg.err)
+		}
+	case diffLoadedMsg:
+		if msg.gen != m.diffGen {
+			return m, nil // the selection moved on while this loaded
+		}
+		if m.diffCancel != nil {
+			m.diffCancel()
+			m.diffCancel = nil
+		}
+		m.fullDiff = msg.full
+		if msg.err == nil {
+			m.currentDiff = msg.diff
+			if msg.full != nil {
+				m.currentDiff += truncatedDiffHint()
+			}
+		} else {
+			// Show error in diff panel
+			m.currentDiff = fmt.Sprintf("Error loading diff: %v", msg.err)
+			m.log.add(logError, "loading diff failed: %v", msg.err)
+		}
+		// A refreshed diff may be shorter than the preserved scroll position
+		m.scrollContent(0)
+	case startRepoMsg:
+		return m, m.handleStartRepo(msg)
+	case workspaceConfigMsg:
+		if msg.err != nil {
+			m.log.add(logError, "loading workspace config failed: %v", msg.err)
+			m.err = msg.err
+		} else {
+			for _, warning := range msg.warnings {
+				m.log.add(logWarn, "%s", warning)
+			}
+			if m.workspaceConfig != nil && !slices.Equal(m.workspaceConfig.Diff.TextConv, msg.config.Diff.TextConv) {
+				// Cached diffs were converted, or not, by the old patterns
+				diffs.clear()
+			}
+			m.workspaceConfig = msg.config
+			git.SetRenames(msg.config.Renames.GitRenames())
+			git.SetTextConv(msg.config.Diff.TextConv)
+			git.SetEnvironment(msg.config.CommandEnv)
+			m.repoCache = msg.cache
+			m.scanner = workspace.NewScanner(msg.config, msg.cache)
+			for _, path := range m.scanner.LargeRepos() {
+				git.SetLargeRepo(path, true)
+			}
+			// Load cached repos immediately
+			m.repos = m.scanner.GetCachedRepos()
+
+			var cmds []tea.Cmd
+			if m.safeMode {
+				// Don't reopen whatever we were doing when we crashed
+				m.log.add(logWarn, "previous sessions ended unexpectedly; started in safe mode")
+				m.repoCache.LastRepoPath = ""
+				m.showingModal = true
+				m.modalMode = safeModeModal
+			}
+			if m.gitProblem != nil {
+				m.showingModal = true
+				m.modalMode = gitSetupModal
+			}
+
+			if startupCmd := m.smartStartup(); startupCmd != nil {
+				cmds = append(cmds, startupCmd)
+			}
+
+			if !m.safeMode {
+				if scanCmd := m.startWorkspaceScan(); scanCmd != nil {
+					cmds = append(cmds, scanCmd)
+				}
+			}
+
+			if scanCmd := m.scheduleAutoScan(); scanCmd != nil {
+				cmds = append(cmds, scanCmd)
+			}
+			if fetchCmd := m.scheduleAutoFetch(); fetchCmd != nil {
+				cmds = append(cmds, fetchCmd)
+			}
+			if updateCmd := m.checkForUpdate(); updateCmd != nil {
+				cmds = append(cmds, updateCmd)
+			}
+
+			m.offerTour()
+
+			switch len(cmds) {
+			case 0:
+				return m, nil
+			case 1:
+				return m, cmds[0]
+			default:
+				return m, tea.Batch(cmds...)
+			}
+		}
+	case updateAvailableMsg:
+		m.latestRelease = msg.release
+		m.log.add(logInfo, "kvist %s is available: %s", msg.release.Tag, msg.release.URL)
+	case repoOpenedMsg:
+		if msg.err != nil {
+			m.log.add(logError, "opening %s failed: %v", msg.path, msg.err)
+		}
+	case editorFinishedMsg:
+		if msg.err != nil {
+			m.log.add(logError, "editing %s failed: %v", msg.path, msg.err)
+		}
+		// The file has most likely changed
+		return m, m.reload(refreshStatus)
+	case pagerFinishedMsg:
+		if msg.err != nil {
+			m.log.add(logError, "showing the diff in the pager failed: %v", msg.err)
+		}
+	case clipboardMsg:
+		if msg.err != nil {
+			m.log.add(logError, "copying %s failed: %v", msg.what, msg.err)
+		} else {
+			m.log.add(logInfo, "copied %s to clipboard", msg.what)
+		}
+	case browserOpenedMsg:
+		if msg.err != nil {
+			m.log.add(logError, "opening in browser failed: %v", msg.err)
+		} else {
+			m.log.add(logInfo, "opened %s", msg.url)
+		}
+	case diffExportedMsg:
+		if msg.err != nil {
+			m.log.add(logError, "diff export to %s failed: %v", msg.path, msg.err)
+		} else {
+			m.log.add(logInfo, "exported diff to %s", msg.path)
+		}
+	case timerMsg:
+		return m, m.handleTimer(msg)
+	case ciChecksMsg:
+		return m, m.handleChecks(msg)
+	case pullRequestsLoadedMsg:
+		m.handlePullRequests(msg)
+	case pullRequestCreatedMsg:
+		return m, m.handlePullRequestCreated(msg)
+	case autoFetchDoneMsg:
+		return m, m.handleAutoFetch(msg)
+	case branchCleanupMsg:
+		m.handleBranchCleanup(msg)
+	case recentBranchesMsg:
+		m.handleRecentBranches(msg)
+	case upstreamCandidatesMsg:
+		m.handleUpstreamCandidates(msg)
+	case changelogTagsMsg:
+		return m, m.handleChangelogTags(msg)
+	case signingMsg:
+		m.handleSigning(msg)
+	case maintenanceStateMsg:
+		m.openMaintenance(msg)
+	case askpassMsg:
+		return m, m.handleAskpass(msg)
+	case commitSettingsMsg:
+		return m, m.handleCommitSettings(msg)
+	case editorCommitFinishedMsg:
+		return m, m.handleEditorCommitFinished(msg)
+	case selectionChangedMsg:
+		return m, m.handleSelectionChanged(msg)
+	case operationRequestedMsg:
+		return m, runOperation(msg.op)
+	case operationOutputMsg:
+		m.log.add(logInfo, "%s: %s", msg.op.desc, msg.line)
+		return m, nextOperationOutput(msg.op, msg.done)
+	case hookFinishedMsg:
+		m.handleHookFinished(msg)
+	case operationFinishedMsg:
+		if msg.err != nil {
+			m.log.add(logError, "%s failed: %v", msg.op.desc, msg.err)
+			if msg.op.failed == nil {
+				return m, nil
+			}
+			// A failure that left changes behind, like a stopped rebase, still refreshes
+			failedCmd := msg.op.failed(&m, msg.err)
+			if m.repo == nil || m.repo.Path != msg.op.repoPath {
+				return m, failedCmd
+			}
+			return m, tea.Batch(failedCmd, m.reload(msg.op.refresh))
+		}
+		m.log.add(logInfo, "%s completed", msg.op.desc)
+		if msg.snapshot != nil {
+			m.undo = msg.snapshot
+		}
+		var finishCmd tea.Cmd
+		if msg.op.finish != nil {
+			finishCmd = msg.op.finish(&m)
+		}
+		if m.repo == nil || m.repo.Path != msg.op.repoPath {
+			return m, finishCmd // the user has moved on to another repository
+		}
+		return m, tea.Batch(finishCmd, m.reload(msg.op.refresh))
+	default:
+		for _, c := range controllers {
+			if cmd, handled := c.handleMsg(&m, msg); handled {
+				return m, cmd
+			}
+		}
+	}
+	return m, nil
+}
+
+// handleGlobalKey handles the key bindings shared by all modes
+func (m *model) handleGlobalKey(key string) tea.Cmd {
+	switch key {
+	case "ctrl+c", "q":
+		return tea.Quit
+	case "Q":
+		if m.printPath {
+			m.exitPath = m.selectedRepoPath()
+			return tea.Quit
+		}
+	case "tab", "shift+tab":
+		if m.controller().panels() == 3 {
+			// Cycle through 3 panels: top -> middle -> bottom -> top
+			step := panel(1)
+			if key == "shift+tab" {
+				step = 2
+			}
+			m.activePanel = (m.activePanel + step) % 3
+		} else if m.activePanel == topPanel {
+			// Only 2 panels, so both directions just toggle
+			m.activePanel = bottomPanel
+		} else {
+			m.activePanel = topPanel
+		}
+	case "up", "k", "down", "j", "ctrl+u", "ctrl+d", "pgup", "pgdown", "home", "end", "G":
+		delta, _ := navigationDelta(key, m.pageSize())
+		switch m.activePanel {
+		case topPanel:
+			return m.moveSelection(delta)
+		case middlePanel:
+			// Middle panel in history mode - could add scrolling for long commit messages later
+			// For now, no scrolling needed
+		case bottomPanel:
+			m.scrollContent(delta)
+		}
+	case "f":
+		if m.repo != nil && m.networkAllowed("fetch") {
+			return gitOperation(m.repo.Path, git.OpFetch)
+		}
+	case "p":
+		if m.repo != nil && m.networkAllowed("pull") {
+			return gitOperation(m.repo.Path, git.OpPull)
+		}
+	case "P":
+		if m.repo != nil && m.networkAllowed("push") {
+			return gitOperation(m.repo.Path, git.OpPush)
+		}
+	case "N":
+		return m.toggleOffline()
+	case "r":
+		diffs.clear()
+		return m.reload(refreshRepo)
+	case "w":
+		// Go to workspace mode
+		m.currentMode = workspaceMode
+		m.currentDiff = ""
+	case "h":
+		// Show the diff of the selected commit
+		m.currentMode = historyMode
+		return m.selectionChanged()
+	case "s":
+		m.currentMode = filesMode
+		m.diffScrollOffset = 0 // Reset scroll when switching to files mode
+	case "T":
+		if m.repo != nil && m.currentMode != workspaceMode && m.currentMode != workspaceManageMode {
+			m.currentMode = todoMode
+			m.activePanel = topPanel
+			return m.startTodoScan()
+		}
+	case "I":
+		if m.repo != nil && m.currentMode != workspaceMode && m.currentMode != workspaceManageMode {
+			m.currentMode = incomingMode
+			m.activePanel = topPanel
+			m.incomingCommits = nil
+			m.selectedIncoming = 0
+			m.currentDiff = ""
+			return m.startIncoming()
+		}
+	case "%":
+		if m.repo != nil && m.currentMode != workspaceMode && m.currentMode != workspaceManageMode {
+			return m.openStats(m.repo.Path)
+		}
+	case "Z":
+		if m.repo != nil && m.currentMode != workspaceMode && m.currentMode != workspaceManageMode {
+			return m.openSizes(m.repo.Path)
+		}
+	case "!":
+		if m.repo != nil && m.currentMode != workspaceMode && m.currentMode != workspaceManageMode {
+			return m.openTasks(m.repo.Path)
+		}
+	case "o":
+		if m.repo != nil && m.currentMode != workspaceManageMode {
+			return m.openRepoExternally(m.repo.Path)
+		}
+	case "y":
+		m.openMenu("📋 Copy to clipboard", m.yankItems())
+	case "O":
+		m.openMenu("🌐 Open in browser", m.browserItems())
+	case "R":
+		return m.requestPullRequests()
+	case "V":
+		if m.repo != nil && m.fullDiff != nil {
+			return pageDiff(m.repo.Path, m.fullDiff)
+		}
+	case "E":
+		if doc, name, ok := m.currentDiffDocument(); ok {
+			return exportDiff(doc, name)
+		}
+	case "b":
+		if m.repo != nil {
+			m.showingBranchMenu = true
+			m.selectedBranchMenu = 0
+		}
+	case "-":
+		if m.repo != nil {
+			return loadRecentBranches(m.repo.Path)
+		}
+	case "u":
+		if m.repo != nil && m.undo != nil && m.undo.RepoPath == m.repo.Path {
+			snapshot := m.undo
+			target := snapshot.Branch
+			if target == "" {
+				target = "detached HEAD"
+			}
+			m.openConfirmation("Undo "+snapshot.Operation,
+				fmt.Sprintf("Restore %s to %s and reset moved branches?", target, shortHash(snapshot.Head)),
+				func(m *model) tea.Cmd {
+					return undoOperation(snapshot)
+				})
+		} else {
+			m.log.add(logInfo, "nothing to undo")
+		}
+	case "f12":
+		return m.toggleHUD()
+	case "ctrl+o":
+		m.openJumpList()
+	case "L":
+		// Show message log, scrolled to the newest entries
+		m.showingModal = true
+		m.modalMode = logModal
+		m.logScrollOffset = max(0, len(m.log.entries)-logVisibleEntries)
+		m.log.unseen = 0
+	case "=":
+		m.openCompareMenu()
+	case "@":
+		if m.repo != nil && m.currentMode != workspaceMode && m.currentMode != workspaceManageMode {
+			return loadSigning(m.repo.Path, m.repo.Name)
+		}
+	case "W":
+		if m.repo != nil && m.currentMode != workspaceMode && m.currentMode != workspaceManageMode {
+			return loadChangelogTags(m.repo.Path)
+		}
+	case "1", "2", "3", "4", "5", "6", "7", "8", "9":
+		return m.switchTab(int(key[0] - '1'))
+	case "ctrl+w":
+		return m.closeShownTab()
+	case ":":
+		m.openCustomCommands()
+	case "+":
+		return m.openPlugins()
+	default:
+		if command, ok := m.customCommandForKey(key); ok {
+			return m.runCustomCommand(command)
+		}
+		if p, ok := m.pluginForKey(key); ok {
+			return m.openPlugin(p)
+		}
+	}
+	return nil
+}
+
+// navigationDelta maps a navigation key to a movement delta for a list or
+// scrollable panel showing page lines at a time
+func navigationDelta(key string, page int) (int, bool) {
+	switch key {
+	case "up", "k":
+		return -1, true
+	case "down", "j":
+		return 1, true
+	case "ctrl+u":
+		return -max(page/2, 1), true
+	case "ctrl+d":
+		return max(page/2, 1), true
+	case "pgup":
+		return -max(page, 1), true
+	case "pgdown":
+		return max(page, 1), true
+	case "home":
+		return math.MinInt32, true
+	case "end", "G":
+		return math.MaxInt32, true
+	}
+	return 0, false
+}
+
+// moveIndex applies delta to a list index, clamping it to [0, n)
+func moveIndex(idx, delta, n int) int {
+	if n <= 0 {
+		return 0
+	}
+	return max(0, min(idx+delta, n-1))
+}
+
+// resolveKeySequence turns "gg" into a jump to the top. It reports pending
+// when the key only starts a sequence and should not be handled yet.
+func (m *model) resolveKeySequence(key string) (string, bool) {
+	if key != "g" {
+		m.pendingG = false
+		return key, false
+	}
+	if m.pendingG {
+		m.pendingG = false
+		return "home", false
+	}
+	m.pendingG = true
+	return key, true
+}
+
+// pageSize returns how many lines the active panel shows at once,
+// mirroring the split used by renderContent
+func (m model) pageSize() int {
+	height := m.contentHeight(m.renderHelp())
+	switch {
+	case m.currentMode == historyMode && m.activePanel == bottomPanel:
+		height = height - height*30/100
+	case m.currentMode == historyMode:
+		// Commit list spans the full height
+	case m.currentMode == filesMode || m.currentMode == treeMode || m.currentMode == incomingMode:
+		if m.activePanel == topPanel {
+			height = height * 2 / 5
+		} else {
+			height = height - height*2/5
+		}
+	default:
+		if m.activePanel == topPanel {
+			height = height * 2 / 3
+		} else {
+			height = height - height*2/3
+		}
+	}
+	return max(height-3, 1) // border and title
+}
+
+// scrollContent scrolls the diff or file content shown in the bottom panel
+func (m *model) scrollContent(delta int) {
+	var lines int
+	switch m.currentMode {
+	case treeMode:
+		lines = strings.Count(m.treeFileContent, "\n")
+	case filesMode, historyMode, incomingMode:
+		lines = len(strings.Split(m.currentDiff, "\n"))
+	case taskMode:
+		if m.task == nil {
+			return
+		}
+		if m.taskFollow {
+			// Scroll from where the followed output is shown
+			m.diffScrollOffset = max(len(m.task.lines)-m.pageSize(), 0)
+		}
+		lines = len(m.task.lines)
+	default:
+		return
+	}
+	maxScroll := max(lines-10, 0) // Keep some buffer
+	m.diffScrollOffset = max(0, min(m.diffScrollOffset+delta, maxScroll))
+	if m.currentMode == taskMode {
+		// Back at the end, new output is followed again
+		m.taskFollow = m.diffScrollOffset+m.pageSize() >= lines
+	}
+}
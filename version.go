@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/asbjornb/kvist/forge"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Set at release time with -ldflags "-X main.version=v1.2.0 -X main.commit=...
+// -X main.date=..."; otherwise taken from the Go build information
+var (
+	version = ""
+	commit  = ""
+	date    = ""
+)
+
+// releaseRepo is where new versions are published
+const releaseRepo = "asbjornb/kvist"
+
+// buildVersion returns the version, commit and build date of this binary
+func buildVersion() (v, rev, built string) {
+	v, rev, built = version, commit, date
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return orDev(v), rev, built
+	}
+	if v == "" && info.Main.Version != "(devel)" {
+		v = info.Main.Version
+	}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if rev == "" {
+				rev = setting.Value
+			}
+		case "vcs.time":
+			if built == "" {
+				built = setting.Value
+			}
+		}
+	}
+	if len(rev) > 7 {
+		rev = rev[:7]
+	}
+	return orDev(v), rev, built
+}
+
+func orDev(v string) string {
+	if v == "" {
+		return "dev"
+	}
+	return v
+}
+
+// versionString describes this build, e.g. "kvist v1.2.0 (abc1234, 2026-01-02)"
+func versionString() string {
+	v, rev, built := buildVersion()
+	var details []string
+	if rev != "" {
+		details = append(details, rev)
+	}
+	if built != "" {
+		details = append(details, built)
+	}
+	if len(details) == 0 {
+		return "kvist " + v
+	}
+	return fmt.Sprintf("kvist %s (%s)", v, strings.Join(details, ", "))
+}
+
+// updateAvailableMsg reports a release newer than this build
+type updateAvailableMsg struct {
+	release forge.Release
+}
+
+// checkForUpdate looks for a newer release when the config opts in.
+// Development builds have nothing to compare with and never check.
+func (m *model) checkForUpdate() tea.Cmd {
+	if m.workspaceConfig == nil || !m.workspaceConfig.CheckForUpdates || m.safeMode || m.offline {
+		return nil
+	}
+	current, _, _ := buildVersion()
+	if current == "dev" {
+		return nil
+	}
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		release, err := forge.LatestRelease(ctx, releaseRepo)
+		if err != nil || !release.NewerThan(current) {
+			return nil // not worth bothering anyone about
+		}
+		return updateAvailableMsg{release: release}
+	}
+}
@@ -0,0 +1,454 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/asbjornb/kvist/git"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// shortHash abbreviates a full commit hash for display
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
+
+func (m model) View() string {
+	if !m.ready {
+		return "\n  Initializing..."
+	}
+
+	if m.err != nil {
+		errView := fmt.Sprintf("\n  Error: %v\n\n  Make sure you're in a git repository.\n  Press L to view the message log.\n", m.err)
+		if configErr := m.configError(); configErr != nil {
+			errView = m.renderConfigError(configErr)
+		}
+		if m.showingModal && m.modalMode == logModal {
+			return m.renderModalOverlay(errView)
+		}
+		return errView
+	}
+
+	// In workspace modes, we don't need a repo loaded
+	if m.currentMode != workspaceMode && m.currentMode != workspaceManageMode {
+		if m.repo == nil {
+			if m.loadingRepo {
+				return "\n  Loading repository..."
+			} else {
+				return "\n  No repository loaded"
+			}
+		}
+
+		// For history mode, we need commits loaded
+		if m.currentMode == historyMode && m.commits == nil && m.loadingMetadata {
+			return "\n  Loading commit history..."
+		}
+	}
+
+	header := m.renderHeader()
+	help := m.renderHelp()
+
+	contentHeight := m.contentHeight(help)
+
+	var result string
+	if m.tourActive {
+		tour := m.renderTourCard()
+		content := m.renderContent(contentHeight - lipgloss.Height(tour))
+		result = lipgloss.JoinVertical(lipgloss.Top, header, content, tour, help)
+	} else if m.showHUD {
+		hud := m.renderHUD(m.width)
+		content := m.renderContent(contentHeight - lipgloss.Height(hud))
+		result = lipgloss.JoinVertical(lipgloss.Top, header, content, hud, help)
+	} else {
+		content := m.renderContent(contentHeight)
+		result = lipgloss.JoinVertical(lipgloss.Top, header, content, help)
+	}
+
+	// Show branch menu overlay
+	if m.showingBranchMenu {
+		return m.renderBranchMenuOverlay(result)
+	}
+
+	// Show branch creation prompt overlay
+	if m.creatingBranch {
+		promptStyle := lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("170")).
+			Background(lipgloss.Color("235")).
+			Padding(1).
+			Margin(1)
+
+		prompt := fmt.Sprintf("Create new branch: %s█", m.branchInput)
+		if m.branchStartPoint != "" {
+			prompt = fmt.Sprintf("Create new branch at %s: %s█", shortHash(m.branchStartPoint), m.branchInput)
+		}
+		checkout := "[ ]"
+		if m.branchCheckout {
+			checkout = "[x]"
+		}
+		promptHelp := "Enter: create • Tab: " + checkout + " check out • Esc: cancel"
+
+		overlay := promptStyle.Render(prompt + "\n" + promptHelp)
+
+		// Position overlay in center
+		overlayHeight := 5
+		overlayTop := (m.height - overlayHeight) / 2
+
+		return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Top, result) +
+			lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Top,
+				strings.Repeat("\n", overlayTop)+overlay)
+	}
+
+	// Show modal overlay
+	if m.showingModal {
+		return m.renderModalOverlay(result)
+	}
+
+	return result
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// branchLabel names the checked out branch, or the commit when HEAD is detached
+func (m model) branchLabel() string {
+	if m.repo.CurrentBranch != "" {
+		return m.repo.CurrentBranch
+	}
+	if m.repo.DetachedHead != "" {
+		return "detached HEAD @ " + shortHash(m.repo.DetachedHead)
+	}
+	return "detached HEAD"
+}
+
+func (m model) renderHeader() string {
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("170")).
+		MarginLeft(2)
+
+	branchStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("214")).
+		MarginLeft(2)
+
+	title := titleStyle.Render("Kvist")
+	if m.safeMode {
+		title += lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Bold(true).Render("  [SAFE MODE]")
+	}
+	if m.offline {
+		title += lipgloss.NewStyle().Foreground(lipgloss.Color("214")).Bold(true).Render("  [OFFLINE: ahead/behind may be stale]")
+	}
+	title += m.renderTabBar()
+	repo := ""
+	mode := ""
+	if m.repo != nil {
+		branchName := m.repo.CurrentBranch
+		statusInfo := ""
+
+		// Add ahead/behind indicators if available
+		if m.branches != nil {
+			for _, branch := range m.branches {
+				if branch.IsCurrent && (branch.Ahead > 0 || branch.Behind > 0) {
+					indicators := ""
+					if branch.Ahead > 0 {
+						indicators += fmt.Sprintf("↑%d", branch.Ahead)
+					}
+					if branch.Behind > 0 {
+						if indicators != "" {
+							indicators += " "
+						}
+						indicators += fmt.Sprintf("↓%d", branch.Behind)
+					}
+					if indicators != "" {
+						statusInfo += " [origin: " + indicators + "]"
+					}
+					break
+				}
+			}
+
+			// Add ahead/behind vs main if not on main
+			if branchName != "main" && branchName != "master" {
+				ahead, behind, ok := git.GetAheadBehindBranch(m.repo.Path, "main")
+				if !ok {
+					// Try master if main doesn't exist
+					ahead, behind, ok = git.GetAheadBehindBranch(m.repo.Path, "master")
+				}
+				if ok && (ahead > 0 || behind > 0) {
+					mainIndicators := ""
+					if ahead > 0 {
+						mainIndicators += fmt.Sprintf("↑%d", ahead)
+					}
+					if behind > 0 {
+						if mainIndicators != "" {
+							mainIndicators += " "
+						}
+						mainIndicators += fmt.Sprintf("↓%d", behind)
+					}
+					if mainIndicators != "" {
+						statusInfo += " [main: " + mainIndicators + "]"
+					}
+				}
+			}
+		} else if m.loadingMetadata {
+			statusInfo = " (loading...)"
+		}
+
+		repo = fmt.Sprintf("📁 %s  🌿 %s%s", m.repo.Name, m.branchLabel(), statusInfo)
+		if m.currentMode == historyMode {
+			mode = "  [History Mode]"
+		} else if m.currentMode == treeMode {
+			mode = fmt.Sprintf("  [Tree @ %s]", shortHash(m.treeRev))
+		} else if m.currentMode == todoMode {
+			mode = "  [TODOs]"
+		} else if m.currentMode == incomingMode {
+			mode = "  [Incoming]"
+		} else if m.currentMode == statsMode {
+			mode = "  [Stats]"
+		} else if m.currentMode == sizeMode {
+			mode = "  [Sizes]"
+		} else if m.currentMode == taskMode {
+			mode = "  [Tasks]"
+		} else if m.currentMode == pluginMode {
+			mode = "  [" + m.plugin.Name + "]"
+		} else if m.currentMode == compareMode {
+			mode = "  [Compare with " + m.compareName(1) + "]"
+		} else if m.currentMode == changelogMode {
+			mode = "  [Changelog]"
+		} else {
+			mode = "  [Files Mode]"
+		}
+	}
+	repoInfo := branchStyle.Render(repo + mode)
+	repoInfo += m.largeRepoBanner()
+	if m.repo != nil && m.repo.CurrentBranch == "" {
+		// Commits made now belong to no branch
+		repoInfo += lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Render("  ⚠ b: create a branch to keep new commits")
+	}
+	if m.repo != nil && len(m.commits) > 0 {
+		// CI state of HEAD
+		if badge := m.ciBadge(m.commits[0].Hash); badge != "" {
+			repoInfo += "  CI " + badge
+		}
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Top, title, repoInfo, "")
+}
+
+// contentHeight returns the height left for the panels between the header
+// and help. The help grows with the status and version lines, so it is
+// measured; the content keeps two lines of slack below it as it always has.
+func (m model) contentHeight(help string) int {
+	headerHeight := 3
+	helpHeight := lipgloss.Height(help) + 2
+	return m.height - headerHeight - helpHeight
+}
+
+func (m model) renderContent(height int) string {
+	// Two-panel vertical layout with mode-specific splits
+	var topHeight, bottomHeight int
+
+	// Files and tree mode: give more space to diff/content (bottom panel)
+	// Other modes: balanced split
+	if m.currentMode == filesMode || m.currentMode == treeMode || m.currentMode == incomingMode {
+		topHeight = height * 2 / 5      // 40% for file list
+		bottomHeight = height - topHeight // 60% for diff
+	} else {
+		topHeight = height * 2 / 3      // 66% for top panel
+		bottomHeight = height - topHeight // 33% for bottom panel
+	}
+
+	// Content depends on current mode
+	if m.currentMode == historyMode {
+		// 3-panel layout for history mode: left (commits) | top-right (details) / bottom-right (diff)
+		leftWidth := m.width * 40 / 100      // 40% for commit list
+		rightWidth := m.width - leftWidth     // 60% for right side
+		rightTopHeight := height * 30 / 100   // 30% of total height for commit details
+		rightBottomHeight := height - rightTopHeight // 70% for diff
+
+		left := m.renderCommits(leftWidth, height)
+		topRight := m.renderCommitDetails(rightWidth, rightTopHeight)
+		bottomRight := m.renderCommitDiff(rightWidth, rightBottomHeight)
+
+		// Stack right panels vertically
+		rightSide := lipgloss.JoinVertical(lipgloss.Top, topRight, bottomRight)
+
+		// Join left and right horizontally
+		return lipgloss.JoinHorizontal(lipgloss.Top, left, rightSide)
+	}
+
+	// 2-panel vertical layout for other modes
+	var top, bottom string
+	if m.currentMode == workspaceMode {
+		top = m.renderWorkspaces(m.width, topHeight)
+		bottom = m.renderRepoDetails(m.width, bottomHeight)
+	} else if m.currentMode == workspaceManageMode {
+		top = m.renderWorkspaceManager(m.width, topHeight)
+		bottom = m.renderWorkspaceHelp(m.width, bottomHeight)
+	} else if m.currentMode == treeMode {
+		top = m.renderTree(m.width, topHeight)
+		bottom = m.renderTreeFile(m.width, bottomHeight)
+	} else if m.currentMode == todoMode {
+		top = m.renderTodos(m.width, topHeight)
+		bottom = m.renderTodoDetails(m.width, bottomHeight)
+	} else if m.currentMode == incomingMode {
+		top = m.renderIncoming(m.width, topHeight)
+		bottom = m.renderCommitDiff(m.width, bottomHeight)
+	} else if m.currentMode == statsMode {
+		top = m.renderStats(m.width, topHeight)
+		bottom = m.renderStatsFiles(m.width, bottomHeight)
+	} else if m.currentMode == sizeMode {
+		top = m.renderSizes(m.width, topHeight)
+		bottom = m.renderSizeSummary(m.width, bottomHeight)
+	} else if m.currentMode == taskMode {
+		top = m.renderTasks(m.width, topHeight)
+		bottom = m.renderTaskOutput(m.width, bottomHeight)
+	} else if m.currentMode == pluginMode {
+		top = m.renderPluginPanel(m.width, topHeight)
+		bottom = m.renderPluginActions(m.width, bottomHeight)
+	} else if m.currentMode == compareMode {
+		top = m.renderCompare(m.width, topHeight)
+		bottom = m.renderCompareSummary(m.width, bottomHeight)
+	} else if m.currentMode == changelogMode {
+		top = m.renderChangelog(m.width, topHeight)
+		bottom = m.renderChangelogSummary(m.width, bottomHeight)
+	} else { // filesMode
+		top = m.renderFiles(m.width, topHeight)
+		bottom = m.renderFileDiff(m.width, bottomHeight)
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Top, top, bottom)
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func (m model) renderHelp() string {
+	helpStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241")).
+		MarginLeft(2)
+
+	statusStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("214")).
+		Bold(true).
+		MarginLeft(2)
+
+	var helpLines []string
+	if m.width < 80 {
+		// Compact help for narrow terminals
+		helpLines = []string{
+			"tab: panels • ↑↓/jk: nav • space: stage • w: workspace/manage • h: history • s: files",
+			"b: branches • -: recent branches • f: fetch • p: pull • P: push • r: refresh • u: undo • y: copy • o: open • O: browser • R: PRs • T: TODOs • I: incoming • :: commands • !: tasks • +: plugins • 1-9: tabs • ctrl+w: close tab • =: compare • W: changelog • @: signing • %: stats • Z: sizes • N: offline • L: log • q: quit",
+		}
+	} else {
+		helpLines = []string{
+			"tab: switch panel • ↑↓/jk: navigate • gg/G: top/bottom • ^u/^d/pgup/pgdn: page • space/enter: stage/checkout",
+			"w: workspace/manage • h: history mode • s: files mode • b: branches • -: recent branches • f: fetch • p: pull • P: push • r: refresh • u: undo • y: copy • o: open • O: browser • R: PRs • T: TODOs • I: incoming • :: custom commands • !: tasks • +: plugins • 1-9: tabs • ctrl+w: close tab • =: compare • W: changelog • @: signing • %: stats • Z: sizes • N: offline • L: log • q: quit",
+		}
+	}
+	switch m.currentMode {
+	case historyMode:
+		helpLines[0] += " • t: browse tree at commit • v: view file at commit • n: branch from commit • C: checkout commit • B: transplant commits after this onto… • J/K: select range • x: range actions • A: archive/bundle • ^g: go to commit • E: export HTML"
+	case filesMode:
+		helpLines[0] += " • c: commit • C: commit without hooks • e: edit • v: view committed version • H: restore from commit • i: ignore • U: untracked/ignored • M: renames on/off • S: stash • z: apply from stash • t: tree/list • ←/→: collapse/expand • X: clean • E: export HTML"
+	case todoMode:
+		helpLines[0] = "↑↓/jk: navigate • enter: open in editor • r: rescan • T/esc: back to files"
+	case incomingMode:
+		helpLines[0] = "tab: switch panel • ↑↓/jk: navigate • p: pull • f: fetch • r: reload • I/esc: back to history"
+	case statsMode:
+		helpLines[0] = "r: reload • %/esc: back"
+	case sizeMode:
+		helpLines[0] = "↑↓/jk: navigate • y: copy path • r: reload • Z/esc: back"
+	case pluginMode:
+		helpLines[0] = "↑↓/jk: scroll • r: reload • +/esc: back"
+	case compareMode:
+		helpLines[0] = "tab: switch side • ↑↓/jk: navigate • v: commits/changed files • x: swap sides • r: reload • =/esc: back"
+	case changelogMode:
+		helpLines[0] = "↑↓/jk: scroll • a: by type/author • y: copy Markdown • e: export to CHANGELOG.md • t: other release • r: reload • W/esc: back"
+	case taskMode:
+		helpLines[0] = "tab: switch panel • ↑↓/jk: navigate/scroll • enter: run task • R: run again • x: stop • r: rescan • !/esc: back"
+	case treeMode:
+		helpLines[0] = "tab: switch panel • ↑↓/jk: navigate • enter: open dir • backspace: up • H: restore file from here • t/esc: back"
+	}
+
+	// Point at the log when warnings or errors arrived in the background
+	if m.log.unseen > 0 {
+		logStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+		helpLines[0] += " • " + logStyle.Render(fmt.Sprintf("⚠ %d new in log (L)", m.log.unseen))
+	}
+
+	versionLine := versionString()
+	if m.latestRelease.Tag != "" {
+		updateStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
+		versionLine += " • " + updateStyle.Render("⬆ new version available: "+m.latestRelease.Tag)
+	}
+	helpLines = append(helpLines, versionLine)
+
+	// Add branch status line if we have a repo loaded
+	var statusLine string
+	if m.repo != nil {
+		branchName := m.repo.CurrentBranch
+		statusInfo := ""
+
+		// Add ahead/behind indicators if available
+		if m.branches != nil {
+			for _, branch := range m.branches {
+				if branch.IsCurrent && (branch.Ahead > 0 || branch.Behind > 0) {
+					indicators := ""
+					if branch.Ahead > 0 {
+						indicators += fmt.Sprintf("↑%d", branch.Ahead)
+					}
+					if branch.Behind > 0 {
+						if indicators != "" {
+							indicators += " "
+						}
+						indicators += fmt.Sprintf("↓%d", branch.Behind)
+					}
+					if indicators != "" {
+						statusInfo += " [origin: " + indicators + "]"
+					}
+					break
+				}
+			}
+
+			// Add ahead/behind vs main if not on main
+			if branchName != "main" && branchName != "master" {
+				ahead, behind, ok := git.GetAheadBehindBranch(m.repo.Path, "main")
+				if !ok {
+					// Try master if main doesn't exist
+					ahead, behind, ok = git.GetAheadBehindBranch(m.repo.Path, "master")
+				}
+				if ok && (ahead > 0 || behind > 0) {
+					mainIndicators := ""
+					if ahead > 0 {
+						mainIndicators += fmt.Sprintf("↑%d", ahead)
+					}
+					if behind > 0 {
+						if mainIndicators != "" {
+							mainIndicators += " "
+						}
+						mainIndicators += fmt.Sprintf("↓%d", behind)
+					}
+					if mainIndicators != "" {
+						statusInfo += " [main: " + mainIndicators + "]"
+					}
+				}
+			}
+		}
+
+		statusLine = statusStyle.Render(fmt.Sprintf("📁 %s  🌿 %s%s", m.repo.Name, m.branchLabel(), statusInfo))
+		return lipgloss.JoinVertical(lipgloss.Top, statusLine, helpStyle.Render(strings.Join(helpLines, "\n")))
+	}
+
+	return helpStyle.Render(strings.Join(helpLines, "\n"))
+}
@@ -0,0 +1,181 @@
+package workspace
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// lockWait is how long Save waits for another instance to finish saving
+	lockWait = 2 * time.Second
+	// lockStale is the age after which a lock file is assumed abandoned
+	lockStale = 10 * time.Second
+)
+
+// lockFile takes an exclusive lock by creating path, waiting while another
+// holder has it. The file names this process and a token of its own, so
+// the lock is only ever released by whoever took it. Locks left behind by
+// processes that are gone, or older than lockStale, are taken over. The
+// returned function releases the lock.
+func lockFile(path string) (unlock func(), err error) {
+	owner := lockOwner()
+	deadline := time.Now().Add(lockWait)
+	for {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, err = file.WriteString(owner)
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(path)
+				return nil, err
+			}
+			return func() { removeLock(path, owner) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if holder, ok := lockAbandoned(path); ok {
+			// Only the abandoned lock goes, not one taken over meanwhile
+			removeLock(path, holder)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s is held by another kvist", path)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// lockOwner is what a lock file holds: the pid of the process taking it,
+// and a random token telling this lock apart from others the process or a
+// later one with the same pid takes
+func lockOwner() string {
+	token := make([]byte, 8)
+	rand.Read(token)
+	return fmt.Sprintf("%d %s\n", os.Getpid(), hex.EncodeToString(token))
+}
+
+// removeLock removes the lock file at path if it still holds owner
+func removeLock(path, owner string) {
+	if data, err := os.ReadFile(path); err == nil && string(data) == owner {
+		os.Remove(path)
+	}
+}
+
+// lockAbandoned reports whether the lock file at path was left by a
+// process that is no longer running or has been held for too long, and
+// returns what it holds. A pid that has been reused keeps the lock only
+// until lockStale.
+func lockAbandoned(path string) (string, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", false // released meanwhile; just retry
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	holder := string(data)
+	if time.Since(info.ModTime()) > lockStale {
+		return holder, true
+	}
+	pidField, _, ok := strings.Cut(strings.TrimSpace(holder), " ")
+	if !ok {
+		return "", false // still being written
+	}
+	pid, err := strconv.Atoi(pidField)
+	if err != nil {
+		return "", false
+	}
+	return holder, pid != os.Getpid() && !processAlive(pid)
+}
+
+// clone copies the cache deeply enough that later changes to rc don't show
+// in the copy
+func (rc *RepoCache) clone() *RepoCache {
+	return &RepoCache{
+		Version:       rc.Version,
+		Repos:         maps.Clone(rc.Repos),
+		LastRepoPath:  rc.LastRepoPath,
+		LastWorkspace: rc.LastWorkspace,
+		RecentRepos:   slices.Clone(rc.RecentRepos),
+		Notes:         maps.Clone(rc.Notes),
+		Pinned:        maps.Clone(rc.Pinned),
+		Archived:      maps.Clone(rc.Archived),
+		Assigned:      maps.Clone(rc.Assigned),
+		LargeRepos:    maps.Clone(rc.LargeRepos),
+		Views:         maps.Clone(rc.Views),
+	}
+}
+
+// merge folds in disk, the cache as another instance saved it: whatever
+// this instance changed since its base wins, everything else is taken
+// from disk
+func (rc *RepoCache) merge(disk *RepoCache) {
+	base := rc.base
+	if base == nil {
+		base = &RepoCache{}
+	}
+	rc.Repos = mergeMap(base.Repos, rc.Repos, disk.Repos)
+	if rc.Repos == nil {
+		rc.Repos = make(map[string]RepoInfo)
+	}
+	rc.Notes = mergeMap(base.Notes, rc.Notes, disk.Notes)
+	rc.Pinned = mergeMap(base.Pinned, rc.Pinned, disk.Pinned)
+	rc.Archived = mergeMap(base.Archived, rc.Archived, disk.Archived)
+	rc.Assigned = mergeMap(base.Assigned, rc.Assigned, disk.Assigned)
+	rc.LargeRepos = mergeMap(base.LargeRepos, rc.LargeRepos, disk.LargeRepos)
+	rc.Views = mergeMap(base.Views, rc.Views, disk.Views)
+	rc.LastRepoPath = mergeValue(base.LastRepoPath, rc.LastRepoPath, disk.LastRepoPath)
+	rc.LastWorkspace = mergeValue(base.LastWorkspace, rc.LastWorkspace, disk.LastWorkspace)
+	rc.RecentRepos = mergeValue(base.RecentRepos, rc.RecentRepos, disk.RecentRepos)
+}
+
+// mergeMap is a three-way merge of map entries: entries ours added, changed
+// or removed since base are applied on top of theirs
+func mergeMap[V any](base, ours, theirs map[string]V) map[string]V {
+	merged := maps.Clone(theirs)
+	apply := func(key string) {
+		before, wasThere := base[key]
+		now, isThere := ours[key]
+		if wasThere == isThere && (!isThere || reflect.DeepEqual(before, now)) {
+			return // unchanged here
+		}
+		if isThere {
+			if merged == nil {
+				merged = make(map[string]V)
+			}
+			merged[key] = now
+		} else {
+			delete(merged, key)
+		}
+	}
+	for key := range base {
+		apply(key)
+	}
+	for key := range ours {
+		if _, ok := base[key]; !ok {
+			apply(key)
+		}
+	}
+	return merged
+}
+
+// mergeValue keeps ours if it changed since base, and theirs otherwise
+func mergeValue[V any](base, ours, theirs V) V {
+	if reflect.DeepEqual(base, ours) {
+		return theirs
+	}
+	return ours
+}
@@ -0,0 +1,89 @@
+package workspace
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// CustomCommand is a shell command of the user's, run in a repository from
+// the custom command menu or by its key
+type CustomCommand struct {
+	// Name is shown in the menu and the operation log
+	Name string `yaml:"name"`
+	// Command runs through the shell in the repository. {{repoPath}},
+	// {{selectedFile}}, {{commitHash}} and {{branch}} stand for the current
+	// selection, quoted for the shell.
+	Command string `yaml:"command"`
+	// Key runs the command directly; keys kvist already uses keep their meaning
+	Key string `yaml:"key,omitempty"`
+	// Confirm asks before running the command
+	Confirm bool `yaml:"confirm,omitempty"`
+}
+
+// CommandPlaceholders are the names custom commands can use in {{...}}
+var CommandPlaceholders = []string{"repoPath", "selectedFile", "commitHash", "branch"}
+
+var (
+	placeholderPattern = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+	// plainWord matches what a POSIX shell takes as is
+	plainWord = regexp.MustCompile(`^[A-Za-z0-9_:./@=-]+$`)
+)
+
+// Placeholders returns the placeholder names in the command, in order
+func (c CustomCommand) Placeholders() []string {
+	var names []string
+	for _, match := range placeholderPattern.FindAllStringSubmatch(c.Command, -1) {
+		names = append(names, match[1])
+	}
+	return names
+}
+
+// Expand replaces the placeholders of the command with values, each passed
+// through quote. It fails when the command needs a value that is empty,
+// e.g. {{commitHash}} with no commit selected.
+func (c CustomCommand) Expand(values map[string]string, quote func(string) string) (string, error) {
+	var missing error
+	expanded := placeholderPattern.ReplaceAllStringFunc(c.Command, func(match string) string {
+		name := placeholderPattern.FindStringSubmatch(match)[1]
+		value := values[name]
+		if value == "" {
+			if missing == nil {
+				missing = fmt.Errorf("%s needs {{%s}}, but there is none here", c.Name, name)
+			}
+			return match
+		}
+		return quote(value)
+	})
+	if missing != nil {
+		return "", missing
+	}
+	return expanded, nil
+}
+
+// ShellQuote quotes s as a single argument for the shell commands run
+// through: cmd on Windows, sh elsewhere, where words the shell takes as is
+// are left alone
+func ShellQuote(s string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+	}
+	if plainWord.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Plugin is an external program that shows a panel of its own in kvist,
+// told what is selected and answering with lines and actions as JSON over
+// its standard input and output
+type Plugin struct {
+	// Name is shown in the plugin menu and above the panel
+	Name string `yaml:"name"`
+	// Command is the shell command that starts the plugin
+	Command string `yaml:"command"`
+	// Key opens the plugin's panel directly; keys kvist already uses keep
+	// their meaning
+	Key string `yaml:"key,omitempty"`
+}
@@ -0,0 +1,172 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/asbjornb/kvist/git"
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestVersion is the manifest format written by ExportManifest
+const ManifestVersion = 1
+
+// Manifest lists workspaces and the repositories in them with their remotes,
+// enough to recreate the setup on another machine
+type Manifest struct {
+	Version    int                 `yaml:"version"`
+	Workspaces []ManifestWorkspace `yaml:"workspaces"`
+}
+
+// ManifestWorkspace is a workspace in a manifest
+type ManifestWorkspace struct {
+	Name  string         `yaml:"name"`
+	Path  string         `yaml:"path"` // ~ stands for the home directory
+	Repos []ManifestRepo `yaml:"repos,omitempty"`
+}
+
+// ManifestRepo is a repository in a manifest
+type ManifestRepo struct {
+	Path   string `yaml:"path"` // relative to the workspace
+	Remote string `yaml:"remote"`
+}
+
+// ExportManifest describes the configured workspaces and the known repos in
+// them. Repos without a remote can't be recreated elsewhere; their paths are
+// returned as skipped.
+func ExportManifest(config *Config, repos []RepoInfo) (manifest *Manifest, skipped []string) {
+	manifest = &Manifest{Version: ManifestVersion}
+	for _, ws := range config.Workspaces {
+		root := ExpandPath(ws.Path)
+		entry := ManifestWorkspace{Name: ws.Name, Path: contractHome(root)}
+		for _, repo := range repos {
+			if repo.WorkspaceName != ws.Name {
+				continue
+			}
+			rel, err := filepath.Rel(root, repo.Path)
+			if err != nil || !filepath.IsLocal(rel) {
+				continue
+			}
+			remote := originURL(repo.Path)
+			if remote == "" {
+				skipped = append(skipped, repo.Path)
+				continue
+			}
+			entry.Repos = append(entry.Repos, ManifestRepo{Path: filepath.ToSlash(rel), Remote: remote})
+		}
+		sort.Slice(entry.Repos, func(i, j int) bool { return entry.Repos[i].Path < entry.Repos[j].Path })
+		manifest.Workspaces = append(manifest.Workspaces, entry)
+	}
+	return manifest, skipped
+}
+
+// Marshal returns the manifest as YAML
+func (m *Manifest) Marshal() ([]byte, error) {
+	return yaml.Marshal(m)
+}
+
+// ParseManifest reads a manifest written by ExportManifest
+func ParseManifest(data []byte) (*Manifest, error) {
+	var manifest Manifest
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if manifest.Version > ManifestVersion {
+		return nil, fmt.Errorf("manifest version %d is newer than this kvist understands (%d)", manifest.Version, ManifestVersion)
+	}
+	return &manifest, nil
+}
+
+// ImportManifest adds the manifest's workspaces that config lacks and clones
+// the repositories that aren't on disk yet; existing ones are left alone,
+// and ones whose path leads out of their workspace are refused.
+// A workspace already configured under the same name keeps its local path.
+// progress receives a line per step and git's clone progress. Failed clones
+// don't stop the import; their errors are returned together at the end.
+func ImportManifest(config *Config, manifest *Manifest, progress func(line string)) (cloned int, err error) {
+	if progress == nil {
+		progress = func(string) {}
+	}
+	var errs []error
+	for _, entry := range manifest.Workspaces {
+		root := ExpandPath(entry.Path)
+		if ws := config.workspaceNamed(entry.Name); ws != nil {
+			root = ExpandPath(ws.Path)
+		} else {
+			if err := os.MkdirAll(root, 0755); err != nil {
+				return cloned, fmt.Errorf("failed to create workspace %s: %w", entry.Name, err)
+			}
+			if err := config.AddWorkspace(entry.Name, root); err != nil {
+				return cloned, err
+			}
+			progress(fmt.Sprintf("added workspace %s at %s", entry.Name, root))
+		}
+
+		for _, repo := range entry.Repos {
+			if !filepath.IsLocal(filepath.FromSlash(repo.Path)) {
+				errs = append(errs, fmt.Errorf("%s: not a path inside workspace %s", repo.Path, entry.Name))
+				continue
+			}
+			dest := filepath.Join(root, filepath.FromSlash(repo.Path))
+			if _, err := os.Stat(dest); err == nil {
+				continue
+			}
+			if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", repo.Path, err))
+				continue
+			}
+			progress(fmt.Sprintf("cloning %s into %s", repo.Remote, dest))
+			if err := git.Clone(filepath.Dir(dest), repo.Remote, filepath.Base(dest), progress); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", repo.Path, err))
+				continue
+			}
+			cloned++
+		}
+	}
+	return cloned, errors.Join(errs...)
+}
+
+// workspaceNamed returns the workspace called name, or nil
+func (c *Config) workspaceNamed(name string) *Workspace {
+	for i := range c.Workspaces {
+		if c.Workspaces[i].Name == name {
+			return &c.Workspaces[i]
+		}
+	}
+	return nil
+}
+
+// originURL returns the fetch URL of the origin remote, or of the first
+// remote when there is no origin
+func originURL(repoPath string) string {
+	remotes, err := git.GetRemotes(repoPath)
+	if err != nil || len(remotes) == 0 {
+		return ""
+	}
+	for _, remote := range remotes {
+		if remote.Name == "origin" {
+			return remote.FetchURL
+		}
+	}
+	return remotes[0].FetchURL
+}
+
+// contractHome writes paths under the home directory with ~, so manifests
+// work for a different user name
+func contractHome(path string) string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return path
+	}
+	if path == home {
+		return "~"
+	}
+	if rel, err := filepath.Rel(home, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return "~/" + filepath.ToSlash(rel)
+	}
+	return path
+}
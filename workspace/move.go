@@ -0,0 +1,229 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/asbjornb/kvist/git"
+)
+
+// AssignRepo lists the repository at repoPath under workspaceName from now
+// on, wherever scans find it. An empty workspaceName drops the assignment,
+// returning the repository to the workspace that contains it.
+func (s *Scanner) AssignRepo(repoPath, workspaceName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if workspaceName == "" {
+		delete(s.cache.Assigned, repoPath)
+		if ws := s.config.WorkspaceForRepo(repoPath); ws != nil {
+			workspaceName = ws.Name
+		}
+	} else {
+		if s.cache.Assigned == nil {
+			s.cache.Assigned = make(map[string]string)
+		}
+		s.cache.Assigned[repoPath] = workspaceName
+	}
+	if repo, ok := s.cache.Repos[repoPath]; ok {
+		repo.WorkspaceName = workspaceName
+		s.cache.Repos[repoPath] = repo
+	}
+}
+
+// AssignedWorkspace returns the workspace repoPath was assigned to by hand, or ""
+func (s *Scanner) AssignedWorkspace(repoPath string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cache.Assigned[repoPath]
+}
+
+// keepsAssigned reports whether a rescan of the workspace the repository
+// is listed under should keep it although it lies elsewhere: it was
+// assigned there by hand and still exists. Callers hold s.mu.
+func (s *Scanner) keepsAssigned(repoPath string) bool {
+	if s.cache.Assigned[repoPath] == "" {
+		return false
+	}
+	_, err := os.Stat(repoPath)
+	return err == nil
+}
+
+// MoveRepo moves the repository directory at oldPath to newPath, repairs
+// its linked worktrees and carries its cache entry, note, pin, archived
+// mark and assignment over, listing it under workspaceName
+func (s *Scanner) MoveRepo(oldPath, newPath, workspaceName string) error {
+	if _, err := os.Stat(newPath); err == nil {
+		return fmt.Errorf("%s already exists", newPath)
+	}
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(oldPath, newPath); err != nil {
+		if errors.Is(err, syscall.EXDEV) {
+			return fmt.Errorf("can't move %s to another file system; move it by hand and rescan", filepath.Base(oldPath))
+		}
+		return err
+	}
+	// Worktrees record where their main repository is, and the other way round
+	_ = git.RepairWorktrees(newPath)
+
+	s.mu.Lock()
+	repo := s.cache.Repos[oldPath]
+	delete(s.cache.Repos, oldPath)
+	if note, ok := s.cache.Notes[oldPath]; ok {
+		delete(s.cache.Notes, oldPath)
+		s.cache.Notes[newPath] = note
+	}
+	for _, marks := range []map[string]bool{s.cache.Pinned, s.cache.Archived, s.cache.LargeRepos} {
+		if marks[oldPath] {
+			delete(marks, oldPath)
+			marks[newPath] = true
+		}
+	}
+	if view, ok := s.cache.Views[oldPath]; ok {
+		delete(s.cache.Views, oldPath)
+		s.cache.Views[newPath] = view
+	}
+	delete(s.cache.Assigned, oldPath)
+	for i, path := range s.cache.RecentRepos {
+		if path == oldPath {
+			s.cache.RecentRepos[i] = newPath
+		}
+	}
+	if s.cache.LastRepoPath == oldPath {
+		s.cache.LastRepoPath = newPath
+	}
+	s.mu.Unlock()
+
+	repo.Path = newPath
+	repo.Name = filepath.Base(newPath)
+	repo.WorkspaceName = workspaceName
+	repo.Parent = ""
+	s.UpdateCacheRepo(repo)
+	return s.SaveCache()
+}
+
+// ErrUnsavedWork is returned when deleting a repository would lose work
+// that exists nowhere else, or when that can't be ruled out
+var ErrUnsavedWork = errors.New("repository has work that exists only here")
+
+// unsavedWork lists what deleting the repository at repoPath would lose.
+// A check that can't be made is an error, as it can't rule the work out.
+func unsavedWork(repoPath string) ([]string, error) {
+	var reasons []string
+	if kind, _ := RepoKindAt(repoPath); kind != BareRepo {
+		status, err := git.GetStatus(repoPath)
+		if err != nil {
+			return nil, fmt.Errorf("reading the status: %w", err)
+		}
+		if n := len(status.Files); n > 0 {
+			reasons = append(reasons, fmt.Sprintf("%d uncommitted change(s)", n))
+		}
+	}
+	unpushed, err := git.CountUnpushedBranches(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("counting unpushed commits: %w", err)
+	}
+	if unpushed > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d unpushed commit(s)", unpushed))
+	}
+	stashes, err := git.GetStashes(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("listing stashes: %w", err)
+	}
+	if len(stashes) > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d stash(es)", len(stashes)))
+	}
+	worktrees, err := git.Worktrees(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("listing worktrees: %w", err)
+	}
+	if len(worktrees) > 1 {
+		reasons = append(reasons, fmt.Sprintf("%d other worktree(s)", len(worktrees)-1))
+	}
+	nested, err := nestedRepos(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("looking for nested repositories: %w", err)
+	}
+	if len(nested) > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d nested repositories, e.g. %s", len(nested), nested[0]))
+	}
+	return reasons, nil
+}
+
+// nestedRepos returns the repositories inside the one at repoPath,
+// submodules included, without looking inside those
+func nestedRepos(repoPath string) ([]string, error) {
+	var nested []string
+	err := filepath.WalkDir(repoPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Name() != ".git" || path == repoPath {
+			return nil
+		}
+		if dir := filepath.Dir(path); dir != repoPath {
+			nested = append(nested, dir)
+		}
+		if d.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return nested, err
+}
+
+// DeleteRepo removes the repository at repoPath from disk and from the
+// cache. Unless force is set it refuses when the repository has
+// uncommitted changes, stashes, commits on no remote, other worktrees or
+// nested repositories, or when it can't tell. Workspace roots and
+// directories that aren't repositories are never deleted.
+func (s *Scanner) DeleteRepo(repoPath string, force bool) error {
+	if _, ok := RepoKindAt(repoPath); !ok {
+		return fmt.Errorf("%s is not a repository", repoPath)
+	}
+	for _, ws := range s.config.Workspaces {
+		if filepath.Clean(ExpandPath(ws.Path)) == filepath.Clean(repoPath) {
+			return fmt.Errorf("%s is the root of workspace %s", repoPath, ws.Name)
+		}
+	}
+	if !force {
+		reasons, err := unsavedWork(repoPath)
+		if err != nil {
+			return fmt.Errorf("%w: can't tell, %v", ErrUnsavedWork, err)
+		}
+		if len(reasons) > 0 {
+			return fmt.Errorf("%w: %s", ErrUnsavedWork, strings.Join(reasons, ", "))
+		}
+	}
+
+	if err := os.RemoveAll(repoPath); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	delete(s.cache.Repos, repoPath)
+	delete(s.cache.Notes, repoPath)
+	delete(s.cache.Pinned, repoPath)
+	delete(s.cache.Archived, repoPath)
+	delete(s.cache.Assigned, repoPath)
+	delete(s.cache.LargeRepos, repoPath)
+	delete(s.cache.Views, repoPath)
+	recent := s.cache.RecentRepos[:0]
+	for _, path := range s.cache.RecentRepos {
+		if path != repoPath {
+			recent = append(recent, path)
+		}
+	}
+	s.cache.RecentRepos = recent
+	if s.cache.LastRepoPath == repoPath {
+		s.cache.LastRepoPath = ""
+	}
+	s.mu.Unlock()
+	return s.SaveCache()
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+
+package workspace
+
+import (
+	"os"
+	"syscall"
+)
+
+// processAlive reports whether a process with the given pid is running
+func processAlive(pid int) bool {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return proc.Signal(syscall.Signal(0)) == nil
+}
@@ -0,0 +1,29 @@
+//go:build windows
+
+package workspace
+
+import "syscall"
+
+const (
+	// processQueryLimitedInformation is the least access that can read a
+	// process's exit code
+	processQueryLimitedInformation = 0x1000
+	// stillActive is the exit code of a process that hasn't exited
+	stillActive = 259
+)
+
+// processAlive reports whether a process with the given pid is running.
+// Windows can't signal a process, so its exit code is asked for instead.
+func processAlive(pid int) bool {
+	handle, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		// Denied access means it exists, but belongs to someone else
+		return err == syscall.ERROR_ACCESS_DENIED
+	}
+	defer syscall.CloseHandle(handle)
+	var code uint32
+	if err := syscall.GetExitCodeProcess(handle, &code); err != nil {
+		return false
+	}
+	return code == stillActive
+}
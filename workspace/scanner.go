@@ -3,8 +3,10 @@ package workspace
 import (
 	"context"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -60,12 +62,18 @@ func (s *Scanner) ScanWorkspaces(ctx context.Context) <-chan ScanResult {
 			successfulWorkspaces[workspace.Name] = true
 		}
 
+		// A cancelled scan only got part of the repos; replacing the cached
+		// ones with those would lose the rest, so the cache is left alone
+		if ctx.Err() != nil {
+			return
+		}
+
 		// Update cache - only clear repos from successfully scanned workspaces
 		s.mu.Lock()
 
 		// Remove old repos only from successfully scanned workspaces
 		for path, repo := range s.cache.Repos {
-			if successfulWorkspaces[repo.WorkspaceName] {
+			if successfulWorkspaces[repo.WorkspaceName] && !s.keepsAssigned(path) {
 				delete(s.cache.Repos, path)
 			}
 		}
@@ -95,7 +103,8 @@ func (s *Scanner) ScanWorkspaces(ctx context.Context) <-chan ScanResult {
 	return results
 }
 
-// GetCachedRepos returns cached repository information sorted by last commit time
+// GetCachedRepos returns cached repository information, pinned repositories
+// first and otherwise sorted by last commit time
 func (s *Scanner) GetCachedRepos() []RepoInfo {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -108,6 +117,9 @@ func (s *Scanner) GetCachedRepos() []RepoInfo {
 	// Sort by last commit time (most recent first)
 	// Repos without commit time go to the end
 	sort.Slice(repos, func(i, j int) bool {
+		if pi, pj := s.cache.Pinned[repos[i].Path], s.cache.Pinned[repos[j].Path]; pi != pj {
+			return pi
+		}
 		// If both have commit times, sort by most recent first
 		if !repos[i].LastCommitTime.IsZero() && !repos[j].LastCommitTime.IsZero() {
 			return repos[i].LastCommitTime.After(repos[j].LastCommitTime)
@@ -126,70 +138,197 @@ func (s *Scanner) GetCachedRepos() []RepoInfo {
 	return repos
 }
 
+// foundRepo is a repository found in a workspace, and the repository it is
+// nested in, if any
+type foundRepo struct {
+	path    string
+	parent  string
+	project bool // a project directory that isn't a repository
+}
+
 // discoverRepos finds all git repositories in a workspace
-func (s *Scanner) discoverRepos(ctx context.Context, workspace Workspace) ([]string, error) {
-	var repos []string
+func (s *Scanner) discoverRepos(ctx context.Context, workspace Workspace) ([]foundRepo, error) {
+	var repos []foundRepo
+	err := workspace.walkRepos(ctx, func(repo foundRepo) error {
+		repos = append(repos, repo)
+		return nil
+	})
+	return repos, err
+}
+
+// walkRepos calls found with each repository in the workspace as soon as it
+// is seen: work trees, linked worktrees and submodules, and bare repos. It
+// searches MaxDepth levels below the workspace, or DefaultScanDepth, and
+// stops at the first error from found or when ctx ends. Repositories inside
+// another one are reported with it as parent, or left out when NestedRepos
+// is NestedSkip. With ShowProjects, project directories outside any
+// repository are reported too, without looking inside them.
+func (w Workspace) walkRepos(ctx context.Context, found func(repo foundRepo) error) error {
+	maxDepth := w.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultScanDepth
+	}
+	visited := make(map[string]bool) // real paths, so symlink loops end
 
-	err := filepath.Walk(workspace.Path, func(path string, info os.FileInfo, err error) error {
+	var walk func(dir, parent string, depth int) error
+	walk = func(dir, parent string, depth int) error {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		default:
 		}
 
+		if real, err := filepath.EvalSymlinks(dir); err == nil {
+			if visited[real] {
+				return nil
+			}
+			visited[real] = true
+		}
+
+		kind, isRepo := RepoKindAt(dir)
+		if isRepo && (parent == "" || w.NestedRepos != NestedSkip) {
+			if err := found(foundRepo{path: dir, parent: parent}); err != nil {
+				return err
+			}
+		}
+		if !isRepo && parent == "" && w.ShowProjects && dir != w.Path && IsProjectDir(dir) {
+			return found(foundRepo{path: dir, project: true})
+		}
+		if isRepo {
+			if kind == BareRepo {
+				// Its subdirectories are git's own
+				return nil
+			}
+			parent = dir
+		}
+
+		if depth >= maxDepth {
+			return nil
+		}
+		entries, err := os.ReadDir(dir)
 		if err != nil {
 			// Skip directories we can't read
 			return nil
 		}
-
-		// Check if this is a git repository
-		if info.IsDir() && info.Name() == ".git" {
-			repoPath := filepath.Dir(path)
-			repos = append(repos, repoPath)
-			return filepath.SkipDir // Don't scan inside .git directories
+		for _, entry := range entries {
+			path := filepath.Join(dir, entry.Name())
+			if !w.scansInto(path, entry) {
+				continue
+			}
+			if err := walk(path, parent, depth+1); err != nil {
+				return err
+			}
 		}
+		return nil
+	}
+
+	return walk(w.Path, "", 0)
+}
 
-		// Check for git worktree (bare repo)
-		if !info.IsDir() && info.Name() == ".git" {
-			repoPath := filepath.Dir(path)
-			repos = append(repos, repoPath)
+// RepoKindAt reports whether dir is a repository, and which kind. A .git
+// directory marks a work tree; a .git file pointing into another
+// repository's worktrees marks a linked worktree, and any other .git file a
+// submodule. A directory holding HEAD, objects and refs is a bare repo.
+func RepoKindAt(dir string) (RepoKind, bool) {
+	gitPath := filepath.Join(dir, ".git")
+	if info, err := os.Stat(gitPath); err == nil {
+		if info.IsDir() {
+			return WorkTree, true
 		}
+		data, err := os.ReadFile(gitPath)
+		if err != nil {
+			return "", false
+		}
+		gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+		if !ok {
+			return "", false
+		}
+		if strings.Contains(filepath.ToSlash(gitDir), "/worktrees/") {
+			return LinkedWorktree, true
+		}
+		return Submodule, true
+	}
 
-		// Skip hidden directories (except .git which we handle above)
-		if info.IsDir() && strings.HasPrefix(info.Name(), ".") && info.Name() != ".git" {
-			return filepath.SkipDir
+	if info, err := os.Stat(filepath.Join(dir, "HEAD")); err != nil || info.IsDir() {
+		return "", false
+	}
+	for _, sub := range []string{"objects", "refs"} {
+		if info, err := os.Stat(filepath.Join(dir, sub)); err != nil || !info.IsDir() {
+			return "", false
 		}
+	}
+	return BareRepo, true
+}
 
-		// Skip common non-repo directories to speed up scan
-		if info.IsDir() {
-			switch info.Name() {
-			case "node_modules", "target", "build", "dist", ".next", ".nuxt", "vendor":
-				return filepath.SkipDir
-			}
+// scansInto reports whether the scanner should descend into entry, found at
+// path inside the workspace
+func (w Workspace) scansInto(path string, entry os.DirEntry) bool {
+	name := entry.Name()
+
+	// Skip hidden directories, including .git which is handled by the caller
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+
+	// Skip common non-repo directories to speed up scan
+	switch name {
+	case "node_modules", "target", "build", "dist", "vendor":
+		return false
+	}
+
+	if entry.Type()&os.ModeSymlink != 0 {
+		if !w.FollowSymlinks {
+			return false
 		}
+		if info, err := os.Stat(path); err != nil || !info.IsDir() {
+			return false
+		}
+	} else if !entry.IsDir() {
+		return false
+	}
 
-		return nil
-	})
+	return !w.excludes(path)
+}
 
-	return repos, err
+// excludes reports whether path matches one of the workspace's Exclude
+// patterns, by name or by its path below the workspace
+func (w Workspace) excludes(path string) bool {
+	if len(w.Exclude) == 0 {
+		return false
+	}
+	rel, err := filepath.Rel(w.Path, path)
+	if err != nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	name := filepath.Base(path)
+	for _, pattern := range w.Exclude {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	return false
 }
 
 // scanRepos scans repository metadata in parallel
-func (s *Scanner) scanRepos(ctx context.Context, repoPaths []string, workspaceName string) []RepoInfo {
+func (s *Scanner) scanRepos(ctx context.Context, found []foundRepo, workspaceName string) []RepoInfo {
 	type result struct {
 		repo RepoInfo
 		err  error
 	}
 
-	results := make(chan result, len(repoPaths))
+	results := make(chan result, len(found))
 	var wg sync.WaitGroup
 
 	// Limit concurrent scans to avoid overwhelming the system
 	semaphore := make(chan struct{}, 10)
 
-	for _, repoPath := range repoPaths {
+	for _, repo := range found {
 		wg.Add(1)
-		go func(path string) {
+		go func(found foundRepo) {
 			defer wg.Done()
 
 			select {
@@ -199,9 +338,9 @@ func (s *Scanner) scanRepos(ctx context.Context, repoPaths []string, workspaceNa
 				return
 			}
 
-			repo, err := s.scanRepo(ctx, path, workspaceName)
+			repo, err := s.scanRepo(ctx, found, workspaceName)
 			results <- result{repo: repo, err: err}
-		}(repoPath)
+		}(repo)
 	}
 
 	// Close results channel when all workers finish
@@ -224,33 +363,56 @@ func (s *Scanner) scanRepos(ctx context.Context, repoPaths []string, workspaceNa
 }
 
 // scanRepo scans a single repository for metadata
-func (s *Scanner) scanRepo(ctx context.Context, repoPath, workspaceName string) (RepoInfo, error) {
+func (s *Scanner) scanRepo(ctx context.Context, found foundRepo, workspaceName string) (RepoInfo, error) {
 	select {
 	case <-ctx.Done():
 		return RepoInfo{}, ctx.Err()
 	default:
 	}
 
+	repoPath := found.path
 	repo := RepoInfo{
 		Path:          repoPath,
 		Name:          filepath.Base(repoPath),
 		WorkspaceName: workspaceName,
 		LastScanned:   time.Now(),
+		Parent:        found.parent,
 	}
 
 	// Check if we have cached info that's recent enough (< 5 minutes old)
 	s.mu.RLock()
+	if assigned := s.cache.Assigned[repoPath]; assigned != "" {
+		repo.WorkspaceName = assigned
+	}
+	if found.project {
+		// There is nothing for git to read yet
+		s.mu.RUnlock()
+		repo.Kind = ProjectDir
+		return repo, nil
+	}
 	if cached, exists := s.cache.Repos[repoPath]; exists {
 		if time.Since(cached.LastScanned) < 5*time.Minute {
 			s.mu.RUnlock()
+			cached.Parent = found.parent
+			cached.WorkspaceName = repo.WorkspaceName
 			return cached, nil
 		}
 	}
 	s.mu.RUnlock()
 
+	return readRepoInfo(repo), nil
+}
+
+// readRepoInfo fills in repo's branch, sync and work tree state from git
+func readRepoInfo(repo RepoInfo) RepoInfo {
+	repoPath := repo.Path
+
+	repo.Kind, _ = RepoKindAt(repoPath)
+
 	// Get current branch
 	if branch, err := git.GetCurrentBranch(repoPath); err == nil {
 		repo.Branch = branch
+		repo.Detached = branch == ""
 	}
 
 	// Get ahead/behind info
@@ -260,12 +422,48 @@ func (s *Scanner) scanRepo(ctx context.Context, repoPath, workspaceName string)
 		repo.HasUpstream = true
 	}
 
-	// Get last commit time
+	// Get the last commit
 	if commits, err := git.GetCommits(repoPath, 1); err == nil && len(commits) > 0 {
 		repo.LastCommitTime = commits[0].Time
+		repo.LastSubject = commits[0].Subject
+		repo.LastAuthor = commits[0].Author
+	}
+
+	// Count uncommitted work
+	if status, err := git.GetStatus(repoPath); err == nil {
+		for _, file := range status.Files {
+			if file.IsUntracked() {
+				repo.Untracked++
+			} else {
+				repo.Changed++
+			}
+		}
+	}
+
+	// Count work that exists only here
+	if stashes, err := git.GetStashes(repoPath); err == nil {
+		repo.Stashes = len(stashes)
+	}
+	if unpushed, oldest, err := git.Unpushed(repoPath); err == nil {
+		repo.Unpushed, repo.OldestUnpushed = unpushed, oldest
+	}
+
+	// Note states that are risky to walk away from
+	repo.InProgress, _ = git.InProgressOperation(repoPath)
+	if repo.Kind != BareRepo {
+		repo.Conflicts, _ = git.CountConflicts(repoPath)
+	}
+
+	if signing, err := git.GetSigning(repoPath); err == nil {
+		repo.SignsCommits, repo.SignsTags = signing.Commits, signing.Tags
+	}
+
+	if base, merged, err := git.MergedBranches(repoPath); err == nil {
+		repo.DefaultBranch = base
+		repo.StaleBranches = len(merged)
 	}
 
-	return repo, nil
+	return repo
 }
 
 // GetRepo returns repository information by path
@@ -287,10 +485,25 @@ func (s *Scanner) UpdateRepo(ctx context.Context, repoPath string) error {
 		}
 	}
 
-	repo, err := s.scanRepo(ctx, repoPath, workspaceName)
-	if err != nil {
+	if err := ctx.Err(); err != nil {
 		return err
 	}
+	s.mu.RLock()
+	parent := s.cache.Repos[repoPath].Parent
+	if assigned := s.cache.Assigned[repoPath]; assigned != "" {
+		workspaceName = assigned
+	}
+	s.mu.RUnlock()
+
+	// Always read afresh: this follows changes made in kvist, which the
+	// scan's cache window would hide
+	repo := readRepoInfo(RepoInfo{
+		Path:          repoPath,
+		Name:          filepath.Base(repoPath),
+		WorkspaceName: workspaceName,
+		LastScanned:   time.Now(),
+		Parent:        parent,
+	})
 
 	s.mu.Lock()
 	s.cache.Repos[repoPath] = repo
@@ -317,12 +530,16 @@ func (s *Scanner) ScanSingleWorkspace(ctx context.Context, workspace Workspace)
 
 		// Scan each repo for metadata in parallel
 		scanned := s.scanRepos(ctx, repos, workspace.Name)
+		// Like ScanWorkspaces, a cancelled scan leaves the cache alone
+		if ctx.Err() != nil {
+			return
+		}
 
 		// Update cache with results from this workspace
 		s.mu.Lock()
 		// Remove old repos from this workspace
 		for path, repo := range s.cache.Repos {
-			if repo.WorkspaceName == workspace.Name {
+			if repo.WorkspaceName == workspace.Name && !s.keepsAssigned(path) {
 				delete(s.cache.Repos, path)
 			}
 		}
@@ -350,30 +567,18 @@ func (s *Scanner) ScanSingleWorkspace(ctx context.Context, workspace Workspace)
 	return results
 }
 
-// DiscoverReposIncremental quickly finds repos and reports them one by one
+// DiscoverReposIncremental finds repos and reports them one by one, each as
+// soon as it is found and scanned
 func (s *Scanner) DiscoverReposIncremental(ctx context.Context, workspace Workspace) <-chan RepoInfo {
 	results := make(chan RepoInfo, 10) // Buffer for faster processing
 
 	go func() {
 		defer close(results)
 
-		// Quick discovery - just find .git directories without deep scanning
-		repoPaths, err := s.discoverReposQuick(ctx, workspace)
-		if err != nil {
-			return
-		}
-
-		// Send each discovered repo immediately with enriched metadata
-		for _, repoPath := range repoPaths {
-			select {
-			case <-ctx.Done():
-				return
-			default:
-			}
-
-			repo, err := s.scanRepo(ctx, repoPath, workspace.Name)
+		_ = workspace.walkRepos(ctx, func(found foundRepo) error {
+			repo, err := s.scanRepo(ctx, found, workspace.Name)
 			if err != nil {
-				continue
+				return err
 			}
 
 			// Update cache immediately so UI can use metadata for sorting
@@ -381,93 +586,144 @@ func (s *Scanner) DiscoverReposIncremental(ctx context.Context, workspace Worksp
 
 			select {
 			case results <- repo:
+				return nil
 			case <-ctx.Done():
-				return
+				return ctx.Err()
 			}
-		}
+		})
 	}()
 
 	return results
 }
 
-// discoverReposQuick finds git repos without deep metadata scanning
-func (s *Scanner) discoverReposQuick(ctx context.Context, workspace Workspace) ([]string, error) {
-	var repos []string
-
-	// First level scan - look at immediate subdirectories
-	entries, err := os.ReadDir(workspace.Path)
-	if err != nil {
-		return nil, err
-	}
-
-	for _, entry := range entries {
-		select {
-		case <-ctx.Done():
-			return repos, ctx.Err()
-		default:
-		}
+// UpdateCacheRepo updates a single repo in cache (thread-safe)
+func (s *Scanner) UpdateCacheRepo(repo RepoInfo) {
+	s.mu.Lock()
+	s.cache.Repos[repo.Path] = repo
+	s.mu.Unlock()
+}
 
-		if !entry.IsDir() {
-			continue
-		}
+// RepoNote returns the note attached to the repository at repoPath, if any
+func (s *Scanner) RepoNote(repoPath string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cache.Notes[repoPath]
+}
 
-		entryPath := filepath.Join(workspace.Path, entry.Name())
+// SetRepoNote attaches note to the repository at repoPath; an empty note removes it
+func (s *Scanner) SetRepoNote(repoPath, note string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if note == "" {
+		delete(s.cache.Notes, repoPath)
+		return
+	}
+	if s.cache.Notes == nil {
+		s.cache.Notes = make(map[string]string)
+	}
+	s.cache.Notes[repoPath] = note
+}
 
-		// Skip hidden directories (except those that might contain repos)
-		if strings.HasPrefix(entry.Name(), ".") && entry.Name() != ".git" {
-			continue
-		}
+// RepoView returns where the user was in the repository at repoPath when
+// they last left it
+func (s *Scanner) RepoView(repoPath string) (RepoView, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	view, ok := s.cache.Views[repoPath]
+	return view, ok
+}
 
-		// Check if this is a git repo
-		gitDir := filepath.Join(entryPath, ".git")
-		if _, err := os.Stat(gitDir); err == nil {
-			repos = append(repos, entryPath)
-			continue
-		}
+// SetRepoView records where the user is in the repository at repoPath
+func (s *Scanner) SetRepoView(repoPath string, view RepoView) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cache.Views == nil {
+		s.cache.Views = make(map[string]RepoView)
+	}
+	s.cache.Views[repoPath] = view
+}
 
-		// Check if it's a bare repo
-		if _, err := os.Stat(filepath.Join(entryPath, "HEAD")); err == nil {
-			if _, err := os.Stat(filepath.Join(entryPath, "refs")); err == nil {
-				repos = append(repos, entryPath)
-				continue
-			}
-		}
+// IsPinned reports whether the repository at repoPath is pinned
+func (s *Scanner) IsPinned(repoPath string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cache.Pinned[repoPath]
+}
 
-		// For non-git directories, do a shallow scan (one level down)
-		// This catches common structures like ~/code/project1, ~/code/project2
-		subEntries, err := os.ReadDir(entryPath)
-		if err != nil {
-			continue // Skip directories we can't read
-		}
+// SetPinned pins or unpins the repository at repoPath
+func (s *Scanner) SetPinned(repoPath string, pinned bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !pinned {
+		delete(s.cache.Pinned, repoPath)
+		return
+	}
+	if s.cache.Pinned == nil {
+		s.cache.Pinned = make(map[string]bool)
+	}
+	s.cache.Pinned[repoPath] = true
+}
 
-		for _, subEntry := range subEntries {
-			if !subEntry.IsDir() {
-				continue
-			}
+// IsArchived reports whether the repository at repoPath is archived
+func (s *Scanner) IsArchived(repoPath string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cache.Archived[repoPath]
+}
 
-			subPath := filepath.Join(entryPath, subEntry.Name())
+// SetArchived archives or restores the repository at repoPath
+func (s *Scanner) SetArchived(repoPath string, archived bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !archived {
+		delete(s.cache.Archived, repoPath)
+		return
+	}
+	if s.cache.Archived == nil {
+		s.cache.Archived = make(map[string]bool)
+	}
+	s.cache.Archived[repoPath] = true
+}
 
-			// Skip common build/dependency directories
-			if subEntry.Name() == "node_modules" || subEntry.Name() == "target" ||
-				subEntry.Name() == "build" || subEntry.Name() == "dist" {
-				continue
-			}
+// IsLargeRepo reports whether the repository at repoPath is in large repo mode
+func (s *Scanner) IsLargeRepo(repoPath string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cache.LargeRepos[repoPath]
+}
 
-			// Check for git repo
-			if _, err := os.Stat(filepath.Join(subPath, ".git")); err == nil {
-				repos = append(repos, subPath)
-			}
-		}
+// SetLargeRepo turns large repo mode on or off for the repository at repoPath
+func (s *Scanner) SetLargeRepo(repoPath string, large bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !large {
+		delete(s.cache.LargeRepos, repoPath)
+		return
+	}
+	if s.cache.LargeRepos == nil {
+		s.cache.LargeRepos = make(map[string]bool)
 	}
+	s.cache.LargeRepos[repoPath] = true
+}
 
-	return repos, nil
+// LargeRepos returns the paths of the repositories in large repo mode
+func (s *Scanner) LargeRepos() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Sorted(maps.Keys(s.cache.LargeRepos))
 }
 
-// UpdateCacheRepo updates a single repo in cache (thread-safe)
-func (s *Scanner) UpdateCacheRepo(repo RepoInfo) {
-	s.mu.Lock()
-	s.cache.Repos[repo.Path] = repo
-	s.mu.Unlock()
+// Unarchived returns repos without the archived ones
+func (s *Scanner) Unarchived(repos []RepoInfo) []RepoInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var active []RepoInfo
+	for _, repo := range repos {
+		if !s.cache.Archived[repo.Path] {
+			active = append(active, repo)
+		}
+	}
+	return active
 }
 
 // GetCache returns the cache (for saving to disk)
@@ -475,20 +731,35 @@ func (s *Scanner) GetCache() *RepoCache {
 	return s.cache
 }
 
-// SaveCache persists the cache with proper locking to avoid races.
+// SaveCache persists the cache with proper locking to avoid races. The
+// scanner is only locked once the cache file is, so waiting on another
+// instance doesn't hold up this one.
 func (s *Scanner) SaveCache() error {
+	unlock, err := lockCache()
+	if err != nil {
+		return err
+	}
+	defer unlock()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	if s.cache == nil {
 		return nil
 	}
-	return s.cache.Save()
+	return s.cache.save()
 }
 
-// UpdateLastRepo updates the last accessed repository in cache
+// UpdateLastRepo updates the last accessed repository in cache, moving it
+// to the front of the recent repositories
 func (s *Scanner) UpdateLastRepo(repoPath string) {
 	s.mu.Lock()
 	s.cache.LastRepoPath = repoPath
+	recent := []string{repoPath}
+	for _, path := range s.cache.RecentRepos {
+		if path != repoPath && len(recent) < MaxRecentRepos {
+			recent = append(recent, path)
+		}
+	}
+	s.cache.RecentRepos = recent
 	// Also update workspace if we can determine it from the repo
 	if repo, exists := s.cache.Repos[repoPath]; exists {
 		s.cache.LastWorkspace = repo.WorkspaceName
@@ -496,9 +767,172 @@ func (s *Scanner) UpdateLastRepo(repoPath string) {
 	s.mu.Unlock()
 }
 
+// RecentRepos returns the recently opened repositories still in the cache,
+// newest first
+func (s *Scanner) RecentRepos() []RepoInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var repos []RepoInfo
+	for _, path := range s.cache.RecentRepos {
+		if repo, ok := s.cache.Repos[path]; ok {
+			repos = append(repos, repo)
+		}
+	}
+	return repos
+}
+
 // UpdateLastWorkspace updates the last accessed workspace in cache
 func (s *Scanner) UpdateLastWorkspace(workspaceName string) {
 	s.mu.Lock()
 	s.cache.LastWorkspace = workspaceName
 	s.mu.Unlock()
 }
+
+// FetchResult is the outcome of fetching one repository
+type FetchResult struct {
+	Repo      RepoInfo // re-read after the fetch
+	NewBehind int      // commits the repo fell behind by in this fetch
+	Error     error
+}
+
+// FetchRepos fetches the repos that have an upstream, a few at a time,
+// and updates their cached information. Only repos that fell further behind
+// or failed are returned, in the order they were given.
+func (s *Scanner) FetchRepos(ctx context.Context, repos []RepoInfo) []FetchResult {
+	results := make([]FetchResult, len(repos))
+	var wg sync.WaitGroup
+
+	// Fetches hit the network and the remotes; keep a few in flight at most
+	semaphore := make(chan struct{}, 4)
+
+	for i, repo := range repos {
+		if !repo.HasUpstream {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, repo RepoInfo) {
+			defer wg.Done()
+
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-ctx.Done():
+				return
+			}
+
+			if err := git.BackgroundFetch(repo.Path); err != nil {
+				results[i] = FetchResult{Repo: repo, Error: err}
+				return
+			}
+			updated := readRepoInfo(RepoInfo{
+				Path:          repo.Path,
+				Name:          repo.Name,
+				WorkspaceName: repo.WorkspaceName,
+				LastScanned:   time.Now(),
+				Parent:        repo.Parent,
+			})
+			s.UpdateCacheRepo(updated)
+			results[i] = FetchResult{Repo: updated, NewBehind: max(0, updated.Behind-repo.Behind)}
+		}(i, repo)
+	}
+	wg.Wait()
+
+	var changed []FetchResult
+	for _, res := range results {
+		if res.NewBehind > 0 || res.Error != nil {
+			changed = append(changed, res)
+		}
+	}
+	return changed
+}
+
+// maxFetchBackoff caps how long a repository whose fetch keeps failing
+// waits before its next background fetch
+const maxFetchBackoff = 6 * time.Hour
+
+// FetchBackoff spaces out the background fetches of repositories whose
+// fetch keeps failing, doubling the wait after each failure, so an
+// unreachable remote isn't tried every interval. The zero value is ready.
+type FetchBackoff struct {
+	failures map[string]int
+	next     map[string]time.Time
+}
+
+// Due reports whether the repository at path may be fetched at now
+func (b *FetchBackoff) Due(path string, now time.Time) bool {
+	return !now.Before(b.next[path])
+}
+
+// Failed records a failed fetch of the repository at path and returns how
+// long it waits before the next, twice the interval after the first failure
+// and doubling from there
+func (b *FetchBackoff) Failed(path string, interval time.Duration, now time.Time) time.Duration {
+	if b.failures == nil {
+		b.failures, b.next = make(map[string]int), make(map[string]time.Time)
+	}
+	b.failures[path]++
+	wait := interval
+	for i := 0; i < b.failures[path] && wait < maxFetchBackoff; i++ {
+		wait *= 2
+	}
+	wait = min(wait, max(maxFetchBackoff, interval))
+	b.next[path] = now.Add(wait)
+	return wait
+}
+
+// Failures returns how many fetches of the repository at path failed in a row
+func (b *FetchBackoff) Failures(path string) int {
+	return b.failures[path]
+}
+
+// Succeeded forgets the failures of the repository at path
+func (b *FetchBackoff) Succeeded(path string) {
+	delete(b.failures, path)
+	delete(b.next, path)
+}
+
+// RepoSearchResult holds the matches found in a single repository
+type RepoSearchResult struct {
+	Repo    RepoInfo
+	Matches []git.GrepMatch
+	Error   error
+}
+
+// SearchRepos runs git grep for pattern across repos with bounded parallelism.
+// Only repos with matches or errors are returned, in the order they were given.
+func SearchRepos(ctx context.Context, repos []RepoInfo, pattern string, maxPerRepo int) []RepoSearchResult {
+	results := make([]RepoSearchResult, len(repos))
+	var wg sync.WaitGroup
+
+	// Limit concurrent searches to avoid overwhelming the system
+	semaphore := make(chan struct{}, 10)
+
+	for i, repo := range repos {
+		if repo.Kind == ProjectDir {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, repo RepoInfo) {
+			defer wg.Done()
+
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-ctx.Done():
+				return
+			}
+
+			matches, err := git.Grep(repo.Path, pattern, maxPerRepo)
+			results[i] = RepoSearchResult{Repo: repo, Matches: matches, Error: err}
+		}(i, repo)
+	}
+	wg.Wait()
+
+	var found []RepoSearchResult
+	for _, res := range results {
+		if len(res.Matches) > 0 || res.Error != nil {
+			found = append(found, res)
+		}
+	}
+	return found
+}
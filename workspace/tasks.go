@@ -0,0 +1,132 @@
+package workspace
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Task is a project task a repository defines for a task runner, such as a
+// make target or an npm script
+type Task struct {
+	Runner  string // "make", "npm", "just", "go", ...
+	Name    string
+	Command string // shell command that runs the task in the repository
+}
+
+// DetectTasks finds the tasks of the task runners set up at the top of the
+// repository at repoPath: Makefile targets, package.json scripts, justfile
+// recipes and the go commands of a Go module
+func DetectTasks(repoPath string) []Task {
+	var tasks []Task
+	for _, name := range []string{"GNUmakefile", "makefile", "Makefile"} {
+		if targets := makeTargets(filepath.Join(repoPath, name)); targets != nil {
+			for _, target := range targets {
+				tasks = append(tasks, Task{Runner: "make", Name: target, Command: "make " + target})
+			}
+			break
+		}
+	}
+	tasks = append(tasks, npmScripts(repoPath)...)
+	for _, name := range []string{"justfile", "Justfile", ".justfile"} {
+		if recipes := justRecipes(filepath.Join(repoPath, name)); recipes != nil {
+			for _, recipe := range recipes {
+				tasks = append(tasks, Task{Runner: "just", Name: recipe, Command: "just " + recipe})
+			}
+			break
+		}
+	}
+	if _, err := os.Stat(filepath.Join(repoPath, "go.mod")); err == nil {
+		for _, command := range []string{"test", "build", "vet"} {
+			tasks = append(tasks, Task{Runner: "go", Name: command, Command: "go " + command + " ./..."})
+		}
+	}
+	return tasks
+}
+
+var (
+	// makeTargetPattern matches a rule line such as "build: deps" or
+	// "build:: deps", but not variable assignments like "CC := gcc",
+	// "CC ::= gcc" or "CC :::= gcc"
+	makeTargetPattern = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9_./-]*)\s*::?([^:=]|$)`)
+	// justRecipePattern matches a recipe header such as "test arg='x':"
+	justRecipePattern = regexp.MustCompile(`^@?([A-Za-z_][A-Za-z0-9_-]*)(\s+[^:]*)?:([^=]|$)`)
+)
+
+// makeTargets returns the targets of the makefile at path in order, nil
+// when there is no such file
+func makeTargets(path string) []string {
+	return ruleNames(path, makeTargetPattern, func(name string) bool {
+		// Pattern rules and file targets aren't tasks
+		return !strings.ContainsAny(name, "%./")
+	})
+}
+
+// justRecipes returns the recipes of the justfile at path in order, nil
+// when there is no such file
+func justRecipes(path string) []string {
+	return ruleNames(path, justRecipePattern, func(name string) bool {
+		return !strings.HasPrefix(name, "_")
+	})
+}
+
+// ruleNames returns the first group of pattern on each unindented line of
+// the file at path that keep accepts, each name once
+func ruleNames(path string, pattern *regexp.Regexp, keep func(name string) bool) []string {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	names := []string{}
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		match := pattern.FindStringSubmatch(scanner.Text())
+		if match == nil || seen[match[1]] || !keep(match[1]) {
+			continue
+		}
+		seen[match[1]] = true
+		names = append(names, match[1])
+	}
+	return names
+}
+
+// npmScripts returns the scripts in package.json, run with the package
+// manager whose lock file is there
+func npmScripts(repoPath string) []Task {
+	data, err := os.ReadFile(filepath.Join(repoPath, "package.json"))
+	if err != nil {
+		return nil
+	}
+	var pkg struct {
+		Scripts map[string]string `json:"scripts"`
+	}
+	if json.Unmarshal(data, &pkg) != nil {
+		return nil
+	}
+	runner := "npm"
+	for _, lock := range []struct{ file, runner string }{
+		{"pnpm-lock.yaml", "pnpm"}, {"yarn.lock", "yarn"}, {"bun.lockb", "bun"}, {"bun.lock", "bun"},
+	} {
+		if _, err := os.Stat(filepath.Join(repoPath, lock.file)); err == nil {
+			runner = lock.runner
+			break
+		}
+	}
+	names := make([]string, 0, len(pkg.Scripts))
+	for name := range pkg.Scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tasks := make([]Task, 0, len(names))
+	for _, name := range names {
+		tasks = append(tasks, Task{Runner: runner, Name: name, Command: runner + " run " + ShellQuote(name)})
+	}
+	return tasks
+}
@@ -0,0 +1,327 @@
+package workspace
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigProblem is something wrong in the config file
+type ConfigProblem struct {
+	Line       int    // line in the file, 0 when unknown
+	Field      string // e.g. workspaces[1].path
+	Message    string
+	Suggestion string // how to fix it, if there is an obvious way
+	Fatal      bool   // the configuration can't be used as it is
+}
+
+func (p ConfigProblem) String() string {
+	var b strings.Builder
+	if p.Line > 0 {
+		fmt.Fprintf(&b, "line %d: ", p.Line)
+	}
+	if p.Field != "" {
+		b.WriteString(p.Field + ": ")
+	}
+	b.WriteString(p.Message)
+	if p.Suggestion != "" {
+		b.WriteString(" (" + p.Suggestion + ")")
+	}
+	return b.String()
+}
+
+// ConfigError lists the problems found in the config file. LoadConfig
+// returns it with a usable config when none of the problems is fatal.
+type ConfigError struct {
+	Path     string
+	Problems []ConfigProblem
+}
+
+func (e *ConfigError) Error() string {
+	lines := make([]string, 0, len(e.Problems))
+	for _, problem := range e.Problems {
+		lines = append(lines, problem.String())
+	}
+	return fmt.Sprintf("%s: %s", e.Path, strings.Join(lines, "; "))
+}
+
+// Fatal reports whether any of the problems makes the config unusable
+func (e *ConfigError) Fatal() bool {
+	for _, problem := range e.Problems {
+		if problem.Fatal {
+			return true
+		}
+	}
+	return false
+}
+
+// yamlLine finds the line number yaml puts in its error messages
+var yamlLine = regexp.MustCompile(`line (\d+)`)
+
+// checkConfig parses data as a config and reports what is wrong with it.
+// The config is nil when the problems are fatal.
+func checkConfig(data []byte) (*Config, []ConfigProblem) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, []ConfigProblem{parseProblem(strings.TrimPrefix(err.Error(), "yaml: "))}
+	}
+
+	var config Config
+	if err := root.Decode(&config); err != nil {
+		var problems []ConfigProblem
+		if typeErr, ok := err.(*yaml.TypeError); ok {
+			for _, msg := range typeErr.Errors {
+				problems = append(problems, parseProblem(msg))
+			}
+		} else {
+			problems = append(problems, parseProblem(err.Error()))
+		}
+		return nil, problems
+	}
+
+	var problems []ConfigProblem
+	if len(root.Content) > 0 {
+		checkKeys(root.Content[0], reflect.TypeOf(config), "", &problems)
+	}
+	problems = append(problems, config.check(workspaceLines(&root))...)
+	if (&ConfigError{Problems: problems}).Fatal() {
+		return nil, problems
+	}
+	return &config, problems
+}
+
+// parseProblem turns a yaml error message into a fatal problem
+func parseProblem(msg string) ConfigProblem {
+	problem := ConfigProblem{Message: msg, Fatal: true}
+	if match := yamlLine.FindStringSubmatchIndex(msg); match != nil && match[0] == 0 {
+		problem.Line, _ = strconv.Atoi(msg[match[2]:match[3]])
+		problem.Message = strings.TrimSpace(strings.TrimPrefix(msg[match[1]:], ":"))
+	}
+	return problem
+}
+
+// checkKeys reports mapping keys in node that t has no field for, with the
+// closest known key as a suggestion
+func checkKeys(node *yaml.Node, t reflect.Type, path string, problems *[]ConfigProblem) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		fields := make(map[string]reflect.Type)
+		for i := 0; i < t.NumField(); i++ {
+			name := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+			if name != "" && name != "-" {
+				fields[name] = t.Field(i).Type
+			}
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			field := key.Value
+			if path != "" {
+				field = path + "." + key.Value
+			}
+			fieldType, ok := fields[key.Value]
+			if !ok {
+				problem := ConfigProblem{Line: key.Line, Field: field, Message: "unknown setting, ignored"}
+				if closest := closestName(key.Value, fields); closest != "" {
+					problem.Suggestion = fmt.Sprintf("did you mean %s?", closest)
+				}
+				*problems = append(*problems, problem)
+				continue
+			}
+			checkKeys(value, fieldType, field, problems)
+		}
+	case t.Kind() == reflect.Slice && node.Kind == yaml.SequenceNode:
+		for i, item := range node.Content {
+			checkKeys(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), problems)
+		}
+	}
+}
+
+// closestName returns the known name that name is most likely a typo of
+func closestName(name string, known map[string]reflect.Type) string {
+	best, bestDistance := "", 3 // more edits than this is no longer a typo
+	for candidate := range known {
+		if strings.EqualFold(candidate, name) {
+			return candidate
+		}
+		d := editDistance(strings.ToLower(name), strings.ToLower(candidate))
+		if d < bestDistance || (d == bestDistance && candidate < best) {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// workspaceLines returns the line each workspace entry starts on
+func workspaceLines(root *yaml.Node) []int {
+	if len(root.Content) == 0 {
+		return nil
+	}
+	doc := root.Content[0]
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		if doc.Content[i].Value == "workspaces" {
+			var lines []int
+			for _, item := range doc.Content[i+1].Content {
+				lines = append(lines, item.Line)
+			}
+			return lines
+		}
+	}
+	return nil
+}
+
+// check reports settings with missing or impossible values; lines are
+// the lines the workspaces start on
+func (c *Config) check(lines []int) []ConfigProblem {
+	var problems []ConfigProblem
+	if c.Renames.Similarity < 0 || c.Renames.Similarity > 100 {
+		problems = append(problems, ConfigProblem{Field: "renames.similarity",
+			Message:    fmt.Sprintf("%d is not a percentage", c.Renames.Similarity),
+			Suggestion: "use a number from 1 to 100, or 0 for git's default"})
+	}
+	for i, column := range c.History.Columns {
+		if !slices.Contains(HistoryColumns, column) {
+			problems = append(problems, ConfigProblem{Field: fmt.Sprintf("history.columns[%d]", i),
+				Message:    fmt.Sprintf("unknown column %q, ignored", column),
+				Suggestion: "use " + strings.Join(HistoryColumns, ", ")})
+		}
+	}
+	for i, pattern := range c.Diff.TextConv {
+		if _, err := path.Match(pattern, ""); err != nil {
+			problems = append(problems, ConfigProblem{Field: fmt.Sprintf("diff.textconv[%d]", i),
+				Message:    fmt.Sprintf("%q is not a valid pattern", pattern),
+				Suggestion: `use a glob such as "*.ipynb"`})
+		}
+	}
+	if c.Startup != "" && !slices.Contains(StartupBehaviors, c.Startup) {
+		problems = append(problems, ConfigProblem{Field: "startup",
+			Message:    fmt.Sprintf("unknown behavior %q, asking instead", c.Startup),
+			Suggestion: "use " + strings.Join(StartupBehaviors, ", ")})
+	}
+	keys := make(map[string]bool)
+	for i, command := range c.CustomCommands {
+		field := fmt.Sprintf("customCommands[%d]", i)
+		if command.Name == "" {
+			problems = append(problems, ConfigProblem{Field: field + ".name",
+				Message:    "missing",
+				Suggestion: "name the command as it should be shown in the menu"})
+		}
+		if command.Command == "" {
+			problems = append(problems, ConfigProblem{Field: field + ".command",
+				Message:    "missing",
+				Suggestion: `give the shell command to run, e.g. "make test"`})
+		}
+		for _, name := range command.Placeholders() {
+			if !slices.Contains(CommandPlaceholders, name) {
+				problems = append(problems, ConfigProblem{Field: field + ".command",
+					Message:    fmt.Sprintf("unknown placeholder {{%s}}", name),
+					Suggestion: "use {{" + strings.Join(CommandPlaceholders, "}}, {{") + "}}"})
+			}
+		}
+		if command.Key != "" && keys[command.Key] {
+			problems = append(problems, ConfigProblem{Field: field + ".key",
+				Message:    fmt.Sprintf("%q is already bound to another custom command", command.Key),
+				Suggestion: "pick another key"})
+		}
+		keys[command.Key] = true
+	}
+	names := make(map[string]bool)
+	for i, plugin := range c.Plugins {
+		field := fmt.Sprintf("plugins[%d]", i)
+		if plugin.Name == "" || names[plugin.Name] {
+			problems = append(problems, ConfigProblem{Field: field + ".name",
+				Message:    "missing or used by another plugin",
+				Suggestion: "give each plugin a name of its own"})
+		}
+		names[plugin.Name] = true
+		if plugin.Command == "" {
+			problems = append(problems, ConfigProblem{Field: field + ".command",
+				Message:    "missing",
+				Suggestion: "give the shell command that starts the plugin"})
+		}
+	}
+	if c.Version > ConfigVersion {
+		problems = append(problems, ConfigProblem{Field: "version",
+			Message:    fmt.Sprintf("version %d is newer than this kvist understands (%d)", c.Version, ConfigVersion),
+			Suggestion: "update kvist"})
+	}
+	for _, setting := range []struct {
+		field string
+		value int
+	}{
+		{"autoFetchMinutes", c.AutoFetchMinutes},
+		{"unpushedWarnDays", c.UnpushedWarnDays},
+		{"commits.maxHeader", c.Commits.MaxHeader},
+	} {
+		if setting.value < 0 {
+			problems = append(problems, ConfigProblem{Field: setting.field, Message: "can't be negative", Suggestion: "use 0 for the default"})
+		}
+	}
+
+	seen := make(map[string]bool)
+	for i, ws := range c.Workspaces {
+		line := 0
+		if i < len(lines) {
+			line = lines[i]
+		}
+		field := fmt.Sprintf("workspaces[%d]", i)
+		add := func(problem ConfigProblem) {
+			problem.Line = line
+			problems = append(problems, problem)
+		}
+		switch {
+		case ws.Name == "":
+			add(ConfigProblem{Field: field + ".name", Message: "missing", Suggestion: "every workspace needs a name", Fatal: true})
+		case seen[ws.Name]:
+			add(ConfigProblem{Field: field + ".name", Message: fmt.Sprintf("%q is used by another workspace", ws.Name), Suggestion: "rename one of them", Fatal: true})
+		}
+		seen[ws.Name] = true
+
+		if ws.Path == "" {
+			add(ConfigProblem{Field: field + ".path", Message: "missing", Suggestion: "set the directory to scan", Fatal: true})
+		} else if info, err := os.Stat(ExpandPath(ws.Path)); err != nil {
+			add(ConfigProblem{Field: field + ".path", Message: fmt.Sprintf("%s does not exist", ws.Path), Suggestion: "create it or fix the path; the workspace is empty until then"})
+		} else if !info.IsDir() {
+			add(ConfigProblem{Field: field + ".path", Message: fmt.Sprintf("%s is not a directory", ws.Path)})
+		}
+
+		if ws.NestedRepos != "" && ws.NestedRepos != NestedChildren && ws.NestedRepos != NestedSkip {
+			add(ConfigProblem{Field: field + ".nestedRepos", Message: fmt.Sprintf("unknown value %q", ws.NestedRepos),
+				Suggestion: fmt.Sprintf("use %s or %s", NestedChildren, NestedSkip)})
+		}
+		if ws.MaxDepth < 0 {
+			add(ConfigProblem{Field: field + ".maxDepth", Message: "can't be negative", Suggestion: "use 0 for the default"})
+		}
+	}
+	return problems
+}
@@ -2,33 +2,182 @@ package workspace
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/asbjornb/kvist/git"
 	"gopkg.in/yaml.v3"
 )
 
 const (
 	ConfigVersion = 1
-	ConfigDir     = ".config/kvist"
-	CacheDir      = ".cache/kvist"
+	ConfigDir     = ".config/kvist" // under the home directory, see configDir
+	CacheDir      = ".cache/kvist"  // under the home directory, see cacheDir
 	ConfigFile    = "config.yaml"
 	CacheFile     = "repos.json"
+	SessionFile   = "session.lock"
+	DebugLogFile  = "debug.log"
 )
 
 // Config represents the kvist configuration
 type Config struct {
 	Version    int         `yaml:"version"`
 	Workspaces []Workspace `yaml:"workspaces"`
+	TourSeen   bool        `yaml:"tourSeen,omitempty"` // onboarding tour has been offered
+	// OpenCommand is run in a repository's directory to open it in an
+	// editor or file manager, e.g. "code ." or "idea .". Workspaces may override it.
+	OpenCommand string `yaml:"openCommand,omitempty"`
+	// TodoPatterns are the marker words the TODO scanner looks for;
+	// empty means TODO, FIXME and HACK
+	TodoPatterns []string `yaml:"todoPatterns,omitempty"`
+	// Forges maps self-hosted forge host names to their kind ("github",
+	// "gitlab", "gitea" or "bitbucket") when the name doesn't tell
+	Forges map[string]string `yaml:"forges,omitempty"`
+	// AutoFetchMinutes fetches every repository with an upstream in the
+	// background this often; 0 disables it
+	AutoFetchMinutes int `yaml:"autoFetchMinutes,omitempty"`
+	// UnpushedWarnDays flags repositories whose oldest unpushed commit is
+	// older than this many days; 0 means DefaultUnpushedWarnDays
+	UnpushedWarnDays int `yaml:"unpushedWarnDays,omitempty"`
+	// CheckForUpdates looks for a newer kvist release on GitHub at startup
+	CheckForUpdates bool `yaml:"checkForUpdates,omitempty"`
+	// Commits configures the commit message assistant; workspaces may override it
+	Commits CommitSettings `yaml:"commits,omitempty"`
+	// Renames configures how moved and copied files are detected
+	Renames RenameSettings `yaml:"renames,omitempty"`
+	// History configures the columns of the history list
+	History HistorySettings `yaml:"history,omitempty"`
+	// Diff configures how diffs are shown
+	Diff DiffSettings `yaml:"diff,omitempty"`
+	// CustomCommands are the user's own shell commands, run from the ":" menu
+	CustomCommands []CustomCommand `yaml:"customCommands,omitempty"`
+	// Hooks are shell commands run on kvist's own events
+	Hooks HookSettings `yaml:"hooks,omitempty"`
+	// Plugins are external programs with panels of their own, opened from
+	// the "+" menu
+	Plugins []Plugin `yaml:"plugins,omitempty"`
+	// Startup is what to do at startup when the repository open when the
+	// last session ended can be resumed: StartupAsk, StartupResume or
+	// StartupPicker; empty means StartupAsk
+	Startup string `yaml:"startup,omitempty"`
+}
+
+// Startup behaviors
+const (
+	StartupAsk    = "ask"    // ask whether to resume the last repository
+	StartupResume = "resume" // open the last repository right away
+	StartupPicker = "picker" // show the workspaces
+)
+
+// StartupBehaviors are the values Config.Startup can take
+var StartupBehaviors = []string{StartupAsk, StartupResume, StartupPicker}
+
+// HookSettings are shell commands run in a repository in the background
+// when something happens in kvist, e.g. "direnv reload" or "npm install".
+// The commands of an event run in order until one fails.
+type HookSettings struct {
+	// Open runs when a repository is opened
+	Open []string `yaml:"open,omitempty"`
+	// Pull runs after a successful pull
+	Pull []string `yaml:"pull,omitempty"`
+	// Commit runs after a commit
+	Commit []string `yaml:"commit,omitempty"`
+}
+
+// DiffSettings configures how diffs are shown
+type DiffSettings struct {
+	// TextConv lists patterns, e.g. "*.ipynb" or "docs/*.docx", of files
+	// diffed through the textconv filters set up in the repository's
+	// .gitattributes and git config. Other files are diffed the way git
+	// does by default.
+	TextConv []string `yaml:"textconv,omitempty"`
+}
+
+// HistoryColumns are the columns the history list can show: the short hash
+// with its CI status, branch and tag names, the relative time, the date, the
+// author's name or initials, and the subject
+var HistoryColumns = []string{"hash", "refs", "time", "date", "author", "initials", "subject"}
+
+// DefaultHistoryColumns are shown when none are configured
+var DefaultHistoryColumns = []string{"hash", "refs", "time", "initials", "subject"}
+
+// HistorySettings configures the history list
+type HistorySettings struct {
+	// Columns are shown in this order; empty means DefaultHistoryColumns.
+	// Columns that don't fit a narrow terminal are left out.
+	Columns []string `yaml:"columns,omitempty"`
+}
+
+// ColumnsOrDefault returns the configured columns, or the default ones
+func (h HistorySettings) ColumnsOrDefault() []string {
+	if len(h.Columns) == 0 {
+		return DefaultHistoryColumns
+	}
+	return h.Columns
+}
+
+// RenameSettings configures rename and copy detection in status and diffs
+type RenameSettings struct {
+	// Similarity is the percentage of content a moved file must keep to be
+	// shown as a rename; 0 means git's default of 50
+	Similarity int `yaml:"similarity,omitempty"`
+	// Copies also detects files copied from other changed files
+	Copies bool `yaml:"copies,omitempty"`
+}
+
+// GitRenames returns the settings as git rename options
+func (r RenameSettings) GitRenames() git.RenameOptions {
+	return git.RenameOptions{Similarity: r.Similarity, Copies: r.Copies}
+}
+
+// CommitSettings configures how commit messages are written and checked
+type CommitSettings struct {
+	// Conventional guides messages through the Conventional Commits form
+	Conventional bool `yaml:"conventional,omitempty"`
+	// Types are the allowed commit types; empty means the standard set
+	Types []string `yaml:"types,omitempty"`
+	// MaxHeader is the longest header line accepted without a warning; 0 means 72
+	MaxHeader int `yaml:"maxHeader,omitempty"`
+	// Editor writes messages in git's configured editor ($GIT_EDITOR,
+	// core.editor, $VISUAL or $EDITOR) instead of in kvist
+	Editor bool `yaml:"editor,omitempty"`
 }
 
 // Workspace represents a workspace configuration
 type Workspace struct {
 	Name string `yaml:"name"`
 	Path string `yaml:"path"`
+	// Env holds variables injected into editors, shells and custom commands
+	// run for repositories in this workspace, e.g. AWS_PROFILE or KUBECONFIG
+	Env map[string]string `yaml:"env,omitempty"`
+	// OpenCommand overrides Config.OpenCommand for repositories in this workspace
+	OpenCommand string `yaml:"openCommand,omitempty"`
+	// Commits overrides Config.Commits for repositories in this workspace
+	Commits *CommitSettings `yaml:"commits,omitempty"`
+	// MaxDepth limits how many directory levels below Path repositories are
+	// looked for; 0 means DefaultScanDepth
+	MaxDepth int `yaml:"maxDepth,omitempty"`
+	// Exclude lists glob patterns of directories never to scan, matched
+	// against the directory name and its path below Path, e.g. "vendor" or
+	// "clients/*/build"
+	Exclude []string `yaml:"exclude,omitempty"`
+	// FollowSymlinks descends into symlinked directories while scanning
+	FollowSymlinks bool `yaml:"followSymlinks,omitempty"`
+	// NestedRepos says what to do with repositories inside another
+	// repository: NestedChildren (the default) lists them under it,
+	// NestedSkip leaves them out, e.g. for vendored checkouts
+	NestedRepos string `yaml:"nestedRepos,omitempty"`
+	// ShowProjects also lists project directories that aren't under git yet,
+	// recognized by files like go.mod or package.json, so they can be adopted
+	ShowProjects bool `yaml:"showProjects,omitempty"`
 }
 
 // RepoInfo holds metadata about a discovered repository
@@ -40,8 +189,142 @@ type RepoInfo struct {
 	Behind         int       `json:"behind"`
 	HasUpstream    bool      `json:"hasUpstream"`
 	LastCommitTime time.Time `json:"lastCommitTime"`
+	LastSubject    string    `json:"lastSubject,omitempty"` // subject of the last commit on HEAD
+	LastAuthor     string    `json:"lastAuthor,omitempty"`
 	LastScanned    time.Time `json:"lastScanned"`
 	WorkspaceName  string    `json:"workspaceName"`
+	Changed        int       `json:"changed"`   // tracked files with uncommitted changes
+	Untracked      int       `json:"untracked"` // untracked files, not counting ignored ones
+	Stashes        int       `json:"stashes"`
+	Unpushed       int       `json:"unpushed"` // commits on HEAD that are on no remote
+	DefaultBranch  string    `json:"defaultBranch"`
+	StaleBranches  int       `json:"staleBranches"` // local branches merged into the default branch
+	Kind           RepoKind  `json:"kind"`
+	Parent         string    `json:"parent,omitempty"` // path of the repository this one is nested in
+	Detached       bool      `json:"detached,omitempty"`
+	InProgress     string    `json:"inProgress,omitempty"` // merge, rebase etc. stopped midway
+	Conflicts      int       `json:"conflicts,omitempty"`  // files with unresolved conflicts
+	OldestUnpushed time.Time `json:"oldestUnpushed,omitempty"`
+
+	// Whether commit.gpgsign and tag.gpgsign are on for the repository
+	SignsCommits bool `json:"signsCommits,omitempty"`
+	SignsTags    bool `json:"signsTags,omitempty"`
+}
+
+// Values for Workspace.NestedRepos
+const (
+	NestedChildren = "children"
+	NestedSkip     = "skip"
+)
+
+// DefaultUnpushedWarnDays is how old unpushed commits get before a
+// repository is flagged, unless configured otherwise
+const DefaultUnpushedWarnDays = 7
+
+// MaxRecentRepos is how many recently opened repositories are remembered
+const MaxRecentRepos = 10
+
+// DefaultScanDepth is how many directory levels below a workspace are
+// searched for repositories when the workspace doesn't set MaxDepth
+const DefaultScanDepth = 5
+
+// RepoKind is the layout of a repository on disk
+type RepoKind string
+
+const (
+	WorkTree       RepoKind = "worktree" // a work tree with its .git directory
+	LinkedWorktree RepoKind = "linked"   // a worktree added with git worktree add
+	Submodule      RepoKind = "submodule"
+	BareRepo       RepoKind = "bare"
+	ProjectDir     RepoKind = "project" // a project directory that isn't a repository yet
+)
+
+// projectMarkers are files at the root of a project directory
+var projectMarkers = []string{
+	"go.mod", "package.json", "Cargo.toml", "pyproject.toml", "setup.py",
+	"requirements.txt", "pom.xml", "build.gradle", "build.gradle.kts",
+	"Gemfile", "composer.json", "mix.exs", "CMakeLists.txt", "Makefile",
+}
+
+// IsProjectDir reports whether dir holds one of the files that mark the
+// root of a project
+func IsProjectDir(dir string) bool {
+	for _, marker := range projectMarkers {
+		if info, err := os.Stat(filepath.Join(dir, marker)); err == nil && !info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// Dirty reports whether the repository has uncommitted work
+func (r RepoInfo) Dirty() bool {
+	return r.Changed > 0 || r.Untracked > 0
+}
+
+// Warnings explains what makes the repository risky to leave as it is:
+// a detached HEAD, an operation stopped midway, conflicts, or unpushed
+// commits older than unpushedAge
+func (r RepoInfo) Warnings(unpushedAge time.Duration) []string {
+	var warnings []string
+	if r.Detached {
+		warnings = append(warnings, "HEAD is detached; new commits belong to no branch")
+	}
+	if r.InProgress != "" {
+		warnings = append(warnings, r.InProgress+" in progress")
+	}
+	if r.Conflicts > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d file(s) with unresolved conflicts", r.Conflicts))
+	}
+	if !r.OldestUnpushed.IsZero() && time.Since(r.OldestUnpushed) > unpushedAge {
+		days := int(time.Since(r.OldestUnpushed).Hours() / 24)
+		warnings = append(warnings, fmt.Sprintf("unpushed commits up to %d days old", days))
+	}
+	return warnings
+}
+
+// NestChildren orders repos so that nested repositories follow their parent,
+// otherwise keeping their order; a repository whose parent isn't in repos
+// stays where it is. depth is each repository's nesting level in the result.
+func NestChildren(repos []RepoInfo) (nested []RepoInfo, depth map[string]int) {
+	present := make(map[string]bool, len(repos))
+	for _, repo := range repos {
+		present[repo.Path] = true
+	}
+	children := make(map[string][]RepoInfo)
+	var roots []RepoInfo
+	for _, repo := range repos {
+		if repo.Parent != "" && present[repo.Parent] {
+			children[repo.Parent] = append(children[repo.Parent], repo)
+		} else {
+			roots = append(roots, repo)
+		}
+	}
+
+	nested = make([]RepoInfo, 0, len(repos))
+	depth = make(map[string]int, len(repos))
+	var add func(repo RepoInfo, level int)
+	add = func(repo RepoInfo, level int) {
+		nested = append(nested, repo)
+		depth[repo.Path] = level
+		for _, child := range children[repo.Path] {
+			add(child, level+1)
+		}
+	}
+	for _, repo := range roots {
+		add(repo, 0)
+	}
+	return nested, depth
+}
+
+// RepoView is where the user was in a repository, put back when it is
+// opened again
+type RepoView struct {
+	Mode       string `json:"mode"`              // "files" or "history"
+	File       string `json:"file,omitempty"`    // path of the selected file
+	Section    string `json:"section,omitempty"` // section of the files panel it was selected in
+	Commit     string `json:"commit,omitempty"`  // hash of the selected commit
+	DiffScroll int    `json:"diffScroll,omitempty"`
 }
 
 // RepoCache holds cached repository information
@@ -50,9 +333,33 @@ type RepoCache struct {
 	Repos           map[string]RepoInfo `json:"repos"`           // path -> RepoInfo
 	LastRepoPath    string              `json:"lastRepoPath"`    // last opened repository
 	LastWorkspace   string              `json:"lastWorkspace"`   // last opened workspace
+	// RecentRepos are the paths of the last opened repositories, newest first
+	RecentRepos []string `json:"recentRepos,omitempty"`
+	// Notes are the user's annotations by repository path. Unlike the
+	// scanned information they survive rescans and Reset.
+	Notes map[string]string `json:"notes,omitempty"`
+	// Pinned repositories are listed first; like notes they survive Reset
+	Pinned map[string]bool `json:"pinned,omitempty"`
+	// Archived repositories are hidden from the list and left out of
+	// operations across repositories; they also survive Reset
+	Archived map[string]bool `json:"archived,omitempty"`
+	// Assigned maps repository paths to the workspace they were moved to by
+	// hand, overriding the workspace they are found in
+	Assigned map[string]string `json:"assigned,omitempty"`
+	// LargeRepos are in large repo mode: lighter status and log queries
+	// and less frequent refreshes
+	LargeRepos map[string]bool `json:"largeRepos,omitempty"`
+	// Views are where the user was in each repository when they left it
+	Views map[string]RepoView `json:"views,omitempty"`
+
+	// base is the cache as this instance last loaded or saved it, so Save
+	// can tell its own changes from those of other running instances
+	base *RepoCache
 }
 
-// LoadConfig loads the kvist configuration from disk
+// LoadConfig loads the kvist configuration from disk. Problems in the file
+// are returned as a *ConfigError; the config is still returned when none of
+// them is fatal.
 func LoadConfig() (*Config, error) {
 	configPath := getConfigPath()
 
@@ -74,12 +381,12 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	config, problems := checkConfig(data)
+	if len(problems) > 0 {
+		return config, &ConfigError{Path: configPath, Problems: problems}
 	}
 
-	return &config, nil
+	return config, nil
 }
 
 // Save saves the configuration to disk
@@ -96,7 +403,7 @@ func (c *Config) Save() error {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	if err := os.WriteFile(configPath, data, 0644); err != nil {
+	if err := writeFileAtomic(configPath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
 
@@ -141,7 +448,79 @@ func (c *Config) RemoveWorkspace(name string) error {
 	return fmt.Errorf("workspace '%s' not found", name)
 }
 
-// LoadRepoCache loads cached repository information
+// WorkspaceForRepo returns the workspace containing repoPath, preferring the
+// most specific one when workspaces are nested, or nil if there is none
+func (c *Config) WorkspaceForRepo(repoPath string) *Workspace {
+	var best *Workspace
+	for i := range c.Workspaces {
+		ws := &c.Workspaces[i]
+		rel, err := filepath.Rel(ws.Path, repoPath)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if best == nil || len(ws.Path) > len(best.Path) {
+			best = ws
+		}
+	}
+	return best
+}
+
+// OpenCommandFor returns the command that opens repoPath externally: the owning
+// workspace's OpenCommand, else the global one. Empty means use the system default.
+func (c *Config) OpenCommandFor(repoPath string) string {
+	if ws := c.WorkspaceForRepo(repoPath); ws != nil && ws.OpenCommand != "" {
+		return ws.OpenCommand
+	}
+	return c.OpenCommand
+}
+
+// UnpushedWarnAge returns how old unpushed commits may get before their
+// repository is flagged
+func (c *Config) UnpushedWarnAge() time.Duration {
+	days := c.UnpushedWarnDays
+	if days <= 0 {
+		days = DefaultUnpushedWarnDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// CommitSettingsFor returns the commit settings for repoPath: the owning
+// workspace's when it has any, else the global ones
+func (c *Config) CommitSettingsFor(repoPath string) CommitSettings {
+	if ws := c.WorkspaceForRepo(repoPath); ws != nil && ws.Commits != nil {
+		return *ws.Commits
+	}
+	return c.Commits
+}
+
+// CommandEnv returns the environment for external commands run in repoPath:
+// the current process environment plus the owning workspace's variables.
+// Values may reference ~ and existing variables, e.g. "$HOME/.kube/client".
+func (c *Config) CommandEnv(repoPath string) []string {
+	env := os.Environ()
+	ws := c.WorkspaceForRepo(repoPath)
+	if ws == nil || len(ws.Env) == 0 {
+		return env
+	}
+
+	keys := make([]string, 0, len(ws.Env))
+	for key := range ws.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		// Later entries win, so these override inherited values
+		env = append(env, key+"="+ExpandPath(os.ExpandEnv(ws.Env[key])))
+	}
+	return env
+}
+
+// ErrCacheCorrupt is returned with a fresh, usable cache when the cache
+// file couldn't be parsed
+var ErrCacheCorrupt = errors.New("repository cache was unreadable; starting with an empty cache")
+
+// LoadRepoCache loads cached repository information. A corrupt cache file
+// is moved aside and replaced by an empty cache, reported as ErrCacheCorrupt.
 func LoadRepoCache() (*RepoCache, error) {
 	cachePath := getCachePath()
 
@@ -159,19 +538,60 @@ func LoadRepoCache() (*RepoCache, error) {
 
 	var cache RepoCache
 	if err := json.Unmarshal(data, &cache); err != nil {
-		return nil, fmt.Errorf("failed to parse cache file: %w", err)
+		fresh := &RepoCache{Version: time.Now(), Repos: make(map[string]RepoInfo)}
+		backup := cachePath + ".corrupt"
+		if renameErr := os.Rename(cachePath, backup); renameErr != nil {
+			return fresh, fmt.Errorf("%w: %v", ErrCacheCorrupt, err)
+		}
+		return fresh, fmt.Errorf("%w (old file kept as %s): %v", ErrCacheCorrupt, backup, err)
 	}
+	if cache.Repos == nil {
+		cache.Repos = make(map[string]RepoInfo)
+	}
+	cache.base = cache.clone()
 
 	return &cache, nil
 }
 
 // Save saves the repository cache to disk
 func (rc *RepoCache) Save() error {
+	unlock, err := lockCache()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return rc.save()
+}
+
+// lockCache takes the lock on the cache file other instances save to,
+// waiting up to lockWait for one that is saving
+func lockCache() (unlock func(), err error) {
 	cachePath := getCachePath()
 
 	// Ensure cache directory exists
 	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
-		return fmt.Errorf("failed to create cache directory: %w", err)
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	unlock, err = lockFile(cachePath + ".lock")
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock cache file: %w", err)
+	}
+	return unlock, nil
+}
+
+// save merges what other instances saved and writes the cache, with the
+// lock on the cache file held
+func (rc *RepoCache) save() error {
+	cachePath := getCachePath()
+
+	// Another instance may have saved since; keep its changes wherever
+	// this instance hasn't changed the same thing
+	if data, err := os.ReadFile(cachePath); err == nil {
+		var disk RepoCache
+		if json.Unmarshal(data, &disk) == nil {
+			rc.merge(&disk)
+		}
 	}
 
 	rc.Version = time.Now()
@@ -181,23 +601,230 @@ func (rc *RepoCache) Save() error {
 		return fmt.Errorf("failed to marshal cache: %w", err)
 	}
 
-	if err := os.WriteFile(cachePath, data, 0644); err != nil {
+	if err := writeFileAtomic(cachePath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write cache file: %w", err)
 	}
+	rc.base = rc.clone()
 
 	return nil
 }
 
+// Reset clears all cached repositories and session state, keeping notes,
+// pins, archived marks and workspace assignments
+func (rc *RepoCache) Reset() {
+	rc.Repos = make(map[string]RepoInfo)
+	rc.LastRepoPath = ""
+	rc.LastWorkspace = ""
+	rc.RecentRepos = nil
+	rc.Views = nil
+}
+
+// BeginSession writes the session sentinel and reports how many sessions in a
+// row have crashed, i.e. left a sentinel behind whose process is no longer
+// running. The sentinel holds the pid and that count; a clean EndSession
+// removes it, which resets the count.
+func BeginSession() (crashes int, err error) {
+	sessionPath := getSessionPath()
+
+	if data, err := os.ReadFile(sessionPath); err == nil {
+		fields := strings.Fields(string(data))
+		if len(fields) > 1 {
+			crashes, _ = strconv.Atoi(fields[1])
+		}
+		pid := 0
+		if len(fields) > 0 {
+			pid, _ = strconv.Atoi(fields[0])
+		}
+		// Another running instance owns the sentinel; that's not a crash
+		if pid <= 0 || !processAlive(pid) {
+			crashes++
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(sessionPath), 0755); err != nil {
+		return crashes, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	content := fmt.Sprintf("%d %d\n", os.Getpid(), crashes)
+	if err := os.WriteFile(sessionPath, []byte(content), 0644); err != nil {
+		return crashes, fmt.Errorf("failed to write session file: %w", err)
+	}
+	return crashes, nil
+}
+
+// EndSession removes the session sentinel after a clean exit
+func EndSession() error {
+	sessionPath := getSessionPath()
+
+	data, err := os.ReadFile(sessionPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read session file: %w", err)
+	}
+
+	// Leave the sentinel alone if a newer instance has taken it over
+	if fields := strings.Fields(string(data)); len(fields) == 0 || fields[0] != strconv.Itoa(os.Getpid()) {
+		return nil
+	}
+	if err := os.Remove(sessionPath); err != nil {
+		return fmt.Errorf("failed to remove session file: %w", err)
+	}
+	return nil
+}
+
+// configDir returns the directory holding the config file: %APPDATA%\kvist
+// on Windows, $XDG_CONFIG_HOME/kvist when set, and ~/.config/kvist otherwise
+func configDir() string {
+	return userDir("APPDATA", "XDG_CONFIG_HOME", ConfigDir)
+}
+
+// cacheDir returns the directory holding the cache and session files:
+// %LOCALAPPDATA%\kvist on Windows, $XDG_CACHE_HOME/kvist when set, and
+// ~/.cache/kvist otherwise
+func cacheDir() string {
+	return userDir("LOCALAPPDATA", "XDG_CACHE_HOME", CacheDir)
+}
+
+// userDir picks kvist's directory from the Windows variable, then the XDG
+// variable, falling back to homeRelative under the home directory. The XDG
+// spec says relative values are invalid and must be ignored.
+func userDir(windowsVar, xdgVar, homeRelative string) string {
+	if runtime.GOOS == "windows" {
+		if dir := os.Getenv(windowsVar); dir != "" {
+			return filepath.Join(dir, "kvist")
+		}
+	}
+	if dir := os.Getenv(xdgVar); dir != "" && filepath.IsAbs(dir) {
+		return filepath.Join(dir, "kvist")
+	}
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, homeRelative)
+}
+
+// MigrateLegacyPaths copies the config and cache directories from
+// ~/.config/kvist and ~/.cache/kvist to where configDir and cacheDir now
+// put them. The old directories stay for older versions of kvist, and once
+// a directory exists in the new place it is left alone, so this only
+// copies on the first start that finds nothing there.
+func MigrateLegacyPaths() error {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	var errs []error
+	for _, dirs := range [][2]string{
+		{filepath.Join(homeDir, ConfigDir), configDir()},
+		{filepath.Join(homeDir, CacheDir), cacheDir()},
+	} {
+		legacy, current := dirs[0], dirs[1]
+		if legacy == current {
+			continue
+		}
+		if _, err := os.Stat(current); err == nil {
+			continue
+		}
+		if _, err := os.Stat(legacy); err != nil {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(current), 0755); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := copyDirAtomic(legacy, current); err != nil {
+			errs = append(errs, fmt.Errorf("failed to copy %s to %s: %w", legacy, current, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// copyDirAtomic copies the directory tree at src to dst, which must not
+// exist. The copy is made next to dst and renamed into place, so dst never
+// holds a partial copy.
+func copyDirAtomic(src, dst string) error {
+	tmp, err := os.MkdirTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp) // no-op once renamed
+
+	err = filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(tmp, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case rel == ".":
+			return os.Chmod(tmp, info.Mode().Perm())
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode().IsRegular():
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			return os.WriteFile(target, data, info.Mode().Perm())
+		}
+		return nil // sockets and the like are of a running instance
+	})
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
 // getConfigPath returns the full path to the config file
 func getConfigPath() string {
-	homeDir, _ := os.UserHomeDir()
-	return filepath.Join(homeDir, ConfigDir, ConfigFile)
+	return filepath.Join(configDir(), ConfigFile)
 }
 
 // getCachePath returns the full path to the cache file
 func getCachePath() string {
-	homeDir, _ := os.UserHomeDir()
-	return filepath.Join(homeDir, CacheDir, CacheFile)
+	return filepath.Join(cacheDir(), CacheFile)
+}
+
+// writeFileAtomic replaces path with data by writing a temporary file next
+// to it and renaming it into place, so a crash never leaves a partial file
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// DebugLogPath returns where the debug log is written
+func DebugLogPath() string {
+	return filepath.Join(cacheDir(), DebugLogFile)
+}
+
+// getSessionPath returns the full path to the session sentinel file
+func getSessionPath() string {
+	return filepath.Join(cacheDir(), SessionFile)
 }
 
 // ExpandPath expands ~ to the user's home directory
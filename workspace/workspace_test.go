@@ -2,12 +2,26 @@ package workspace
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 )
 
+// TestMain keeps tests that point HOME at a temporary directory from
+// writing to the real XDG directories
+func TestMain(m *testing.M) {
+	os.Unsetenv("XDG_CONFIG_HOME")
+	os.Unsetenv("XDG_CACHE_HOME")
+	os.Exit(m.Run())
+}
+
 func TestConfig(t *testing.T) {
 	// Test creating a config with some workspaces
 	config := &Config{
@@ -41,6 +55,60 @@ func TestConfig(t *testing.T) {
 	}
 }
 
+func TestCheckConfig(t *testing.T) {
+	dir := t.TempDir()
+	config, problems := checkConfig([]byte(`version: 1
+autoFetchMinutez: 5
+workspaces:
+  - name: work
+    path: ` + dir + `
+    nestedRepos: flat
+  - name: gone
+    path: ` + filepath.Join(dir, "missing") + `
+`))
+	if config == nil {
+		t.Fatalf("Expected a usable config, got problems %v", problems)
+	}
+	want := []string{
+		"line 2: autoFetchMinutez: unknown setting, ignored (did you mean autoFetchMinutes?)",
+		"line 4: workspaces[0].nestedRepos: unknown value \"flat\" (use children or skip)",
+	}
+	if len(problems) != 3 {
+		t.Fatalf("Expected 3 problems, got %v", problems)
+	}
+	for i, line := range want {
+		if got := problems[i].String(); got != line {
+			t.Errorf("Expected %q, got %q", line, got)
+		}
+	}
+	if problems[2].Field != "workspaces[1].path" || problems[2].Fatal {
+		t.Errorf("Expected a warning about the missing path, got %+v", problems[2])
+	}
+
+	config, problems = checkConfig([]byte("workspaces:\n  - name: work\n    maxDepth: deep\n"))
+	if config != nil || len(problems) != 1 || problems[0].Line != 3 || !problems[0].Fatal {
+		t.Errorf("Expected a fatal type error on line 3, got %+v", problems)
+	}
+	config, problems = checkConfig([]byte("workspaces:\n  - name: work\n"))
+	if config != nil || len(problems) != 1 || problems[0].Field != "workspaces[0].path" {
+		t.Errorf("Expected the missing path to be fatal, got %+v", problems)
+	}
+	if config, problems = checkConfig(nil); config == nil || len(problems) != 0 {
+		t.Errorf("Expected an empty file to be fine, got %+v", problems)
+	}
+	if got := config.History.ColumnsOrDefault(); !slices.Equal(got, DefaultHistoryColumns) {
+		t.Errorf("Expected the default history columns, got %v", got)
+	}
+	config, problems = checkConfig([]byte("history:\n  columns: [hash, author, sha]\n"))
+	if config == nil || len(problems) != 1 || problems[0].Field != "history.columns[2]" || problems[0].Fatal {
+		t.Errorf("Expected a warning about the unknown column, got %+v", problems)
+	}
+	config, problems = checkConfig([]byte("startup: always\n"))
+	if config == nil || len(problems) != 1 || problems[0].Field != "startup" || problems[0].Fatal {
+		t.Errorf("Expected a warning about the unknown startup behavior, got %+v", problems)
+	}
+}
+
 func TestRepoCache(t *testing.T) {
 	cache := &RepoCache{
 		Version: time.Now(),
@@ -77,6 +145,154 @@ func TestRepoCache(t *testing.T) {
 	}
 }
 
+func TestRepoCacheSaveAndRecover(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cache := &RepoCache{Repos: map[string]RepoInfo{"/tmp/a": {Path: "/tmp/a", Name: "a"}}}
+	if err := cache.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := LoadRepoCache()
+	if err != nil || loaded.Repos["/tmp/a"].Name != "a" {
+		t.Fatalf("Expected the saved cache back, got %+v, %v", loaded, err)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(getCachePath()))
+	if len(entries) != 1 {
+		t.Errorf("Expected only the cache file after saving, got %d entries", len(entries))
+	}
+
+	if err := os.WriteFile(getCachePath(), []byte(`{"repos": {"/tmp/a": {`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err = LoadRepoCache()
+	if !errors.Is(err, ErrCacheCorrupt) {
+		t.Fatalf("Expected ErrCacheCorrupt, got %v", err)
+	}
+	if loaded == nil || len(loaded.Repos) != 0 {
+		t.Errorf("Expected a fresh cache, got %+v", loaded)
+	}
+	if _, err := os.Stat(getCachePath() + ".corrupt"); err != nil {
+		t.Errorf("Expected the corrupt file to be kept aside: %v", err)
+	}
+}
+
+func TestConcurrentCacheSaves(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	seed := &RepoCache{Repos: map[string]RepoInfo{
+		"/tmp/a": {Path: "/tmp/a", Branch: "main"},
+		"/tmp/b": {Path: "/tmp/b", Branch: "main"},
+	}, Pinned: map[string]bool{"/tmp/a": true}}
+	if err := seed.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := LoadRepoCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := LoadRepoCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first.LastRepoPath = "/tmp/a"
+	first.Repos["/tmp/a"] = RepoInfo{Path: "/tmp/a", Branch: "feature"}
+	delete(first.Pinned, "/tmp/a")
+	if err := first.Save(); err != nil {
+		t.Fatal(err)
+	}
+	second.LastWorkspace = "work"
+	second.Repos["/tmp/c"] = RepoInfo{Path: "/tmp/c"}
+	delete(second.Repos, "/tmp/b")
+	if err := second.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	merged, err := LoadRepoCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.LastRepoPath != "/tmp/a" || merged.LastWorkspace != "work" {
+		t.Errorf("Expected both instances' session state, got %q and %q", merged.LastRepoPath, merged.LastWorkspace)
+	}
+	if merged.Repos["/tmp/a"].Branch != "feature" {
+		t.Errorf("Expected the first instance's scan to survive, got %+v", merged.Repos["/tmp/a"])
+	}
+	if _, ok := merged.Repos["/tmp/b"]; ok {
+		t.Error("Expected the second instance's removal to stick")
+	}
+	if _, ok := merged.Repos["/tmp/c"]; !ok {
+		t.Error("Expected the second instance's new repo")
+	}
+	if merged.Pinned["/tmp/a"] {
+		t.Error("Expected the unpin not to be undone by the other instance")
+	}
+	if _, err := os.Stat(getCachePath() + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("Expected the lock to be released, got %v", err)
+	}
+}
+
+func TestLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json.lock")
+	if err := os.WriteFile(path, []byte("999999999 gone\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	unlock, err := lockFile(path)
+	if err != nil {
+		t.Fatalf("Expected the lock of a process that is gone to be taken over, got %v", err)
+	}
+
+	// Someone else took the lock over meanwhile; releasing must leave theirs
+	if err := os.WriteFile(path, []byte("1 other\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	unlock()
+	if data, err := os.ReadFile(path); err != nil || string(data) != "1 other\n" {
+		t.Errorf("Expected the other holder's lock to stay, got %q, %v", data, err)
+	}
+}
+
+func TestMigrateLegacyPaths(t *testing.T) {
+	home, xdg := t.TempDir(), t.TempDir()
+	t.Setenv("HOME", home)
+	legacy := filepath.Join(home, ConfigDir, ConfigFile)
+	if err := os.MkdirAll(filepath.Dir(legacy), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(legacy, []byte("version: 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", "relative/ignored")
+	if got := getConfigPath(); got != legacy {
+		t.Errorf("Expected a relative XDG_CONFIG_HOME to be ignored, got %s", got)
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	if err := MigrateLegacyPaths(); err != nil {
+		t.Fatalf("MigrateLegacyPaths failed: %v", err)
+	}
+	if got, want := getConfigPath(), filepath.Join(xdg, "kvist", ConfigFile); got != want {
+		t.Errorf("Expected config at %s, got %s", want, got)
+	}
+	if data, err := os.ReadFile(getConfigPath()); err != nil || string(data) != "version: 1\n" {
+		t.Errorf("Expected the config to have been copied, got %q, %v", data, err)
+	}
+	if _, err := os.Stat(legacy); err != nil {
+		t.Errorf("Expected the legacy config to stay for older versions: %v", err)
+	}
+
+	// Once there, the new config isn't copied over again
+	if err := os.WriteFile(getConfigPath(), []byte("version: 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := MigrateLegacyPaths(); err != nil {
+		t.Fatalf("MigrateLegacyPaths failed: %v", err)
+	}
+	if data, _ := os.ReadFile(getConfigPath()); string(data) != "version: 2\n" {
+		t.Errorf("Expected the new config left alone, got %q", data)
+	}
+}
+
 func TestScanner(t *testing.T) {
 	// Create a temporary directory for testing
 	tempDir, err := os.MkdirTemp("", "kvist_test_workspace")
@@ -124,9 +340,929 @@ func TestScanner(t *testing.T) {
 		t.Errorf("Expected 1 repo, found %d", len(repos))
 	}
 
-	if len(repos) > 0 && repos[0] != testRepo {
-		t.Errorf("Expected repo path %s, got %s", testRepo, repos[0])
+	if len(repos) > 0 && repos[0].path != testRepo {
+		t.Errorf("Expected repo path %s, got %s", testRepo, repos[0].path)
+	}
+
+	t.Logf("Successfully discovered repo: %s", repos[0].path)
+}
+func TestSessionCrashDetection(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	// Fresh start: nothing to recover from
+	crashes, err := BeginSession()
+	if err != nil {
+		t.Fatalf("BeginSession failed: %v", err)
+	}
+	if crashes != 0 {
+		t.Errorf("Expected no crash on first session, got %d", crashes)
+	}
+
+	// Clean exit removes the sentinel
+	if err := EndSession(); err != nil {
+		t.Fatalf("EndSession failed: %v", err)
+	}
+	if _, err := os.Stat(getSessionPath()); !os.IsNotExist(err) {
+		t.Errorf("Expected session file to be removed after clean exit")
 	}
 
-	t.Logf("Successfully discovered repo: %s", repos[0])
-}
\ No newline at end of file
+	// A sentinel left by a dead process counts one crash
+	if err := os.WriteFile(getSessionPath(), []byte("999999999\n"), 0644); err != nil {
+		t.Fatalf("Failed to write stale session file: %v", err)
+	}
+	crashes, err = BeginSession()
+	if err != nil {
+		t.Fatalf("BeginSession failed: %v", err)
+	}
+	if crashes != 1 {
+		t.Errorf("Expected stale session file to count one crash, got %d", crashes)
+	}
+
+	// Crashing again adds to the count
+	if err := os.WriteFile(getSessionPath(), []byte("999999999 1\n"), 0644); err != nil {
+		t.Fatalf("Failed to write stale session file: %v", err)
+	}
+	if crashes, err = BeginSession(); err != nil {
+		t.Fatalf("BeginSession failed: %v", err)
+	}
+	if crashes != 2 {
+		t.Errorf("Expected a second crash in a row to count two, got %d", crashes)
+	}
+
+	// A clean exit resets the count
+	if err := EndSession(); err != nil {
+		t.Fatalf("EndSession failed: %v", err)
+	}
+	if crashes, err = BeginSession(); err != nil {
+		t.Fatalf("BeginSession failed: %v", err)
+	}
+	if crashes != 0 {
+		t.Errorf("Expected clean exit to reset the crash count, got %d", crashes)
+	}
+	_ = EndSession()
+}
+
+func TestCommandEnv(t *testing.T) {
+	t.Setenv("KVIST_TEST_BASE", "/base")
+	config := &Config{
+		Workspaces: []Workspace{
+			{Name: "work", Path: "/src/work", Env: map[string]string{"AWS_PROFILE": "work", "KUBECONFIG": "$KVIST_TEST_BASE/kube"}},
+			{Name: "client", Path: "/src/work/client", Env: map[string]string{"AWS_PROFILE": "client"}},
+			{Name: "plain", Path: "/src/plain"},
+		},
+	}
+
+	lookup := func(env []string, key string) string {
+		value := ""
+		for _, kv := range env {
+			if k, v, ok := strings.Cut(kv, "="); ok && k == key {
+				value = v // last one wins, as for exec
+			}
+		}
+		return value
+	}
+
+	env := config.CommandEnv("/src/work/api")
+	if got := lookup(env, "AWS_PROFILE"); got != "work" {
+		t.Errorf("Expected AWS_PROFILE=work, got %q", got)
+	}
+	if got := lookup(env, "KUBECONFIG"); got != "/base/kube" {
+		t.Errorf("Expected expanded KUBECONFIG, got %q", got)
+	}
+
+	// Nested workspace takes precedence
+	if got := lookup(config.CommandEnv("/src/work/client/app"), "AWS_PROFILE"); got != "client" {
+		t.Errorf("Expected AWS_PROFILE=client for nested workspace, got %q", got)
+	}
+
+	// Sibling directory sharing a prefix is not inside the workspace
+	if ws := config.WorkspaceForRepo("/src/workshop/x"); ws != nil {
+		t.Errorf("Expected no workspace for /src/workshop/x, got %q", ws.Name)
+	}
+	if got := len(config.CommandEnv("/src/plain/x")); got != len(os.Environ()) {
+		t.Errorf("Expected unchanged environment for workspace without env, got %d entries", got)
+	}
+}
+
+func TestOpenCommandFor(t *testing.T) {
+	config := &Config{
+		OpenCommand: "code .",
+		Workspaces: []Workspace{
+			{Name: "java", Path: "/src/java", OpenCommand: "idea ."},
+			{Name: "go", Path: "/src/go"},
+		},
+	}
+
+	if got := config.OpenCommandFor("/src/java/app"); got != "idea ." {
+		t.Errorf("Expected workspace override, got %q", got)
+	}
+	if got := config.OpenCommandFor("/src/go/app"); got != "code ." {
+		t.Errorf("Expected global command, got %q", got)
+	}
+	if got := (&Config{}).OpenCommandFor("/src/go/app"); got != "" {
+		t.Errorf("Expected no command, got %q", got)
+	}
+}
+
+func TestCommitSettingsFor(t *testing.T) {
+	config := &Config{
+		Commits: CommitSettings{Conventional: true, MaxHeader: 50},
+		Workspaces: []Workspace{
+			{Name: "oss", Path: "/src/oss", Commits: &CommitSettings{Types: []string{"feat", "fix"}}},
+			{Name: "work", Path: "/src/work"},
+		},
+	}
+
+	got := config.CommitSettingsFor("/src/oss/lib")
+	if got.Conventional || len(got.Types) != 2 {
+		t.Errorf("Expected workspace settings to replace the global ones, got %+v", got)
+	}
+	got = config.CommitSettingsFor("/src/work/app")
+	if !got.Conventional || got.MaxHeader != 50 {
+		t.Errorf("Expected global settings, got %+v", got)
+	}
+}
+
+func TestRepoNotes(t *testing.T) {
+	cache := &RepoCache{Repos: map[string]RepoInfo{"/src/api": {Path: "/src/api", Name: "api"}}}
+	scanner := NewScanner(&Config{}, cache)
+
+	if note := scanner.RepoNote("/src/api"); note != "" {
+		t.Errorf("Expected no note, got %q", note)
+	}
+	scanner.SetRepoNote("/src/api", "deprecated, migrate to infra-v2")
+	if note := scanner.RepoNote("/src/api"); note != "deprecated, migrate to infra-v2" {
+		t.Errorf("Expected the note back, got %q", note)
+	}
+
+	cache.Reset()
+	if note := scanner.RepoNote("/src/api"); note == "" {
+		t.Error("Expected the note to survive a cache reset")
+	}
+
+	scanner.SetRepoNote("/src/api", "")
+	if _, ok := cache.Notes["/src/api"]; ok {
+		t.Error("Expected an empty note to remove it")
+	}
+}
+
+func TestRepoViews(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cache := &RepoCache{Repos: map[string]RepoInfo{"/src/api": {Path: "/src/api", Name: "api"}}}
+	scanner := NewScanner(&Config{}, cache)
+
+	if _, ok := scanner.RepoView("/src/api"); ok {
+		t.Error("Expected no view before one is recorded")
+	}
+	view := RepoView{Mode: "history", Commit: "abc123", DiffScroll: 40}
+	scanner.SetRepoView("/src/api", view)
+	if err := scanner.SaveCache(); err != nil {
+		t.Fatalf("SaveCache failed: %v", err)
+	}
+
+	loaded, err := LoadRepoCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.Views["/src/api"]; got != view {
+		t.Errorf("Expected the view to be saved, got %+v", got)
+	}
+
+	cache.Reset()
+	if _, ok := scanner.RepoView("/src/api"); ok {
+		t.Error("Expected a cache reset to forget views")
+	}
+}
+
+func TestPinnedReposFirst(t *testing.T) {
+	now := time.Now()
+	cache := &RepoCache{Repos: map[string]RepoInfo{
+		"/src/new":  {Path: "/src/new", Name: "new", LastCommitTime: now},
+		"/src/old":  {Path: "/src/old", Name: "old", LastCommitTime: now.Add(-30 * 24 * time.Hour)},
+		"/src/none": {Path: "/src/none", Name: "none"},
+	}}
+	scanner := NewScanner(&Config{}, cache)
+
+	names := func() []string {
+		var names []string
+		for _, repo := range scanner.GetCachedRepos() {
+			names = append(names, repo.Name)
+		}
+		return names
+	}
+	if got := strings.Join(names(), ","); got != "new,old,none" {
+		t.Errorf("Expected recency order, got %s", got)
+	}
+
+	scanner.SetPinned("/src/old", true)
+	scanner.SetPinned("/src/none", true)
+	if got := strings.Join(names(), ","); got != "old,none,new" {
+		t.Errorf("Expected pinned repos first, got %s", got)
+	}
+	if !scanner.IsPinned("/src/old") || scanner.IsPinned("/src/new") {
+		t.Error("IsPinned disagrees with SetPinned")
+	}
+
+	scanner.SetPinned("/src/old", false)
+	if got := strings.Join(names(), ","); got != "none,new,old" {
+		t.Errorf("Expected old to drop back after unpinning, got %s", got)
+	}
+}
+
+func TestArchivedRepos(t *testing.T) {
+	cache := &RepoCache{Repos: map[string]RepoInfo{
+		"/src/live": {Path: "/src/live", Name: "live"},
+		"/src/old":  {Path: "/src/old", Name: "old"},
+	}}
+	scanner := NewScanner(&Config{}, cache)
+
+	scanner.SetArchived("/src/old", true)
+	if !scanner.IsArchived("/src/old") || scanner.IsArchived("/src/live") {
+		t.Error("IsArchived disagrees with SetArchived")
+	}
+	active := scanner.Unarchived(scanner.GetCachedRepos())
+	if len(active) != 1 || active[0].Name != "live" {
+		t.Errorf("Expected only live to be active, got %v", active)
+	}
+
+	cache.Reset()
+	if !scanner.IsArchived("/src/old") {
+		t.Error("Expected the archived mark to survive Reset")
+	}
+	scanner.SetArchived("/src/old", false)
+	if scanner.IsArchived("/src/old") {
+		t.Error("Expected old to be restored")
+	}
+}
+
+func TestRecentRepos(t *testing.T) {
+	cache := &RepoCache{Repos: make(map[string]RepoInfo)}
+	for i := 0; i < MaxRecentRepos+2; i++ {
+		path := fmt.Sprintf("/src/r%d", i)
+		cache.Repos[path] = RepoInfo{Path: path, Name: fmt.Sprintf("r%d", i)}
+	}
+	scanner := NewScanner(&Config{}, cache)
+
+	for i := 0; i < MaxRecentRepos+2; i++ {
+		scanner.UpdateLastRepo(fmt.Sprintf("/src/r%d", i))
+	}
+	scanner.UpdateLastRepo("/src/r5")
+	delete(cache.Repos, "/src/r11") // gone since, e.g. after a rescan
+
+	var names []string
+	for _, repo := range scanner.RecentRepos() {
+		names = append(names, repo.Name)
+	}
+	if got, want := strings.Join(names, ","), "r5,r10,r9,r8,r7,r6,r4,r3,r2"; got != want {
+		t.Errorf("got recent repos %s, want %s", got, want)
+	}
+	if len(cache.RecentRepos) != MaxRecentRepos {
+		t.Errorf("expected %d remembered repos, got %d", MaxRecentRepos, len(cache.RecentRepos))
+	}
+}
+
+func TestRepoWarnings(t *testing.T) {
+	week := 7 * 24 * time.Hour
+	if w := (RepoInfo{OldestUnpushed: time.Now().Add(-time.Hour)}).Warnings(week); len(w) != 0 {
+		t.Errorf("Expected a fresh unpushed commit to be fine, got %v", w)
+	}
+	risky := RepoInfo{
+		Detached:       true,
+		InProgress:     "rebase",
+		Conflicts:      2,
+		OldestUnpushed: time.Now().Add(-10 * 24 * time.Hour),
+	}
+	got := strings.Join(risky.Warnings(week), "; ")
+	for _, want := range []string{"detached", "rebase in progress", "2 file(s)", "10 days old"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected warnings to mention %q, got %s", want, got)
+		}
+	}
+	if age := (&Config{}).UnpushedWarnAge(); age != DefaultUnpushedWarnDays*24*time.Hour {
+		t.Errorf("Expected the default warning age, got %v", age)
+	}
+}
+
+func TestAssignAndMoveRepo(t *testing.T) {
+	t.Setenv("HOME", t.TempDir()) // MoveRepo saves the cache
+	work, play := t.TempDir(), t.TempDir()
+	repoPath := filepath.Join(work, "tool")
+	if err := os.MkdirAll(filepath.Join(repoPath, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{Workspaces: []Workspace{{Name: "work", Path: work}, {Name: "play", Path: play}}}
+	cache := &RepoCache{Repos: make(map[string]RepoInfo)}
+	scanner := NewScanner(config, cache)
+
+	scan := func(ws Workspace) {
+		for range scanner.ScanSingleWorkspace(context.Background(), ws) {
+		}
+	}
+	scan(config.Workspaces[0])
+	scanner.AssignRepo(repoPath, "play")
+	scan(config.Workspaces[0])
+	scan(config.Workspaces[1])
+	if repo, ok := scanner.GetRepo(repoPath); !ok || repo.WorkspaceName != "play" {
+		t.Errorf("Expected the assignment to survive rescans, got %+v", repo)
+	}
+	scanner.AssignRepo(repoPath, "")
+	if repo, _ := scanner.GetRepo(repoPath); repo.WorkspaceName != "work" {
+		t.Errorf("Expected tool back in work, got %s", repo.WorkspaceName)
+	}
+
+	scanner.SetRepoNote(repoPath, "keep me")
+	scanner.SetPinned(repoPath, true)
+	newPath := filepath.Join(play, "games", "tool")
+	if err := scanner.MoveRepo(repoPath, newPath, "play"); err != nil {
+		t.Fatalf("MoveRepo failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(newPath, ".git")); err != nil {
+		t.Errorf("Expected the repository at its new place: %v", err)
+	}
+	if _, ok := scanner.GetRepo(repoPath); ok {
+		t.Error("Expected the old path to leave the cache")
+	}
+	if repo, ok := scanner.GetRepo(newPath); !ok || repo.WorkspaceName != "play" {
+		t.Errorf("Expected the moved repo under play, got %+v", repo)
+	}
+	if scanner.RepoNote(newPath) != "keep me" || !scanner.IsPinned(newPath) {
+		t.Error("Expected the note and pin to follow the repository")
+	}
+	if err := scanner.MoveRepo(newPath, newPath, "play"); err == nil {
+		t.Error("Expected moving onto an existing path to fail")
+	}
+}
+
+func TestDeleteRepo(t *testing.T) {
+	t.Setenv("HOME", t.TempDir()) // DeleteRepo saves the cache
+	work := t.TempDir()
+	repoPath := filepath.Join(work, "old")
+	if out, err := exec.Command("git", "init", "-q", repoPath).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v\n%s", err, out)
+	}
+	if err := os.WriteFile(filepath.Join(repoPath, "draft.txt"), []byte("unsaved\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{Workspaces: []Workspace{{Name: "work", Path: work}}}
+	scanner := NewScanner(config, &RepoCache{Repos: map[string]RepoInfo{repoPath: {Path: repoPath}}})
+	scanner.SetPinned(repoPath, true)
+	scanner.UpdateLastRepo(repoPath)
+
+	if err := scanner.DeleteRepo(repoPath, false); !errors.Is(err, ErrUnsavedWork) {
+		t.Fatalf("Expected an untracked file to block the delete, got %v", err)
+	}
+	if err := scanner.DeleteRepo(work, true); err == nil {
+		t.Error("Expected deleting a workspace root to fail")
+	}
+	if err := scanner.DeleteRepo(repoPath, true); err != nil {
+		t.Fatalf("DeleteRepo failed: %v", err)
+	}
+	if _, err := os.Stat(repoPath); !os.IsNotExist(err) {
+		t.Errorf("Expected the directory to be gone, got %v", err)
+	}
+	if _, ok := scanner.GetRepo(repoPath); ok || scanner.IsPinned(repoPath) || len(scanner.RecentRepos()) != 0 {
+		t.Error("Expected the repository to leave the cache")
+	}
+}
+
+func TestDeleteRepoUnsavedWork(t *testing.T) {
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	t.Setenv("HOME", t.TempDir())
+	work := t.TempDir()
+	scanner := NewScanner(&Config{Workspaces: []Workspace{{Name: "work", Path: work}}}, &RepoCache{})
+
+	// Each repository is clean with HEAD pushed, but has work elsewhere
+	newRepo := func(name string) string {
+		repoPath := filepath.Join(work, name)
+		for _, args := range [][]string{
+			{"init", "-q", "-b", "main", repoPath},
+			{"-C", repoPath, "commit", "-q", "--allow-empty", "-m", "initial"},
+			{"-C", repoPath, "update-ref", "refs/remotes/origin/main", "HEAD"},
+		} {
+			if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+				t.Fatalf("git %v failed: %v\n%s", args, err, out)
+			}
+		}
+		return repoPath
+	}
+	git := func(repoPath string, args ...string) {
+		if out, err := exec.Command("git", append([]string{"-C", repoPath}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+
+	branch := newRepo("branch")
+	git(branch, "branch", "topic")
+	git(branch, "commit", "-q", "--allow-empty", "-m", "on main")
+	git(branch, "update-ref", "refs/remotes/origin/main", "HEAD")
+	git(branch, "checkout", "-q", "topic")
+	git(branch, "commit", "-q", "--allow-empty", "-m", "only on topic")
+	git(branch, "checkout", "-q", "main")
+
+	worktree := newRepo("worktree")
+	git(worktree, "worktree", "add", "-q", filepath.Join(t.TempDir(), "wt"))
+
+	nested := newRepo("nested")
+	if out, err := exec.Command("git", "init", "-q", filepath.Join(nested, "inner")).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v\n%s", err, out)
+	}
+	// The inner repository is excluded, so it isn't an uncommitted change
+	if err := os.WriteFile(filepath.Join(nested, ".git", "info", "exclude"), []byte("inner/\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for repoPath, want := range map[string]string{
+		branch:   "1 unpushed commit",
+		worktree: "1 other worktree",
+		nested:   "1 nested repositories",
+	} {
+		err := scanner.DeleteRepo(repoPath, false)
+		if !errors.Is(err, ErrUnsavedWork) || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected deleting %s to be refused for %q, got %v", filepath.Base(repoPath), want, err)
+		}
+	}
+
+	// A check that fails refuses too
+	broken := newRepo("broken")
+	if err := os.WriteFile(filepath.Join(broken, ".git", "HEAD"), []byte("garbage\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := scanner.DeleteRepo(broken, false); !errors.Is(err, ErrUnsavedWork) {
+		t.Errorf("Expected a failing check to block the delete, got %v", err)
+	}
+}
+
+func TestUpdateRepoCountsChanges(t *testing.T) {
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	t.Setenv("HOME", t.TempDir()) // UpdateRepo saves the cache
+
+	repo := t.TempDir()
+	run := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	run("init", "-q", "-b", "main")
+	if err := os.WriteFile(filepath.Join(repo, "tracked.txt"), []byte("a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run("add", "tracked.txt")
+	run("commit", "-q", "-m", "initial")
+	// Without a remote nothing counts as unpushed
+	run("remote", "add", "origin", "https://example.com/repo.git")
+
+	scanner := NewScanner(&Config{}, &RepoCache{Repos: make(map[string]RepoInfo)})
+	if err := scanner.UpdateRepo(context.Background(), repo); err != nil {
+		t.Fatalf("UpdateRepo failed: %v", err)
+	}
+	if info, _ := scanner.GetRepo(repo); info.Dirty() {
+		t.Errorf("Expected a clean repo, got %d changed, %d untracked", info.Changed, info.Untracked)
+	}
+	if info, _ := scanner.GetRepo(repo); info.Unpushed != 1 || info.Stashes != 0 {
+		t.Errorf("Expected 1 unpushed commit and no stashes, got %d and %d", info.Unpushed, info.Stashes)
+	}
+	if info, _ := scanner.GetRepo(repo); info.DefaultBranch != "main" || info.StaleBranches != 0 {
+		t.Errorf("Expected default branch main without stale branches, got %q and %d", info.DefaultBranch, info.StaleBranches)
+	}
+	if info, _ := scanner.GetRepo(repo); info.LastSubject != "initial" || info.LastAuthor != "Test" {
+		t.Errorf("Expected the last commit to be initial by Test, got %q by %q", info.LastSubject, info.LastAuthor)
+	}
+
+	run("branch", "merged-feature")
+	for name, content := range map[string]string{"tracked.txt": "b\n", "new1.txt": "x", "new2.txt": "y"} {
+		if err := os.WriteFile(filepath.Join(repo, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := scanner.UpdateRepo(context.Background(), repo); err != nil {
+		t.Fatalf("UpdateRepo failed: %v", err)
+	}
+	if info, _ := scanner.GetRepo(repo); info.Changed != 1 || info.Untracked != 2 {
+		t.Errorf("Expected 1 changed and 2 untracked, got %d and %d", info.Changed, info.Untracked)
+	}
+	if info, _ := scanner.GetRepo(repo); info.StaleBranches != 1 {
+		t.Errorf("Expected merged-feature to count as stale, got %d", info.StaleBranches)
+	}
+}
+
+func TestFetchReposReportsNewlyBehind(t *testing.T) {
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	run := func(dir string, args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	upstream := t.TempDir()
+	run(upstream, "init", "-q", "-b", "main")
+	run(upstream, "commit", "-q", "--allow-empty", "-m", "initial")
+	clone := filepath.Join(t.TempDir(), "clone")
+	run(upstream, "clone", "-q", upstream, clone)
+	quiet := filepath.Join(t.TempDir(), "quiet")
+	run(upstream, "clone", "-q", upstream, quiet)
+
+	scanner := NewScanner(&Config{}, &RepoCache{Repos: make(map[string]RepoInfo)})
+	repos := []RepoInfo{
+		{Path: clone, Name: "clone", HasUpstream: true},
+		{Path: quiet, Name: "quiet"}, // no upstream recorded, so not fetched
+	}
+	run(upstream, "commit", "-q", "--allow-empty", "-m", "upstream one")
+	run(upstream, "commit", "-q", "--allow-empty", "-m", "upstream two")
+
+	results := scanner.FetchRepos(context.Background(), repos)
+	if len(results) != 1 || results[0].Repo.Path != clone || results[0].NewBehind != 2 || results[0].Error != nil {
+		t.Fatalf("Expected clone to fall 2 behind, got %+v", results)
+	}
+	if info, ok := scanner.GetRepo(clone); !ok || info.Behind != 2 {
+		t.Errorf("Expected the cache to record 2 behind, got %+v", info)
+	}
+
+	// Nothing new the second time
+	repos[0] = results[0].Repo
+	if results := scanner.FetchRepos(context.Background(), repos); len(results) != 0 {
+		t.Errorf("Expected no news, got %+v", results)
+	}
+}
+
+func TestManifestExportImport(t *testing.T) {
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	home := t.TempDir()
+	t.Setenv("HOME", home) // the import saves the config
+
+	run := func(dir string, args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	upstream := t.TempDir()
+	run(upstream, "init", "-q", "-b", "main")
+	run(upstream, "commit", "-q", "--allow-empty", "-m", "initial")
+
+	// The old machine: one cloned repo and one without a remote
+	oldRoot := filepath.Join(home, "code")
+	if err := os.MkdirAll(filepath.Join(oldRoot, "local"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	run(oldRoot, "clone", "-q", upstream, "team/api")
+	run(filepath.Join(oldRoot, "local"), "init", "-q")
+	oldConfig := &Config{Workspaces: []Workspace{{Name: "code", Path: "~/code"}}}
+	repos := []RepoInfo{
+		{Path: filepath.Join(oldRoot, "team", "api"), WorkspaceName: "code"},
+		{Path: filepath.Join(oldRoot, "local"), WorkspaceName: "code"},
+	}
+
+	manifest, skipped := ExportManifest(oldConfig, repos)
+	if len(skipped) != 1 || skipped[0] != filepath.Join(oldRoot, "local") {
+		t.Errorf("Expected the repo without a remote to be skipped, got %v", skipped)
+	}
+	data, err := manifest.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !strings.Contains(string(data), "path: ~/code") || !strings.Contains(string(data), "path: team/api") {
+		t.Errorf("Expected portable paths in the manifest, got:\n%s", data)
+	}
+
+	// The new machine already has the workspace, at a different path
+	parsed, err := ParseManifest(data)
+	if err != nil {
+		t.Fatalf("ParseManifest failed: %v", err)
+	}
+	newRoot := filepath.Join(home, "src")
+	if err := os.MkdirAll(newRoot, 0755); err != nil {
+		t.Fatal(err)
+	}
+	newConfig := &Config{Workspaces: []Workspace{{Name: "code", Path: "~/src"}}}
+	cloned, err := ImportManifest(newConfig, parsed, nil)
+	if err != nil || cloned != 1 {
+		t.Fatalf("Expected 1 clone, got %d, %v", cloned, err)
+	}
+	if _, err := os.Stat(filepath.Join(newRoot, "team", "api", ".git")); err != nil {
+		t.Errorf("Expected api cloned under the local workspace path: %v", err)
+	}
+
+	// Running it again changes nothing
+	if cloned, err := ImportManifest(newConfig, parsed, nil); err != nil || cloned != 0 {
+		t.Errorf("Expected nothing to clone, got %d, %v", cloned, err)
+	}
+
+	// Paths leading out of the workspace are refused
+	parsed.Workspaces[0].Repos = []ManifestRepo{{Path: "../../escaped", Remote: upstream}}
+	if cloned, err := ImportManifest(newConfig, parsed, nil); err == nil || cloned != 0 {
+		t.Errorf("Expected the escaping path to be refused, got %d, %v", cloned, err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(home), "escaped")); err == nil {
+		t.Error("Expected nothing cloned outside the workspace")
+	}
+}
+
+func TestDiscoverReposScanSettings(t *testing.T) {
+	root := t.TempDir()
+	elsewhere := t.TempDir()
+	for _, dir := range []string{
+		"app/.git",
+		"org/api/.git",
+		"org/deep/nested/repo/.git",
+		"third_party/lib/.git",
+		"clients/acme/.git",
+		"clients/acme/build/.git", // build output is always skipped
+		"mirrors/tools.git/objects",
+		"mirrors/tools.git/refs",
+	} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		"mirrors/tools.git/HEAD":  "ref: refs/heads/main\n",
+		"org/api-feature/.git":    "gitdir: " + filepath.Join(root, "org/api/.git/worktrees/api-feature") + "\n",
+		"app/modules/plugin/.git": "gitdir: ../../.git/modules/plugin\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(elsewhere, "linked", ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(elsewhere, "linked"), filepath.Join(root, "linked")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	// A loop back to the root must not hang the scan
+	if err := os.Symlink(root, filepath.Join(root, "org", "loop")); err != nil {
+		t.Fatal(err)
+	}
+
+	scanner := NewScanner(&Config{}, &RepoCache{Repos: make(map[string]RepoInfo)})
+	discover := func(ws Workspace) string {
+		repos, err := scanner.discoverRepos(context.Background(), ws)
+		if err != nil {
+			t.Fatalf("discovery failed: %v", err)
+		}
+		var rels []string
+		for _, repo := range repos {
+			rel, _ := filepath.Rel(root, repo.path)
+			rels = append(rels, filepath.ToSlash(rel))
+		}
+		sort.Strings(rels)
+		return strings.Join(rels, ",")
+	}
+
+	tests := []struct {
+		name string
+		ws   Workspace
+		want string
+	}{
+		{"defaults", Workspace{Path: root}, "app,app/modules/plugin,clients/acme,mirrors/tools.git,org/api,org/api-feature,org/deep/nested/repo,third_party/lib"},
+		{"max depth", Workspace{Path: root, MaxDepth: 2}, "app,clients/acme,mirrors/tools.git,org/api,org/api-feature,third_party/lib"},
+		{"excludes", Workspace{Path: root, Exclude: []string{"third_party", "clients/*", "*.git", "app"}}, "org/api,org/api-feature,org/deep/nested/repo"},
+		{"symlinks", Workspace{Path: root, FollowSymlinks: true, MaxDepth: 1}, "app,linked"},
+		{"skip nested", Workspace{Path: root, NestedRepos: NestedSkip, MaxDepth: 2}, "app,clients/acme,mirrors/tools.git,org/api,org/api-feature,third_party/lib"},
+	}
+	for _, tt := range tests {
+		if got := discover(tt.ws); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	kinds := map[string]RepoKind{
+		"app":                WorkTree,
+		"app/modules/plugin": Submodule,
+		"org/api-feature":    LinkedWorktree,
+		"mirrors/tools.git":  BareRepo,
+	}
+	for dir, want := range kinds {
+		if got, ok := RepoKindAt(filepath.Join(root, dir)); !ok || got != want {
+			t.Errorf("RepoKindAt(%s) = %q, %v; want %q", dir, got, ok, want)
+		}
+	}
+	if _, ok := RepoKindAt(filepath.Join(root, "org")); ok {
+		t.Error("expected org not to be a repository")
+	}
+
+	// Incremental discovery finds the same repositories as a full scan
+	var incremental []string
+	for repo := range scanner.DiscoverReposIncremental(context.Background(), Workspace{Path: root, Name: "test"}) {
+		rel, _ := filepath.Rel(root, repo.Path)
+		incremental = append(incremental, filepath.ToSlash(rel))
+	}
+	sort.Strings(incremental)
+	if got, want := strings.Join(incremental, ","), discover(Workspace{Path: root}); got != want {
+		t.Errorf("incremental discovery found %s, want %s", got, want)
+	}
+}
+
+func TestDiscoverProjects(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"repo/.git", "repo/tools", "newapp/src", "notes"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"repo/tools/go.mod", "newapp/package.json", "newapp/src/go.mod"} {
+		if err := os.WriteFile(filepath.Join(root, file), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scanner := NewScanner(&Config{}, &RepoCache{Repos: make(map[string]RepoInfo)})
+	for _, show := range []bool{false, true} {
+		found, err := scanner.discoverRepos(context.Background(), Workspace{Path: root, ShowProjects: show})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, repo := range found {
+			rel, _ := filepath.Rel(root, repo.path)
+			if repo.project {
+				rel += " (project)"
+			}
+			got = append(got, filepath.ToSlash(rel))
+		}
+		sort.Strings(got)
+		// Projects inside repositories and other projects aren't listed
+		want := "repo"
+		if show {
+			want = "newapp (project),repo"
+		}
+		if strings.Join(got, ",") != want {
+			t.Errorf("ShowProjects=%v: got %v, want %s", show, got, want)
+		}
+	}
+
+	repo, err := scanner.scanRepo(context.Background(), foundRepo{path: filepath.Join(root, "newapp"), project: true}, "test")
+	if err != nil || repo.Kind != ProjectDir || repo.Name != "newapp" {
+		t.Errorf("Expected a project entry, got %+v, %v", repo, err)
+	}
+}
+
+func TestNestedRepos(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"mono/.git", "mono/services/billing/.git", "mono/services/billing/vendor-lib/.git", "solo/.git"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scanner := NewScanner(&Config{}, &RepoCache{Repos: make(map[string]RepoInfo)})
+	found, err := scanner.discoverRepos(context.Background(), Workspace{Path: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parents := make(map[string]string)
+	for _, repo := range found {
+		parents[repo.path] = repo.parent
+	}
+	mono := filepath.Join(root, "mono")
+	billing := filepath.Join(mono, "services", "billing")
+	if parents[mono] != "" || parents[billing] != mono || parents[filepath.Join(billing, "vendor-lib")] != billing {
+		t.Errorf("unexpected parents: %v", parents)
+	}
+
+	// Children follow their parent even when sorted apart
+	repos := []RepoInfo{
+		{Path: filepath.Join(billing, "vendor-lib"), Parent: billing},
+		{Path: filepath.Join(root, "solo")},
+		{Path: billing, Parent: mono},
+		{Path: mono},
+		{Path: filepath.Join(root, "gone", "orphan"), Parent: filepath.Join(root, "gone")},
+	}
+	nested, depth := NestChildren(repos)
+	var order []string
+	for _, repo := range nested {
+		rel, _ := filepath.Rel(root, repo.Path)
+		order = append(order, fmt.Sprintf("%s:%d", filepath.ToSlash(rel), depth[repo.Path]))
+	}
+	want := "solo:0,mono:0,mono/services/billing:1,mono/services/billing/vendor-lib:2,gone/orphan:0"
+	if got := strings.Join(order, ","); got != want {
+		t.Errorf("got order %s, want %s", got, want)
+	}
+}
+
+func TestFetchBackoff(t *testing.T) {
+	var backoff FetchBackoff
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	if !backoff.Due("/a", now) {
+		t.Fatal("a repository that never failed should be due")
+	}
+
+	var waits []time.Duration
+	for range 5 {
+		waits = append(waits, backoff.Failed("/a", time.Hour, now))
+	}
+	want := []time.Duration{2 * time.Hour, 4 * time.Hour, 6 * time.Hour, 6 * time.Hour, 6 * time.Hour}
+	if !slices.Equal(waits, want) {
+		t.Errorf("got waits %v, want %v", waits, want)
+	}
+	if backoff.Failures("/a") != 5 {
+		t.Errorf("got %d failures, want 5", backoff.Failures("/a"))
+	}
+	if backoff.Due("/a", now.Add(5*time.Hour)) || !backoff.Due("/a", now.Add(6*time.Hour)) {
+		t.Error("a failing repository should be due once its wait is over")
+	}
+	if !backoff.Due("/b", now) {
+		t.Error("other repositories should not be held back")
+	}
+
+	backoff.Succeeded("/a")
+	if !backoff.Due("/a", now) || backoff.Failures("/a") != 0 {
+		t.Error("a successful fetch should clear the backoff")
+	}
+}
+
+func TestCustomCommands(t *testing.T) {
+	config, problems := checkConfig([]byte(`customCommands:
+  - name: Open PR
+    command: gh pr create --head {{branch}}
+    key: ctrl+p
+  - name: Lint
+    command: golint {{ selectedFile }} {{file}}
+    key: ctrl+p
+  - command: make
+`))
+	if config == nil || len(config.CustomCommands) != 3 {
+		t.Fatalf("Expected three custom commands, got %+v", config)
+	}
+	var fields []string
+	for _, problem := range problems {
+		fields = append(fields, problem.Field)
+	}
+	want := []string{"customCommands[1].command", "customCommands[1].key", "customCommands[2].name"}
+	if !slices.Equal(fields, want) {
+		t.Errorf("Expected problems with %v, got %v", want, problems)
+	}
+
+	quote := func(s string) string { return "'" + s + "'" }
+	line, err := config.CustomCommands[0].Expand(map[string]string{"branch": "feature/x"}, quote)
+	if err != nil || line != "gh pr create --head 'feature/x'" {
+		t.Errorf("Expected the branch filled in, got %q, %v", line, err)
+	}
+	lint := config.CustomCommands[1]
+	if got := lint.Placeholders(); !slices.Equal(got, []string{"selectedFile", "file"}) {
+		t.Errorf("Expected placeholders selectedFile and file, got %v", got)
+	}
+	if _, err := lint.Expand(map[string]string{"branch": "main"}, quote); err == nil {
+		t.Error("Expected an error when the selection has no file")
+	}
+}
+
+func TestDetectTasks(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"Makefile":     ".PHONY: build test\nCC := gcc\nLD ::= ld\nAR :::= ar\nbuild: deps\n\tgo build\ntest:\n\tgo test ./...\n%.o: %.c\nbin/app: main.go\nbuild:\nclean::\n\trm -rf bin\n",
+		"package.json": `{"scripts": {"lint": "eslint .", "dev server": "vite"}}`,
+		"yarn.lock":    "",
+		"justfile":     "set shell := [\"bash\", \"-c\"]\nrelease version='1.0':\n  echo {{version}}\n_helper:\n  true\n",
+		"go.mod":       "module example.com/x\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []string
+	for _, task := range DetectTasks(dir) {
+		got = append(got, task.Runner+":"+task.Name+":"+task.Command)
+	}
+	want := []string{
+		"make:build:make build",
+		"make:test:make test",
+		"make:clean:make clean",
+		"yarn:dev server:yarn run 'dev server'",
+		"yarn:lint:yarn run lint",
+		"just:release:just release",
+		"go:test:go test ./...",
+		"go:build:go build ./...",
+		"go:vet:go vet ./...",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got tasks\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if tasks := DetectTasks(t.TempDir()); len(tasks) != 0 {
+		t.Errorf("Expected no tasks in an empty directory, got %v", tasks)
+	}
+}